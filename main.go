@@ -1,22 +1,41 @@
 package main
 
 import (
+	"errors"
+	"flag"
 	"os"
 
 	"github.com/kuoss/common/logger"
 	"github.com/kuoss/venti/pkg/application"
+	"github.com/kuoss/venti/pkg/cli"
+	"github.com/kuoss/venti/pkg/config"
 )
 
 var (
 	Version = "development" // Version will be overwritten by ldflags
-	addr    = ":3030"
 
 	app  application.IApp = application.App{}
 	exit                  = os.Exit
+	args                  = os.Args[1:]
 )
 
 func main() {
-	if err := app.Run(Version, addr); err != nil {
+	flags, rest, err := config.ParseFlags(args, os.Getenv, os.Stderr)
+	if err == nil && len(rest) > 0 {
+		// a subcommand such as `venti check rules`, rather than the server; the flags may come before it
+		exit(cli.Run(args, os.Stdout, os.Stderr))
+		return
+	}
+	if errors.Is(err, flag.ErrHelp) {
+		exit(cli.ExitSuccess)
+		return
+	}
+	if err != nil {
+		logger.Errorf("flags error: %v", err)
+		exit(cli.ExitUsage)
+		return
+	}
+	if err := app.Run(Version, flags); err != nil {
 		logger.Errorf("application error: %v", err)
 		exit(1)
 	} else {
@@ -5,32 +5,34 @@ import (
 	"testing"
 
 	"github.com/stretchr/testify/assert"
+
+	"github.com/kuoss/venti/pkg/config"
 )
 
 type MockApp struct {
-	runFunc func(version string, addr ...string) error
+	runFunc func(version string, flags config.Flags) error
 }
 
-func (m *MockApp) Run(version string, addr ...string) error {
-	return m.runFunc(version)
+func (m *MockApp) Run(version string, flags config.Flags) error {
+	return m.runFunc(version, flags)
 }
 
 func TestMainFunctionExitCode(t *testing.T) {
 	testCases := []struct {
 		name         string
-		mockRunFunc  func(version string, addr ...string) error
+		mockRunFunc  func(version string, flags config.Flags) error
 		wantExitCode int
 	}{
 		{
 			name: "Successful exit",
-			mockRunFunc: func(version string, addr ...string) error {
+			mockRunFunc: func(version string, flags config.Flags) error {
 				return nil
 			},
 			wantExitCode: 0,
 		},
 		{
 			name: "Error exit",
-			mockRunFunc: func(version string, addr ...string) error {
+			mockRunFunc: func(version string, flags config.Flags) error {
 				return errors.New("run error")
 			},
 			wantExitCode: 1,
@@ -39,10 +41,13 @@ func TestMainFunctionExitCode(t *testing.T) {
 
 	originalApp := app
 	originalExit := exit
+	originalArgs := args
 	defer func() {
 		app = originalApp
 		exit = originalExit
+		args = originalArgs
 	}()
+	args = nil
 
 	for _, tc := range testCases {
 		t.Run(tc.name, func(t *testing.T) {
@@ -59,3 +64,80 @@ func TestMainFunctionExitCode(t *testing.T) {
 		})
 	}
 }
+
+func TestMainSubcommand(t *testing.T) {
+	originalArgs := args
+	originalExit := exit
+	defer func() {
+		args = originalArgs
+		exit = originalExit
+	}()
+
+	testCases := []struct {
+		args         []string
+		wantExitCode int
+	}{
+		{[]string{"help"}, 0},
+		{[]string{"xxx"}, 2},
+		{[]string{"--config.file", "none.yml", "check", "config"}, 1},
+		{[]string{"--rules.files=testdata/none/*.yml", "xxx"}, 2},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.args[0], func(t *testing.T) {
+			args = tc.args
+			gotExitCode := -1
+			exit = func(code int) {
+				gotExitCode = code
+			}
+
+			main()
+
+			assert.Equal(t, tc.wantExitCode, gotExitCode)
+		})
+	}
+}
+
+func TestMainFlags(t *testing.T) {
+	originalApp := app
+	originalArgs := args
+	originalExit := exit
+	defer func() {
+		app = originalApp
+		args = originalArgs
+		exit = originalExit
+	}()
+
+	testCases := []struct {
+		args         []string
+		wantFlags    config.Flags
+		wantExitCode int
+	}{
+		{[]string{"-web.listen-address", ":4040", "--dashboards.dir=/dashboards"}, config.Flags{ListenAddress: ":4040", DashboardsDir: "/dashboards"}, 0},
+		{[]string{"-h"}, config.Flags{}, 0},
+		{[]string{"-xxx"}, config.Flags{}, 2},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.args[0], func(t *testing.T) {
+			var gotFlags config.Flags
+			app = &MockApp{runFunc: func(version string, flags config.Flags) error {
+				gotFlags = flags
+				return nil
+			}}
+			args = tc.args
+			gotExitCode := -1
+			exit = func(code int) {
+				gotExitCode = code
+			}
+
+			main()
+
+			assert.Equal(t, tc.wantExitCode, gotExitCode)
+			if tc.wantFlags != (config.Flags{}) {
+				want := config.DefaultFlags()
+				want.ListenAddress = tc.wantFlags.ListenAddress
+				want.DashboardsDir = tc.wantFlags.DashboardsDir
+				assert.Equal(t, want, gotFlags)
+			}
+		})
+	}
+}
@@ -2,6 +2,8 @@ package alerter
 
 import (
 	"fmt"
+	"hash/fnv"
+	"sync"
 	"time"
 
 	"github.com/kuoss/common/logger"
@@ -13,13 +15,20 @@ type Alerter struct {
 	alertingService    alerting.IAlertingService
 	evaluationInterval time.Duration
 	isRunning          bool
-	quitCh             chan bool
+	quitCh             chan struct{}
+	wg                 sync.WaitGroup
 }
 
+const defaultEvaluationInterval = 5 * time.Second
+
 func New(cfg *config.Config, alertingService alerting.IAlertingService) *Alerter {
+	evaluationInterval := cfg.AlertingConfig.EvaluationInterval
+	if evaluationInterval <= 0 {
+		evaluationInterval = defaultEvaluationInterval
+	}
 	return &Alerter{
 		alertingService:    alertingService,
-		evaluationInterval: cfg.AlertingConfig.EvaluationInterval,
+		evaluationInterval: evaluationInterval,
 	}
 }
 
@@ -29,8 +38,13 @@ func (a *Alerter) Start() error {
 	}
 	a.isRunning = true
 	logger.Infof("starting alerter...")
-	a.quitCh = make(chan bool)
-	go a.loop(a.quitCh)
+	a.quitCh = make(chan struct{})
+	a.wg.Add(1)
+	go a.reloadLoop(a.quitCh)
+	for _, group := range a.alertingService.GetAlertingRuleGroups() {
+		a.wg.Add(1)
+		go a.groupLoop(group, a.quitCh)
+	}
 	return nil
 }
 
@@ -39,26 +53,86 @@ func (a *Alerter) Stop() error {
 		return fmt.Errorf("already stopped")
 	}
 	logger.Infof("stopping alerter...")
-	a.quitCh <- true
+	close(a.quitCh)
+	a.wg.Wait()
 	a.isRunning = false
+	logger.Infof("alerter stopped")
 	return nil
 }
 
-func (a *Alerter) loop(quitCh chan bool) {
+// reloadLoop refreshes discovered datasources on the global evaluation interval,
+// independently of the group schedules.
+func (a *Alerter) reloadLoop(quitCh <-chan struct{}) {
+	defer a.wg.Done()
+	ticker := time.NewTicker(a.evaluationInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-quitCh:
+			return
+		case <-ticker.C:
+			if err := a.alertingService.ReloadDatasources(); err != nil {
+				logger.Errorf("ReloadDatasources err: %s", err)
+			}
+		}
+	}
+}
+
+// groupLoop evaluates a single group on its own ticker.
+// The first evaluation is delayed by the group offset so that the schedule stays aligned.
+func (a *Alerter) groupLoop(group *alerting.AlertingRuleGroup, quitCh <-chan struct{}) {
+	defer a.wg.Done()
+	interval := a.groupInterval(group)
+	next := nextEvalTime(group.Name, interval, time.Now())
+	timer := time.NewTimer(time.Until(next))
+	select {
+	case <-quitCh:
+		timer.Stop()
+		return
+	case <-timer.C:
+	}
+	logger.Infof("group %q: evaluating every %s", group.Name, interval)
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	a.evalGroup(group, next)
 	for {
 		select {
 		case <-quitCh:
-			logger.Infof("alerter stopped")
-			close(quitCh)
 			return
-		default:
-			a.Once()
-			logger.Infof("sleep: %s", a.evaluationInterval)
-			time.Sleep(a.evaluationInterval)
+		case evalTime := <-ticker.C:
+			a.evalGroup(group, evalTime)
 		}
 	}
 }
 
+func (a *Alerter) evalGroup(group *alerting.AlertingRuleGroup, evalTime time.Time) {
+	err := a.alertingService.DoAlertGroup(group, evalTime)
+	if err != nil {
+		logger.Errorf("DoAlertGroup(%s) err: %s", group.Name, err)
+	}
+}
+
+func (a *Alerter) groupInterval(group *alerting.AlertingRuleGroup) time.Duration {
+	if group.Interval > 0 {
+		return group.Interval
+	}
+	return a.evaluationInterval
+}
+
+// nextEvalTime returns the first evaluation time of a group at or after now.
+// Each group gets a stable offset within its interval derived from its name,
+// so that groups sharing an interval do not all evaluate at the same instant.
+func nextEvalTime(name string, interval time.Duration, now time.Time) time.Time {
+	h := fnv.New64a()
+	_, _ = h.Write([]byte(name))
+	offset := time.Duration(h.Sum64() % uint64(interval))
+	next := now.Truncate(interval).Add(offset)
+	if next.Before(now) {
+		next = next.Add(interval)
+	}
+	return next
+}
+
 func (a *Alerter) Once() {
 	err := a.alertingService.DoAlert()
 	if err != nil {
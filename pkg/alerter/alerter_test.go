@@ -23,6 +23,18 @@ func (m *mockAlertingService) DoAlert() error {
 	return fmt.Errorf("mock doAlert err")
 }
 
+func (m *mockAlertingService) DoAlertGroup(group *alertingservice.AlertingRuleGroup, evalTime time.Time) error {
+	return fmt.Errorf("mock doAlertGroup err")
+}
+
+func (m *mockAlertingService) GetAlertingRuleGroups() []*alertingservice.AlertingRuleGroup {
+	return []*alertingservice.AlertingRuleGroup{}
+}
+
+func (m *mockAlertingService) ReloadDatasources() error {
+	return fmt.Errorf("mock reloadDatasources err")
+}
+
 var (
 	alerter1         *Alerter
 	servers          *ms.Servers
@@ -119,6 +131,55 @@ func TestStartAndStop(t *testing.T) {
 	require.EqualError(t, err, "already stopped")
 }
 
+func TestGroupInterval(t *testing.T) {
+	testCases := []struct {
+		group *alertingservice.AlertingRuleGroup
+		want  time.Duration
+	}{
+		{&alertingservice.AlertingRuleGroup{}, 5 * time.Second},
+		{&alertingservice.AlertingRuleGroup{Interval: 15 * time.Second}, 15 * time.Second},
+		{&alertingservice.AlertingRuleGroup{Interval: 5 * time.Minute}, 5 * time.Minute},
+	}
+	tempAlerter := New(&config.Config{}, alertingService1)
+	for _, tc := range testCases {
+		t.Run("", func(t *testing.T) {
+			got := tempAlerter.groupInterval(tc.group)
+			require.Equal(t, tc.want, got)
+		})
+	}
+}
+
+func TestNextEvalTime(t *testing.T) {
+	now := time.Date(2026, 1, 1, 0, 0, 7, 0, time.UTC)
+	testCases := []struct {
+		name     string
+		interval time.Duration
+	}{
+		{"sample", 15 * time.Second},
+		{"capacity", 5 * time.Minute},
+		{"liveness", time.Second},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			got := nextEvalTime(tc.name, tc.interval, now)
+			require.False(t, got.Before(now))
+			require.Less(t, got.Sub(now), tc.interval)
+			// the offset is stable across cycles
+			require.Equal(t, got.Add(tc.interval), nextEvalTime(tc.name, tc.interval, got.Add(time.Nanosecond)))
+		})
+	}
+}
+
+func TestGroupLoop(t *testing.T) {
+	tempAlerter := New(&config.Config{}, &mockAlertingService{})
+	quitCh := make(chan struct{})
+	tempAlerter.wg.Add(1)
+	go tempAlerter.groupLoop(&alertingservice.AlertingRuleGroup{Name: "loop", Interval: 10 * time.Millisecond}, quitCh)
+	time.Sleep(50 * time.Millisecond)
+	close(quitCh)
+	tempAlerter.wg.Wait()
+}
+
 func TestOnce(t *testing.T) {
 	// DoAlert ok
 	alerter1.Once()
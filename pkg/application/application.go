@@ -1,26 +1,38 @@
 package application
 
 import (
+	"context"
+	"errors"
 	"fmt"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
 
+	"github.com/gin-gonic/gin"
 	"github.com/kuoss/common/logger"
 	"github.com/kuoss/venti/pkg/alerter"
 	"github.com/kuoss/venti/pkg/config"
-	"github.com/kuoss/venti/pkg/handler"
 	"github.com/kuoss/venti/pkg/service"
 )
 
 type IApp interface {
-	Run(version string, addr ...string) error
+	Run(version string, flags config.Flags) error
 }
 
 type App struct{}
 
-func (a App) Run(version string, addr ...string) error {
+func (a App) Run(version string, flags config.Flags) error {
+	return a.RunContext(context.Background(), version, flags)
+}
+
+// RunContext runs like Run, and shuts down when the context is done.
+func (a App) RunContext(ctx context.Context, version string, flags config.Flags) error {
 	logger.Infof("Starting Venti 💨 version=%s", version)
+	flags = flags.WithDefaults()
 
 	// Load configuration
-	cfg, err := new(config.ConfigProvider).New(version)
+	cfg, err := (&config.ConfigProvider{Flags: flags}).New(version)
 	if err != nil {
 		return fmt.Errorf("failed to load configuration: %w", err)
 	}
@@ -38,10 +50,42 @@ func (a App) Run(version string, addr ...string) error {
 		return fmt.Errorf("failed to start alerter: %w", err)
 	}
 
+	// Reload on POST /-/reload, SIGHUP and, optionally, changes of the files.
+	// The mode is set once, as the routers of the reloads are built while the others serve.
+	gin.SetMode(gin.ReleaseMode)
+	reloader := newReloader(version, flags, cfg.GlobalConfig.TrustedProxies, services, alerter)
+	defer func() {
+		if err := reloader.close(); err != nil {
+			// test unreachable
+			logger.Errorf("close reloader err: %s", err)
+		}
+	}()
+	sigCh := make(chan os.Signal, 1)
+	signal.Notify(sigCh, syscall.SIGHUP)
+	defer signal.Stop(sigCh)
+	reloader.handleSignals(sigCh)
+	if cfg.GlobalConfig.WatchConfig {
+		if err = reloader.watch(); err != nil {
+			return fmt.Errorf("failed to watch configuration: %w", err)
+		}
+	}
+
 	// Start server
-	router := handler.NewRouter(services)
-	logger.Infof("listen %v", addr)
-	if err = router.Run(addr...); err != nil {
+	logger.Infof("listen %s", flags.ListenAddress)
+	server := &http.Server{Addr: flags.ListenAddress, Handler: reloader}
+	shutdownCh := make(chan struct{})
+	stopShutdown := context.AfterFunc(ctx, func() {
+		defer close(shutdownCh)
+		if err := server.Shutdown(context.Background()); err != nil {
+			// test unreachable
+			logger.Errorf("shutdown err: %s", err)
+		}
+	})
+	err = server.ListenAndServe()
+	if !stopShutdown() {
+		<-shutdownCh
+	}
+	if err != nil && !errors.Is(err, http.ErrServerClosed) {
 		// test unreachable
 		return fmt.Errorf("failed to run router: %w", err)
 	}
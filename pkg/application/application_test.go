@@ -6,6 +6,7 @@ import (
 	"testing"
 	"time"
 
+	"github.com/kuoss/venti/pkg/config"
 	"github.com/kuoss/venti/pkg/testutil"
 	"github.com/stretchr/testify/assert"
 )
@@ -14,7 +15,7 @@ func TestRun_LoadGlobalConfigFileError(t *testing.T) {
 	_, cleanup := testutil.SetupTest(t, map[string]string{})
 	defer cleanup()
 
-	err := new(App).Run("1.0.0")
+	err := new(App).Run("1.0.0", config.Flags{})
 	assert.EqualError(t, err, "failed to load configuration: loadGlobalConfigFile err: error on ReadFile: open etc/venti.yml: no such file or directory")
 }
 
@@ -25,7 +26,7 @@ func TestRun_NewServicesError_DBOpenError(t *testing.T) {
 	})
 	defer cleanup()
 
-	err := new(App).Run("1.0.0")
+	err := new(App).Run("1.0.0", config.Flags{})
 	assert.EqualError(t, err, "failed to initialize services: NewUserService err: DB open err: unable to open database file: no such file or directory")
 }
 
@@ -42,7 +43,7 @@ func TestRun_NewServicesError_Alert(t *testing.T) {
 	err := os.WriteFile(tempDir+"/etc/alertrules/sample.yml", []byte(invalidFileData), os.ModePerm)
 	assert.NoError(t, err)
 
-	err = new(App).Run("1.0.0")
+	err = new(App).Run("1.0.0", config.Flags{})
 	assert.EqualError(t, err, "failed to initialize services: new alertRuleService err: loadAlertRuleFileFromFilename err: unmarshalStrict err: yaml: unmarshal errors:\n  line 3: cannot unmarshal !!str `...` into []model.RuleGroup")
 }
 
@@ -57,25 +58,16 @@ func TestRun_SmokeTest(t *testing.T) {
 	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
 	defer cancel()
 
-	panicChan := make(chan interface{}, 1)
-
+	errCh := make(chan error, 1)
 	go func() {
-		defer func() {
-			if r := recover(); r != nil {
-				panicChan <- r
-			}
-		}()
-		err := new(App).Run("1.0.0")
-		assert.NoError(t, err)
-		close(panicChan)
+		errCh <- new(App).RunContext(ctx, "1.0.0", config.Flags{ListenAddress: ":0"})
 	}()
 
-	var done bool
 	select {
-	case <-ctx.Done():
-		done = true
-	case p := <-panicChan:
-		t.Fatalf("panic occurred: %v", p)
+	case err := <-errCh:
+		assert.NoError(t, err)
+		assert.ErrorIs(t, ctx.Err(), context.DeadlineExceeded, "shut down before the context is done")
+	case <-time.After(5 * time.Second):
+		t.Fatal("not shut down")
 	}
-	assert.True(t, done)
 }
@@ -0,0 +1,198 @@
+package application
+
+import (
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+	"github.com/gin-gonic/gin"
+	"github.com/kuoss/common/logger"
+
+	"github.com/kuoss/venti/pkg/alerter"
+	"github.com/kuoss/venti/pkg/config"
+	"github.com/kuoss/venti/pkg/handler"
+	"github.com/kuoss/venti/pkg/service"
+)
+
+// watchDelay gathers changes in a row, such as the update of a mounted ConfigMap, into a single reload.
+var watchDelay = time.Second
+
+// reloader loads the configurations again on POST /-/reload, SIGHUP or changes of the files,
+// and swaps the services, the alerter and the router in as a whole.
+type reloader struct {
+	mu       sync.Mutex // one reload at a time
+	version  string
+	flags    config.Flags
+	services *service.Services
+	alerter  *alerter.Alerter
+	router   atomic.Pointer[gin.Engine]
+	quitCh   chan struct{}
+}
+
+func newReloader(version string, flags config.Flags, trustedProxies []string, services *service.Services, a *alerter.Alerter) *reloader {
+	r := &reloader{version: version, flags: flags, services: services, alerter: a, quitCh: make(chan struct{})}
+	r.router.Store(handler.NewRouter(services, flags.WebRoot, trustedProxies, r.reload))
+	return r
+}
+
+// ServeHTTP serves with the router of the current services.
+func (r *reloader) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	r.router.Load().ServeHTTP(w, req)
+}
+
+// reload swaps in the services of the reloaded configurations.
+// On a failure, the current services go on, and the failure is shown in the runtime info.
+func (r *reloader) reload() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	logger.Infof("reloading configurations...")
+	err := r.swap()
+	r.services.StatusService.ConfigReloaded(err == nil)
+	if err != nil {
+		logger.Errorf("reload err: %s", err)
+		return err
+	}
+	logger.Infof("configurations reloaded")
+	return nil
+}
+
+func (r *reloader) swap() error {
+	cfg, err := (&config.ConfigProvider{Flags: r.flags}).New(r.version)
+	if err != nil {
+		return fmt.Errorf("load configuration err: %w", err)
+	}
+	// the alerts are inherited from the stopped alerter, which starts again if the services fail to load
+	if err := r.alerter.Stop(); err != nil {
+		return fmt.Errorf("stop alerter err: %w", err)
+	}
+	services, err := r.services.Reload(cfg)
+	if err != nil {
+		if startErr := r.alerter.Start(); startErr != nil {
+			logger.Errorf("start alerter err: %s", startErr)
+		}
+		return fmt.Errorf("reload services err: %w", err)
+	}
+	a := alerter.New(cfg, services.AlertingService)
+	if err := a.Start(); err != nil {
+		// test unreachable
+		return fmt.Errorf("start alerter err: %w", err)
+	}
+	r.router.Store(handler.NewRouter(services, r.flags.WebRoot, cfg.GlobalConfig.TrustedProxies, r.reload))
+	old := r.services
+	r.services, r.alerter = services, a
+	// the old alerter is stopped, so the queues of the old services only have to send what is left
+	old.AlertingService.Close()
+	return nil
+}
+
+// handleSignals reloads on the signals of the channel, which is notified of SIGHUP, like Prometheus.
+func (r *reloader) handleSignals(sigCh <-chan os.Signal) {
+	go func() {
+		for {
+			select {
+			case <-r.quitCh:
+				return
+			case <-sigCh:
+				_ = r.reload() // logged by reload
+			}
+		}
+	}()
+}
+
+// watch reloads on changes of the configuration files, the rule files and the dashboards.
+// Only their directories are watched, as the others may be busy, like the one of the database.
+func (r *reloader) watch() error {
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return fmt.Errorf("new watcher err: %w", err)
+	}
+	dirs := r.watchDirs()
+	for _, dir := range dirs {
+		if err := watcher.Add(dir); err != nil {
+			_ = watcher.Close()
+			return fmt.Errorf("watch %s err: %w", dir, err)
+		}
+	}
+	logger.Infof("watching %v", dirs)
+	go func() {
+		defer watcher.Close()
+		var delayCh <-chan time.Time
+		for {
+			select {
+			case <-r.quitCh:
+				return
+			case event := <-watcher.Events:
+				if event.Has(fsnotify.Chmod) || !r.watched(event.Name) {
+					continue
+				}
+				logger.Debugf("watcher event: %s", event)
+				delayCh = time.After(watchDelay)
+			case err := <-watcher.Errors:
+				logger.Warnf("watcher err: %s", err)
+			case <-delayCh:
+				delayCh = nil
+				_ = r.reload() // logged by reload
+			}
+		}
+	}()
+	return nil
+}
+
+// watchDirs returns the directories of the files to watch, including the subdirectories of the dashboards.
+func (r *reloader) watchDirs() []string {
+	dirs := []string{
+		filepath.Dir(r.flags.GlobalConfigFile),
+		filepath.Dir(r.flags.DatasourceConfigFile),
+		filepath.Dir(r.flags.UserConfigFile),
+		filepath.Dir(r.flags.AlertingConfigFile),
+		filepath.Dir(r.flags.AlertRuleFiles),
+		filepath.Clean(r.flags.DashboardsDir),
+	}
+	entries, _ := os.ReadDir(r.flags.DashboardsDir)
+	for _, entry := range entries {
+		if entry.IsDir() {
+			dirs = append(dirs, filepath.Join(r.flags.DashboardsDir, entry.Name()))
+		}
+	}
+	slices.Sort(dirs)
+	return slices.Compact(dirs)
+}
+
+// watched returns whether a changed file is one to reload.
+func (r *reloader) watched(name string) bool {
+	name = filepath.Clean(name)
+	// a mounted ConfigMap is updated by swapping its ..data symlink
+	if strings.HasPrefix(filepath.Base(name), "..") {
+		return true
+	}
+	for _, file := range []string{r.flags.GlobalConfigFile, r.flags.DatasourceConfigFile, r.flags.UserConfigFile, r.flags.AlertingConfigFile} {
+		if name == filepath.Clean(file) {
+			return true
+		}
+	}
+	for _, pattern := range []string{r.flags.AlertRuleFiles, r.flags.DashboardsDir + "/*.y*ml", r.flags.DashboardsDir + "/*/*.y*ml"} {
+		if ok, _ := filepath.Match(filepath.Clean(pattern), name); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// close stops handling signals and watching files, and then the current alerter and the queues of its services.
+func (r *reloader) close() error {
+	close(r.quitCh)
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if err := r.alerter.Stop(); err != nil {
+		return fmt.Errorf("stop alerter err: %w", err)
+	}
+	r.services.AlertingService.Close()
+	return nil
+}
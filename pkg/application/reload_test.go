@@ -0,0 +1,193 @@
+package application
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strconv"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/kuoss/venti/pkg/alerter"
+	"github.com/kuoss/venti/pkg/config"
+	"github.com/kuoss/venti/pkg/service"
+	"github.com/kuoss/venti/pkg/testutil"
+)
+
+func setupReloader(t *testing.T) (string, *reloader, func()) {
+	tempDir, cleanup := testutil.SetupTest(t, map[string]string{
+		"@/data":                               "data",
+		"@/etc":                                "etc",
+		"@/docs/examples/datasources.dev1.yml": "etc/datasources.yml",
+	})
+	cfg, err := new(config.ConfigProvider).New("1.0.0")
+	require.NoError(t, err)
+	services, err := service.NewServices(cfg)
+	require.NoError(t, err)
+	a := alerter.New(cfg, services.AlertingService)
+	require.NoError(t, a.Start())
+	r := newReloader("1.0.0", config.DefaultFlags(), nil, services, a)
+	return tempDir, r, func() {
+		assert.NoError(t, r.close())
+		cleanup()
+	}
+}
+
+func postReload(t *testing.T, r *reloader) *httptest.ResponseRecorder {
+	user, err := r.services.UserService.FindByUsername("admin")
+	require.NoError(t, err)
+	token, err := r.services.UserService.CreateSession(user)
+	require.NoError(t, err)
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("POST", "/-/reload", nil)
+	req.Header.Set("Authorization", "Bearer "+token)
+	req.Header.Set("UserID", strconv.Itoa(user.ID))
+	r.ServeHTTP(w, req)
+	return w
+}
+
+func TestReload(t *testing.T) {
+	tempDir, r, cleanup := setupReloader(t)
+	defer cleanup()
+	services, a, router := r.services, r.alerter, r.router.Load()
+	lastConfigTime := services.StatusService.RuntimeInfo().LastConfigTime
+
+	w := postReload(t, r)
+	assert.Equal(t, 200, w.Code)
+	assert.NotSame(t, services, r.services)
+	assert.NotSame(t, a, r.alerter)
+	assert.NotSame(t, router, r.router.Load())
+	runtimeInfo := r.services.StatusService.RuntimeInfo()
+	assert.True(t, runtimeInfo.ReloadConfigSuccess)
+	assert.True(t, runtimeInfo.LastConfigTime.After(lastConfigTime))
+
+	// a broken rule file keeps the current services
+	services, a, router = r.services, r.alerter, r.router.Load()
+	lastConfigTime = runtimeInfo.LastConfigTime
+	require.NoError(t, os.WriteFile(tempDir+"/etc/alertrules/sample.yml", []byte("groups: ..."), 0644))
+	w = postReload(t, r)
+	assert.Equal(t, 500, w.Code)
+	assert.Contains(t, w.Body.String(), "failed to reload config: reload services err: new alertRuleService err: ")
+	assert.Same(t, services, r.services)
+	assert.Same(t, a, r.alerter)
+	assert.Same(t, router, r.router.Load())
+	runtimeInfo = r.services.StatusService.RuntimeInfo()
+	assert.False(t, runtimeInfo.ReloadConfigSuccess)
+	assert.Equal(t, lastConfigTime, runtimeInfo.LastConfigTime)
+
+	// a broken config file as well
+	require.NoError(t, os.WriteFile(tempDir+"/etc/venti.yml", []byte("xxx: 1"), 0644))
+	w = postReload(t, r)
+	assert.Equal(t, 500, w.Code)
+	assert.Equal(t, "failed to reload config: load configuration err: loadGlobalConfigFile err: unmarshalStrict err: yaml: unmarshal errors:\n  line 1: field xxx not found in type model.GlobalConfig\n", w.Body.String())
+	assert.Same(t, services, r.services)
+
+	// the alerter goes on after the failures
+	assert.EqualError(t, r.alerter.Start(), "already running")
+}
+
+func TestReload_signal(t *testing.T) {
+	_, r, cleanup := setupReloader(t)
+	defer cleanup()
+	services := r.services
+	sigCh := make(chan os.Signal, 1)
+	r.handleSignals(sigCh)
+
+	sigCh <- syscall.SIGHUP
+	require.Eventually(t, func() bool {
+		r.mu.Lock()
+		defer r.mu.Unlock()
+		return r.services != services
+	}, 5*time.Second, 10*time.Millisecond)
+}
+
+func TestReload_watch(t *testing.T) {
+	tempDir, r, cleanup := setupReloader(t)
+	defer cleanup()
+	services := r.services
+	watchDelay = 100 * time.Millisecond
+	defer func() { watchDelay = time.Second }()
+	require.NoError(t, r.watch())
+
+	// a change in a subdirectory
+	require.NoError(t, os.WriteFile(tempDir+"/etc/dashboards/sample.yml", []byte("title: Sample\nrows: []\n"), 0644))
+	require.Eventually(t, func() bool {
+		r.mu.Lock()
+		defer r.mu.Unlock()
+		return r.services != services
+	}, 5*time.Second, 10*time.Millisecond)
+}
+
+func TestReload_watchError(t *testing.T) {
+	dir := t.TempDir()
+	r := &reloader{flags: config.Flags{
+		GlobalConfigFile:     dir + "/venti.yml",
+		DatasourceConfigFile: dir + "/datasources.yml",
+		UserConfigFile:       dir + "/users.yml",
+		AlertingConfigFile:   dir + "/alerting.yml",
+		AlertRuleFiles:       dir + "/*.yml",
+		DashboardsDir:        dir + "/none",
+	}}
+	assert.EqualError(t, r.watch(), "watch "+dir+"/none err: no such file or directory")
+}
+
+func TestWatchDirs(t *testing.T) {
+	_, r, cleanup := setupReloader(t)
+	defer cleanup()
+	assert.Equal(t, []string{"etc", "etc/alertrules", "etc/dashboards"}, r.watchDirs())
+
+	r.flags = config.Flags{
+		GlobalConfigFile:     "/config/venti.yml",
+		DatasourceConfigFile: "/config/datasources.yml",
+		UserConfigFile:       "/secret/users.yml",
+		AlertingConfigFile:   "/config/alerting.yml",
+		AlertRuleFiles:       "/rules/*.yml",
+		DashboardsDir:        "/dashboards/",
+	}
+	assert.Equal(t, []string{"/config", "/dashboards", "/rules", "/secret"}, r.watchDirs())
+}
+
+func TestWatched(t *testing.T) {
+	r := &reloader{flags: config.DefaultFlags()}
+	testCases := []struct {
+		name string
+		want bool
+	}{
+		{"etc/venti.yml", true},
+		{"etc/datasources.yml", true},
+		{"etc/users.yml", true},
+		{"etc/alerting.yml", true},
+		{"etc/alertrules/sample.yml", true},
+		{"etc/alertrules/sample.yaml", true},
+		{"etc/dashboards/sample.yml", true},
+		{"etc/dashboards/team1/sample.yml", true},
+		{"etc/..data", true},
+		{"etc/..2024_01_01_00_00_00.000000000", true},
+		{"etc/venti.yml.swp", false},
+		{"etc/alertrules/README.md", false},
+		{"etc/dashboards/team1/team2/sample.yml", false},
+		{"data/venti.sqlite3", false},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			assert.Equal(t, tc.want, r.watched(tc.name))
+		})
+	}
+}
+
+func TestReload_unauthorized(t *testing.T) {
+	_, r, cleanup := setupReloader(t)
+	defer cleanup()
+	services := r.services
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("POST", "/-/reload", nil)
+	r.ServeHTTP(w, req)
+	assert.Equal(t, 401, w.Code)
+	assert.Same(t, services, r.services)
+}
@@ -0,0 +1,99 @@
+package cli
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/kuoss/common/logger"
+
+	"github.com/kuoss/venti/pkg/config"
+	"github.com/kuoss/venti/pkg/service/alertrule"
+	"github.com/kuoss/venti/pkg/service/dashboard"
+)
+
+// runCheck validates files offline with the loaders of the server, like `promtool check`.
+// The flags after the kind of check are parsed over the ones before the command.
+func runCheck(args []string, flags config.Flags, stdout io.Writer, stderr io.Writer) int {
+	if len(args) == 0 {
+		fmt.Fprint(stderr, usage)
+		return ExitUsage
+	}
+	kind := args[0]
+	if kind != "config" && kind != "rules" {
+		fmt.Fprintf(stderr, "unknown check %q\n\n%s", kind, usage)
+		return ExitUsage
+	}
+	// the flags of the server locate the files, e.g. venti check config --config.file=conf/venti.yml
+	flags, rest, err := flags.Parse("venti check "+kind, args[1:], stderr)
+	if errors.Is(err, flag.ErrHelp) {
+		return ExitSuccess
+	}
+	if err != nil {
+		return ExitUsage // written by the flag set
+	}
+	for _, arg := range rest {
+		if kind == "config" {
+			fmt.Fprintf(stderr, "unexpected argument %q\n\n%s", arg, usage)
+			return ExitUsage
+		}
+		if strings.HasPrefix(arg, "-") {
+			fmt.Fprintf(stderr, "flag %q after the files: the flags come first\n\n%s", arg, usage)
+			return ExitUsage
+		}
+	}
+
+	// the loaders log every file, which only gets in the way of the results
+	logLevel := logger.GetLevel()
+	defer logger.SetLevel(logLevel)
+	logger.SetLevel(logger.WarnLevel)
+
+	var checks []config.FileCheck
+	switch kind {
+	case "config":
+		checks = (&config.ConfigProvider{Flags: flags}).Check()
+		dashboardChecks, err := dashboard.Check(flags.DashboardsDir)
+		if err != nil {
+			fmt.Fprintf(stderr, "  FAILED: %s\n", err)
+			return ExitFailure
+		}
+		checks = append(checks, dashboardChecks...)
+	case "rules":
+		patterns := rest
+		if len(patterns) == 0 {
+			patterns = []string{flags.AlertRuleFiles}
+		}
+		for _, pattern := range patterns {
+			ruleChecks, err := alertrule.Check(pattern)
+			if err != nil {
+				fmt.Fprintf(stderr, "  FAILED: %s\n", err)
+				return ExitFailure
+			}
+			if len(ruleChecks) == 0 {
+				ruleChecks = []config.FileCheck{{File: pattern, Err: fmt.Errorf("no such file")}}
+			}
+			checks = append(checks, ruleChecks...)
+		}
+	}
+	return printChecks("Checking", checks, stdout, stderr)
+}
+
+func printChecks(title string, checks []config.FileCheck, stdout io.Writer, stderr io.Writer) int {
+	code := ExitSuccess
+	for _, check := range checks {
+		fmt.Fprintf(stdout, "%s %s\n", title, check.File)
+		if check.Err != nil {
+			fmt.Fprintf(stderr, "  FAILED:\n%s\n\n", check.Err)
+			code = ExitFailure
+			continue
+		}
+		if check.Summary == "" {
+			fmt.Fprint(stdout, "  SUCCESS\n\n")
+			continue
+		}
+		fmt.Fprintf(stdout, "  SUCCESS: %s\n\n", check.Summary)
+	}
+	return code
+}
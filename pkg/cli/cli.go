@@ -0,0 +1,57 @@
+// Package cli implements the subcommands of venti, besides running the server.
+package cli
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/kuoss/venti/pkg/config"
+)
+
+const (
+	ExitSuccess = 0
+	ExitFailure = 1 // a check or a test failed
+	ExitUsage   = 2
+)
+
+const usage = `usage: venti [flag...] [command [flag...] [arg...]]
+
+Without a command, venti runs the server; see venti -h for its flags.
+The flags of the server locate the files of the check commands. They can be given before the command,
+after it (before the files of check rules), or by their VENTI_* environment variables.
+
+Commands:
+  check config           check venti.yml, datasources.yml, users.yml, alerting.yml and the dashboards
+  check rules [file...]  check the alert rule files (default: the glob of --rules.files)
+  test rules file...     run the unit tests of alert rules
+`
+
+// Run runs a subcommand with its arguments, and returns the exit code.
+// The flags of the server may come before the subcommand, e.g. venti --rules.files=rules/*.yml check rules.
+func Run(args []string, stdout io.Writer, stderr io.Writer) int {
+	flags, args, err := config.ParseFlags(args, os.Getenv, stderr)
+	if errors.Is(err, flag.ErrHelp) {
+		return ExitSuccess
+	}
+	if err != nil {
+		return ExitUsage // written by the flag set
+	}
+	if len(args) == 0 {
+		fmt.Fprint(stderr, usage)
+		return ExitUsage
+	}
+	switch args[0] {
+	case "check":
+		return runCheck(args[1:], flags, stdout, stderr)
+	case "test":
+		return runTest(args[1:], stdout, stderr)
+	case "help":
+		fmt.Fprint(stdout, usage)
+		return ExitSuccess
+	}
+	fmt.Fprintf(stderr, "unknown command %q\n\n%s", args[0], usage)
+	return ExitUsage
+}
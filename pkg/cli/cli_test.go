@@ -0,0 +1,255 @@
+package cli
+
+import (
+	"bytes"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/kuoss/venti/pkg/testutil"
+)
+
+func TestRun(t *testing.T) {
+	testCases := []struct {
+		args       []string
+		wantCode   int
+		wantStdout string
+		wantStderr string
+	}{
+		{nil, ExitUsage, "", usage},
+		{[]string{"help"}, ExitSuccess, usage, ""},
+		{[]string{"xxx"}, ExitUsage, "", "unknown command \"xxx\"\n\n" + usage},
+		{[]string{"check"}, ExitUsage, "", usage},
+		{[]string{"check", "xxx"}, ExitUsage, "", "unknown check \"xxx\"\n\n" + usage},
+		{[]string{"test"}, ExitUsage, "", usage},
+		{[]string{"test", "rules"}, ExitUsage, "", usage},
+		{[]string{"test", "xxx"}, ExitUsage, "", "unknown test \"xxx\"\n\n" + usage},
+		{[]string{"--db.file=x"}, ExitUsage, "", usage},
+		{[]string{"--db.file=x", "help"}, ExitSuccess, usage, ""},
+	}
+	for _, tc := range testCases {
+		t.Run("", func(t *testing.T) {
+			var stdout, stderr bytes.Buffer
+			code := Run(tc.args, &stdout, &stderr)
+			require.Equal(t, tc.wantCode, code)
+			require.Equal(t, tc.wantStdout, stdout.String())
+			require.Equal(t, tc.wantStderr, stderr.String())
+		})
+	}
+}
+
+func TestRunCheckConfig(t *testing.T) {
+	tempDir, cleanup := testutil.SetupTest(t, map[string]string{
+		"@/etc":                                "etc",
+		"@/docs/examples/datasources.dev1.yml": "etc/datasources.yml",
+	})
+	defer cleanup()
+
+	var stdout, stderr bytes.Buffer
+	code := Run([]string{"check", "config"}, &stdout, &stderr)
+	require.Equal(t, ExitSuccess, code)
+	require.Equal(t, `Checking etc/venti.yml
+  SUCCESS
+
+Checking etc/datasources.yml
+  SUCCESS
+
+Checking etc/users.yml
+  SUCCESS
+
+Checking etc/alerting.yml
+  SUCCESS
+
+Checking etc/dashboards/sample.yml
+  SUCCESS: 6 rows found
+
+`, stdout.String())
+	require.Empty(t, stderr.String())
+
+	require.NoError(t, os.WriteFile(tempDir+"/etc/users.yml", []byte("accounts: []"), 0644))
+	stdout.Reset()
+	code = Run([]string{"check", "config"}, &stdout, &stderr)
+	require.Equal(t, ExitFailure, code)
+	require.Contains(t, stdout.String(), "Checking etc/users.yml\nChecking etc/alerting.yml\n")
+	require.Equal(t, "  FAILED:\nunmarshalStrict err: yaml: unmarshal errors:\n  line 1: field accounts not found in type model.UserConfig\n\n", stderr.String())
+}
+
+func TestRunCheckRules(t *testing.T) {
+	tempDir, cleanup := testutil.SetupTest(t, map[string]string{
+		"@/etc": "etc",
+	})
+	defer cleanup()
+	badRules := `
+datasourceSelector:
+  type: prometheus
+groups:
+- name: group1
+  rules:
+  - alert: Up
+    expr: sum(up
+`
+	require.NoError(t, os.WriteFile(tempDir+"/bad.yml", []byte(badRules), 0644))
+
+	testCases := []struct {
+		args       []string
+		wantCode   int
+		wantStdout string
+		wantStderr string
+	}{
+		{
+			[]string{"check", "rules"},
+			ExitSuccess,
+			"Checking etc/alertrules/sample.yml\n  SUCCESS: 4 rules found\n\n",
+			"",
+		},
+		{
+			[]string{"check", "rules", "bad.yml", "etc/alertrules/*.yml"},
+			ExitFailure,
+			"Checking bad.yml\nChecking etc/alertrules/sample.yml\n  SUCCESS: 4 rules found\n\n",
+			"  FAILED:\nvalidateRuleFile err: bad.yml:8:11: group \"group1\", rule \"Up\", invalid expr: 1:7: parse error: unclosed left parenthesis\n\n",
+		},
+		{
+			[]string{"check", "rules", "none.yml"},
+			ExitFailure,
+			"Checking none.yml\n",
+			"  FAILED:\nno such file\n\n",
+		},
+		{
+			[]string{"check", "rules", "["},
+			ExitFailure,
+			"",
+			"  FAILED: glob err: syntax error in pattern\n",
+		},
+	}
+	for _, tc := range testCases {
+		t.Run("", func(t *testing.T) {
+			var stdout, stderr bytes.Buffer
+			code := Run(tc.args, &stdout, &stderr)
+			require.Equal(t, tc.wantCode, code)
+			require.Equal(t, tc.wantStdout, stdout.String())
+			require.Equal(t, tc.wantStderr, stderr.String())
+		})
+	}
+}
+
+func TestRunTestRules(t *testing.T) {
+	_, cleanup := testutil.SetupTest(t, map[string]string{
+		"@/testdata/ruletest": "tests",
+	})
+	defer cleanup()
+
+	var stdout, stderr bytes.Buffer
+	code := Run([]string{"test", "rules", "tests/rules_test.yml", "tests/failing_test.yml"}, &stdout, &stderr)
+	require.Equal(t, ExitFailure, code)
+	require.Equal(t, "Unit testing tests/rules_test.yml\n  SUCCESS: 3 tests passed\n\nUnit testing tests/failing_test.yml\n", stdout.String())
+	require.Equal(t, `  FAILED:
+test "instance down too early": alertname "InstanceDown" at 2m:
+  exp:
+    {__name__="up", alertname="InstanceDown", datasource="prometheus", instance="node1:9100", job="node", severity="warning"} {summary="node1:9100 of node is down"}
+  got:
+    (none)
+
+`, stderr.String())
+}
+
+func TestRunCheck_env(t *testing.T) {
+	tempDir, cleanup := testutil.SetupTest(t, map[string]string{
+		"@/etc":                                "etc",
+		"@/docs/examples/datasources.dev1.yml": "etc/datasources.yml",
+	})
+	defer cleanup()
+	require.NoError(t, os.Rename(tempDir+"/etc/alertrules", tempDir+"/rules"))
+	t.Setenv("VENTI_CONFIG_FILE", "conf/venti.yml")
+	t.Setenv("VENTI_RULES_FILES", "rules/*.yml")
+
+	var stdout, stderr bytes.Buffer
+	code := Run([]string{"check", "config"}, &stdout, &stderr)
+	require.Equal(t, ExitFailure, code)
+	require.Contains(t, stdout.String(), "Checking conf/venti.yml\nChecking etc/datasources.yml\n")
+	require.Equal(t, "  FAILED:\nerror on ReadFile: open conf/venti.yml: no such file or directory\n\n", stderr.String())
+
+	stdout.Reset()
+	stderr.Reset()
+	code = Run([]string{"check", "rules"}, &stdout, &stderr)
+	require.Equal(t, ExitSuccess, code)
+	require.Equal(t, "Checking rules/sample.yml\n  SUCCESS: 4 rules found\n\n", stdout.String())
+}
+
+func TestRunCheck_flags(t *testing.T) {
+	tempDir, cleanup := testutil.SetupTest(t, map[string]string{
+		"@/etc":                                "etc",
+		"@/docs/examples/datasources.dev1.yml": "etc/datasources.yml",
+	})
+	defer cleanup()
+	require.NoError(t, os.Rename(tempDir+"/etc/alertrules", tempDir+"/rules"))
+
+	testCases := []struct {
+		args       []string
+		wantCode   int
+		wantStdout string
+		wantStderr string
+	}{
+		{
+			[]string{"check", "config", "--config.file=conf/venti.yml"},
+			ExitFailure,
+			"Checking conf/venti.yml\n",
+			"  FAILED:\nerror on ReadFile: open conf/venti.yml: no such file or directory\n\n",
+		},
+		{
+			[]string{"check", "rules", "--rules.files", "rules/*.yml"},
+			ExitSuccess,
+			"Checking rules/sample.yml\n  SUCCESS: 4 rules found\n\n",
+			"",
+		},
+		{
+			[]string{"--config.file=conf/venti.yml", "check", "config"},
+			ExitFailure,
+			"Checking conf/venti.yml\n",
+			"  FAILED:\nerror on ReadFile: open conf/venti.yml: no such file or directory\n\n",
+		},
+		{
+			// after the command over before it
+			[]string{"--rules.files", "none/*.yml", "check", "rules", "--rules.files", "rules/*.yml"},
+			ExitSuccess,
+			"Checking rules/sample.yml\n  SUCCESS: 4 rules found\n\n",
+			"",
+		},
+		{
+			[]string{"check", "config", "conf/venti.yml"},
+			ExitUsage,
+			"",
+			"unexpected argument \"conf/venti.yml\"\n\n" + usage,
+		},
+		{
+			[]string{"check", "rules", "rules/sample.yml", "--rules.files=x"},
+			ExitUsage,
+			"",
+			"flag \"--rules.files=x\" after the files: the flags come first\n\n" + usage,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run("", func(t *testing.T) {
+			var stdout, stderr bytes.Buffer
+			code := Run(tc.args, &stdout, &stderr)
+			require.Equal(t, tc.wantCode, code)
+			if tc.wantCode == ExitFailure {
+				require.Contains(t, stdout.String(), tc.wantStdout)
+			} else {
+				require.Equal(t, tc.wantStdout, stdout.String())
+			}
+			require.Equal(t, tc.wantStderr, stderr.String())
+		})
+	}
+
+	// an unknown flag fails, rather than being ignored
+	var stdout, stderr bytes.Buffer
+	require.Equal(t, ExitUsage, Run([]string{"check", "config", "--xxx"}, &stdout, &stderr))
+	require.Contains(t, stderr.String(), "flag provided but not defined: -xxx\nUsage of venti check config:\n")
+	stderr.Reset()
+	require.Equal(t, ExitUsage, Run([]string{"--xxx", "check", "config"}, &stdout, &stderr))
+	require.Contains(t, stderr.String(), "flag provided but not defined: -xxx\nUsage of venti:\n")
+	stderr.Reset()
+	require.Equal(t, ExitSuccess, Run([]string{"check", "config", "-h"}, &stdout, &stderr))
+	require.Contains(t, stderr.String(), "  -config.file string\n")
+}
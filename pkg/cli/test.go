@@ -0,0 +1,32 @@
+package cli
+
+import (
+	"fmt"
+	"io"
+
+	"github.com/kuoss/common/logger"
+
+	"github.com/kuoss/venti/pkg/ruletest"
+)
+
+// runTest runs the unit tests of alert rules against fake datasources, like `promtool test rules`.
+func runTest(args []string, stdout io.Writer, stderr io.Writer) int {
+	if len(args) == 0 {
+		fmt.Fprint(stderr, usage)
+		return ExitUsage
+	}
+	if args[0] != "rules" {
+		fmt.Fprintf(stderr, "unknown test %q\n\n%s", args[0], usage)
+		return ExitUsage
+	}
+	if len(args) == 1 {
+		fmt.Fprint(stderr, usage)
+		return ExitUsage
+	}
+	// the evaluation logs every rule file and alert, which only gets in the way of the results
+	logLevel := logger.GetLevel()
+	defer logger.SetLevel(logLevel)
+	logger.SetLevel(logger.WarnLevel)
+
+	return printChecks("Unit testing", ruletest.Run(args[1:]), stdout, stderr)
+}
@@ -0,0 +1,27 @@
+package config
+
+import "github.com/kuoss/common/logger"
+
+// FileCheck is the result of checking a file, for `venti check`.
+type FileCheck struct {
+	File    string
+	Summary string // details on success, such as the number of rules
+	Err     error
+}
+
+// Check loads each configuration file with the loaders of New,
+// and reports all of them instead of stopping at the first error.
+func (p *ConfigProvider) Check() []FileCheck {
+	flags := p.Flags.WithDefaults()
+	cfg := &Config{}
+	// checking does not apply the log level of venti.yml
+	logLevel := logger.GetLevel()
+	globalErr := cfg.loadGlobalConfigFile(flags.GlobalConfigFile)
+	logger.SetLevel(logLevel)
+	return []FileCheck{
+		{File: flags.GlobalConfigFile, Err: globalErr},
+		{File: flags.DatasourceConfigFile, Err: cfg.loadDatasourceConfigFile(flags.DatasourceConfigFile)},
+		{File: flags.UserConfigFile, Err: cfg.loadUserConfigFile(flags.UserConfigFile)},
+		{File: flags.AlertingConfigFile, Err: cfg.loadAlertingConfigFile(flags.AlertingConfigFile)},
+	}
+}
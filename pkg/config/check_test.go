@@ -0,0 +1,30 @@
+package config
+
+import (
+	"testing"
+
+	"github.com/kuoss/common/logger"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/kuoss/venti/pkg/testutil"
+)
+
+func TestCheck(t *testing.T) {
+	_, cleanup := testutil.SetupTest(t, map[string]string{
+		"@/etc": "etc",
+	})
+	defer cleanup()
+
+	logger.SetLevel(logger.WarnLevel)
+	defer logger.SetLevel(logger.InfoLevel)
+	checks := new(ConfigProvider).Check()
+	assert.Equal(t, logger.WarnLevel, logger.GetLevel())
+	assert.Len(t, checks, 4)
+	for _, check := range checks {
+		if check.File == "etc/datasources.yml" {
+			assert.EqualError(t, check.Err, "error on ReadFile: open etc/datasources.yml: no such file or directory")
+			continue
+		}
+		assert.NoError(t, check.Err, check.File)
+	}
+}
@@ -2,7 +2,10 @@ package config
 
 import (
 	"fmt"
+	"net"
 	"os"
+	"slices"
+	"strings"
 	"time"
 
 	"github.com/gin-gonic/gin"
@@ -12,6 +15,7 @@ import (
 )
 
 type Config struct {
+	Flags            Flags
 	AppInfo          model.AppInfo
 	GlobalConfig     model.GlobalConfig
 	DatasourceConfig model.DatasourceConfig
@@ -23,27 +27,32 @@ type IConfigProvider interface {
 	New(version string) (*Config, error)
 }
 
-type ConfigProvider struct{}
+// ConfigProvider loads the configuration files at the locations of its flags.
+// The zero value uses the default flags.
+type ConfigProvider struct {
+	Flags Flags
+}
 
-// TODO: each Config filepath could be parameter.
 func (p *ConfigProvider) New(version string) (*Config, error) {
 	logger.Infof("loading configurations...")
 
+	flags := p.Flags.WithDefaults()
 	cfg := &Config{
+		Flags: flags,
 		AppInfo: model.AppInfo{
 			Version: version,
 		},
 	}
-	if err := cfg.loadGlobalConfigFile("etc/venti.yml"); err != nil {
+	if err := cfg.loadGlobalConfigFile(flags.GlobalConfigFile); err != nil {
 		return nil, fmt.Errorf("loadGlobalConfigFile err: %w", err)
 	}
-	if err := cfg.loadDatasourceConfigFile("etc/datasources.yml"); err != nil {
+	if err := cfg.loadDatasourceConfigFile(flags.DatasourceConfigFile); err != nil {
 		return nil, fmt.Errorf("loadDatasourceConfigFile err: %w", err)
 	}
-	if err := cfg.loadUserConfigFile("etc/users.yml"); err != nil {
+	if err := cfg.loadUserConfigFile(flags.UserConfigFile); err != nil {
 		return nil, fmt.Errorf("loadUserConfigFile err: %w", err)
 	}
-	if err := cfg.loadAlertingConfigFile("etc/alerting.yml"); err != nil {
+	if err := cfg.loadAlertingConfigFile(flags.AlertingConfigFile); err != nil {
 		return nil, fmt.Errorf("loadAlertingConfigFile err: %w", err)
 	}
 	return cfg, nil
@@ -69,10 +78,133 @@ func (c *Config) loadGlobalConfigFile(file string) error {
 	}
 	logger.SetLevel(logLevel)
 
+	if cfg.OIDC != nil {
+		if err := setOIDCDefaults(cfg.OIDC, cfg.ExternalURL); err != nil {
+			return fmt.Errorf("oidc: %w", err)
+		}
+	}
+	if cfg.LDAP != nil {
+		if err := setLDAPDefaults(cfg.LDAP); err != nil {
+			return fmt.Errorf("ldap: %w", err)
+		}
+	}
+	if err := validateSessionConfig(cfg.Session); err != nil {
+		return fmt.Errorf("session: %w", err)
+	}
+	if err := validateLoginConfig(cfg.Login); err != nil {
+		return fmt.Errorf("login: %w", err)
+	}
+	if err := validateTrustedProxies(cfg.TrustedProxies); err != nil {
+		return fmt.Errorf("trustedProxies: %w", err)
+	}
 	c.GlobalConfig = cfg
 	return nil
 }
 
+func setOIDCDefaults(cfg *model.OIDCConfig, externalURL string) error {
+	if cfg.Issuer == "" {
+		return fmt.Errorf("no issuer")
+	}
+	if cfg.ClientID == "" {
+		return fmt.Errorf("no clientID")
+	}
+	if cfg.RedirectURL == "" {
+		if externalURL == "" {
+			return fmt.Errorf("no redirectURL or externalURL")
+		}
+		cfg.RedirectURL = strings.TrimSuffix(externalURL, "/") + "/auth/oidc/callback"
+	}
+	if len(cfg.Scopes) == 0 {
+		cfg.Scopes = []string{"openid", "profile", "email"}
+	}
+	if cfg.GroupsClaim == "" {
+		cfg.GroupsClaim = "groups"
+	}
+	return validateGroupRoles(cfg.GroupRoles, cfg.DefaultRole)
+}
+
+func setLDAPDefaults(cfg *model.LDAPConfig) error {
+	if cfg.URL == "" {
+		return fmt.Errorf("no url")
+	}
+	if cfg.BaseDN == "" {
+		return fmt.Errorf("no baseDN")
+	}
+	if cfg.Timeout == 0 {
+		cfg.Timeout = 10 * time.Second
+	}
+	if cfg.UserFilter == "" {
+		cfg.UserFilter = "(uid={username})"
+	}
+	if cfg.GroupBaseDN == "" {
+		cfg.GroupBaseDN = cfg.BaseDN
+	}
+	if cfg.GroupFilter == "" {
+		cfg.GroupFilter = "(member={dn})"
+	}
+	if cfg.GroupAttribute == "" {
+		cfg.GroupAttribute = "cn"
+	}
+	return validateGroupRoles(cfg.GroupRoles, cfg.DefaultRole)
+}
+
+func validateSessionConfig(cfg model.SessionConfig) error {
+	if cfg.TTL < 0 {
+		return fmt.Errorf("negative ttl")
+	}
+	if cfg.MaxLifetime < 0 {
+		return fmt.Errorf("negative maxLifetime")
+	}
+	if cfg.MaxLifetime > 0 && !cfg.Sliding {
+		return fmt.Errorf("maxLifetime without sliding")
+	}
+	return nil
+}
+
+func validateLoginConfig(cfg model.LoginConfig) error {
+	if cfg.MaxFailures < 0 {
+		return fmt.Errorf("negative maxFailures")
+	}
+	if cfg.IPMaxFailures < 0 {
+		return fmt.Errorf("negative ipMaxFailures")
+	}
+	if cfg.LockoutDuration < 0 {
+		return fmt.Errorf("negative lockoutDuration")
+	}
+	if cfg.BaseDelay < 0 {
+		return fmt.Errorf("negative baseDelay")
+	}
+	if cfg.MaxDelay < 0 {
+		return fmt.Errorf("negative maxDelay")
+	}
+	return nil
+}
+
+// validateTrustedProxies accepts the IPs and the CIDRs, as gin does.
+func validateTrustedProxies(trustedProxies []string) error {
+	for _, proxy := range trustedProxies {
+		if net.ParseIP(proxy) != nil {
+			continue
+		}
+		if _, _, err := net.ParseCIDR(proxy); err != nil {
+			return fmt.Errorf("invalid IP or CIDR %q", proxy)
+		}
+	}
+	return nil
+}
+
+func validateGroupRoles(groupRoles map[string]model.Role, defaultRole model.Role) error {
+	for group, role := range groupRoles {
+		if role == model.RoleNone || !role.Valid() {
+			return fmt.Errorf("group %q: unknown role %q", group, role)
+		}
+	}
+	if !defaultRole.Valid() {
+		return fmt.Errorf("unknown defaultRole %q", defaultRole)
+	}
+	return nil
+}
+
 func (c *Config) loadDatasourceConfigFile(file string) error {
 	logger.Infof("loading datasource config file: %s", file)
 	yamlBytes, err := os.ReadFile(file)
@@ -106,10 +238,43 @@ func (c *Config) loadUserConfigFile(file string) error {
 	if err := util.UnmarshalStrict(yamlBytes, &cfg); err != nil {
 		return fmt.Errorf("unmarshalStrict err: %w", err)
 	}
+	if err := validateUserConfig(cfg, c.DatasourceConfig.Datasources); err != nil {
+		return fmt.Errorf("validateUserConfig err: %w", err)
+	}
 	c.UserConfig = cfg
 	return nil
 }
 
+// validateUserConfig also rejects the grants that name a Lethe of datasources.yml beside namespaces,
+// as the namespaces do not restrict the logs of all namespaces in it.
+func validateUserConfig(cfg model.UserConfig, datasources []model.Datasource) error {
+	for _, user := range cfg.EtcUsers {
+		if !user.Role.Valid() {
+			return fmt.Errorf("user %q: unknown role %q", user.Username, user.Role)
+		}
+	}
+	if !cfg.DefaultAccess.Valid() {
+		return fmt.Errorf("unknown defaultAccess %q", cfg.DefaultAccess)
+	}
+	for i, grant := range cfg.Grants {
+		if len(grant.Users) == 0 && len(grant.Groups) == 0 {
+			return fmt.Errorf("grants[%d]: no users or groups", i)
+		}
+		if len(grant.Datasources) == 0 && len(grant.Namespaces) == 0 {
+			return fmt.Errorf("grants[%d]: no datasources or namespaces", i)
+		}
+		if len(grant.Namespaces) == 0 {
+			continue
+		}
+		for _, datasource := range datasources {
+			if datasource.Type == model.DatasourceTypeLethe && slices.Contains(grant.Datasources, datasource.Name) {
+				return fmt.Errorf("grants[%d]: namespaces do not restrict the shared datasource %q; grant it without namespaces", i, datasource.Name)
+			}
+		}
+	}
+	return nil
+}
+
 func (c *Config) loadAlertingConfigFile(file string) error {
 	logger.Infof("loading alerting config file: %s", file)
 	yamlBytes, err := os.ReadFile(file)
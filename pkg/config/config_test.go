@@ -2,9 +2,12 @@ package config
 
 import (
 	"testing"
+	"time"
 
 	"github.com/kuoss/venti/pkg/model"
 	"github.com/kuoss/venti/pkg/testutil"
+	commonmodel "github.com/prometheus/common/model"
+	"github.com/prometheus/prometheus/model/relabel"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -128,6 +131,7 @@ func TestLoadDatasourceConfigFile(t *testing.T) {
 func TestLoadUserConfigFile(t *testing.T) {
 	_, cleanup := testutil.SetupTest(t, map[string]string{
 		"@/etc":                                "etc",
+		"@/docs/examples":                      "docs/examples",
 		"@/docs/examples/datasources.dev1.yml": "etc/datasources.yml",
 	})
 	defer cleanup()
@@ -149,6 +153,22 @@ func TestLoadUserConfigFile(t *testing.T) {
 			}},
 			"",
 		},
+		{
+			"docs/examples/users.rbac.yml",
+			model.UserConfig{
+				EtcUsers: []model.EtcUser{
+					{Username: "admin", Hash: "$2a$12$VcCDgh2NDk07JGN0rjGbM.Ad41qVR/YFJcgHp0UGns5JDymv..TOG", Role: model.RoleAdmin},
+					{Username: "alice", Hash: "$2a$12$VcCDgh2NDk07JGN0rjGbM.Ad41qVR/YFJcgHp0UGns5JDymv..TOG", Role: model.RoleEditor, Groups: []string{"team-a"}},
+					{Username: "bob", Hash: "$2a$12$VcCDgh2NDk07JGN0rjGbM.Ad41qVR/YFJcgHp0UGns5JDymv..TOG", Groups: []string{"team-b"}},
+				},
+				Grants: []model.Grant{
+					{Groups: []string{"team-a"}, Namespaces: []string{"team-a", "team-a-dev"}},
+					{Groups: []string{"team-b"}, Users: []string{"alice"}, Datasources: []string{"prometheus"}},
+				},
+				DefaultAccess: model.DefaultAccessNone,
+			},
+			"",
+		},
 	}
 	for _, tc := range testCases {
 		t.Run("", func(t *testing.T) {
@@ -163,9 +183,242 @@ func TestLoadUserConfigFile(t *testing.T) {
 	}
 }
 
+func TestValidateUserConfig(t *testing.T) {
+	testCases := []struct {
+		name      string
+		cfg       model.UserConfig
+		wantError string
+	}{
+		{"empty", model.UserConfig{}, ""},
+		{
+			"roles",
+			model.UserConfig{EtcUsers: []model.EtcUser{{Username: "a"}, {Username: "b", Role: model.RoleViewer}, {Username: "c", Role: model.RoleAdmin}}},
+			"",
+		},
+		{
+			"unknown role",
+			model.UserConfig{EtcUsers: []model.EtcUser{{Username: "a", Role: "root"}}},
+			`user "a": unknown role "root"`,
+		},
+		{
+			"no subjects",
+			model.UserConfig{Grants: []model.Grant{{Datasources: []string{"prometheus"}}}},
+			"grants[0]: no users or groups",
+		},
+		{
+			"no datasources",
+			model.UserConfig{Grants: []model.Grant{{Users: []string{"a"}, Datasources: []string{"prometheus"}}, {Groups: []string{"team-a"}}}},
+			"grants[1]: no datasources or namespaces",
+		},
+		{
+			"defaultAccess",
+			model.UserConfig{DefaultAccess: model.DefaultAccessNone},
+			"",
+		},
+		{
+			"unknown defaultAccess",
+			model.UserConfig{DefaultAccess: "some"},
+			`unknown defaultAccess "some"`,
+		},
+		{
+			"namespaces and a prometheus",
+			model.UserConfig{Grants: []model.Grant{{Groups: []string{"team-a"}, Datasources: []string{"prometheus"}, Namespaces: []string{"team-a"}}}},
+			"",
+		},
+		{
+			"namespaces and a shared lethe",
+			model.UserConfig{Grants: []model.Grant{{Groups: []string{"team-a"}, Datasources: []string{"lethe"}, Namespaces: []string{"team-a"}}}},
+			`grants[0]: namespaces do not restrict the shared datasource "lethe"; grant it without namespaces`,
+		},
+	}
+	datasources := []model.Datasource{
+		{Name: "prometheus", Type: model.DatasourceTypePrometheus},
+		{Name: "lethe", Type: model.DatasourceTypeLethe},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			err := validateUserConfig(tc.cfg, datasources)
+			if tc.wantError == "" {
+				assert.NoError(t, err)
+			} else {
+				assert.EqualError(t, err, tc.wantError)
+			}
+		})
+	}
+}
+
+func TestSetOIDCDefaults(t *testing.T) {
+	testCases := []struct {
+		name        string
+		cfg         model.OIDCConfig
+		externalURL string
+		want        model.OIDCConfig
+		wantError   string
+	}{
+		{
+			"defaults",
+			model.OIDCConfig{Issuer: "https://idp.example.com", ClientID: "venti"},
+			"http://venti.example.com/",
+			model.OIDCConfig{Issuer: "https://idp.example.com", ClientID: "venti", RedirectURL: "http://venti.example.com/auth/oidc/callback", Scopes: []string{"openid", "profile", "email"}, GroupsClaim: "groups"},
+			"",
+		},
+		{
+			"set",
+			model.OIDCConfig{Issuer: "https://idp.example.com", ClientID: "venti", RedirectURL: "http://localhost/auth/oidc/callback", Scopes: []string{"openid"}, GroupsClaim: "roles", GroupRoles: map[string]model.Role{"ops": model.RoleAdmin}, DefaultRole: model.RoleViewer},
+			"",
+			model.OIDCConfig{Issuer: "https://idp.example.com", ClientID: "venti", RedirectURL: "http://localhost/auth/oidc/callback", Scopes: []string{"openid"}, GroupsClaim: "roles", GroupRoles: map[string]model.Role{"ops": model.RoleAdmin}, DefaultRole: model.RoleViewer},
+			"",
+		},
+		{"no issuer", model.OIDCConfig{ClientID: "venti"}, "", model.OIDCConfig{}, "no issuer"},
+		{"no clientID", model.OIDCConfig{Issuer: "https://idp.example.com"}, "", model.OIDCConfig{}, "no clientID"},
+		{"no redirectURL", model.OIDCConfig{Issuer: "https://idp.example.com", ClientID: "venti"}, "", model.OIDCConfig{}, "no redirectURL or externalURL"},
+		{
+			"unknown group role",
+			model.OIDCConfig{Issuer: "https://idp.example.com", ClientID: "venti", GroupRoles: map[string]model.Role{"ops": "root"}},
+			"http://venti.example.com",
+			model.OIDCConfig{},
+			`group "ops": unknown role "root"`,
+		},
+		{
+			"unknown defaultRole",
+			model.OIDCConfig{Issuer: "https://idp.example.com", ClientID: "venti", DefaultRole: "root"},
+			"http://venti.example.com",
+			model.OIDCConfig{},
+			`unknown defaultRole "root"`,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			err := setOIDCDefaults(&tc.cfg, tc.externalURL)
+			if tc.wantError != "" {
+				assert.EqualError(t, err, tc.wantError)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tc.want, tc.cfg)
+		})
+	}
+}
+
+func TestSetLDAPDefaults(t *testing.T) {
+	testCases := []struct {
+		name      string
+		cfg       model.LDAPConfig
+		want      model.LDAPConfig
+		wantError string
+	}{
+		{
+			"defaults",
+			model.LDAPConfig{URL: "ldap://ldap.example.com", BaseDN: "dc=example,dc=com"},
+			model.LDAPConfig{URL: "ldap://ldap.example.com", BaseDN: "dc=example,dc=com", Timeout: 10 * time.Second, UserFilter: "(uid={username})", GroupBaseDN: "dc=example,dc=com", GroupFilter: "(member={dn})", GroupAttribute: "cn"},
+			"",
+		},
+		{
+			"set",
+			model.LDAPConfig{URL: "ldaps://ldap.example.com", BaseDN: "ou=people,dc=example,dc=com", Timeout: time.Second, UserFilter: "(sAMAccountName={username})", GroupBaseDN: "ou=groups,dc=example,dc=com", GroupFilter: "(memberUid={username})", GroupAttribute: "name"},
+			model.LDAPConfig{URL: "ldaps://ldap.example.com", BaseDN: "ou=people,dc=example,dc=com", Timeout: time.Second, UserFilter: "(sAMAccountName={username})", GroupBaseDN: "ou=groups,dc=example,dc=com", GroupFilter: "(memberUid={username})", GroupAttribute: "name"},
+			"",
+		},
+		{"no url", model.LDAPConfig{BaseDN: "dc=example,dc=com"}, model.LDAPConfig{}, "no url"},
+		{"no baseDN", model.LDAPConfig{URL: "ldap://ldap.example.com"}, model.LDAPConfig{}, "no baseDN"},
+		{
+			"unknown group role",
+			model.LDAPConfig{URL: "ldap://ldap.example.com", BaseDN: "dc=example,dc=com", GroupRoles: map[string]model.Role{"ops": "root"}},
+			model.LDAPConfig{},
+			`group "ops": unknown role "root"`,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			err := setLDAPDefaults(&tc.cfg)
+			if tc.wantError != "" {
+				assert.EqualError(t, err, tc.wantError)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tc.want, tc.cfg)
+		})
+	}
+}
+
+func TestValidateSessionConfig(t *testing.T) {
+	testCases := []struct {
+		name      string
+		cfg       model.SessionConfig
+		wantError string
+	}{
+		{"empty", model.SessionConfig{}, ""},
+		{"absolute", model.SessionConfig{TTL: 8 * time.Hour}, ""},
+		{"sliding", model.SessionConfig{TTL: time.Hour, Sliding: true, MaxLifetime: 720 * time.Hour}, ""},
+		{"negative ttl", model.SessionConfig{TTL: -time.Hour}, "negative ttl"},
+		{"negative maxLifetime", model.SessionConfig{Sliding: true, MaxLifetime: -time.Hour}, "negative maxLifetime"},
+		{"maxLifetime without sliding", model.SessionConfig{MaxLifetime: time.Hour}, "maxLifetime without sliding"},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			err := validateSessionConfig(tc.cfg)
+			if tc.wantError == "" {
+				assert.NoError(t, err)
+			} else {
+				assert.EqualError(t, err, tc.wantError)
+			}
+		})
+	}
+}
+
+func TestValidateLoginConfig(t *testing.T) {
+	testCases := []struct {
+		name      string
+		cfg       model.LoginConfig
+		wantError string
+	}{
+		{"empty", model.LoginConfig{}, ""},
+		{"limits", model.LoginConfig{MaxFailures: 3, IPMaxFailures: 50, LockoutDuration: time.Hour, BaseDelay: 2 * time.Second, MaxDelay: 5 * time.Minute}, ""},
+		{"negative maxFailures", model.LoginConfig{MaxFailures: -1}, "negative maxFailures"},
+		{"negative ipMaxFailures", model.LoginConfig{IPMaxFailures: -1}, "negative ipMaxFailures"},
+		{"negative lockoutDuration", model.LoginConfig{LockoutDuration: -time.Minute}, "negative lockoutDuration"},
+		{"negative baseDelay", model.LoginConfig{BaseDelay: -time.Second}, "negative baseDelay"},
+		{"negative maxDelay", model.LoginConfig{MaxDelay: -time.Second}, "negative maxDelay"},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			err := validateLoginConfig(tc.cfg)
+			if tc.wantError == "" {
+				assert.NoError(t, err)
+			} else {
+				assert.EqualError(t, err, tc.wantError)
+			}
+		})
+	}
+}
+
+func TestValidateTrustedProxies(t *testing.T) {
+	testCases := []struct {
+		name           string
+		trustedProxies []string
+		wantError      string
+	}{
+		{"empty", nil, ""},
+		{"IPs and CIDRs", []string{"10.0.0.1", "10.1.0.0/16", "::1", "fd00::/8"}, ""},
+		{"invalid", []string{"10.0.0.1", "proxy"}, `invalid IP or CIDR "proxy"`},
+		{"invalid CIDR", []string{"10.0.0.0/33"}, `invalid IP or CIDR "10.0.0.0/33"`},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			err := validateTrustedProxies(tc.trustedProxies)
+			if tc.wantError == "" {
+				assert.NoError(t, err)
+			} else {
+				assert.EqualError(t, err, tc.wantError)
+			}
+		})
+	}
+}
+
 func TestLoadAlertingConfigFile(t *testing.T) {
 	_, cleanup := testutil.SetupTest(t, map[string]string{
 		"@/etc":                                "etc",
+		"@/docs/examples":                      "docs/examples",
 		"@/docs/examples/datasources.dev1.yml": "etc/datasources.yml",
 	})
 	defer cleanup()
@@ -194,6 +447,26 @@ func TestLoadAlertingConfigFile(t *testing.T) {
 			},
 			"",
 		},
+		{
+			"docs/examples/alerting.dev2.yml",
+			model.AlertingConfig{
+				EvaluationInterval: 5000000000,
+				AlertRelabelConfigs: []*relabel.Config{
+					{Separator: ";", Regex: relabel.MustNewRegexp("rulefile"), Replacement: "$1", Action: relabel.LabelDrop},
+					{SourceLabels: commonmodel.LabelNames{"severity"}, Separator: ";", Regex: relabel.MustNewRegexp("silence"), Replacement: "$1", Action: relabel.Drop},
+				},
+				AlertmanagerConfigs: model.AlertmanagerConfigs{
+					{StaticConfig: []*model.TargetGroup{
+						{Targets: []string{"http://vs-alertmanager:9093"}},
+					}},
+				},
+				GlobalLabels: map[string]string{"venti": "development"},
+				RemoteWriteConfigs: []*model.RemoteWriteConfig{
+					{URL: "http://vs-prometheus:9090/api/v1/write"},
+				},
+			},
+			"",
+		},
 	}
 	for _, tc := range testCases {
 		t.Run("", func(t *testing.T) {
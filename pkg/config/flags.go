@@ -0,0 +1,106 @@
+package config
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"reflect"
+	"strings"
+)
+
+// Flags are the locations of the files and the server.
+// Each one is set by a command-line flag, a VENTI_* environment variable or its default, in that order.
+type Flags struct {
+	GlobalConfigFile     string
+	DatasourceConfigFile string
+	UserConfigFile       string
+	AlertingConfigFile   string
+	AlertRuleFiles       string // a glob
+	DashboardsDir        string
+	DBFile               string
+	ListenAddress        string
+	WebRoot              string
+}
+
+// DefaultFlags returns the flags with the paths of the release image, relative to the working directory.
+func DefaultFlags() Flags {
+	return Flags{
+		GlobalConfigFile:     "etc/venti.yml",
+		DatasourceConfigFile: "etc/datasources.yml",
+		UserConfigFile:       "etc/users.yml",
+		AlertingConfigFile:   "etc/alerting.yml",
+		AlertRuleFiles:       "etc/alertrules/*.y*ml",
+		DashboardsDir:        "etc/dashboards",
+		DBFile:               "./data/venti.sqlite3",
+		ListenAddress:        ":3030",
+		WebRoot:              "./web/dist",
+	}
+}
+
+// WithDefaults returns the flags with the empty ones set to their defaults, for the configs built in code.
+func (f Flags) WithDefaults() Flags {
+	v := reflect.ValueOf(&f).Elem()
+	defaults := reflect.ValueOf(DefaultFlags())
+	for i := range v.NumField() {
+		if v.Field(i).String() == "" {
+			v.Field(i).Set(defaults.Field(i))
+		}
+	}
+	return f
+}
+
+// ParseFlags parses the command-line flags at the start of args, and returns the arguments after them,
+// e.g. a subcommand and its files. getenv is usually os.Getenv.
+// The usage is written to output on -h or an invalid flag.
+func ParseFlags(args []string, getenv func(string) string, output io.Writer) (Flags, []string, error) {
+	return DefaultFlags().withEnv(getenv).Parse("venti", args, output)
+}
+
+// Parse parses the flags at the start of args over f, and returns the arguments after them.
+// The usage of name is written to output on -h or an invalid flag.
+func (f Flags) Parse(name string, args []string, output io.Writer) (Flags, []string, error) {
+	fs := flag.NewFlagSet(name, flag.ContinueOnError)
+	fs.SetOutput(output)
+	for _, d := range f.defs() {
+		fs.StringVar(d.p, d.name, *d.p, fmt.Sprintf("%s (env: %s)", d.usage, envName(d.name)))
+	}
+	if err := fs.Parse(args); err != nil {
+		return Flags{}, nil, err
+	}
+	return f, fs.Args(), nil
+}
+
+// withEnv returns the flags with the ones set by the VENTI_* environment variables.
+func (f Flags) withEnv(getenv func(string) string) Flags {
+	for _, d := range f.defs() {
+		if v := getenv(envName(d.name)); v != "" {
+			*d.p = v
+		}
+	}
+	return f
+}
+
+type flagDef struct {
+	p     *string
+	name  string
+	usage string
+}
+
+func (f *Flags) defs() []flagDef {
+	return []flagDef{
+		{&f.GlobalConfigFile, "config.file", "global config file"},
+		{&f.DatasourceConfigFile, "datasources.file", "datasource config file"},
+		{&f.UserConfigFile, "users.file", "user config file"},
+		{&f.AlertingConfigFile, "alerting.file", "alerting config file"},
+		{&f.AlertRuleFiles, "rules.files", "glob of the alert rule files"},
+		{&f.DashboardsDir, "dashboards.dir", "directory of the dashboard files"},
+		{&f.DBFile, "db.file", "sqlite database file of the users and the alert state"},
+		{&f.ListenAddress, "web.listen-address", "address to listen on"},
+		{&f.WebRoot, "web.root", "directory of the web UI"},
+	}
+}
+
+// envName returns the environment variable of a flag, e.g. VENTI_WEB_LISTEN_ADDRESS for web.listen-address.
+func envName(flagName string) string {
+	return "VENTI_" + strings.ToUpper(strings.NewReplacer(".", "_", "-", "_").Replace(flagName))
+}
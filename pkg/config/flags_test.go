@@ -0,0 +1,104 @@
+package config
+
+import (
+	"bytes"
+	"flag"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseFlags(t *testing.T) {
+	defaults := DefaultFlags()
+	testCases := []struct {
+		name      string
+		args      []string
+		env       map[string]string
+		want      func(f *Flags)
+		wantRest  []string
+		wantError string
+	}{
+		{"defaults", nil, nil, func(f *Flags) {}, nil, ""},
+		{
+			"flags",
+			[]string{"--config.file=/config/venti.yml", "-web.listen-address", ":8080", "-db.file", "/data/venti.db"},
+			nil,
+			func(f *Flags) {
+				f.GlobalConfigFile = "/config/venti.yml"
+				f.ListenAddress = ":8080"
+				f.DBFile = "/data/venti.db"
+			},
+			nil,
+			"",
+		},
+		{
+			"env",
+			nil,
+			map[string]string{"VENTI_RULES_FILES": "/rules/*.yml", "VENTI_WEB_ROOT": "/web", "VENTI_XXX": "x"},
+			func(f *Flags) {
+				f.AlertRuleFiles = "/rules/*.yml"
+				f.WebRoot = "/web"
+			},
+			nil,
+			"",
+		},
+		{
+			"flags over env",
+			[]string{"-dashboards.dir", "/flag"},
+			map[string]string{"VENTI_DASHBOARDS_DIR": "/env", "VENTI_USERS_FILE": "/secret/users.yml"},
+			func(f *Flags) {
+				f.DashboardsDir = "/flag"
+				f.UserConfigFile = "/secret/users.yml"
+			},
+			nil,
+			"",
+		},
+		{
+			"arguments",
+			[]string{"-rules.files", "/rules/*.yml", "check", "rules", "a.yml", "-db.file", "x"},
+			nil,
+			func(f *Flags) {
+				f.AlertRuleFiles = "/rules/*.yml"
+			},
+			[]string{"check", "rules", "a.yml", "-db.file", "x"},
+			"",
+		},
+		{"unknown flag", []string{"-xxx"}, nil, nil, nil, "flag provided but not defined: -xxx"},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			var output bytes.Buffer
+			got, rest, err := ParseFlags(tc.args, func(key string) string { return tc.env[key] }, &output)
+			if tc.wantError != "" {
+				require.EqualError(t, err, tc.wantError)
+				return
+			}
+			require.NoError(t, err)
+			want := defaults
+			tc.want(&want)
+			require.Equal(t, want, got)
+			if tc.wantRest == nil {
+				require.Empty(t, rest)
+			} else {
+				require.Equal(t, tc.wantRest, rest)
+			}
+			require.Empty(t, output.String())
+		})
+	}
+}
+
+func TestParseFlags_help(t *testing.T) {
+	var output bytes.Buffer
+	_, _, err := ParseFlags([]string{"-h"}, func(string) string { return "" }, &output)
+	require.ErrorIs(t, err, flag.ErrHelp)
+	require.Contains(t, output.String(), "  -web.listen-address string\n    \taddress to listen on (env: VENTI_WEB_LISTEN_ADDRESS) (default \":3030\")\n")
+}
+
+func TestWithDefaults(t *testing.T) {
+	require.Equal(t, DefaultFlags(), Flags{}.WithDefaults())
+
+	got := Flags{DBFile: "/data/venti.db"}.WithDefaults()
+	want := DefaultFlags()
+	want.DBFile = "/data/venti.db"
+	require.Equal(t, want, got)
+}
@@ -0,0 +1,103 @@
+package handler
+
+import (
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/require"
+
+	"github.com/kuoss/venti/pkg/handler/api"
+	"github.com/kuoss/venti/pkg/model"
+	"github.com/kuoss/venti/pkg/service/user"
+)
+
+// serveWithAccess serves the handler for a user with the grants.
+func serveWithAccess(handler gin.HandlerFunc, u model.User, grants []model.Grant, path string) *httptest.ResponseRecorder {
+	r := gin.New()
+	r.GET("/", func(c *gin.Context) {
+		api.SetAccess(c, user.NewAccess(u, grants, ""))
+	}, handler)
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest("GET", path, nil))
+	return w
+}
+
+func TestAccess(t *testing.T) {
+	granted := []model.Grant{{Users: []string{"alice"}, Datasources: []string{"prometheus"}}}
+	notGranted := []model.Grant{{Users: []string{"alice"}, Datasources: []string{"lethe"}}}
+	alice := model.User{Username: "alice"}
+
+	testCases := []struct {
+		name    string
+		handler gin.HandlerFunc
+		path    string
+		want    string // with the grant of prometheus
+		wantNot string // with the grant of lethe only
+	}{
+		{
+			"datasources", handlers.datasourceHandler.Datasources, "/",
+			`[{"type":"prometheus","name":"prometheus","url":"","basicAuth":false,"basicAuthUser":"","basicAuthPassword":"","isMain":true}]`,
+			`[]`,
+		},
+		{
+			"dashboards", handlers.dashboardHandler.Dashboards, "/",
+			`[{"title":"Sample",`,
+			`[]`,
+		},
+		{
+			"alerts", handlers.alertHandler.Alerts, "/",
+			`{"data":{"alerts":[]},"status":"success"}`,
+			`{"data":{"alerts":[]},"status":"success"}`,
+		},
+		{
+			"rules", handlers.alertHandler.Rules, "/?type=alert",
+			`{"data":{"groups":[{"name":"sample",`,
+			`{"data":{"groups":[]},"status":"success"}`,
+		},
+		{
+			"groups", handlers.alertHandler.AlertingRuleGroups, "/",
+			`{"data":[{"name":"sample",`,
+			`{"data":[],"status":"success"}`,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			w := serveWithAccess(tc.handler, alice, granted, tc.path)
+			require.Equal(t, 200, w.Code)
+			require.Contains(t, w.Body.String(), tc.want)
+
+			w = serveWithAccess(tc.handler, alice, notGranted, tc.path)
+			require.Equal(t, 200, w.Code)
+			require.Equal(t, tc.wantNot, w.Body.String())
+
+			// admins use all datasources
+			w = serveWithAccess(tc.handler, model.User{Username: "alice", Role: model.RoleAdmin}, notGranted, tc.path)
+			require.Equal(t, 200, w.Code)
+			require.Contains(t, w.Body.String(), tc.want)
+		})
+	}
+}
+
+func TestAllowsDashboard(t *testing.T) {
+	d := model.Dashboard{Rows: []model.Row{
+		{Panels: []model.Panel{{Type: "stat"}, {Type: "timeSeries"}}},
+		{Panels: []model.Panel{{Type: "logs"}}},
+	}}
+	require.True(t, allowsDashboard(d, map[model.DatasourceType]bool{}))
+	require.True(t, allowsDashboard(d, map[model.DatasourceType]bool{model.DatasourceTypePrometheus: false, model.DatasourceTypeLethe: false}))
+	require.False(t, allowsDashboard(d, map[model.DatasourceType]bool{model.DatasourceTypePrometheus: true}))
+	require.False(t, allowsDashboard(d, map[model.DatasourceType]bool{model.DatasourceTypeLethe: true}))
+	require.True(t, allowsDashboard(model.Dashboard{}, map[model.DatasourceType]bool{model.DatasourceTypeLethe: true}))
+}
+
+func TestTargetByName_notAllowed(t *testing.T) {
+	r := gin.New()
+	r.GET("/:name", func(c *gin.Context) {
+		api.SetAccess(c, user.NewAccess(model.User{Username: "alice"}, []model.Grant{{Users: []string{"alice"}, Datasources: []string{"lethe"}}}, ""))
+	}, handlers.datasourceHandler.TargetByName)
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest("GET", "/prometheus", nil))
+	require.Equal(t, 403, w.Code)
+	require.Equal(t, `{"error":"datasource not allowed: prometheus","errorType":"forbidden","status":"error"}`, w.Body.String())
+}
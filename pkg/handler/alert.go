@@ -1,22 +1,72 @@
 package handler
 
 import (
+	"fmt"
+
 	"github.com/gin-gonic/gin"
+	"github.com/kuoss/venti/pkg/handler/api"
 	"github.com/kuoss/venti/pkg/service/alerting"
 	"github.com/kuoss/venti/pkg/service/alertrule"
+	dsService "github.com/kuoss/venti/pkg/service/datasource"
+	"github.com/prometheus/prometheus/promql/parser"
 )
 
 type alertHandler struct {
-	alertRuleService *alertrule.AlertRuleService
-	alertingService  *alerting.AlertingService
+	alertRuleService  *alertrule.AlertRuleService
+	alertingService   *alerting.AlertingService
+	datasourceService *dsService.DatasourceService
+}
+
+func NewAlertHandler(alertRuleService *alertrule.AlertRuleService, alertingService *alerting.AlertingService, datasourceService *dsService.DatasourceService) *alertHandler {
+	return &alertHandler{alertRuleService, alertingService, datasourceService}
+}
+
+// allowedDatasources returns the filter of the datasources that the user can use, or nil for all.
+func (h *alertHandler) allowedDatasources(c *gin.Context) func(name string) bool {
+	access, _ := api.GetAccess(c)
+	if access.AllDatasources() {
+		return nil
+	}
+	return func(name string) bool {
+		datasource, err := h.datasourceService.GetDatasourceByName(name)
+		return err == nil && access.AllowsDatasource(datasource)
+	}
 }
 
-func NewAlertHandler(alertRuleService *alertrule.AlertRuleService, alertingService *alerting.AlertingService) *alertHandler {
-	return &alertHandler{alertRuleService, alertingService}
+func (h *alertHandler) AlertingRuleGroups(c *gin.Context) {
+	c.JSON(200, gin.H{"status": "success", "data": h.alertingService.GetAlertingRuleGroupsWithDatasources(h.allowedDatasources(c))})
 }
 
+// Alerts lists active alerts in the format of /api/v1/alerts of Prometheus.
+// They can be filtered by state, datasource, and label matchers with match[].
+// https://prometheus.io/docs/prometheus/latest/querying/api/#alerts
 func (h *alertHandler) Alerts(c *gin.Context) {
-	c.JSON(200, gin.H{"status": "success", "data": h.alertingService.GetAlertingRuleGroups()})
+	filter := alerting.AlertsFilter{State: c.Query("state"), Datasource: c.Query("datasource"), Datasources: h.allowedDatasources(c)}
+	if filter.State != "" && filter.State != "pending" && filter.State != "firing" {
+		api.ResponseError(c, api.ErrorBadData, fmt.Errorf("not supported value %q", filter.State))
+		return
+	}
+	for _, s := range c.QueryArray("match[]") {
+		matchers, err := parser.ParseMetricSelector(s)
+		if err != nil {
+			api.ResponseError(c, api.ErrorBadData, fmt.Errorf("invalid match[] %q: %w", s, err))
+			return
+		}
+		filter.Matchers = append(filter.Matchers, matchers)
+	}
+	c.JSON(200, gin.H{"status": "success", "data": h.alertingService.GetAlertDiscovery(filter)})
+}
+
+// Rules lists rule groups in the format of /api/v1/rules of Prometheus.
+// https://prometheus.io/docs/prometheus/latest/querying/api/#rules
+func (h *alertHandler) Rules(c *gin.Context) {
+	typ := c.Query("type")
+	if typ != "" && typ != "alert" && typ != "record" {
+		api.ResponseError(c, api.ErrorBadData, fmt.Errorf("not supported value %q", typ))
+		return
+	}
+	filter := alerting.RulesFilter{Type: typ, RuleNames: c.QueryArray("rule_name[]"), Datasources: h.allowedDatasources(c)}
+	c.JSON(200, gin.H{"status": "success", "data": h.alertingService.GetRuleDiscovery(filter)})
 }
 
 func (h *alertHandler) SendTestAlert(c *gin.Context) {
@@ -15,12 +15,12 @@ func TestNewAlertHandler(t *testing.T) {
 func TestAlertRuleFiles(t *testing.T) {
 	alertHandler1 := handlers.alertHandler
 	r := gin.Default()
-	r.GET("/", alertHandler1.Alerts)
+	r.GET("/", alertHandler1.AlertingRuleGroups)
 	w := httptest.NewRecorder()
 	req := httptest.NewRequest("GET", "/", nil)
 	r.ServeHTTP(w, req)
 	require.Equal(t, 200, w.Code)
-	want := `{"data":[{"datasourceSelector":{"system":"","type":"prometheus"},"groupLabels":{"rulefile":"sample-v3","severity":"silence"},"alertingRules":[{"rule":{"alert":"S00-AlwaysOn","expr":"vector(1234)","for":"0s","labels":{"hello":"world"},"annotations":{"summary":"AlwaysOn value={{ $value }}"}}},{"rule":{"alert":"S01-Monday","expr":"day_of_week() == 1 and hour() \u003c 2","for":"0s","annotations":{"summary":"Monday"}}},{"rule":{"alert":"S02-NewNamespace","expr":"time() - kube_namespace_created \u003c 120","for":"0s","annotations":{"summary":"labels={{ $labels }} namespace={{ $labels.namespace }} value={{ $value }}"}}},{"rule":{"alert":"PodNotHealthy","expr":"sum by (namespace, pod) (kube_pod_status_phase{phase=~\"Pending|Unknown|Failed\"}) \u003e 0","for":"3s","annotations":{"summary":"{{ $labels.namespace }}/{{ $labels.pod }}"}}}]}],"status":"success"}`
+	want := `{"data":[{"name":"sample","file":"etc/alertrules/sample.yml","datasourceSelector":{"system":"","type":"prometheus"},"groupLabels":{"rulefile":"sample-v3","severity":"silence"},"alertingRules":[{"rule":{"alert":"S00-AlwaysOn","expr":"vector(1234)","for":"0s","labels":{"hello":"world"},"annotations":{"summary":"AlwaysOn value={{ $value }}"}},"status":{"health":"unknown","lastEvaluation":"0001-01-01T00:00:00Z","evaluationTime":0}},{"rule":{"alert":"S01-Monday","expr":"day_of_week() == 1 and hour() \u003c 2","for":"0s","annotations":{"summary":"Monday"}},"status":{"health":"unknown","lastEvaluation":"0001-01-01T00:00:00Z","evaluationTime":0}},{"rule":{"alert":"S02-NewNamespace","expr":"time() - kube_namespace_created \u003c 120","for":"0s","annotations":{"summary":"labels={{ $labels }} namespace={{ $labels.namespace }} value={{ $value }}"}},"status":{"health":"unknown","lastEvaluation":"0001-01-01T00:00:00Z","evaluationTime":0}},{"rule":{"alert":"PodNotHealthy","expr":"sum by (namespace, pod) (kube_pod_status_phase{phase=~\"Pending|Unknown|Failed\"}) \u003e 0","for":"3s","annotations":{"summary":"{{ $labels.namespace }}/{{ $labels.pod }}"}},"status":{"health":"unknown","lastEvaluation":"0001-01-01T00:00:00Z","evaluationTime":0}}]}],"status":"success"}`
 	require.Equal(t, want, w.Body.String())
 }
 
@@ -35,3 +35,77 @@ func TestSendTestAlert(t *testing.T) {
 	want := `{"status":"success"}`
 	require.Equal(t, want, w.Body.String())
 }
+
+func TestRules(t *testing.T) {
+	alertHandler1 := handlers.alertHandler
+	r := gin.Default()
+	r.GET("/", alertHandler1.Rules)
+	testCases := []struct {
+		query    string
+		wantCode int
+		wantBody string
+	}{
+		{
+			"?type=record",
+			200, `{"data":{"groups":[]},"status":"success"}`,
+		},
+		{
+			"?type=alert&rule_name[]=S01-Monday&rule_name[]=none",
+			200, `{"data":{"groups":[{"name":"sample","file":"etc/alertrules/sample.yml","rules":[{"state":"inactive","name":"S01-Monday","query":"day_of_week() == 1 and hour() \u003c 2","duration":0,"keepFiringFor":0,"labels":{},"annotations":{"summary":"Monday"},"alerts":[],"health":"unknown","evaluationTime":0,"lastEvaluation":"0001-01-01T00:00:00Z","type":"alerting"}],"interval":0,"limit":0,"evaluationTime":0,"lastEvaluation":"0001-01-01T00:00:00Z"}]},"status":"success"}`,
+		},
+		{
+			"?rule_name[]=none",
+			200, `{"data":{"groups":[]},"status":"success"}`,
+		},
+		{
+			"?type=foo",
+			405, `{"error":"not supported value \"foo\"","errorType":"bad_data","status":"error"}`,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.query, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			req := httptest.NewRequest("GET", "/"+tc.query, nil)
+			r.ServeHTTP(w, req)
+			require.Equal(t, tc.wantCode, w.Code)
+			require.Equal(t, tc.wantBody, w.Body.String())
+		})
+	}
+}
+
+func TestAlerts(t *testing.T) {
+	alertHandler1 := handlers.alertHandler
+	r := gin.Default()
+	r.GET("/", alertHandler1.Alerts)
+	testCases := []struct {
+		query    string
+		wantCode int
+		wantBody string
+	}{
+		{
+			"",
+			200, `{"data":{"alerts":[]},"status":"success"}`,
+		},
+		{
+			`?state=firing&datasource=prometheus&match[]={severity="critical"}`,
+			200, `{"data":{"alerts":[]},"status":"success"}`,
+		},
+		{
+			"?state=inactive",
+			405, `{"error":"not supported value \"inactive\"","errorType":"bad_data","status":"error"}`,
+		},
+		{
+			"?match[]={",
+			405, `{"error":"invalid match[] \"{\": 1:2: parse error: unexpected end of input inside braces","errorType":"bad_data","status":"error"}`,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.query, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			req := httptest.NewRequest("GET", "/"+tc.query, nil)
+			r.ServeHTTP(w, req)
+			require.Equal(t, tc.wantCode, w.Code)
+			require.Equal(t, tc.wantBody, w.Body.String())
+		})
+	}
+}
@@ -0,0 +1,24 @@
+package api
+
+import (
+	"github.com/gin-gonic/gin"
+
+	"github.com/kuoss/venti/pkg/service/user"
+)
+
+const accessKey = "access"
+
+// SetAccess keeps the access of the authenticated user in the context.
+func SetAccess(c *gin.Context, access user.Access) {
+	c.Set(accessKey, access)
+}
+
+// GetAccess returns the access of the authenticated user, or false on the routes without authentication.
+func GetAccess(c *gin.Context) (user.Access, bool) {
+	v, ok := c.Get(accessKey)
+	if !ok {
+		return user.Access{}, false
+	}
+	access, ok := v.(user.Access)
+	return access, ok
+}
@@ -22,9 +22,11 @@ const (
 	ErrorCanceled     errorType = "canceled"     //
 	ErrorExec         errorType = "execution"    //
 	ErrorUnauthorized errorType = "unauthorized" // 401 Unauthorized
+	ErrorForbidden    errorType = "forbidden"    // 403 Forbidden
 	ErrorNotFound     errorType = "not_found"    // 404 Not Found
 	ErrorBadData      errorType = "bad_data"     // 405 StatusMethodNotAllowed
 	ErrorTimeout      errorType = "timeout"      // 408 Request Timeout
+	ErrorTooMany      errorType = "too_many"     // 429 Too Many Requests
 	ErrorInternal     errorType = "internal"     // 500 Internal Server Error
 	ErrorUnavailable  errorType = "unavailable"  // 503 Service Unavailable
 )
@@ -38,12 +40,16 @@ func getCodeFromType(typ errorType) int {
 	switch typ {
 	case ErrorUnauthorized:
 		return http.StatusUnauthorized // 401 Unauthorized
+	case ErrorForbidden:
+		return http.StatusForbidden // 403 Forbidden
 	case ErrorNotFound:
 		return http.StatusNotFound // 404 Not Found
 	case ErrorBadData:
 		return http.StatusMethodNotAllowed // 405 StatusMethodNotAllowed
 	case ErrorTimeout:
 		return http.StatusRequestTimeout // 408 Request Timeout
+	case ErrorTooMany:
+		return http.StatusTooManyRequests // 429 Too Many Requests
 	case ErrorInternal:
 		return http.StatusInternalServerError // 500 Internal Server Error
 	case ErrorUnavailable:
@@ -0,0 +1,78 @@
+package handler
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/kuoss/venti/pkg/handler/api"
+	"github.com/kuoss/venti/pkg/model"
+	userService "github.com/kuoss/venti/pkg/service/user"
+)
+
+type apiKeyHandler struct {
+	userService *userService.UserService
+}
+
+func NewAPIKeyHandler(s *userService.UserService) *apiKeyHandler {
+	return &apiKeyHandler{s}
+}
+
+type createAPIKeyRequest struct {
+	Name      string     `json:"name"`
+	Role      model.Role `json:"role"`
+	ExpiresAt *time.Time `json:"expiresAt"` // optional
+}
+
+// GET /api/v1/admin/apikeys
+func (h *apiKeyHandler) List(c *gin.Context) {
+	keys, err := h.userService.ListAPIKeys()
+	if err != nil {
+		api.ResponseError(c, api.ErrorInternal, fmt.Errorf("ListAPIKeys err: %w", err))
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"status": "success", "data": keys})
+}
+
+// POST /api/v1/admin/apikeys
+// Create responds the key once. Only its hash is kept.
+func (h *apiKeyHandler) Create(c *gin.Context) {
+	var req createAPIKeyRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		api.ResponseError(c, api.ErrorBadData, fmt.Errorf("invalid request: %w", err))
+		return
+	}
+	createdBy := ""
+	if access, ok := api.GetAccess(c); ok {
+		createdBy = access.User.Username
+	}
+	key, secret, err := h.userService.CreateAPIKey(req.Name, req.Role, req.ExpiresAt, createdBy)
+	if err != nil {
+		api.ResponseError(c, api.ErrorBadData, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"status": "success", "data": gin.H{"apiKey": key, "key": secret}})
+}
+
+// DELETE /api/v1/admin/apikeys/:id
+func (h *apiKeyHandler) Revoke(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		api.ResponseError(c, api.ErrorBadData, fmt.Errorf("invalid id: %s", c.Param("id")))
+		return
+	}
+	err = h.userService.RevokeAPIKey(id)
+	if err != nil {
+		if errors.Is(err, userService.ErrAPIKeyNotFound) {
+			api.ResponseError(c, api.ErrorNotFound, err)
+			return
+		}
+		api.ResponseError(c, api.ErrorInternal, fmt.Errorf("RevokeAPIKey err: %w", err))
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"status": "success"})
+}
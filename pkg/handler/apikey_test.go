@@ -0,0 +1,84 @@
+package handler
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/kuoss/venti/pkg/model"
+)
+
+func TestAPIKey(t *testing.T) {
+	adminID, adminToken := setTestUser(t, "apikey-admin", model.RoleAdmin, time.Now().Add(time.Hour))
+	router := NewRouter(services, "web/dist", nil, nil)
+
+	serve := func(method, path, body, token, userID string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest(method, path, strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("Authorization", "Bearer "+token)
+		req.Header.Set("UserID", userID)
+		router.ServeHTTP(w, req)
+		return w
+	}
+	create := func(body string) (model.APIKey, string) {
+		w := serve("POST", "/api/v1/admin/apikeys", body, adminToken, adminID)
+		require.Equal(t, 200, w.Code, w.Body.String())
+		var resp struct {
+			Data struct {
+				APIKey model.APIKey `json:"apiKey"`
+				Key    string       `json:"key"`
+			} `json:"data"`
+		}
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+		return resp.Data.APIKey, resp.Data.Key
+	}
+
+	editorKey, editorSecret := create(`{"name":"handler-ci","role":"editor"}`)
+	require.Equal(t, model.RoleEditor, editorKey.Role)
+	require.Equal(t, "apikey-admin", editorKey.CreatedBy)
+	viewerKey, viewerSecret := create(`{"name":"handler-bot","role":"viewer","expiresAt":"` + time.Now().Add(time.Hour).Format(time.RFC3339) + `"}`)
+	require.NotNil(t, viewerKey.ExpiresAt)
+
+	w := serve("POST", "/api/v1/admin/apikeys", `{"name":"handler-ci","role":"editor"}`, adminToken, adminID)
+	assert.Equal(t, 405, w.Code)
+	assert.JSONEq(t, `{"error":"api key \"handler-ci\" exists","errorType":"bad_data","status":"error"}`, w.Body.String())
+
+	w = serve("GET", "/api/v1/admin/apikeys", "", adminToken, adminID)
+	assert.Equal(t, 200, w.Code)
+	assert.Contains(t, w.Body.String(), `"name":"handler-ci"`)
+	assert.NotContains(t, w.Body.String(), editorSecret)
+
+	// the keys without the UserID header
+	testCases := []struct {
+		method     string
+		path       string
+		wantEditor int
+		wantViewer int
+	}{
+		{"GET", "/api/v1/remote/query", 500, 500},
+		{"GET", "/api/v1/alerts/test", 200, 403},
+		{"GET", "/api/v1/admin/apikeys", 403, 403},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.method+" "+tc.path, func(t *testing.T) {
+			assert.Equal(t, tc.wantEditor, serve(tc.method, tc.path, "", editorSecret, "").Code)
+			assert.Equal(t, tc.wantViewer, serve(tc.method, tc.path, "", viewerSecret, "").Code)
+		})
+	}
+
+	// revoke
+	w = serve("DELETE", "/api/v1/admin/apikeys/"+strconv.Itoa(editorKey.ID), "", adminToken, adminID)
+	assert.Equal(t, 200, w.Code)
+	w = serve("GET", "/api/v1/alerts/test", "", editorSecret, "")
+	assert.Equal(t, 401, w.Code)
+	assert.JSONEq(t, `{"error":"valid token required","errorType":"unauthorized","status":"error"}`, w.Body.String())
+	require.NoError(t, services.UserService.RevokeAPIKey(viewerKey.ID))
+}
@@ -1,32 +1,37 @@
 package handler
 
 import (
-	"crypto/rand"
+	"errors"
 	"fmt"
-	"net/http"
+	"math"
+	"strconv"
 	"strings"
-	"time"
 
 	"github.com/kuoss/common/logger"
 	"github.com/kuoss/venti/pkg/handler/api"
-	"github.com/kuoss/venti/pkg/model"
+	authService "github.com/kuoss/venti/pkg/service/auth"
+	oidcService "github.com/kuoss/venti/pkg/service/oidc"
 	userService "github.com/kuoss/venti/pkg/service/user"
-	"gorm.io/gorm"
 
 	"github.com/gin-gonic/gin"
-	"golang.org/x/crypto/bcrypt"
 )
 
 type authHandler struct {
 	// todo service to database
 	userService *userService.UserService
+	authService *authService.AuthService
+	oidcService *oidcService.OIDCService
 }
 
-func NewAuthHandler(s *userService.UserService) *authHandler {
-	return &authHandler{s}
+func NewAuthHandler(s *userService.UserService, a *authService.AuthService, o *oidcService.OIDCService) *authHandler {
+	return &authHandler{s, a, o}
 }
 
 func (h *authHandler) Login(c *gin.Context) {
+	if h.oidcService.PasswordLoginDisabled() {
+		api.ResponseError(c, api.ErrorUnauthorized, fmt.Errorf("password login disabled"))
+		return
+	}
 	username := c.PostForm("username")
 	password := c.PostForm("password")
 	if username == "" {
@@ -38,56 +43,38 @@ func (h *authHandler) Login(c *gin.Context) {
 		return
 	}
 
-	user, err := h.userService.FindByUsername(username)
+	user, err := h.authService.Login(c.Request.Context(), username, password, c.ClientIP())
 	if err != nil {
-		if err == gorm.ErrRecordNotFound {
-			api.ResponseError(c, api.ErrorUnauthorized, fmt.Errorf("username not found"))
+		if responseThrottled(c, err) {
 			return
 		}
-		api.ResponseError(c, api.ErrorUnauthorized, fmt.Errorf("FindByUsername err: %w", err))
-		return
-	}
-
-	if !checkPassword(password, user.Hash) {
-		logger.Infof("User login failed.")
-		api.ResponseError(c, api.ErrorUnauthorized, fmt.Errorf("username or password is incorrect"))
+		if errors.Is(err, authService.ErrInvalidPassword) {
+			logger.Infof("User login failed.")
+			api.ResponseError(c, api.ErrorUnauthorized, err)
+			return
+		}
+		logger.Errorf("authenticate err: %s", err.Error())
+		api.ResponseError(c, api.ErrorUnauthorized, fmt.Errorf("login failed"))
 		return
 	}
 
-	user = issueToken(user)
-	err = h.userService.Save(user)
+	token, err := h.userService.CreateSession(user)
 	if err != nil {
-		logger.Errorf("update token err: %s", err.Error())
-		api.ResponseError(c, api.ErrorInternal, fmt.Errorf("token save err: %w", err))
+		logger.Errorf("create session err: %s", err.Error())
+		api.ResponseError(c, api.ErrorInternal, fmt.Errorf("session save err: %w", err))
 		return
 	}
 
 	logger.Infof("user '%s' logged in successfully.", user.Username)
 	c.JSON(200, gin.H{
 		"message":  "You are logged in.",
-		"token":    user.Token,
+		"token":    token,
 		"userID":   user.ID,
 		"username": user.Username,
+		"role":     user.GetRole(),
 	})
 }
 
-func checkPassword(plain string, hashed string) bool {
-	return bcrypt.CompareHashAndPassword([]byte(hashed), []byte(plain)) == nil
-}
-
-func issueToken(user model.User) model.User {
-	if user.Token != "" && user.TokenExpires.After(time.Now()) {
-		user.TokenExpires = time.Now().Add(48 * time.Hour)
-	} else {
-		b := make([]byte, 16)
-		_, _ = rand.Read(b)
-		token := fmt.Sprintf("%x", b)
-		user.Token = token
-		user.TokenExpires = time.Now().Add(48 * time.Hour)
-	}
-	return user
-}
-
 func (h *authHandler) Logout(c *gin.Context) {
 	//deleteTokenIfWeCan(c)
 	// token delete if we can
@@ -99,14 +86,7 @@ func (h *authHandler) Logout(c *gin.Context) {
 	tokenFromHeader = strings.TrimPrefix(tokenFromHeader, "Bearer ")
 	userID := c.GetHeader("UserID")
 
-	user, err := h.userService.FindByUserIdAndToken(userID, tokenFromHeader)
-	if err != nil {
-		return
-	}
-
-	user.Token = ""
-	user.TokenExpires = time.Now().Add(-480 * time.Hour)
-	err = h.userService.Save(user)
+	err := h.userService.DeleteSession(userID, tokenFromHeader)
 	if err != nil {
 		return
 	}
@@ -115,23 +95,39 @@ func (h *authHandler) Logout(c *gin.Context) {
 	})
 }
 
-// TODO: add to handler routes check if we have to this on method
-// only checks userid, Bearer tokene exist. not validation.
-func (h *authHandler) HeaderRequired(c *gin.Context) {
-	token := c.GetHeader("Authorization")
-	if token == "" || !strings.HasPrefix(token, "Bearer ") {
-		c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{
-			"message": "valid token required",
-		})
+// POST /auth/password
+// ChangePassword changes the password of the current user, and logs out its other sessions.
+func (h *authHandler) ChangePassword(c *gin.Context) {
+	access, ok := api.GetAccess(c)
+	if !ok {
+		api.ResponseError(c, api.ErrorUnauthorized, fmt.Errorf("token required"))
 		return
 	}
-
-	userID := c.GetHeader("UserID")
-	if userID == "" {
-		c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{
-			"message": "userId required",
-		})
+	token := strings.TrimPrefix(c.GetHeader("Authorization"), "Bearer ")
+	err := h.authService.ChangePassword(access.User, c.PostForm("currentPassword"), c.PostForm("newPassword"), token, c.ClientIP())
+	if err != nil {
+		if responseThrottled(c, err) {
+			return
+		}
+		if errors.Is(err, userService.ErrWrongPassword) {
+			api.ResponseError(c, api.ErrorUnauthorized, err)
+			return
+		}
+		api.ResponseError(c, api.ErrorBadData, err)
 		return
 	}
-	c.Next()
+	c.JSON(200, gin.H{
+		"message": "Your password is changed.",
+	})
+}
+
+// responseThrottled responds 429 with Retry-After to a throttled password, and returns whether it did.
+func responseThrottled(c *gin.Context, err error) bool {
+	var throttled *authService.ThrottledError
+	if !errors.As(err, &throttled) {
+		return false
+	}
+	c.Header("Retry-After", strconv.Itoa(int(math.Ceil(throttled.RetryAfter.Seconds()))))
+	api.ResponseError(c, api.ErrorTooMany, err)
+	return true
 }
@@ -0,0 +1,86 @@
+package handler
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/bcrypt"
+
+	"github.com/kuoss/venti/pkg/model"
+	authService "github.com/kuoss/venti/pkg/service/auth"
+)
+
+func TestLogin(t *testing.T) {
+	hash, err := bcrypt.GenerateFromPassword([]byte("secret"), bcrypt.MinCost)
+	require.NoError(t, err)
+	u, err := services.UserService.FindByUsername("login-local")
+	if err != nil {
+		u = model.User{Username: "login-local"}
+	}
+	u.Hash = string(hash)
+	require.NoError(t, services.UserService.Save(u))
+
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.POST("/auth/login", handlers.authHandler.Login)
+
+	testCases := []struct {
+		name     string
+		form     string
+		wantCode int
+		wantBody string
+	}{
+		{"no username", "password=secret", 401, `{"error":"username is empty","errorType":"unauthorized","status":"error"}`},
+		{"no password", "username=login-local", 401, `{"error":"password is empty","errorType":"unauthorized","status":"error"}`},
+		{"not found", "username=login-nobody&password=secret", 401, `{"error":"username or password is incorrect","errorType":"unauthorized","status":"error"}`},
+		{"wrong password", "username=login-local&password=wrong", 401, `{"error":"username or password is incorrect","errorType":"unauthorized","status":"error"}`},
+		{"ok", "username=login-local&password=secret", 200, ""},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			req, _ := http.NewRequest("POST", "/auth/login", strings.NewReader(tc.form))
+			req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+			r.ServeHTTP(w, req)
+			assert.Equal(t, tc.wantCode, w.Code)
+			if tc.wantBody != "" {
+				assert.JSONEq(t, tc.wantBody, w.Body.String())
+			} else {
+				assert.Contains(t, w.Body.String(), `"username":"login-local"`)
+			}
+		})
+	}
+}
+
+func TestLoginThrottled(t *testing.T) {
+	guard, err := authService.NewGuard(model.LoginConfig{MaxFailures: 2, BaseDelay: time.Minute})
+	require.NoError(t, err)
+	h := NewAuthHandler(services.UserService, authService.New(services.UserService, nil, guard), services.OIDCService)
+
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.POST("/auth/login", h.Login)
+	login := func(form string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("POST", "/auth/login", strings.NewReader(form))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		r.ServeHTTP(w, req)
+		return w
+	}
+
+	w := login("username=throttled&password=wrong")
+	assert.Equal(t, 401, w.Code)
+	assert.JSONEq(t, `{"error":"username or password is incorrect","errorType":"unauthorized","status":"error"}`, w.Body.String())
+
+	// the next try waits for the delay, even with the right password
+	w = login("username=throttled&password=secret")
+	assert.Equal(t, 429, w.Code)
+	assert.Equal(t, "60", w.Header().Get("Retry-After"))
+	assert.JSONEq(t, `{"error":"too many failed logins, retry after 1m0s","errorType":"too_many","status":"error"}`, w.Body.String())
+}
@@ -4,18 +4,58 @@ import (
 	"net/http"
 
 	"github.com/gin-gonic/gin"
+
+	"github.com/kuoss/venti/pkg/handler/api"
+	"github.com/kuoss/venti/pkg/model"
 	"github.com/kuoss/venti/pkg/service/dashboard"
+	dsService "github.com/kuoss/venti/pkg/service/datasource"
 )
 
 type dashboardHandler struct {
-	dashboardService *dashboard.DashboardService
+	dashboardService  *dashboard.DashboardService
+	datasourceService *dsService.DatasourceService
 }
 
-func NewDashboardHandler(s *dashboard.DashboardService) *dashboardHandler {
-	return &dashboardHandler{s}
+func NewDashboardHandler(s *dashboard.DashboardService, datasourceService *dsService.DatasourceService) *dashboardHandler {
+	return &dashboardHandler{s, datasourceService}
 }
 
 // GET /dashboards
+// A dashboard is left out when the user cannot use any datasource of a type that its panels query.
 func (h *dashboardHandler) Dashboards(c *gin.Context) {
-	c.JSON(http.StatusOK, h.dashboardService.Dashboards())
+	access, _ := api.GetAccess(c)
+	if access.AllDatasources() {
+		c.JSON(http.StatusOK, h.dashboardService.Dashboards())
+		return
+	}
+	deniedTypes := map[model.DatasourceType]bool{}
+	for _, datasource := range h.datasourceService.GetDatasources() {
+		deniedTypes[datasource.Type] = true
+	}
+	for _, datasource := range access.FilterDatasources(h.datasourceService.GetDatasources()) {
+		deniedTypes[datasource.Type] = false
+	}
+	dashboards := []model.Dashboard{}
+	for _, d := range h.dashboardService.Dashboards() {
+		if allowsDashboard(d, deniedTypes) {
+			dashboards = append(dashboards, d)
+		}
+	}
+	c.JSON(http.StatusOK, dashboards)
+}
+
+// allowsDashboard returns whether no panel is denied, the logs panels querying lethe and the others prometheus.
+func allowsDashboard(d model.Dashboard, deniedTypes map[model.DatasourceType]bool) bool {
+	for _, row := range d.Rows {
+		for _, panel := range row.Panels {
+			typ := model.DatasourceTypePrometheus
+			if panel.Type == "logs" {
+				typ = model.DatasourceTypeLethe
+			}
+			if deniedTypes[typ] {
+				return false
+			}
+		}
+	}
+	return true
 }
@@ -21,13 +21,15 @@ func NewDatasourceHandler(datasourceService *dsService.DatasourceService, remote
 
 // GET /datasources
 func (h *datasourceHandler) Datasources(c *gin.Context) {
-	c.JSON(http.StatusOK, h.datasourceService.GetDatasources())
+	access, _ := api.GetAccess(c)
+	c.JSON(http.StatusOK, access.FilterDatasources(h.datasourceService.GetDatasources()))
 }
 
 // GET /datasources/targets
 func (h *datasourceHandler) Targets(c *gin.Context) {
+	access, _ := api.GetAccess(c)
 	var results []string
-	for _, datasource := range h.datasourceService.GetDatasources() {
+	for _, datasource := range access.FilterDatasources(h.datasourceService.GetDatasources()) {
 		_, body, err := h.remoteService.GET(c.Request.Context(), &datasource, remote.ActionTargets, "state=active")
 		if err != nil {
 			body = fmt.Sprintf(`{"status":"error","errorType":"%s","error":%q}`, api.ErrorExec, err.Error())
@@ -52,6 +54,10 @@ func (h *datasourceHandler) TargetByName(c *gin.Context) {
 		c.JSON(400, gin.H{"msg": err})
 		return
 	}
+	if access, _ := api.GetAccess(c); !access.AllowsDatasource(datasource) {
+		api.ResponseError(c, api.ErrorForbidden, fmt.Errorf("datasource not allowed: %s", uri.Name))
+		return
+	}
 	_, body, err := h.remoteService.GET(c.Request.Context(), &datasource, remote.ActionTargets, "state=active")
 	if err != nil {
 		body = fmt.Sprintf(`{"status":"error","errorType":"%s","error":%q}`, api.ErrorExec, err.Error())
@@ -74,6 +80,10 @@ func (h *datasourceHandler) HealthyByName(c *gin.Context) {
 		c.JSON(400, gin.H{"msg": err})
 		return
 	}
+	if access, _ := api.GetAccess(c); !access.AllowsDatasource(datasource) {
+		api.ResponseError(c, api.ErrorForbidden, fmt.Errorf("datasource not allowed: %s", uri.Name))
+		return
+	}
 	_, body, err := h.remoteService.GET(c.Request.Context(), &datasource, remote.ActionHealthy, "")
 	if err != nil {
 		body = fmt.Sprintf(`{"status":"error","errorType":"%s","error":%q}`, api.ErrorExec, err.Error())
@@ -7,8 +7,8 @@ import (
 	"github.com/gin-gonic/gin"
 )
 
-func handleSPA() gin.HandlerFunc {
-	directory := static.LocalFile("./web/dist", true)
+func handleSPA(webRoot string) gin.HandlerFunc {
+	directory := static.LocalFile(webRoot, true)
 	fileserver := http.StripPrefix("/", http.FileServer(directory))
 	return func(c *gin.Context) {
 		if !directory.Exists("/", c.Request.URL.Path) {
@@ -7,22 +7,30 @@ import (
 
 type Handlers struct {
 	alertHandler      *alertHandler
+	apiKeyHandler     *apiKeyHandler
 	authHandler       *authHandler
 	dashboardHandler  *dashboardHandler
 	datasourceHandler *datasourceHandler
+	oidcHandler       *oidcHandler
 	probeHandler      *probeHandler
+	reloadHandler     *reloadHandler
 	remoteHandler     *remote.RemoteHandler
 	statusHandler     *statusHandler
+	userHandler       *userHandler
 }
 
-func loadHandlers(services *service.Services) *Handlers {
+func loadHandlers(services *service.Services, reload func() error) *Handlers {
 	return &Handlers{
-		NewAlertHandler(services.AlertRuleService, services.AlertingService),
-		NewAuthHandler(services.UserService),
-		NewDashboardHandler(services.DashboardService),
+		NewAlertHandler(services.AlertRuleService, services.AlertingService, services.DatasourceService),
+		NewAPIKeyHandler(services.UserService),
+		NewAuthHandler(services.UserService, services.AuthService, services.OIDCService),
+		NewDashboardHandler(services.DashboardService, services.DatasourceService),
 		NewDatasourceHandler(services.DatasourceService, services.RemoteService),
+		NewOIDCHandler(services.OIDCService, services.UserService),
 		NewProbeHandler(),
+		NewReloadHandler(reload),
 		remote.New(services.DatasourceService, services.RemoteService),
 		NewStatusHandler(services.StatusService),
+		NewUserHandler(services.UserService),
 	}
 }
@@ -22,12 +22,14 @@ func TestLoadHandlers(t *testing.T) {
 	assert.NoError(t, err)
 	assert.NotEmpty(t, services)
 
-	handlers := loadHandlers(services)
+	handlers := loadHandlers(services, func() error { return nil })
 	assert.NotEmpty(t, handlers)
 	assert.NotEmpty(t, handlers.alertHandler)
 	assert.NotEmpty(t, handlers.authHandler)
 	assert.NotEmpty(t, handlers.dashboardHandler)
 	assert.NotEmpty(t, handlers.datasourceHandler)
+	assert.NotNil(t, handlers.oidcHandler)
+	assert.NotEmpty(t, handlers.reloadHandler)
 	assert.NotEmpty(t, handlers.remoteHandler)
 	assert.NotEmpty(t, handlers.statusHandler)
 }
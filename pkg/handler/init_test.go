@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"os"
 	"testing"
+	"time"
 
 	"github.com/kuoss/venti/pkg/config"
 	"github.com/kuoss/venti/pkg/mocker"
@@ -17,7 +18,11 @@ var (
 	handlers         *Handlers
 	alertmanagerMock *mocker.Server
 	cfg              = &config.Config{
-		AppInfo:    model.AppInfo{Version: "Unknown"},
+		AppInfo: model.AppInfo{Version: "Unknown"},
+		// the tests log in many times with wrong passwords from the same address
+		GlobalConfig: model.GlobalConfig{
+			Login: model.LoginConfig{MaxFailures: 100, IPMaxFailures: 1000, BaseDelay: time.Nanosecond, MaxDelay: time.Nanosecond},
+		},
 		UserConfig: model.UserConfig{},
 		DatasourceConfig: model.DatasourceConfig{
 			Datasources: []model.Datasource{
@@ -62,7 +67,7 @@ func setup() {
 	if err != nil {
 		panic(err)
 	}
-	handlers = loadHandlers(services)
+	handlers = loadHandlers(services, nil)
 }
 
 func shutdown() {
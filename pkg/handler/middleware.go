@@ -1,31 +1,89 @@
 package handler
 
 import (
+	"errors"
 	"fmt"
 	"strings"
 
 	"github.com/gin-gonic/gin"
+
 	"github.com/kuoss/venti/pkg/handler/api"
+	"github.com/kuoss/venti/pkg/model"
+	userService "github.com/kuoss/venti/pkg/service/user"
 )
 
-func tokenRequired() gin.HandlerFunc {
+// tokenRequired authenticates the request by the bearer token and the UserID header, as issued by POST /auth/login,
+// or by an API key as the bearer token alone.
+// An unauthenticated request gets 401 in the error format of the Prometheus API.
+// The access of the user is kept in the context for roleRequired and the handlers.
+func tokenRequired(s *userService.UserService) gin.HandlerFunc {
 	return func(c *gin.Context) {
-		token := c.GetHeader("Authorization")
-		if token == "" || !strings.HasPrefix(token, "Bearer ") {
-			api.ResponseError(c, api.ErrorUnauthorized, fmt.Errorf("token required"))
+		user, err := authenticate(c, s)
+		if err != nil {
+			api.ResponseError(c, api.ErrorUnauthorized, err)
 			c.Abort()
+			return
 		}
-		at := strings.Split(token, " ")
-		if len(at) != 2 {
-			api.ResponseError(c, api.ErrorUnauthorized, fmt.Errorf("valid token required"))
+		api.SetAccess(c, s.Access(user))
+		c.Next()
+	}
+}
+
+// roleRequired lets the users with the role or a higher one through, after tokenRequired.
+func roleRequired(role model.Role) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		access, ok := api.GetAccess(c)
+		if !ok {
+			api.ResponseError(c, api.ErrorUnauthorized, fmt.Errorf("token required"))
 			c.Abort()
+			return
 		}
-		token = at[1]
-		// fixme: decide token
-		if token != "fixme" {
-			api.ResponseError(c, api.ErrorUnauthorized, fmt.Errorf("valid token required"))
+		if !access.HasRole(role) {
+			api.ResponseError(c, api.ErrorForbidden, fmt.Errorf("role %s required", role))
 			c.Abort()
+			return
 		}
 		c.Next()
 	}
 }
+
+func authenticate(c *gin.Context, s *userService.UserService) (model.User, error) {
+	token, ok := strings.CutPrefix(c.GetHeader("Authorization"), "Bearer ")
+	if !ok || token == "" {
+		return model.User{}, fmt.Errorf("token required")
+	}
+	if strings.HasPrefix(token, userService.APIKeyPrefix) {
+		return authenticateAPIKey(s, token)
+	}
+	userID := c.GetHeader("UserID")
+	if userID == "" {
+		return model.User{}, fmt.Errorf("userID required")
+	}
+	user, err := s.FindBySession(userID, token)
+	if err != nil {
+		switch {
+		case errors.Is(err, userService.ErrSessionNotFound):
+			return model.User{}, fmt.Errorf("valid token required")
+		case errors.Is(err, userService.ErrSessionExpired):
+			return model.User{}, fmt.Errorf("token expired")
+		case errors.Is(err, userService.ErrUserDisabled):
+			return model.User{}, fmt.Errorf("user disabled")
+		}
+		return model.User{}, fmt.Errorf("FindBySession err: %w", err)
+	}
+	return user, nil
+}
+
+func authenticateAPIKey(s *userService.UserService, token string) (model.User, error) {
+	key, err := s.FindAPIKey(token)
+	if err != nil {
+		switch {
+		case errors.Is(err, userService.ErrAPIKeyNotFound):
+			return model.User{}, fmt.Errorf("valid token required")
+		case errors.Is(err, userService.ErrAPIKeyExpired):
+			return model.User{}, fmt.Errorf("token expired")
+		}
+		return model.User{}, fmt.Errorf("FindAPIKey err: %w", err)
+	}
+	return key.User(), nil
+}
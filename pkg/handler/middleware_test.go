@@ -1,51 +1,111 @@
 package handler
 
 import (
+	"crypto/sha256"
+	"fmt"
 	"net/http"
 	"net/http/httptest"
+	"strconv"
 	"testing"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/kuoss/venti/pkg/handler/api"
+	"github.com/kuoss/venti/pkg/model"
+	"github.com/kuoss/venti/pkg/service/user"
 )
 
-func TestInvalidToken(t *testing.T) {
+// setTestUser saves a user with the role, and returns its ID and the token of a new session that expires at tokenExpires.
+func setTestUser(t *testing.T, username string, role model.Role, tokenExpires time.Time) (string, string) {
+	u, err := services.UserService.FindByUsername(username)
+	if err != nil {
+		u = model.User{Username: username}
+	}
+	u.Role = role
+	require.NoError(t, services.UserService.Save(u))
+	u, err = services.UserService.FindByUsername(username)
+	require.NoError(t, err)
+	token, err := services.UserService.CreateSession(u)
+	require.NoError(t, err)
+	err = services.UserService.DB().Model(&model.Session{}).Where("hash = ?", fmt.Sprintf("%x", sha256.Sum256([]byte(token)))).Update("expires_at", tokenExpires).Error
+	require.NoError(t, err)
+	return strconv.Itoa(u.ID), token
+}
+
+func TestTokenRequired(t *testing.T) {
+	validID, token1 := setTestUser(t, "middleware-valid", model.RoleNone, time.Now().Add(time.Hour))
+	expiredID, token2 := setTestUser(t, "middleware-expired", model.RoleNone, time.Now().Add(-time.Hour))
 
 	gin.SetMode(gin.TestMode)
-	r := gin.Default()
-	r.GET("/test", tokenRequired(), func(c *gin.Context) {
-		c.JSON(http.StatusOK, gin.H{
-			"message": "test",
-		})
+	r := gin.New()
+	r.GET("/test", tokenRequired(services.UserService), func(c *gin.Context) {
+		access, _ := api.GetAccess(c)
+		c.JSON(http.StatusOK, gin.H{"message": "test", "username": access.User.Username})
 	})
 
-	w := httptest.NewRecorder()
-
-	req, _ := http.NewRequest("GET", "/test", nil)
-	req.Header.Set("Authorization", "Bearer INVALID")
-	r.ServeHTTP(w, req)
-
-	assert.Equal(t, http.StatusUnauthorized, w.Code)
-	assert.Equal(t, "{\"error\":\"valid token required\",\"errorType\":\"unauthorized\",\"status\":\"error\"}", w.Body.String())
+	testCases := []struct {
+		name          string
+		authorization string
+		userID        string
+		wantCode      int
+		wantBody      string
+	}{
+		{"no header", "", "", 401, `{"error":"token required","errorType":"unauthorized","status":"error"}`},
+		{"no bearer", token1, validID, 401, `{"error":"token required","errorType":"unauthorized","status":"error"}`},
+		{"empty token", "Bearer ", validID, 401, `{"error":"token required","errorType":"unauthorized","status":"error"}`},
+		{"no userID", "Bearer " + token1, "", 401, `{"error":"userID required","errorType":"unauthorized","status":"error"}`},
+		{"invalid token", "Bearer INVALID", validID, 401, `{"error":"valid token required","errorType":"unauthorized","status":"error"}`},
+		{"other user", "Bearer " + token1, expiredID, 401, `{"error":"valid token required","errorType":"unauthorized","status":"error"}`},
+		{"expired", "Bearer " + token2, expiredID, 401, `{"error":"token expired","errorType":"unauthorized","status":"error"}`},
+		{"valid", "Bearer " + token1, validID, 200, `{"message":"test","username":"middleware-valid"}`},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			req, _ := http.NewRequest("GET", "/test", nil)
+			req.Header.Set("Authorization", tc.authorization)
+			req.Header.Set("UserID", tc.userID)
+			r.ServeHTTP(w, req)
+			assert.Equal(t, tc.wantCode, w.Code)
+			assert.Equal(t, tc.wantBody, w.Body.String())
+		})
+	}
 }
 
-func TestValidToken(t *testing.T) {
-
+func TestRoleRequired(t *testing.T) {
 	gin.SetMode(gin.TestMode)
-	r := gin.Default()
-	r.GET("/test", tokenRequired(), func(c *gin.Context) {
-		c.JSON(http.StatusOK, gin.H{
-			"message": "test",
+	testCases := []struct {
+		name     string
+		user     *model.User
+		role     model.Role
+		wantCode int
+		wantBody string
+	}{
+		{"no access", nil, model.RoleViewer, 401, `{"error":"token required","errorType":"unauthorized","status":"error"}`},
+		{"no role", &model.User{}, model.RoleViewer, 200, `{"message":"test"}`},
+		{"viewer", &model.User{Role: model.RoleViewer}, model.RoleEditor, 403, `{"error":"role editor required","errorType":"forbidden","status":"error"}`},
+		{"editor", &model.User{Role: model.RoleEditor}, model.RoleEditor, 200, `{"message":"test"}`},
+		{"editor for admin", &model.User{Role: model.RoleEditor}, model.RoleAdmin, 403, `{"error":"role admin required","errorType":"forbidden","status":"error"}`},
+		{"isAdmin", &model.User{IsAdmin: true}, model.RoleAdmin, 200, `{"message":"test"}`},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			r := gin.New()
+			r.GET("/test", func(c *gin.Context) {
+				if tc.user != nil {
+					api.SetAccess(c, user.NewAccess(*tc.user, nil, ""))
+				}
+			}, roleRequired(tc.role), func(c *gin.Context) {
+				c.JSON(http.StatusOK, gin.H{"message": "test"})
+			})
+			w := httptest.NewRecorder()
+			req, _ := http.NewRequest("GET", "/test", nil)
+			r.ServeHTTP(w, req)
+			assert.Equal(t, tc.wantCode, w.Code)
+			assert.Equal(t, tc.wantBody, w.Body.String())
 		})
-	})
-
-	w := httptest.NewRecorder()
-
-	req, _ := http.NewRequest("GET", "/test", nil)
-
-	req.Header.Set("Authorization", "Bearer fixme")
-	r.ServeHTTP(w, req)
-
-	assert.Equal(t, http.StatusOK, w.Code)
-	assert.Equal(t, "{\"message\":\"test\"}", w.Body.String())
+	}
 }
@@ -0,0 +1,116 @@
+package handler
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/kuoss/common/logger"
+
+	"github.com/kuoss/venti/pkg/handler/api"
+	oidcService "github.com/kuoss/venti/pkg/service/oidc"
+	userService "github.com/kuoss/venti/pkg/service/user"
+)
+
+const (
+	oidcProvider    = "oidc"
+	oidcStateCookie = "venti_oidc_state"
+)
+
+type oidcHandler struct {
+	oidcService *oidcService.OIDCService
+	userService *userService.UserService
+}
+
+func NewOIDCHandler(o *oidcService.OIDCService, u *userService.UserService) *oidcHandler {
+	return &oidcHandler{o, u}
+}
+
+// GET /auth/methods
+// Methods tells the login page how the users can log in.
+func (h *oidcHandler) Methods(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{
+		"password": !h.oidcService.PasswordLoginDisabled(),
+		"oidc":     h.oidcService != nil,
+	})
+}
+
+// GET /auth/oidc/login
+// Login sends the user to the provider, with the state in a cookie to bind the callback to the browser.
+func (h *oidcHandler) Login(c *gin.Context) {
+	if h.oidcService == nil {
+		api.ResponseError(c, api.ErrorNotFound, fmt.Errorf("oidc not configured"))
+		return
+	}
+	state, authURL, err := h.oidcService.AuthCodeURL(c.Request.Context())
+	if err != nil {
+		logger.Errorf("AuthCodeURL err: %s", err)
+		api.ResponseError(c, api.ErrorUnavailable, fmt.Errorf("oidc provider unavailable"))
+		return
+	}
+	c.SetSameSite(http.SameSiteLaxMode)
+	c.SetCookie(oidcStateCookie, state, 600, "/auth/oidc", "", c.Request.TLS != nil, true)
+	c.Redirect(http.StatusFound, authURL)
+}
+
+// GET /auth/oidc/callback
+// Callback logs in the user from the provider, and sends it to the login page with the token in the fragment,
+// or with the error, as the login page gets them from POST /auth/login.
+func (h *oidcHandler) Callback(c *gin.Context) {
+	if h.oidcService == nil {
+		api.ResponseError(c, api.ErrorNotFound, fmt.Errorf("oidc not configured"))
+		return
+	}
+	c.SetSameSite(http.SameSiteLaxMode)
+	c.SetCookie(oidcStateCookie, "", -1, "/auth/oidc", "", c.Request.TLS != nil, true)
+
+	if errorCode := c.Query("error"); errorCode != "" {
+		logger.Infof("oidc login failed: %s %s", errorCode, c.Query("error_description"))
+		redirectToLogin(c, url.Values{"error": {"login failed: " + errorCode}})
+		return
+	}
+	state := c.Query("state")
+	cookie, err := c.Cookie(oidcStateCookie)
+	if err != nil || state == "" || cookie != state {
+		redirectToLogin(c, url.Values{"error": {"invalid state"}})
+		return
+	}
+	identity, err := h.oidcService.Exchange(c.Request.Context(), state, c.Query("code"))
+	if err != nil {
+		logger.Infof("oidc login failed: %s", err)
+		redirectToLogin(c, url.Values{"error": {"login failed"}})
+		return
+	}
+	user, err := h.userService.ProvisionUser(identity.Username, oidcProvider, identity.Groups, identity.Role)
+	if err != nil {
+		logger.Infof("oidc login failed: %s", err)
+		redirectToLogin(c, url.Values{"error": {"login failed"}})
+		return
+	}
+	if user.Disabled {
+		logger.Infof("oidc login failed: user '%s' disabled", user.Username)
+		redirectToLogin(c, url.Values{"error": {"user disabled"}})
+		return
+	}
+
+	token, err := h.userService.CreateSession(user)
+	if err != nil {
+		logger.Errorf("create session err: %s", err.Error())
+		redirectToLogin(c, url.Values{"error": {"token save failed"}})
+		return
+	}
+	logger.Infof("user '%s' logged in successfully by oidc.", user.Username)
+	redirectToLogin(c, url.Values{
+		"token":    {token},
+		"userID":   {strconv.Itoa(user.ID)},
+		"username": {user.Username},
+		"role":     {string(user.GetRole())},
+	})
+}
+
+// redirectToLogin keeps the values in the fragment, so that they are not sent to the server nor kept in its logs.
+func redirectToLogin(c *gin.Context, values url.Values) {
+	c.Redirect(http.StatusFound, "/login#"+values.Encode())
+}
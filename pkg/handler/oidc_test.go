@@ -0,0 +1,173 @@
+package handler
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	mockerOIDC "github.com/kuoss/venti/pkg/mocker/oidc"
+	"github.com/kuoss/venti/pkg/model"
+	oidcService "github.com/kuoss/venti/pkg/service/oidc"
+)
+
+func newOIDCRouter(t *testing.T, disablePasswordLogin bool) (*gin.Engine, *mockerOIDC.Issuer) {
+	issuer, err := mockerOIDC.New("venti")
+	require.NoError(t, err)
+	t.Cleanup(issuer.Close)
+
+	o := oidcService.New(&model.OIDCConfig{
+		Issuer:               issuer.URL,
+		ClientID:             "venti",
+		RedirectURL:          "http://venti.example.com/auth/oidc/callback",
+		Scopes:               []string{"openid"},
+		GroupsClaim:          "groups",
+		GroupRoles:           map[string]model.Role{"ops": model.RoleAdmin, "dev": model.RoleEditor},
+		DisablePasswordLogin: disablePasswordLogin,
+	})
+	h := NewOIDCHandler(o, services.UserService)
+	a := NewAuthHandler(services.UserService, services.AuthService, o)
+
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.POST("/auth/login", a.Login)
+	r.GET("/auth/methods", h.Methods)
+	r.GET("/auth/oidc/login", h.Login)
+	r.GET("/auth/oidc/callback", h.Callback)
+	return r, issuer
+}
+
+// oidcLogin starts a login at the router, authorizes at the issuer, and returns the callback request with the state cookie.
+func oidcLogin(t *testing.T, r *gin.Engine) *http.Request {
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/auth/oidc/login", nil)
+	r.ServeHTTP(w, req)
+	require.Equal(t, http.StatusFound, w.Code)
+	cookies := w.Result().Cookies()
+	require.Len(t, cookies, 1)
+	require.Equal(t, "venti_oidc_state", cookies[0].Name)
+	require.True(t, cookies[0].HttpOnly)
+
+	client := &http.Client{CheckRedirect: func(*http.Request, []*http.Request) error { return http.ErrUseLastResponse }}
+	resp, err := client.Get(w.Header().Get("Location"))
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	require.Equal(t, http.StatusFound, resp.StatusCode)
+	callback, err := url.Parse(resp.Header.Get("Location"))
+	require.NoError(t, err)
+
+	req, _ = http.NewRequest("GET", callback.RequestURI(), nil)
+	req.AddCookie(cookies[0])
+	return req
+}
+
+func loginFragment(t *testing.T, w *httptest.ResponseRecorder) url.Values {
+	require.Equal(t, http.StatusFound, w.Code)
+	fragment, ok := strings.CutPrefix(w.Header().Get("Location"), "/login#")
+	require.True(t, ok, w.Header().Get("Location"))
+	values, err := url.ParseQuery(fragment)
+	require.NoError(t, err)
+	return values
+}
+
+func TestOIDCLogin(t *testing.T) {
+	r, issuer := newOIDCRouter(t, false)
+	issuer.SetClaims(map[string]any{"preferred_username": "oidc-alice", "groups": []string{"dev"}})
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, oidcLogin(t, r))
+	values := loginFragment(t, w)
+	require.Equal(t, "oidc-alice", values.Get("username"))
+	require.Equal(t, "editor", values.Get("role"))
+
+	user, err := services.UserService.FindBySession(values.Get("userID"), values.Get("token"))
+	require.NoError(t, err)
+	require.Equal(t, "oidc", user.Provider)
+	require.Equal(t, []string{"dev"}, user.Groups)
+	require.Equal(t, model.RoleEditor, user.GetRole())
+
+	// the groups and the role follow the provider at the next login
+	issuer.SetClaims(map[string]any{"preferred_username": "oidc-alice", "groups": []string{"ops"}})
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, oidcLogin(t, r))
+	values = loginFragment(t, w)
+	require.Equal(t, strconv.Itoa(user.ID), values.Get("userID"))
+	require.Equal(t, "admin", values.Get("role"))
+}
+
+func TestOIDCLogin_failed(t *testing.T) {
+	r, issuer := newOIDCRouter(t, false)
+	setTestUser(t, "oidc-local", model.RoleViewer, time.Time{})
+
+	testCases := []struct {
+		name      string
+		claims    map[string]any
+		request   func(req *http.Request)
+		wantError string
+	}{
+		{"no role", map[string]any{"preferred_username": "oidc-bob", "groups": []string{"other"}}, nil, "login failed"},
+		{"local user", map[string]any{"preferred_username": "oidc-local", "groups": []string{"ops"}}, nil, "login failed"},
+		{"no cookie", map[string]any{"preferred_username": "oidc-bob", "groups": []string{"ops"}}, func(req *http.Request) { req.Header.Del("Cookie") }, "invalid state"},
+		{"other state", map[string]any{"preferred_username": "oidc-bob", "groups": []string{"ops"}}, func(req *http.Request) {
+			query := req.URL.Query()
+			query.Set("state", "OTHER")
+			req.URL.RawQuery = query.Encode()
+		}, "invalid state"},
+		{"provider error", nil, func(req *http.Request) { req.URL.RawQuery = "error=access_denied" }, "login failed: access_denied"},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			issuer.SetClaims(tc.claims)
+			req := oidcLogin(t, r)
+			if tc.request != nil {
+				tc.request(req)
+			}
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, req)
+			values := loginFragment(t, w)
+			require.Equal(t, url.Values{"error": {tc.wantError}}, values)
+		})
+	}
+	_, err := services.UserService.FindByUsername("oidc-bob")
+	require.Error(t, err)
+}
+
+func TestOIDCMethods(t *testing.T) {
+	r, _ := newOIDCRouter(t, true)
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/auth/methods", nil)
+	r.ServeHTTP(w, req)
+	assert.Equal(t, 200, w.Code)
+	assert.JSONEq(t, `{"oidc":true,"password":false}`, w.Body.String())
+
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest("POST", "/auth/login", strings.NewReader("username=admin&password=admin"))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	r.ServeHTTP(w, req)
+	assert.Equal(t, 401, w.Code)
+	assert.JSONEq(t, `{"error":"password login disabled","errorType":"unauthorized","status":"error"}`, w.Body.String())
+
+	// without oidc
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest("GET", "/auth/methods", nil)
+	handlers.oidcHandler.Methods(ginContext(w, req))
+	assert.JSONEq(t, `{"oidc":false,"password":true}`, w.Body.String())
+
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest("GET", "/auth/oidc/login", nil)
+	handlers.oidcHandler.Login(ginContext(w, req))
+	assert.Equal(t, 404, w.Code)
+}
+
+func ginContext(w http.ResponseWriter, req *http.Request) *gin.Context {
+	c, _ := gin.CreateTestContext(w)
+	c.Request = req
+	return c
+}
@@ -0,0 +1,29 @@
+package handler
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+type reloadHandler struct {
+	reload func() error
+}
+
+func NewReloadHandler(reload func() error) *reloadHandler {
+	return &reloadHandler{reload}
+}
+
+// POST /-/reload
+// https://prometheus.io/docs/prometheus/latest/management_api/#reload
+func (h *reloadHandler) Reload(c *gin.Context) {
+	if h.reload == nil {
+		c.String(http.StatusServiceUnavailable, "Reload is not available.\n")
+		return
+	}
+	if err := h.reload(); err != nil {
+		c.String(http.StatusInternalServerError, "failed to reload config: %s\n", err)
+		return
+	}
+	c.Status(http.StatusOK)
+}
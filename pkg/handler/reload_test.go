@@ -0,0 +1,36 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestReload(t *testing.T) {
+	testCases := []struct {
+		reload   func() error
+		wantCode int
+		wantBody string
+	}{
+		{nil, 503, "Reload is not available.\n"},
+		{func() error { return nil }, 200, ""},
+		{func() error { return errors.New("fake error") }, 500, "failed to reload config: fake error\n"},
+	}
+	for _, tc := range testCases {
+		t.Run("", func(t *testing.T) {
+			router := gin.New()
+			router.POST("/-/reload", NewReloadHandler(tc.reload).Reload)
+
+			w := httptest.NewRecorder()
+			req, err := http.NewRequest("POST", "/-/reload", nil)
+			assert.NoError(t, err)
+			router.ServeHTTP(w, req)
+			assert.Equal(t, tc.wantCode, w.Code)
+			assert.Equal(t, tc.wantBody, w.Body.String())
+		})
+	}
+}
@@ -10,6 +10,7 @@ import (
 	"github.com/kuoss/venti/pkg/model"
 	dsService "github.com/kuoss/venti/pkg/service/datasource"
 	"github.com/kuoss/venti/pkg/service/remote"
+	"github.com/kuoss/venti/pkg/service/user"
 )
 
 type RemoteHandler struct {
@@ -57,8 +58,13 @@ func (h *RemoteHandler) QueryRange(c *gin.Context) {
 }
 
 func (h *RemoteHandler) remoteAction(c *gin.Context, action remote.Action, rawQuery string) {
-	datasource, err := h.getDatasourceWithParams(c.Query("dsName"), c.Query("dsType"))
+	access, _ := api.GetAccess(c)
+	datasource, err := h.getDatasourceWithParams(access, c.Query("dsName"), c.Query("dsType"))
 	if err != nil {
+		if errors.Is(err, errDatasourceNotAllowed) {
+			api.ResponseError(c, api.ErrorForbidden, fmt.Errorf("getDatasourceWithParams err: %w", err))
+			return
+		}
 		api.ResponseError(c, api.ErrorInternal, fmt.Errorf("getDatasourceWithParams err: %w", err))
 		return
 	}
@@ -70,8 +76,10 @@ func (h *RemoteHandler) remoteAction(c *gin.Context, action remote.Action, rawQu
 	c.String(code, body)
 }
 
-// Select and return the datasource corresponding to the dsID or dsType parameter
-func (h *RemoteHandler) getDatasourceWithParams(dsName string, dsType string) (model.Datasource, error) {
+var errDatasourceNotAllowed = errors.New("datasource not allowed")
+
+// Select and return the datasource corresponding to the dsID or dsType parameter, among the ones that the user can use
+func (h *RemoteHandler) getDatasourceWithParams(access user.Access, dsName string, dsType string) (model.Datasource, error) {
 	if dsName == "" && dsType == "" {
 		return model.Datasource{}, errors.New("either dsName or dsType must be specified")
 	}
@@ -82,6 +90,9 @@ func (h *RemoteHandler) getDatasourceWithParams(dsName string, dsType string) (m
 		if err != nil {
 			return model.Datasource{}, fmt.Errorf("GetDatasourceByName err: %w", err)
 		}
+		if !access.AllowsDatasource(datasource) {
+			return model.Datasource{}, fmt.Errorf("%w: %s", errDatasourceNotAllowed, dsName)
+		}
 		return datasource, nil
 	}
 	// The following handles cases where there is no dsName...
@@ -94,5 +105,13 @@ func (h *RemoteHandler) getDatasourceWithParams(dsName string, dsType string) (m
 	if err != nil {
 		return model.Datasource{}, fmt.Errorf("GetMainDatasourceByType err: %w", err)
 	}
-	return datasource, nil
+	if access.AllowsDatasource(datasource) {
+		return datasource, nil
+	}
+	// or the first one that the user can use, e.g. the discovered one in the namespace of the team
+	allowed := access.FilterDatasources(h.datasourceService.GetDatasourcesWithSelector(model.DatasourceSelector{Type: model.DatasourceType(dsType)}))
+	if len(allowed) == 0 {
+		return model.Datasource{}, fmt.Errorf("%w: no %s datasource", errDatasourceNotAllowed, dsType)
+	}
+	return allowed[0], nil
 }
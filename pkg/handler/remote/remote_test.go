@@ -10,11 +10,13 @@ import (
 
 	"github.com/gin-gonic/gin"
 	"github.com/kuoss/common/logger"
+	"github.com/kuoss/venti/pkg/handler/api"
 	ms "github.com/kuoss/venti/pkg/mock/servers"
 	"github.com/kuoss/venti/pkg/model"
 	dsService "github.com/kuoss/venti/pkg/service/datasource"
 	"github.com/kuoss/venti/pkg/service/discovery"
 	"github.com/kuoss/venti/pkg/service/remote"
+	"github.com/kuoss/venti/pkg/service/user"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -175,3 +177,58 @@ func TestQueryRange(t *testing.T) {
 		})
 	}
 }
+
+func TestGetDatasourceWithParams(t *testing.T) {
+	grants := []model.Grant{
+		{Groups: []string{"team-a"}, Datasources: []string{"prometheus2", "lethe2"}},
+		{Users: []string{"bob"}, Datasources: []string{"lethe1"}},
+	}
+	all := user.NewAccess(model.User{Username: "alice"}, grants, "")
+	teamA := user.NewAccess(model.User{Username: "carol", Groups: []string{"team-a"}}, grants, "")
+	bob := user.NewAccess(model.User{Username: "bob"}, grants, "")
+
+	testCases := []struct {
+		name      string
+		access    user.Access
+		dsName    string
+		dsType    string
+		want      string
+		wantError string
+	}{
+		{"no params", all, "", "", "", "either dsName or dsType must be specified"},
+		{"name", all, "prometheus2", "", "prometheus2", ""},
+		{"type", all, "", "lethe", "lethe1", ""},
+		{"invalid type", all, "", "xxx", "", "invalid dsType"},
+		{"not found", teamA, "xxx", "", "", "GetDatasourceByName err: datasource of name xxx not found"},
+		{"granted name", teamA, "lethe2", "", "lethe2", ""},
+		{"not granted name", teamA, "lethe1", "", "", "datasource not allowed: lethe1"},
+		{"granted main", bob, "", "lethe", "lethe1", ""},
+		{"granted sub", teamA, "", "prometheus", "prometheus2", ""},
+		{"no granted type", bob, "", "prometheus", "", "datasource not allowed: no prometheus datasource"},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ds, err := remoteHandler1.getDatasourceWithParams(tc.access, tc.dsName, tc.dsType)
+			if tc.wantError != "" {
+				assert.EqualError(t, err, tc.wantError)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tc.want, ds.Name)
+		})
+	}
+}
+
+func TestQuery_notAllowed(t *testing.T) {
+	router := gin.New()
+	router.GET("/api/remote/query", func(c *gin.Context) {
+		api.SetAccess(c, user.NewAccess(model.User{Username: "bob"}, []model.Grant{{Users: []string{"bob"}, Datasources: []string{"lethe1"}}}, ""))
+	}, remoteHandler1.Query)
+
+	w := httptest.NewRecorder()
+	req, err := http.NewRequest("GET", "/api/remote/query?dsName=prometheus1&query=up", nil)
+	assert.NoError(t, err)
+	router.ServeHTTP(w, req)
+	assert.Equal(t, 403, w.Code)
+	assert.Equal(t, `{"error":"getDatasourceWithParams err: datasource not allowed: prometheus1","errorType":"forbidden","status":"error"}`, w.Body.String())
+}
@@ -2,21 +2,36 @@ package handler
 
 import (
 	"github.com/gin-gonic/gin"
+	"github.com/kuoss/common/logger"
+
+	"github.com/kuoss/venti/pkg/model"
 	"github.com/kuoss/venti/pkg/service"
 )
 
-func NewRouter(services *service.Services) *gin.Engine {
-
-	gin.SetMode(gin.ReleaseMode)
+// NewRouter returns the router of the services, with the web UI in webRoot. reload is called by POST /-/reload.
+// The client IP is the remote address, or the one forwarded by a trusted proxy.
+func NewRouter(services *service.Services, webRoot string, trustedProxies []string, reload func() error) *gin.Engine {
 	router := gin.Default()
-	handlers := loadHandlers(services)
+	if err := router.SetTrustedProxies(trustedProxies); err != nil {
+		// test unreachable: validated with the configuration
+		logger.Errorf("set trusted proxies err: %s", err.Error())
+		_ = router.SetTrustedProxies(nil)
+	}
+	handlers := loadHandlers(services, reload)
 
-	api := router.Group("/api/v1")
-	// fixme: api.Use(tokenRequired())
+	// every route is marked either public, or protected by the token of POST /auth/login or an API key, and a role
+	public := router.Group("")
+	protected := router.Group("", tokenRequired(services.UserService))
+	viewer := protected.Group("", roleRequired(model.RoleViewer))
+	editor := protected.Group("", roleRequired(model.RoleEditor))
+	admin := protected.Group("", roleRequired(model.RoleAdmin))
+
+	api := viewer.Group("/api/v1")
 	{
 		api.GET("/alerts", handlers.alertHandler.Alerts)
-		api.GET("/alerts/test", handlers.alertHandler.SendTestAlert)
+		api.GET("/alerts/groups", handlers.alertHandler.AlertingRuleGroups)
 		api.GET("/alertmanagers", handlers.alertHandler.Alertmanagers)
+		api.GET("/rules", handlers.alertHandler.Rules)
 
 		api.GET("/dashboards", handlers.dashboardHandler.Dashboards)
 
@@ -29,18 +44,36 @@ func NewRouter(services *service.Services) *gin.Engine {
 		api.GET("/remote/query", handlers.remoteHandler.Query)
 		api.GET("/remote/query_range", handlers.remoteHandler.QueryRange)
 
-		api.GET("/status/buildinfo", handlers.statusHandler.BuildInfo)
 		api.GET("/status/runtimeinfo", handlers.statusHandler.RuntimeInfo)
-
 	}
+	editor.GET("/api/v1/alerts/test", handlers.alertHandler.SendTestAlert)
+	public.GET("/api/v1/status/buildinfo", handlers.statusHandler.BuildInfo)
 
-	router.POST("/auth/login", handlers.authHandler.Login)
-	router.POST("/auth/logout", handlers.authHandler.Logout)
+	public.POST("/auth/login", handlers.authHandler.Login)
+	public.POST("/auth/logout", handlers.authHandler.Logout)
+	protected.POST("/auth/password", handlers.authHandler.ChangePassword)
+	public.GET("/auth/methods", handlers.oidcHandler.Methods)
+	public.GET("/auth/oidc/login", handlers.oidcHandler.Login)
+	public.GET("/auth/oidc/callback", handlers.oidcHandler.Callback)
 
-	router.GET("/-/healthy", handlers.probeHandler.Healthy)
-	router.GET("/-/ready", handlers.probeHandler.Ready)
+	public.GET("/-/healthy", handlers.probeHandler.Healthy)
+	public.GET("/-/ready", handlers.probeHandler.Ready)
+	admin.POST("/-/reload", handlers.reloadHandler.Reload)
+
+	adminAPI := admin.Group("/api/v1/admin")
+	{
+		adminAPI.GET("/apikeys", handlers.apiKeyHandler.List)
+		adminAPI.POST("/apikeys", handlers.apiKeyHandler.Create)
+		adminAPI.DELETE("/apikeys/:id", handlers.apiKeyHandler.Revoke)
+
+		adminAPI.GET("/users", handlers.userHandler.List)
+		adminAPI.POST("/users", handlers.userHandler.Create)
+		adminAPI.PUT("/users/:id", handlers.userHandler.Update)
+		adminAPI.DELETE("/users/:id", handlers.userHandler.Delete)
+		adminAPI.DELETE("/users/:id/sessions", handlers.userHandler.RevokeSessions)
+	}
 
-	router.Use(handleSPA())
+	router.Use(handleSPA(webRoot))
 
 	return router
 }
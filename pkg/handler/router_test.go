@@ -1,9 +1,15 @@
 package handler
 
 import (
+	"net/http"
+	"net/http/httptest"
 	"testing"
+	"time"
 
+	"github.com/gin-gonic/gin"
 	"github.com/stretchr/testify/assert"
+
+	"github.com/kuoss/venti/pkg/model"
 )
 
 func TestSetupRouter(t *testing.T) {
@@ -12,9 +18,95 @@ func TestSetupRouter(t *testing.T) {
 	assert.NotEmpty(t, handlers.authHandler)
 	assert.NotEmpty(t, handlers.dashboardHandler)
 	assert.NotEmpty(t, handlers.datasourceHandler)
+	assert.NotNil(t, handlers.oidcHandler)
+	assert.NotNil(t, handlers.reloadHandler)
 	assert.NotEmpty(t, handlers.remoteHandler)
 	assert.NotEmpty(t, handlers.statusHandler)
 
-	router := NewRouter(services)
+	router := NewRouter(services, "web/dist", nil, nil)
 	assert.NotEmpty(t, router)
 }
+
+func TestRouterTrustedProxies(t *testing.T) {
+	testCases := []struct {
+		name           string
+		trustedProxies []string
+		remoteAddr     string
+		want           string
+	}{
+		{"none", nil, "10.0.0.1:1234", "10.0.0.1"},
+		{"untrusted proxy", []string{"10.1.0.0/16"}, "10.0.0.1:1234", "10.0.0.1"},
+		{"trusted proxy", []string{"10.1.0.0/16"}, "10.1.0.1:1234", "203.0.113.7"},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			router := NewRouter(services, "web/dist", tc.trustedProxies, nil)
+			c := gin.CreateTestContextOnly(httptest.NewRecorder(), router)
+			c.Request = httptest.NewRequest("POST", "/auth/login", nil)
+			c.Request.RemoteAddr = tc.remoteAddr
+			c.Request.Header.Set("X-Forwarded-For", "203.0.113.7")
+			assert.Equal(t, tc.want, c.ClientIP())
+		})
+	}
+}
+
+func TestRouterAuth(t *testing.T) {
+	viewerID, viewerToken := setTestUser(t, "router-viewer", model.RoleViewer, time.Now().Add(time.Hour))
+	adminID, adminToken := setTestUser(t, "router-admin", model.RoleAdmin, time.Now().Add(time.Hour))
+	router := NewRouter(services, "web/dist", nil, nil)
+
+	testCases := []struct {
+		method     string
+		path       string
+		wantCode   int
+		wantViewer int
+		wantAdmin  int
+	}{
+		// public
+		{"GET", "/api/v1/status/buildinfo", 200, 200, 200},
+		{"GET", "/-/healthy", 200, 200, 200},
+		{"GET", "/-/ready", 200, 200, 200},
+		{"GET", "/auth/methods", 200, 200, 200},
+		{"GET", "/auth/oidc/login", 404, 404, 404},
+		// viewer
+		{"GET", "/api/v1/alerts", 401, 200, 200},
+		{"GET", "/api/v1/dashboards", 401, 200, 200},
+		{"GET", "/api/v1/datasources", 401, 200, 200},
+		{"GET", "/api/v1/remote/query", 401, 500, 500},
+		{"GET", "/api/v1/rules", 401, 200, 200},
+		{"GET", "/api/v1/status/runtimeinfo", 401, 200, 200},
+		{"POST", "/auth/password", 401, 405, 405},
+		// editor
+		{"GET", "/api/v1/alerts/test", 401, 403, 200},
+		// admin
+		{"POST", "/-/reload", 401, 403, 503},
+		{"GET", "/api/v1/admin/apikeys", 401, 403, 200},
+		{"DELETE", "/api/v1/admin/apikeys/0", 401, 403, 404},
+		{"DELETE", "/api/v1/admin/users/0/sessions", 401, 403, 200},
+		{"GET", "/api/v1/admin/users", 401, 403, 200},
+		{"DELETE", "/api/v1/admin/users/0", 401, 403, 404},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.method+" "+tc.path, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			req, _ := http.NewRequest(tc.method, tc.path, nil)
+			router.ServeHTTP(w, req)
+			assert.Equal(t, tc.wantCode, w.Code)
+			if tc.wantCode == 401 {
+				assert.Equal(t, `{"error":"token required","errorType":"unauthorized","status":"error"}`, w.Body.String())
+			}
+
+			w = httptest.NewRecorder()
+			req.Header.Set("Authorization", "Bearer "+viewerToken)
+			req.Header.Set("UserID", viewerID)
+			router.ServeHTTP(w, req)
+			assert.Equal(t, tc.wantViewer, w.Code)
+
+			w = httptest.NewRecorder()
+			req.Header.Set("Authorization", "Bearer "+adminToken)
+			req.Header.Set("UserID", adminID)
+			router.ServeHTTP(w, req)
+			assert.Equal(t, tc.wantAdmin, w.Code)
+		})
+	}
+}
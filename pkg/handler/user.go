@@ -0,0 +1,135 @@
+package handler
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/kuoss/venti/pkg/handler/api"
+	"github.com/kuoss/venti/pkg/model"
+	userService "github.com/kuoss/venti/pkg/service/user"
+)
+
+type userHandler struct {
+	userService *userService.UserService
+}
+
+func NewUserHandler(s *userService.UserService) *userHandler {
+	return &userHandler{s}
+}
+
+type createUserRequest struct {
+	Username string     `json:"username"`
+	Password string     `json:"password"`
+	Role     model.Role `json:"role"`
+	Groups   []string   `json:"groups"`
+}
+
+// GET /api/v1/admin/users
+func (h *userHandler) List(c *gin.Context) {
+	users, err := h.userService.ListUsers()
+	if err != nil {
+		api.ResponseError(c, api.ErrorInternal, fmt.Errorf("ListUsers err: %w", err))
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"status": "success", "data": users})
+}
+
+// POST /api/v1/admin/users
+// Create creates a user managed in the database.
+func (h *userHandler) Create(c *gin.Context) {
+	var req createUserRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		api.ResponseError(c, api.ErrorBadData, fmt.Errorf("invalid request: %w", err))
+		return
+	}
+	user, err := h.userService.CreateUser(req.Username, req.Password, req.Role, req.Groups)
+	if err != nil {
+		api.ResponseError(c, api.ErrorBadData, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"status": "success", "data": user})
+}
+
+// PUT /api/v1/admin/users/:id
+// Update changes the password, the role, the groups, the flags or some of them. Admins cannot disable themselves.
+func (h *userHandler) Update(c *gin.Context) {
+	id, ok := userID(c)
+	if !ok {
+		return
+	}
+	var req userService.UserUpdate
+	if err := c.ShouldBindJSON(&req); err != nil {
+		api.ResponseError(c, api.ErrorBadData, fmt.Errorf("invalid request: %w", err))
+		return
+	}
+	if req.Disabled != nil && *req.Disabled && isCurrentUser(c, id) {
+		api.ResponseError(c, api.ErrorBadData, fmt.Errorf("cannot disable yourself"))
+		return
+	}
+	user, err := h.userService.UpdateUser(id, req)
+	if err != nil {
+		responseUserError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"status": "success", "data": user})
+}
+
+// DELETE /api/v1/admin/users/:id
+// Admins cannot delete themselves.
+func (h *userHandler) Delete(c *gin.Context) {
+	id, ok := userID(c)
+	if !ok {
+		return
+	}
+	if isCurrentUser(c, id) {
+		api.ResponseError(c, api.ErrorBadData, fmt.Errorf("cannot delete yourself"))
+		return
+	}
+	if err := h.userService.DeleteUser(id); err != nil {
+		responseUserError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"status": "success"})
+}
+
+// DELETE /api/v1/admin/users/:id/sessions
+// RevokeSessions logs out the user everywhere.
+func (h *userHandler) RevokeSessions(c *gin.Context) {
+	id, ok := userID(c)
+	if !ok {
+		return
+	}
+	n, err := h.userService.DeleteSessions(id)
+	if err != nil {
+		api.ResponseError(c, api.ErrorInternal, fmt.Errorf("DeleteSessions err: %w", err))
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"status": "success", "data": gin.H{"revoked": n}})
+}
+
+// userID returns the id in the path, or responds an error.
+func userID(c *gin.Context) (int, bool) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		api.ResponseError(c, api.ErrorBadData, fmt.Errorf("invalid id: %s", c.Param("id")))
+		return 0, false
+	}
+	return id, true
+}
+
+func isCurrentUser(c *gin.Context, id int) bool {
+	access, ok := api.GetAccess(c)
+	return ok && access.User.ID == id
+}
+
+func responseUserError(c *gin.Context, err error) {
+	if errors.Is(err, userService.ErrUserNotFound) {
+		api.ResponseError(c, api.ErrorNotFound, err)
+		return
+	}
+	api.ResponseError(c, api.ErrorBadData, err)
+}
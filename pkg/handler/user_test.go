@@ -0,0 +1,123 @@
+package handler
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/kuoss/venti/pkg/model"
+)
+
+func TestRevokeSessions(t *testing.T) {
+	adminID, adminToken := setTestUser(t, "sessions-admin", model.RoleAdmin, time.Now().Add(time.Hour))
+	userID, token1 := setTestUser(t, "sessions-user", model.RoleViewer, time.Now().Add(time.Hour))
+	_, token2 := setTestUser(t, "sessions-user", model.RoleViewer, time.Now().Add(time.Hour))
+	router := NewRouter(services, "web/dist", nil, nil)
+
+	serve := func(method, path, token, userID string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest(method, path, nil)
+		req.Header.Set("Authorization", "Bearer "+token)
+		req.Header.Set("UserID", userID)
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	// a new session leaves the other one as it is
+	assert.Equal(t, 200, serve("GET", "/api/v1/status/runtimeinfo", token1, userID).Code)
+	assert.Equal(t, 200, serve("GET", "/api/v1/status/runtimeinfo", token2, userID).Code)
+
+	w := serve("DELETE", "/api/v1/admin/users/"+userID+"/sessions", adminToken, adminID)
+	require.Equal(t, 200, w.Code)
+	assert.JSONEq(t, `{"status":"success","data":{"revoked":2}}`, w.Body.String())
+
+	assert.Equal(t, 401, serve("GET", "/api/v1/status/runtimeinfo", token1, userID).Code)
+	assert.Equal(t, 401, serve("GET", "/api/v1/status/runtimeinfo", token2, userID).Code)
+	assert.Equal(t, 200, serve("GET", "/api/v1/status/runtimeinfo", adminToken, adminID).Code)
+
+	w = serve("DELETE", "/api/v1/admin/users/x/sessions", adminToken, adminID)
+	assert.Equal(t, 405, w.Code)
+}
+
+func TestUserAPI(t *testing.T) {
+	adminID, adminToken := setTestUser(t, "users-admin", model.RoleAdmin, time.Now().Add(time.Hour))
+	router := NewRouter(services, "web/dist", nil, nil)
+
+	serve := func(method, path, body, contentType, token, userID string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest(method, path, strings.NewReader(body))
+		req.Header.Set("Content-Type", contentType)
+		req.Header.Set("Authorization", "Bearer "+token)
+		req.Header.Set("UserID", userID)
+		router.ServeHTTP(w, req)
+		return w
+	}
+	admin := func(method, path, body string) *httptest.ResponseRecorder {
+		return serve(method, path, body, "application/json", adminToken, adminID)
+	}
+	login := func(username, password string) *httptest.ResponseRecorder {
+		return serve("POST", "/auth/login", "username="+username+"&password="+password, "application/x-www-form-urlencoded", "", "")
+	}
+
+	// create
+	w := admin("POST", "/api/v1/admin/users", `{"username":"users-alice","password":"password1","role":"editor","groups":["team-a"]}`)
+	require.Equal(t, 200, w.Code, w.Body.String())
+	assert.NotContains(t, w.Body.String(), "hash")
+	var created struct {
+		Data model.User `json:"data"`
+	}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &created))
+	aliceID := strconv.Itoa(created.Data.ID)
+	defer func() { _ = services.UserService.DeleteUser(created.Data.ID) }()
+	assert.True(t, created.Data.DBManaged)
+
+	w = admin("POST", "/api/v1/admin/users", `{"username":"users-alice","password":"password1"}`)
+	assert.Equal(t, 405, w.Code)
+	assert.JSONEq(t, `{"error":"user \"users-alice\" exists","errorType":"bad_data","status":"error"}`, w.Body.String())
+
+	w = admin("GET", "/api/v1/admin/users", "")
+	assert.Equal(t, 200, w.Code)
+	assert.Contains(t, w.Body.String(), `"username":"users-alice"`)
+
+	// change the password
+	w = login("users-alice", "password1")
+	require.Equal(t, 200, w.Code)
+	var session struct {
+		Token string `json:"token"`
+	}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &session))
+	w = serve("POST", "/auth/password", "currentPassword=wrong&newPassword=password2", "application/x-www-form-urlencoded", session.Token, aliceID)
+	assert.Equal(t, 401, w.Code)
+	assert.JSONEq(t, `{"error":"current password is incorrect","errorType":"unauthorized","status":"error"}`, w.Body.String())
+	w = serve("POST", "/auth/password", "currentPassword=password1&newPassword=password2", "application/x-www-form-urlencoded", session.Token, aliceID)
+	assert.Equal(t, 200, w.Code)
+	assert.Equal(t, 401, login("users-alice", "password1").Code)
+	assert.Equal(t, 200, login("users-alice", "password2").Code)
+
+	// update and disable
+	w = admin("PUT", "/api/v1/admin/users/"+aliceID, `{"role":"viewer","disabled":true}`)
+	require.Equal(t, 200, w.Code, w.Body.String())
+	assert.Contains(t, w.Body.String(), `"role":"viewer"`)
+	w = login("users-alice", "password2")
+	assert.Equal(t, 401, w.Code)
+	assert.JSONEq(t, `{"error":"username or password is incorrect","errorType":"unauthorized","status":"error"}`, w.Body.String())
+	assert.Equal(t, 401, serve("GET", "/api/v1/status/runtimeinfo", "", "", session.Token, aliceID).Code)
+
+	// not yourself
+	w = admin("PUT", "/api/v1/admin/users/"+adminID, `{"disabled":true}`)
+	assert.JSONEq(t, `{"error":"cannot disable yourself","errorType":"bad_data","status":"error"}`, w.Body.String())
+	w = admin("DELETE", "/api/v1/admin/users/"+adminID, "")
+	assert.JSONEq(t, `{"error":"cannot delete yourself","errorType":"bad_data","status":"error"}`, w.Body.String())
+
+	// delete
+	assert.Equal(t, 200, admin("DELETE", "/api/v1/admin/users/"+aliceID, "").Code)
+	assert.Equal(t, 404, admin("DELETE", "/api/v1/admin/users/"+aliceID, "").Code)
+	assert.Equal(t, 404, admin("PUT", "/api/v1/admin/users/"+aliceID, `{"disabled":false}`).Code)
+}
@@ -23,6 +23,7 @@ func (c *Context) JSONString(code int, str string) {
 }
 
 func (c *Context) render(code int, r render.Render) {
+	r.WriteContentType(c.Writer)
 	c.Writer.WriteHeader(code)
 	err := r.Render(c.Writer)
 	if err != nil {
@@ -33,3 +34,11 @@ func (c *Context) render(code int, r render.Render) {
 func (c *Context) Query(key string) (value string) {
 	return c.Request.URL.Query().Get(key)
 }
+
+func (c *Context) PostForm(key string) (value string) {
+	return c.Request.PostFormValue(key)
+}
+
+func (c *Context) Redirect(code int, location string) {
+	http.Redirect(c.Writer, c.Request, location, code)
+}
@@ -40,6 +40,7 @@ func TestJSON(t *testing.T) {
 			context.JSON(tc.code, tc.obj)
 
 			require.Equal(t, tc.wantCode, w.Code)
+			require.Equal(t, "application/json; charset=utf-8", w.Header().Get("Content-Type"))
 			require.Equal(t, tc.wantBody, w.Body.String())
 		})
 	}
@@ -0,0 +1,219 @@
+package ldap
+
+import (
+	"fmt"
+	"net"
+	"strings"
+	"sync"
+
+	ber "github.com/go-asn1-ber/asn1-ber"
+	"github.com/go-ldap/ldap/v3"
+)
+
+// Server is a fake LDAP server with the entries in memory.
+// It answers simple binds, and searches with and, or, not, equality and presence filters.
+type Server struct {
+	URL string
+
+	listener net.Listener
+	wg       sync.WaitGroup
+	mu       sync.Mutex
+	entries  []Entry
+}
+
+// Entry is an entry of the directory. A bind as its DN succeeds with the password.
+type Entry struct {
+	DN         string
+	Password   string
+	Attributes map[string][]string
+}
+
+func New(entries ...Entry) (*Server, error) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return nil, fmt.Errorf("error on Listen: %w", err)
+	}
+	s := &Server{URL: "ldap://" + listener.Addr().String(), listener: listener, entries: entries}
+	s.wg.Add(1)
+	go s.serve()
+	return s, nil
+}
+
+func (s *Server) AddEntry(entry Entry) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.entries = append(s.entries, entry)
+}
+
+func (s *Server) Close() {
+	s.listener.Close()
+	s.wg.Wait()
+}
+
+func (s *Server) serve() {
+	defer s.wg.Done()
+	for {
+		conn, err := s.listener.Accept()
+		if err != nil {
+			return
+		}
+		s.wg.Add(1)
+		go func() {
+			defer s.wg.Done()
+			defer conn.Close()
+			s.handle(conn)
+		}()
+	}
+}
+
+func (s *Server) handle(conn net.Conn) {
+	for {
+		packet, err := ber.ReadPacket(conn)
+		if err != nil || len(packet.Children) < 2 {
+			return
+		}
+		messageID, _ := packet.Children[0].Value.(int64)
+		op := packet.Children[1]
+		var responses []*ber.Packet
+		switch op.Tag {
+		case ldap.ApplicationBindRequest:
+			responses = []*ber.Packet{s.bind(op)}
+		case ldap.ApplicationSearchRequest:
+			responses = s.search(op)
+		case ldap.ApplicationUnbindRequest:
+			return
+		default:
+			responses = []*ber.Packet{result(ldap.ApplicationExtendedResponse, ldap.LDAPResultUnwillingToPerform)}
+		}
+		for _, response := range responses {
+			envelope := ber.Encode(ber.ClassUniversal, ber.TypeConstructed, ber.TagSequence, nil, "LDAP Response")
+			envelope.AppendChild(ber.NewInteger(ber.ClassUniversal, ber.TypePrimitive, ber.TagInteger, messageID, "MessageID"))
+			envelope.AppendChild(response)
+			if _, err := conn.Write(envelope.Bytes()); err != nil {
+				return
+			}
+		}
+	}
+}
+
+func result(tag ber.Tag, code uint16) *ber.Packet {
+	p := ber.Encode(ber.ClassApplication, ber.TypeConstructed, tag, nil, "Result")
+	p.AppendChild(ber.NewInteger(ber.ClassUniversal, ber.TypePrimitive, ber.TagEnumerated, int64(code), "resultCode"))
+	p.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, "", "matchedDN"))
+	p.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, "", "diagnosticMessage"))
+	return p
+}
+
+func (s *Server) bind(op *ber.Packet) *ber.Packet {
+	if len(op.Children) < 3 {
+		return result(ldap.ApplicationBindResponse, ldap.LDAPResultProtocolError)
+	}
+	dn := op.Children[1].Data.String()
+	password := op.Children[2].Data.String()
+	if dn == "" && password == "" {
+		return result(ldap.ApplicationBindResponse, ldap.LDAPResultSuccess)
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, entry := range s.entries {
+		if strings.EqualFold(entry.DN, dn) && entry.Password != "" && entry.Password == password {
+			return result(ldap.ApplicationBindResponse, ldap.LDAPResultSuccess)
+		}
+	}
+	return result(ldap.ApplicationBindResponse, ldap.LDAPResultInvalidCredentials)
+}
+
+func (s *Server) search(op *ber.Packet) []*ber.Packet {
+	if len(op.Children) < 8 {
+		return []*ber.Packet{result(ldap.ApplicationSearchResultDone, ldap.LDAPResultProtocolError)}
+	}
+	baseDN := strings.ToLower(op.Children[0].Data.String())
+	sizeLimit, _ := op.Children[3].Value.(int64)
+	filter := op.Children[6]
+	var attributes []string
+	for _, a := range op.Children[7].Children {
+		attributes = append(attributes, a.Data.String())
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var responses []*ber.Packet
+	for _, entry := range s.entries {
+		if !strings.HasSuffix(strings.ToLower(entry.DN), baseDN) || !matches(entry, filter) {
+			continue
+		}
+		if sizeLimit > 0 && int64(len(responses)) == sizeLimit {
+			return append(responses, result(ldap.ApplicationSearchResultDone, ldap.LDAPResultSizeLimitExceeded))
+		}
+		responses = append(responses, searchEntry(entry, attributes))
+	}
+	return append(responses, result(ldap.ApplicationSearchResultDone, ldap.LDAPResultSuccess))
+}
+
+func searchEntry(entry Entry, attributes []string) *ber.Packet {
+	p := ber.Encode(ber.ClassApplication, ber.TypeConstructed, ldap.ApplicationSearchResultEntry, nil, "Search Result Entry")
+	p.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, entry.DN, "objectName"))
+	attrs := ber.Encode(ber.ClassUniversal, ber.TypeConstructed, ber.TagSequence, nil, "attributes")
+	for name, values := range entry.Attributes {
+		if len(attributes) > 0 && !containsFold(attributes, name) {
+			continue
+		}
+		attr := ber.Encode(ber.ClassUniversal, ber.TypeConstructed, ber.TagSequence, nil, "attribute")
+		attr.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, name, "type"))
+		set := ber.Encode(ber.ClassUniversal, ber.TypeConstructed, ber.TagSet, nil, "vals")
+		for _, v := range values {
+			set.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, v, "val"))
+		}
+		attr.AppendChild(set)
+		attrs.AppendChild(attr)
+	}
+	p.AppendChild(attrs)
+	return p
+}
+
+func matches(entry Entry, filter *ber.Packet) bool {
+	switch filter.Tag {
+	case ldap.FilterAnd:
+		for _, f := range filter.Children {
+			if !matches(entry, f) {
+				return false
+			}
+		}
+		return true
+	case ldap.FilterOr:
+		for _, f := range filter.Children {
+			if matches(entry, f) {
+				return true
+			}
+		}
+		return false
+	case ldap.FilterNot:
+		return len(filter.Children) == 1 && !matches(entry, filter.Children[0])
+	case ldap.FilterEqualityMatch:
+		if len(filter.Children) != 2 {
+			return false
+		}
+		return containsFold(attributeValues(entry, filter.Children[0].Data.String()), filter.Children[1].Data.String())
+	case ldap.FilterPresent:
+		return len(attributeValues(entry, filter.Data.String())) > 0
+	}
+	return false
+}
+
+func attributeValues(entry Entry, name string) []string {
+	for k, v := range entry.Attributes {
+		if strings.EqualFold(k, name) {
+			return v
+		}
+	}
+	return nil
+}
+
+func containsFold(values []string, value string) bool {
+	for _, v := range values {
+		if strings.EqualFold(v, value) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,61 @@
+package ldap_test
+
+import (
+	"testing"
+
+	goldap "github.com/go-ldap/ldap/v3"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/kuoss/venti/pkg/mocker/ldap"
+)
+
+func TestServer(t *testing.T) {
+	server, err := ldap.New(
+		ldap.Entry{DN: "uid=alice,ou=people,dc=example,dc=com", Password: "alice", Attributes: map[string][]string{"uid": {"alice"}, "mail": {"alice@example.com"}}},
+		ldap.Entry{DN: "uid=bob,ou=people,dc=example,dc=com", Password: "bob", Attributes: map[string][]string{"uid": {"bob"}}},
+	)
+	require.NoError(t, err)
+	defer server.Close()
+	server.AddEntry(ldap.Entry{DN: "cn=dev,ou=groups,dc=example,dc=com", Attributes: map[string][]string{"cn": {"dev"}, "member": {"uid=alice,ou=people,dc=example,dc=com"}}})
+
+	conn, err := goldap.DialURL(server.URL)
+	require.NoError(t, err)
+	defer conn.Close()
+
+	// bind
+	assert.NoError(t, conn.UnauthenticatedBind(""))
+	assert.NoError(t, conn.Bind("uid=alice,ou=people,dc=example,dc=com", "alice"))
+	err = conn.Bind("uid=alice,ou=people,dc=example,dc=com", "wrong")
+	assert.True(t, goldap.IsErrorWithCode(err, goldap.LDAPResultInvalidCredentials), err)
+
+	// search
+	testCases := []struct {
+		baseDN    string
+		filter    string
+		sizeLimit int
+		want      []string
+	}{
+		{"dc=example,dc=com", "(uid=alice)", 0, []string{"uid=alice,ou=people,dc=example,dc=com"}},
+		{"dc=example,dc=com", "(uid=*)", 0, []string{"uid=alice,ou=people,dc=example,dc=com", "uid=bob,ou=people,dc=example,dc=com"}},
+		{"dc=example,dc=com", "(&(uid=*)(mail=alice@example.com))", 0, []string{"uid=alice,ou=people,dc=example,dc=com"}},
+		{"dc=example,dc=com", "(|(uid=bob)(cn=dev))", 0, []string{"uid=bob,ou=people,dc=example,dc=com", "cn=dev,ou=groups,dc=example,dc=com"}},
+		{"dc=example,dc=com", "(&(uid=*)(!(uid=alice)))", 0, []string{"uid=bob,ou=people,dc=example,dc=com"}},
+		{"ou=groups,dc=example,dc=com", "(member=uid=alice,ou=people,dc=example,dc=com)", 0, []string{"cn=dev,ou=groups,dc=example,dc=com"}},
+		{"ou=groups,dc=example,dc=com", "(uid=alice)", 0, nil},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.filter, func(t *testing.T) {
+			result, err := conn.Search(goldap.NewSearchRequest(tc.baseDN, goldap.ScopeWholeSubtree, goldap.NeverDerefAliases, tc.sizeLimit, 0, false, tc.filter, []string{"cn"}, nil))
+			require.NoError(t, err)
+			var got []string
+			for _, entry := range result.Entries {
+				got = append(got, entry.DN)
+			}
+			assert.Equal(t, tc.want, got)
+		})
+	}
+
+	_, err = conn.Search(goldap.NewSearchRequest("dc=example,dc=com", goldap.ScopeWholeSubtree, goldap.NeverDerefAliases, 1, 0, false, "(uid=*)", nil, nil))
+	assert.True(t, goldap.IsErrorWithCode(err, goldap.LDAPResultSizeLimitExceeded), err)
+}
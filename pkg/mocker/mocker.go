@@ -32,6 +32,10 @@ func (s *Server) GET(pattern string, handler HandlerFunc) {
 	s.addRoute(pattern, handler)
 }
 
+func (s *Server) POST(pattern string, handler HandlerFunc) {
+	s.addRoute("POST "+pattern, handler)
+}
+
 func (s *Server) addRoute(pattern string, handler HandlerFunc) {
 	f := func(w http.ResponseWriter, r *http.Request) {
 		if s.basicAuth && !s.verifyBasicAuth(w, r) {
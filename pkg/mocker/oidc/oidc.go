@@ -0,0 +1,165 @@
+package oidc
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"sync"
+	"time"
+
+	"github.com/go-jose/go-jose/v4"
+
+	"github.com/kuoss/venti/pkg/mocker"
+)
+
+// Issuer is a fake OpenID Connect provider for the authorization code flow with PKCE.
+// Its authorization endpoint logs in the user of Claims at once, without any page.
+type Issuer struct {
+	*mocker.Server
+	ClientID string
+
+	mu     sync.Mutex
+	claims map[string]any
+	codes  map[string]authRequest
+	signer jose.Signer
+	jwks   jose.JSONWebKeySet
+}
+
+type authRequest struct {
+	claims      map[string]any
+	nonce       string
+	challenge   string
+	redirectURI string
+}
+
+func New(clientID string) (*Issuer, error) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		return nil, fmt.Errorf("GenerateKey err: %w", err)
+	}
+	signer, err := jose.NewSigner(jose.SigningKey{Algorithm: jose.RS256, Key: key}, (&jose.SignerOptions{}).WithType("JWT").WithHeader("kid", "key1"))
+	if err != nil {
+		return nil, fmt.Errorf("NewSigner err: %w", err)
+	}
+	s := &Issuer{
+		Server:   mocker.New(),
+		ClientID: clientID,
+		claims:   map[string]any{},
+		codes:    map[string]authRequest{},
+		signer:   signer,
+		jwks:     jose.JSONWebKeySet{Keys: []jose.JSONWebKey{{Key: &key.PublicKey, KeyID: "key1", Algorithm: "RS256", Use: "sig"}}},
+	}
+	s.GET("/.well-known/openid-configuration", s.handleDiscovery)
+	s.GET("/keys", s.handleKeys)
+	s.GET("/authorize", s.handleAuthorize)
+	s.POST("/token", s.handleToken)
+
+	err = s.Start()
+	if err != nil {
+		err = fmt.Errorf("error on Start: %w", err)
+	}
+	return s, err
+}
+
+// SetClaims sets the claims of the user to log in next, e.g. preferred_username and groups.
+func (s *Issuer) SetClaims(claims map[string]any) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.claims = claims
+}
+
+func (s *Issuer) handleDiscovery(c *mocker.Context) {
+	c.JSON(200, mocker.H{
+		"issuer":                                s.URL,
+		"authorization_endpoint":                s.URL + "/authorize",
+		"token_endpoint":                        s.URL + "/token",
+		"jwks_uri":                              s.URL + "/keys",
+		"response_types_supported":              []string{"code"},
+		"subject_types_supported":               []string{"public"},
+		"id_token_signing_alg_values_supported": []string{"RS256"},
+		"code_challenge_methods_supported":      []string{"S256"},
+	})
+}
+
+func (s *Issuer) handleKeys(c *mocker.Context) {
+	c.JSON(200, s.jwks)
+}
+
+func (s *Issuer) handleAuthorize(c *mocker.Context) {
+	if c.Query("client_id") != s.ClientID || c.Query("response_type") != "code" {
+		c.JSON(400, mocker.H{"error": "invalid_request"})
+		return
+	}
+	if c.Query("code_challenge_method") != "S256" || c.Query("code_challenge") == "" {
+		c.JSON(400, mocker.H{"error": "invalid_request", "error_description": "PKCE required"})
+		return
+	}
+	redirectURI, err := url.Parse(c.Query("redirect_uri"))
+	if err != nil {
+		c.JSON(400, mocker.H{"error": "invalid_request"})
+		return
+	}
+	code := rand.Text()
+	s.mu.Lock()
+	s.codes[code] = authRequest{claims: s.claims, nonce: c.Query("nonce"), challenge: c.Query("code_challenge"), redirectURI: redirectURI.String()}
+	s.mu.Unlock()
+
+	query := redirectURI.Query()
+	query.Set("code", code)
+	query.Set("state", c.Query("state"))
+	redirectURI.RawQuery = query.Encode()
+	c.Redirect(302, redirectURI.String())
+}
+
+func (s *Issuer) handleToken(c *mocker.Context) {
+	clientID, _, ok := c.Request.BasicAuth()
+	if !ok {
+		clientID = c.PostForm("client_id")
+	}
+	s.mu.Lock()
+	req, found := s.codes[c.PostForm("code")]
+	delete(s.codes, c.PostForm("code"))
+	s.mu.Unlock()
+
+	if clientID != s.ClientID || !found || c.PostForm("grant_type") != "authorization_code" || c.PostForm("redirect_uri") != req.redirectURI {
+		c.JSON(400, mocker.H{"error": "invalid_grant"})
+		return
+	}
+	challenge := sha256.Sum256([]byte(c.PostForm("code_verifier")))
+	if base64.RawURLEncoding.EncodeToString(challenge[:]) != req.challenge {
+		c.JSON(400, mocker.H{"error": "invalid_grant", "error_description": "invalid code_verifier"})
+		return
+	}
+
+	claims := map[string]any{
+		"iss":   s.URL,
+		"sub":   fmt.Sprint(req.claims["preferred_username"]),
+		"aud":   s.ClientID,
+		"iat":   time.Now().Unix(),
+		"exp":   time.Now().Add(time.Hour).Unix(),
+		"nonce": req.nonce,
+	}
+	for k, v := range req.claims {
+		claims[k] = v
+	}
+	payload, err := json.Marshal(claims)
+	if err != nil {
+		c.JSON(500, mocker.H{"error": err.Error()})
+		return
+	}
+	jws, err := s.signer.Sign(payload)
+	if err != nil {
+		c.JSON(500, mocker.H{"error": err.Error()})
+		return
+	}
+	idToken, err := jws.CompactSerialize()
+	if err != nil {
+		c.JSON(500, mocker.H{"error": err.Error()})
+		return
+	}
+	c.JSON(200, mocker.H{"access_token": rand.Text(), "token_type": "Bearer", "expires_in": 3600, "id_token": idToken})
+}
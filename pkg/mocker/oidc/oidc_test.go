@@ -0,0 +1,40 @@
+package oidc_test
+
+import (
+	"testing"
+
+	mockerClient "github.com/kuoss/venti/pkg/mocker/client"
+	"github.com/kuoss/venti/pkg/mocker/oidc"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDiscovery(t *testing.T) {
+	server, err := oidc.New("venti")
+	require.NoError(t, err)
+	defer server.Close()
+	client := mockerClient.New(server.URL)
+
+	code, body, err := client.GET("/.well-known/openid-configuration", "")
+	assert.NoError(t, err)
+	assert.Equal(t, 200, code)
+	assert.Contains(t, body, `"issuer":"`+server.URL+`"`)
+	assert.Contains(t, body, `"code_challenge_methods_supported":["S256"]`)
+
+	code, body, err = client.GET("/keys", "")
+	assert.NoError(t, err)
+	assert.Equal(t, 200, code)
+	assert.Contains(t, body, `"kid":"key1"`)
+}
+
+func TestAuthorize_PKCERequired(t *testing.T) {
+	server, err := oidc.New("venti")
+	require.NoError(t, err)
+	defer server.Close()
+	client := mockerClient.New(server.URL)
+
+	code, body, err := client.GET("/authorize", "client_id=venti&response_type=code&redirect_uri=http://localhost/callback&state=s")
+	assert.NoError(t, err)
+	assert.Equal(t, 400, code)
+	assert.JSONEq(t, `{"error":"invalid_request","error_description":"PKCE required"}`, body)
+}
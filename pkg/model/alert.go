@@ -0,0 +1,13 @@
+package model
+
+// ActiveAlert is the persisted state of an active alert, so that it survives restarts.
+// A group is identified by its file and name, as several files can have a group of the same name,
+// and a rule by the hash of everything that makes its alerts, so that a changed rule does not get the alerts of its old version.
+type ActiveAlert struct {
+	ID        int    `gorm:"primaryKey"`
+	File      string `gorm:"index:idx_active_alerts_group"`
+	GroupName string `gorm:"index:idx_active_alerts_group"`
+	RuleName  string
+	RuleKey   string
+	Data      string // the alert in JSON
+}
@@ -0,0 +1,25 @@
+package model
+
+import "time"
+
+// APIKey is a long-lived token of a service account, e.g. for CI jobs and bots, with a role.
+// Only the hash of the key is stored. Grants apply to it as the user named by Username.
+type APIKey struct {
+	ID         int        `gorm:"primaryKey" json:"id"`
+	Name       string     `gorm:"index:,unique" json:"name"`
+	Hash       string     `gorm:"index:,unique" json:"-"`
+	Prefix     string     `json:"prefix"` // the first characters of the key, to tell the keys apart
+	Role       Role       `json:"role"`
+	ExpiresAt  *time.Time `json:"expiresAt,omitempty"` // nil for no expiry
+	LastUsedAt *time.Time `json:"lastUsedAt,omitempty"`
+	CreatedBy  string     `json:"createdBy"`
+	CreatedAt  time.Time  `json:"createdAt"`
+}
+
+// APIKeyUserPrefix is the prefix of the usernames of the API keys, e.g. apikey:ci for the key named ci.
+const APIKeyUserPrefix = "apikey:"
+
+// User returns the user that the key acts as.
+func (k APIKey) User() User {
+	return User{Username: APIKeyUserPrefix + k.Name, Role: k.Role, Provider: "apikey"}
+}
@@ -12,18 +12,111 @@ type AppInfo struct {
 }
 
 type GlobalConfig struct {
-	GinMode  string `yaml:"ginMode,omitempty"`
-	LogLevel string `yaml:"logLevel,omitempty"`
+	GinMode     string        `yaml:"ginMode,omitempty"`
+	LogLevel    string        `yaml:"logLevel,omitempty"`
+	ExternalURL string        `yaml:"externalURL,omitempty"` // used for generatorURL of alerts
+	WatchConfig bool          `yaml:"watchConfig,omitempty"` // reload on changes of the configuration files, the rule files and the dashboards
+	OIDC        *OIDCConfig   `yaml:"oidc,omitempty"`        // single sign-on
+	LDAP        *LDAPConfig   `yaml:"ldap,omitempty"`        // password login of the users of a directory, besides users.yml
+	Session     SessionConfig `yaml:"session,omitempty"`     // lifetime of the logins
+	Login       LoginConfig   `yaml:"login,omitempty"`       // protection of the password login against brute force
+
+	// TrustedProxies are the IPs or CIDRs of the reverse proxies whose X-Forwarded-For or X-Real-IP is the client IP,
+	// e.g. of the login throttling; default: none, the client IP is the remote address.
+	TrustedProxies []string `yaml:"trustedProxies,omitempty"`
+}
+
+// LoginConfig protects the password login against brute force. After a failure, the username and the IP
+// wait for a delay that doubles at each failure, and they are locked out after too many failures.
+type LoginConfig struct {
+	MaxFailures     int           `yaml:"maxFailures,omitempty"`     // failures of a username before its lockout; default: 5
+	IPMaxFailures   int           `yaml:"ipMaxFailures,omitempty"`   // failures from an IP before its lockout; default: 20
+	LockoutDuration time.Duration `yaml:"lockoutDuration,omitempty"` // also how long the failures are remembered; default: 15m
+	BaseDelay       time.Duration `yaml:"baseDelay,omitempty"`       // the delay after the first failure; default: 1s
+	MaxDelay        time.Duration `yaml:"maxDelay,omitempty"`        // default: 1m
+	AuditLogFile    string        `yaml:"auditLogFile,omitempty"`    // JSON lines of the failed logins; default: the log
+}
+
+// WithDefaults returns the config with the empty fields set to their defaults, for the configs built in code.
+func (c LoginConfig) WithDefaults() LoginConfig {
+	if c.MaxFailures == 0 {
+		c.MaxFailures = 5
+	}
+	if c.IPMaxFailures == 0 {
+		c.IPMaxFailures = 20
+	}
+	if c.LockoutDuration == 0 {
+		c.LockoutDuration = 15 * time.Minute
+	}
+	if c.BaseDelay == 0 {
+		c.BaseDelay = time.Second
+	}
+	if c.MaxDelay == 0 {
+		c.MaxDelay = time.Minute
+	}
+	return c
+}
+
+// SessionConfig is the lifetime of the sessions of the logins. A user can have several sessions, e.g. on two laptops.
+type SessionConfig struct {
+	TTL         time.Duration `yaml:"ttl,omitempty"`         // default: 48h
+	Sliding     bool          `yaml:"sliding,omitempty"`     // extend the expiry to ttl from each use, instead of from the login
+	MaxLifetime time.Duration `yaml:"maxLifetime,omitempty"` // with sliding, the limit from the login; 0 for none
+}
+
+// WithDefaults returns the config with the empty TTL set to its default, for the configs built in code.
+func (c SessionConfig) WithDefaults() SessionConfig {
+	if c.TTL == 0 {
+		c.TTL = 48 * time.Hour
+	}
+	return c
+}
+
+// OIDCConfig is an OpenID Connect provider for single sign-on, by the authorization code flow with PKCE.
+// The users are provisioned at their first login, with the roles of their groups.
+type OIDCConfig struct {
+	Issuer               string          `yaml:"issuer"`
+	ClientID             string          `yaml:"clientID"`
+	ClientSecret         string          `yaml:"clientSecret,omitempty"`         // empty for a public client
+	RedirectURL          string          `yaml:"redirectURL,omitempty"`          // default: externalURL + /auth/oidc/callback
+	Scopes               []string        `yaml:"scopes,omitempty"`               // default: openid, profile, email
+	UsernameClaim        string          `yaml:"usernameClaim,omitempty"`        // default: preferred_username, or email without it
+	GroupsClaim          string          `yaml:"groupsClaim,omitempty"`          // default: groups
+	GroupRoles           map[string]Role `yaml:"groupRoles,omitempty"`           // the highest role of the groups of a user applies
+	DefaultRole          Role            `yaml:"defaultRole,omitempty"`          // for the users without a role by groups; empty to deny them
+	DisablePasswordLogin bool            `yaml:"disablePasswordLogin,omitempty"` // to require single sign-on
+}
+
+// LDAPConfig is a directory to log in the users that are not in users.yml, by a search and a bind as the user.
+// The users are provisioned at their first login, with the roles of their groups.
+type LDAPConfig struct {
+	URL                string          `yaml:"url"`                          // ldap://host:389 or ldaps://host:636
+	StartTLS           bool            `yaml:"startTLS,omitempty"`           // upgrade an ldap:// connection
+	InsecureSkipVerify bool            `yaml:"insecureSkipVerify,omitempty"` // only for testing
+	Timeout            time.Duration   `yaml:"timeout,omitempty"`            // default: 10s
+	BindDN             string          `yaml:"bindDN,omitempty"`             // to search the users and the groups; empty for an anonymous search
+	BindPassword       string          `yaml:"bindPassword,omitempty"`
+	BaseDN             string          `yaml:"baseDN"`                   // of the users
+	UserFilter         string          `yaml:"userFilter,omitempty"`     // default: (uid={username})
+	GroupBaseDN        string          `yaml:"groupBaseDN,omitempty"`    // default: baseDN
+	GroupFilter        string          `yaml:"groupFilter,omitempty"`    // default: (member={dn}); {username} is also replaced
+	GroupAttribute     string          `yaml:"groupAttribute,omitempty"` // the name of a group; default: cn
+	GroupRoles         map[string]Role `yaml:"groupRoles,omitempty"`     // the highest role of the groups of a user applies
+	DefaultRole        Role            `yaml:"defaultRole,omitempty"`    // for the users without a role by groups; empty to deny them
 }
 
 type UserConfig struct {
-	EtcUsers []EtcUser `yaml:"users"`
+	EtcUsers      []EtcUser     `yaml:"users"`
+	Grants        []Grant       `yaml:"grants,omitempty"`
+	DefaultAccess DefaultAccess `yaml:"defaultAccess,omitempty"` // datasources of the users without a grant: all (default) or none
 }
 
 type EtcUser struct {
-	Username string `yaml:"username"`
-	Hash     string `yaml:"hash"`
-	IsAdmin  bool   `yaml:"isAdmin,omitempty"`
+	Username string   `yaml:"username"`
+	Hash     string   `yaml:"hash"`
+	IsAdmin  bool     `yaml:"isAdmin,omitempty"` // same as role: admin
+	Role     Role     `yaml:"role,omitempty"`    // default: viewer
+	Groups   []string `yaml:"groups,omitempty"`
 }
 
 type DatasourceConfig struct {
@@ -48,10 +141,18 @@ type AlertingConfigFile struct {
 }
 
 type AlertingConfig struct {
-	EvaluationInterval  time.Duration       `yaml:"evaluation_interval,omitempty"`
-	AlertRelabelConfigs []*relabel.Config   `yaml:"alert_relabel_configs,omitempty"`
-	AlertmanagerConfigs AlertmanagerConfigs `yaml:"alertmanagers,omitempty"`
-	GlobalLabels        map[string]string   `yaml:"globalLabels,omitempty"`
+	EvaluationInterval    time.Duration        `yaml:"evaluation_interval,omitempty"`
+	AlertRelabelConfigs   []*relabel.Config    `yaml:"alert_relabel_configs,omitempty"`
+	AlertmanagerConfigs   AlertmanagerConfigs  `yaml:"alertmanagers,omitempty"`
+	GlobalLabels          map[string]string    `yaml:"globalLabels,omitempty"`
+	RemoteWriteConfigs    []*RemoteWriteConfig `yaml:"remote_write,omitempty"`
+	Concurrency           int                  `yaml:"concurrency,omitempty"`            // default: 16, rule queries at the same time in total
+	DatasourceConcurrency int                  `yaml:"datasource_concurrency,omitempty"` // default: 4, for each datasource without its own
+}
+
+// RemoteWriteConfig is an endpoint where the results of recording rules are pushed.
+type RemoteWriteConfig struct {
+	URL string `yaml:"url"`
 }
 
 // AlertmanagerConfigs is a slice of *AlertmanagerConfig.
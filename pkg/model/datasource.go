@@ -9,6 +9,8 @@ type Datasource struct {
 	BasicAuthPassword string         `json:"basicAuthPassword" yaml:"basicAuthPassword"`
 	IsMain            bool           `json:"isMain,omitempty" yaml:"isMain,omitempty"`
 	IsDiscovered      bool           `json:"isDiscovered,omitempty" yaml:"isDiscovered,omitempty"`
+	Namespace         string         `json:"namespace,omitempty" yaml:"namespace,omitempty"`     // of the service, for the discovered ones
+	Concurrency       int            `json:"concurrency,omitempty" yaml:"concurrency,omitempty"` // queries of rule evaluation at the same time
 }
 
 type DatasourceType string
@@ -25,6 +25,7 @@ https://github.com/prometheus/prometheus/blob/v2.43.0/web/api/v1/api.go
 */
 
 type RuleFile struct {
+	File               string             `json:"-" yaml:"-"` // the path it is loaded from
 	Kind               string             `json:"kind,omitempty" yaml:"kind,omitempty"`
 	CommonLabels       map[string]string  `json:"commonLabels,omitempty" yaml:"commonLabels,omitempty"`
 	DatasourceSelector DatasourceSelector `json:"datasourceSelector" yaml:"datasourceSelector"`
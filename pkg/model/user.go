@@ -1,14 +1,126 @@
 package model
 
-import "time"
+import (
+	"slices"
+	"time"
+)
 
 type User struct {
-	ID           int    `gorm:"primaryKey"`
-	Username     string `gorm:"index:,unique"`
-	Hash         string
-	IsAdmin      bool
-	Token        string
-	TokenExpires time.Time
-	CreatedAt    time.Time
-	UpdatedAt    time.Time
+	ID        int       `gorm:"primaryKey" json:"id"`
+	Username  string    `gorm:"index:,unique" json:"username"`
+	Hash      string    `json:"-"`
+	IsAdmin   bool      `json:"isAdmin,omitempty"`
+	Role      Role      `json:"role"`
+	Groups    []string  `gorm:"serializer:json" json:"groups"`
+	Provider  string    `json:"provider,omitempty"` // who logs in the user, e.g. oidc, or none for a password
+	DBManaged bool      `json:"dbManaged"`          // the database is authoritative for the user, not users.yml
+	Disabled  bool      `json:"disabled"`           // cannot log in
+	CreatedAt time.Time `json:"createdAt"`
+	UpdatedAt time.Time `json:"updatedAt"`
+}
+
+// Session is a login of a user, by the token issued at the login. Only the hash of the token is stored.
+type Session struct {
+	ID         int    `gorm:"primaryKey"`
+	UserID     int    `gorm:"index"`
+	Hash       string `gorm:"index:,unique"`
+	ExpiresAt  time.Time
+	LastUsedAt time.Time
+	CreatedAt  time.Time
+}
+
+// GetRole returns the role of the user. IsAdmin is kept for the users before roles, and no role is a viewer.
+func (u User) GetRole() Role {
+	if u.IsAdmin {
+		return RoleAdmin
+	}
+	if u.Role == RoleNone {
+		return RoleViewer
+	}
+	return u.Role
+}
+
+// HasRole returns whether the user has the role or a higher one.
+func (u User) HasRole(role Role) bool {
+	return u.GetRole().Includes(role)
+}
+
+// Role is what a user can do. Each role can do what the lower ones can.
+type Role string
+
+const (
+	RoleNone   Role = ""
+	RoleViewer Role = "viewer" // reads the dashboards, datasources and alerts, and queries the datasources
+	RoleEditor Role = "editor" // also sends test alerts
+	RoleAdmin  Role = "admin"  // also reloads the configurations, and uses all datasources regardless of the grants
+)
+
+// roles are in ascending order.
+var roles = []Role{RoleViewer, RoleEditor, RoleAdmin}
+
+// Includes returns whether the role can do what the other can, i.e. it is the same or higher.
+func (r Role) Includes(other Role) bool {
+	return slices.Index(roles, r) >= slices.Index(roles, other)
+}
+
+// Valid returns whether the role is one of the roles, or none.
+func (r Role) Valid() bool {
+	return r == RoleNone || slices.Contains(roles, r)
+}
+
+// RoleOfGroups returns the highest role that groupRoles gives to the groups, or defaultRole if none is given.
+func RoleOfGroups(groupRoles map[string]Role, groups []string, defaultRole Role) Role {
+	role := RoleNone
+	for _, group := range groups {
+		if r, ok := groupRoles[group]; ok && (role == RoleNone || r.Includes(role)) {
+			role = r
+		}
+	}
+	if role == RoleNone {
+		return defaultRole
+	}
+	return role
+}
+
+// DefaultAccess is what the users without a grant that applies can use.
+type DefaultAccess string
+
+const (
+	DefaultAccessAll  DefaultAccess = "all"  // all datasources; the default, for the configurations before the grants
+	DefaultAccessNone DefaultAccess = "none" // no datasource, so that a user needs a grant
+)
+
+// Valid returns whether the default access is one of the above, or empty for all.
+func (d DefaultAccess) Valid() bool {
+	return d == "" || d == DefaultAccessAll || d == DefaultAccessNone
+}
+
+// Grant restricts the users and the members of the groups to the datasources,
+// selected by name or by namespace, e.g. the discovered ones in the namespaces of a team.
+// A namespace selects the datasources in it, not the data of a namespace in a shared datasource.
+// A user can use the datasources of all grants that apply, and those of the default access if none applies.
+type Grant struct {
+	Users       []string `yaml:"users,omitempty"`
+	Groups      []string `yaml:"groups,omitempty"`
+	Datasources []string `yaml:"datasources,omitempty"`
+	Namespaces  []string `yaml:"namespaces,omitempty"`
+}
+
+// AppliesTo returns whether the grant is for the user or one of the groups of the user.
+func (g Grant) AppliesTo(user User) bool {
+	if slices.Contains(g.Users, user.Username) {
+		return true
+	}
+	for _, group := range user.Groups {
+		if slices.Contains(g.Groups, group) {
+			return true
+		}
+	}
+	return false
+}
+
+// Allows returns whether the grant includes the datasource.
+func (g Grant) Allows(datasource Datasource) bool {
+	return slices.Contains(g.Datasources, datasource.Name) ||
+		(datasource.Namespace != "" && slices.Contains(g.Namespaces, datasource.Namespace))
 }
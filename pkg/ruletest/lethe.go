@@ -0,0 +1,180 @@
+package ruletest
+
+import (
+	"fmt"
+	"net/http"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	commonmodel "github.com/prometheus/common/model"
+	"github.com/prometheus/prometheus/model/labels"
+	"github.com/prometheus/prometheus/promql/parser"
+
+	"github.com/kuoss/venti/pkg/mocker"
+)
+
+type parsedLogStream struct {
+	labels labels.Labels // with the log type as __name__, e.g. pod or node
+	lines  []logLine
+}
+
+func parseLogStreams(streams []logStream) ([]parsedLogStream, error) {
+	parsed := make([]parsedLogStream, len(streams))
+	for i, stream := range streams {
+		lbls, err := parser.ParseMetric(stream.Labels)
+		if err != nil {
+			return nil, fmt.Errorf("parse labels %q err: %w", stream.Labels, err)
+		}
+		parsed[i] = parsedLogStream{labels: lbls, lines: stream.Lines}
+	}
+	return parsed, nil
+}
+
+// logQuery is the subset of LetheQL for log lines:
+// a selector with an optional range, followed by line filters, e.g. pod{namespace="default"}[5m] |= "error" != "timeout".
+type logQuery struct {
+	matchers []*labels.Matcher
+	rng      time.Duration // 0 for the default range
+	filters  []lineFilter
+}
+
+type lineFilter struct {
+	op    string // one of |=, !=, |~, !~
+	value string
+	re    *regexp.Regexp
+}
+
+func (f lineFilter) match(line string) bool {
+	switch f.op {
+	case "|=":
+		return strings.Contains(line, f.value)
+	case "!=":
+		return !strings.Contains(line, f.value)
+	case "|~":
+		return f.re.MatchString(line)
+	}
+	return !f.re.MatchString(line)
+}
+
+var (
+	logSelectorRegexp = regexp.MustCompile(`^\s*([a-zA-Z_:][a-zA-Z0-9_:]*)?\s*(\{(?:[^}"]|"(?:[^"\\]|\\.)*")*\})?`)
+	logRangeRegexp    = regexp.MustCompile(`^\s*\[([^\]]+)\]`)
+	lineFilterRegexp  = regexp.MustCompile("^\\s*(\\|=|!=|\\|~|!~)\\s*(\"(?:[^\"\\\\]|\\\\.)*\"|`[^`]*`)")
+)
+
+func parseLogQuery(query string) (*logQuery, error) {
+	selector := logSelectorRegexp.FindString(query)
+	if strings.TrimSpace(selector) == "" {
+		return nil, fmt.Errorf("no log selector in %q", query)
+	}
+	matchers, err := parser.ParseMetricSelector(selector)
+	if err != nil {
+		return nil, fmt.Errorf("parse selector err: %w", err)
+	}
+	q := &logQuery{matchers: matchers}
+	rest := query[len(selector):]
+
+	if m := logRangeRegexp.FindStringSubmatch(rest); m != nil {
+		rng, err := commonmodel.ParseDuration(m[1])
+		if err != nil {
+			return nil, fmt.Errorf("parse range err: %w", err)
+		}
+		q.rng = time.Duration(rng)
+		rest = rest[len(m[0]):]
+	}
+
+	for strings.TrimSpace(rest) != "" {
+		m := lineFilterRegexp.FindStringSubmatch(rest)
+		if m == nil {
+			return nil, fmt.Errorf("unexpected %q", strings.TrimSpace(rest))
+		}
+		value, err := strconv.Unquote(m[2])
+		if err != nil {
+			return nil, fmt.Errorf("unquote err: %w", err)
+		}
+		filter := lineFilter{op: m[1], value: value}
+		if filter.op == "|~" || filter.op == "!~" {
+			filter.re, err = regexp.Compile(value)
+			if err != nil {
+				return nil, fmt.Errorf("compile err: %w", err)
+			}
+		}
+		q.filters = append(q.filters, filter)
+		rest = rest[len(m[0]):]
+	}
+	return q, nil
+}
+
+func (q *logQuery) matchLabels(lbls labels.Labels) bool {
+	for _, m := range q.matchers {
+		if !m.Matches(lbls.Get(m.Name)) {
+			return false
+		}
+	}
+	return true
+}
+
+func (q *logQuery) matchLine(line string) bool {
+	for _, f := range q.filters {
+		if !f.match(line) {
+			return false
+		}
+	}
+	return true
+}
+
+type logEntry struct {
+	time   time.Duration
+	fields map[string]string
+}
+
+// newFakeLethe serves the input logs over the query API of Lethe.
+// A query without a range gets the lines of the last defaultRange, i.e. since the previous evaluation.
+func newFakeLethe(streams []parsedLogStream, clk *clock, defaultRange time.Duration) (*mocker.Server, error) {
+	s := mocker.New()
+	s.GET("/api/v1/query", func(c *mocker.Context) {
+		q, err := parseLogQuery(c.Query("query"))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, mocker.H{"status": "error", "errorType": "bad_data", "error": err.Error()})
+			return
+		}
+		end := clk.get().Sub(time.Unix(0, 0))
+		rng := q.rng
+		if rng == 0 {
+			rng = defaultRange
+		}
+
+		var entries []logEntry
+		for _, stream := range streams {
+			if !q.matchLabels(stream.labels) {
+				continue
+			}
+			for _, line := range stream.lines {
+				t := time.Duration(line.Time)
+				if t <= end-rng || t > end || !q.matchLine(line.Log) {
+					continue
+				}
+				fields := stream.labels.Map()
+				delete(fields, labels.MetricName)
+				fields["time"] = time.Unix(0, 0).UTC().Add(t).Format("2006-01-02T15:04:05.000000Z")
+				fields["log"] = line.Log
+				entries = append(entries, logEntry{time: t, fields: fields})
+			}
+		}
+		sort.SliceStable(entries, func(i, j int) bool { return entries[i].time < entries[j].time })
+		result := make([]map[string]string, len(entries))
+		for i, entry := range entries {
+			result[i] = entry.fields
+		}
+		c.JSON(http.StatusOK, mocker.H{"status": "success", "data": mocker.H{"resultType": "logs", "result": result}})
+	})
+
+	err := s.Start()
+	if err != nil {
+		err = fmt.Errorf("error on Start: %w", err)
+	}
+	return s, err
+}
@@ -0,0 +1,44 @@
+package ruletest
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseLogQuery(t *testing.T) {
+	testCases := []struct {
+		query     string
+		lines     map[string]bool // whether each line matches
+		wantRange time.Duration
+		wantError string
+	}{
+		{`pod`, map[string]bool{"hello": true}, 0, ``},
+		{`pod{namespace="default"}[5m]`, map[string]bool{"hello": true}, 5 * time.Minute, ``},
+		{`pod{namespace="a}b"} |= "error"`, map[string]bool{"error: x": true, "warn: x": false}, 0, ``},
+		{`pod |= "error" != "timeout"`, map[string]bool{"error: x": true, "error: timeout": false}, 0, ``},
+		{"pod |~ `^E\\d+` !~ \"(?i)retry\"", map[string]bool{"E01 x": true, "E01 Retry": false, "W01 x": false}, 0, ``},
+		{`node{node="node1"}[1h] |= "\"quoted\""`, map[string]bool{`a "quoted" b`: true, "quoted": false}, time.Hour, ``},
+		// errors
+		{``, nil, 0, `no log selector in ""`},
+		{`pod{namespace=}`, nil, 0, `parse selector err: 1:15: parse error: unexpected "}" in label matching, expected string`},
+		{`pod[x]`, nil, 0, `parse range err: not a valid duration string: "x"`},
+		{`pod |= error`, nil, 0, `unexpected "|= error"`},
+		{`pod |~ "("`, nil, 0, "compile err: error parsing regexp: missing closing ): `(`"},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.query, func(t *testing.T) {
+			q, err := parseLogQuery(tc.query)
+			if tc.wantError != "" {
+				require.EqualError(t, err, tc.wantError)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, tc.wantRange, q.rng)
+			for line, want := range tc.lines {
+				require.Equal(t, want, q.matchLine(line), line)
+			}
+		})
+	}
+}
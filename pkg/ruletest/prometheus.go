@@ -0,0 +1,34 @@
+package ruletest
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/kuoss/venti/pkg/mocker"
+)
+
+// newFakePrometheus serves the input series over the query API of Prometheus,
+// evaluating the queries with the PromQL engine at the time of the clock.
+func newFakePrometheus(loader *seriesLoader, clk *clock) (*mocker.Server, error) {
+	s := mocker.New()
+	s.GET("/api/v1/query", func(c *mocker.Context) {
+		q, err := loader.query(c.Request.Context(), c.Query("query"), clk.get())
+		if err != nil {
+			c.JSON(http.StatusBadRequest, mocker.H{"status": "error", "errorType": "bad_data", "error": err.Error()})
+			return
+		}
+		defer q.Close()
+		res := q.Exec(c.Request.Context())
+		if res.Err != nil {
+			c.JSON(http.StatusUnprocessableEntity, mocker.H{"status": "error", "errorType": "execution", "error": res.Err.Error()})
+			return
+		}
+		c.JSON(http.StatusOK, mocker.H{"status": "success", "data": mocker.H{"resultType": res.Value.Type(), "result": res.Value}})
+	})
+
+	err := s.Start()
+	if err != nil {
+		err = fmt.Errorf("error on Start: %w", err)
+	}
+	return s, err
+}
@@ -0,0 +1,180 @@
+// Package ruletest runs unit tests of alert rules against fake datasources, like `promtool test rules`.
+// https://prometheus.io/docs/prometheus/latest/configuration/unit_testing_rules/
+package ruletest
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	commonmodel "github.com/prometheus/common/model"
+	"github.com/prometheus/prometheus/model/labels"
+
+	"github.com/kuoss/venti/pkg/config"
+	"github.com/kuoss/venti/pkg/model"
+	"github.com/kuoss/venti/pkg/service/alertrule"
+	"github.com/kuoss/venti/pkg/util"
+)
+
+const defaultEvaluationInterval = commonmodel.Duration(time.Minute)
+
+// testFile is the format of a test file, which follows the one of promtool with input_logs added.
+type testFile struct {
+	RuleFiles          []string             `yaml:"rule_files"` // globs relative to the test file
+	EvaluationInterval commonmodel.Duration `yaml:"evaluation_interval,omitempty"`
+	ExternalLabels     map[string]string    `yaml:"external_labels,omitempty"` // globalLabels of alerting.yml
+	ExternalURL        string               `yaml:"external_url,omitempty"`
+	Tests              []testGroup          `yaml:"tests"`
+}
+
+type testGroup struct {
+	Name           string               `yaml:"name,omitempty"`
+	Interval       commonmodel.Duration `yaml:"interval,omitempty"` // of input_series; default: evaluation_interval
+	InputSeries    []series             `yaml:"input_series,omitempty"`
+	InputLogs      []logStream          `yaml:"input_logs,omitempty"`
+	AlertRuleTests []alertTestCase      `yaml:"alert_rule_test,omitempty"`
+}
+
+// series is written in the expanding notation of promtool, e.g. values: '1+1x10 _ 5'.
+type series struct {
+	Series string `yaml:"series"`
+	Values string `yaml:"values"`
+}
+
+// logStream is the log lines of a target, e.g. labels: 'pod{namespace="default",pod="nginx",container="nginx"}'.
+type logStream struct {
+	Labels string    `yaml:"labels"`
+	Lines  []logLine `yaml:"lines"`
+}
+
+type logLine struct {
+	Time commonmodel.Duration `yaml:"time"`
+	Log  string               `yaml:"log"`
+}
+
+type alertTestCase struct {
+	EvalTime  commonmodel.Duration `yaml:"eval_time"`
+	Alertname string               `yaml:"alertname"`
+	ExpAlerts []expAlert           `yaml:"exp_alerts"`
+}
+
+type expAlert struct {
+	ExpLabels      map[string]string `yaml:"exp_labels"`
+	ExpAnnotations map[string]string `yaml:"exp_annotations"`
+}
+
+// Run runs each test file and reports it, with the number of tests in the summary
+// or all of the failures in the error.
+func Run(filenames []string) []config.FileCheck {
+	checks := make([]config.FileCheck, len(filenames))
+	for i, filename := range filenames {
+		checks[i].File = filename
+		count, err := runFile(filename)
+		if err != nil {
+			checks[i].Err = err
+			continue
+		}
+		checks[i].Summary = fmt.Sprintf("%d tests passed", count)
+	}
+	return checks
+}
+
+func runFile(filename string) (int, error) {
+	tf, err := loadTestFile(filename)
+	if err != nil {
+		return 0, fmt.Errorf("loadTestFile err: %w", err)
+	}
+	ruleFiles, err := loadRuleFiles(filepath.Dir(filename), tf.RuleFiles)
+	if err != nil {
+		return 0, fmt.Errorf("loadRuleFiles err: %w", err)
+	}
+	var errs []error
+	for _, tg := range tf.Tests {
+		errs = append(errs, tf.runTestGroup(tg, ruleFiles)...)
+	}
+	return len(tf.Tests), errors.Join(errs...)
+}
+
+func loadTestFile(filename string) (*testFile, error) {
+	yamlBytes, err := os.ReadFile(filename)
+	if err != nil {
+		return nil, fmt.Errorf("readFile err: %w", err)
+	}
+	var tf *testFile
+	if err := util.UnmarshalStrict(yamlBytes, &tf); err != nil {
+		return nil, fmt.Errorf("unmarshalStrict err: %w", err)
+	}
+	if tf == nil {
+		return nil, fmt.Errorf("empty test file")
+	}
+	if tf.EvaluationInterval == 0 {
+		tf.EvaluationInterval = defaultEvaluationInterval
+	}
+	return tf, nil
+}
+
+// loadRuleFiles loads the rule files with the loader of the server, so that they are validated as well.
+func loadRuleFiles(dir string, patterns []string) ([]model.RuleFile, error) {
+	if len(patterns) == 0 {
+		return nil, fmt.Errorf("no rule_files")
+	}
+	var ruleFiles []model.RuleFile
+	for _, pattern := range patterns {
+		if !filepath.IsAbs(pattern) {
+			pattern = filepath.Join(dir, pattern)
+		}
+		alertRuleService, err := alertrule.New(pattern)
+		if err != nil {
+			return nil, fmt.Errorf("alertrule.New err: %w", err)
+		}
+		files := alertRuleService.GetAlertRuleFiles()
+		if len(files) == 0 {
+			return nil, fmt.Errorf("no rule files match %q", pattern)
+		}
+		ruleFiles = append(ruleFiles, files...)
+	}
+	return ruleFiles, nil
+}
+
+// alertsError is a mismatch of the firing alerts of a rule at an evaluation time.
+type alertsError struct {
+	test      string
+	alertname string
+	evalTime  commonmodel.Duration
+	exp       []string
+	got       []string
+}
+
+func (e *alertsError) Error() string {
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "test %q: alertname %q at %s:\n", e.test, e.alertname, e.evalTime)
+	for _, part := range []struct {
+		name   string
+		alerts []string
+	}{{"exp", e.exp}, {"got", e.got}} {
+		fmt.Fprintf(&sb, "  %s:\n", part.name)
+		if len(part.alerts) == 0 {
+			sb.WriteString("    (none)\n")
+		}
+		for _, alert := range part.alerts {
+			fmt.Fprintf(&sb, "    %s\n", alert)
+		}
+	}
+	return strings.TrimSuffix(sb.String(), "\n")
+}
+
+// formatAlert writes an alert in a line, which makes alerts comparable.
+func formatAlert(lbls map[string]string, annotations map[string]string) string {
+	return labels.FromMap(lbls).String() + " " + labels.FromMap(annotations).String()
+}
+
+// compareAlerts compares the alerts regardless of their order.
+func compareAlerts(exp []string, got []string) bool {
+	slices.Sort(exp)
+	slices.Sort(got)
+	return slices.Equal(exp, got)
+}
@@ -0,0 +1,191 @@
+package ruletest
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func init() {
+	err := os.Chdir("../..")
+	if err != nil {
+		panic(err)
+	}
+}
+
+func TestRun(t *testing.T) {
+	checks := Run([]string{"testdata/ruletest/rules_test.yml", "testdata/ruletest/failing_test.yml", "testdata/ruletest/none.yml"})
+	require.Len(t, checks, 3)
+
+	require.Equal(t, "testdata/ruletest/rules_test.yml", checks[0].File)
+	require.Equal(t, "3 tests passed", checks[0].Summary)
+	require.NoError(t, checks[0].Err)
+
+	require.Equal(t, "testdata/ruletest/failing_test.yml", checks[1].File)
+	require.Empty(t, checks[1].Summary)
+	require.EqualError(t, checks[1].Err, `test "instance down too early": alertname "InstanceDown" at 2m:
+  exp:
+    {__name__="up", alertname="InstanceDown", datasource="prometheus", instance="node1:9100", job="node", severity="warning"} {summary="node1:9100 of node is down"}
+  got:
+    (none)`)
+
+	require.EqualError(t, checks[2].Err, "loadTestFile err: readFile err: open testdata/ruletest/none.yml: no such file or directory")
+}
+
+func TestRunFile(t *testing.T) {
+	testCases := []struct {
+		name      string
+		content   string
+		wantCount int
+		wantError string
+	}{
+		{
+			"empty",
+			`null`,
+			0, `loadTestFile err: empty test file`,
+		},
+		{
+			"unknown field",
+			`rule_file: []`,
+			0, `loadTestFile err: unmarshalStrict err: yaml: unmarshal errors:` + "\n" + `  line 1: field rule_file not found in type ruletest.testFile`,
+		},
+		{
+			"no rule_files",
+			`tests: []`,
+			0, `loadRuleFiles err: no rule_files`,
+		},
+		{
+			"no match",
+			`rule_files: [none.yml]`,
+			0, `loadRuleFiles err: no rule files match "DIR/none.yml"`,
+		},
+		{
+			"invalid input_series",
+			`
+rule_files: [rules.yml]
+tests:
+- name: t1
+  input_series:
+  - series: 'up{'
+    values: '1'
+`,
+			1, `test "t1": input_series err: series "up{": 1:5: parse error: unexpected character inside braces: '1'`,
+		},
+		{
+			"invalid input_logs",
+			`
+rule_files: [rules.yml]
+tests:
+- name: t1
+  input_logs:
+  - labels: 'pod{'
+`,
+			1, `test "t1": input_logs err: parse labels "pod{" err: 1:5: parse error: unexpected end of input inside braces`,
+		},
+		{
+			"no input",
+			`
+rule_files: [logs.yml]
+tests:
+- name: t1
+  alert_rule_test:
+  - eval_time: 1m
+    alertname: PodErrorLogs
+`,
+			1, ``,
+		},
+		{
+			"evaluation error",
+			`
+rule_files: [bad.yml]
+tests:
+- name: t1
+  alert_rule_test:
+  - eval_time: 1m
+    alertname: Bad
+`,
+			1, `test "t1": at 0s: rule "Bad": datasource(prometheus): queryRule err: not successful code=422`,
+		},
+		{
+			"intervals",
+			`
+rule_files: [intervals.yml]
+tests:
+- name: t1
+  alert_rule_test:
+  - eval_time: 2m
+    alertname: Every3m
+  - eval_time: 4m
+    alertname: Every3m
+    exp_alerts:
+    - exp_labels: {}
+  - eval_time: 4m
+    alertname: Every2m
+    exp_alerts:
+    - exp_labels: {}
+`,
+			1, ``,
+		},
+		{
+			"same alert name in groups of different datasources",
+			`
+rule_files: [down-logs.yml, down.yml]
+tests:
+- name: t1
+  alert_rule_test:
+  - eval_time: 1m
+    alertname: Down
+    exp_alerts:
+    - exp_labels: {datasource: prometheus}
+  - eval_time: 2m
+    alertname: Down
+    exp_alerts:
+    - exp_labels: {}
+`,
+			// without the datasource, the expected alert of 2m does not say which group it is of
+			1, `test "t1": alertname "Down" at 2m:` + "\n" + `  exp:` + "\n" + `    {alertname="Down"} {}` + "\n" + `  got:` + "\n" + `    {alertname="Down", datasource="prometheus"} {}`,
+		},
+		{
+			"no datasource matches",
+			`
+rule_files: [sub.yml]
+tests:
+- name: t1
+  alert_rule_test:
+  - eval_time: 1m
+    alertname: Sub
+`,
+			1, `test "t1": group "sub": no datasource matches the datasourceSelector`,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			dir := t.TempDir()
+			files := map[string]string{
+				"rules.yml": "groups: []",
+				"logs.yml":  "datasourceSelector: {type: lethe}\ngroups:\n- name: logs\n  rules:\n  - alert: PodErrorLogs\n    expr: pod{namespace=\"default\"}\n",
+				"bad.yml":   "datasourceSelector: {type: prometheus}\ngroups:\n- name: bad\n  rules:\n  - alert: Bad\n    expr: label_replace(up, \"x\", \"y\", \"z\", \"(\")\n",
+				"sub.yml":   "datasourceSelector: {system: sub}\ngroups:\n- name: sub\n  rules:\n  - alert: Sub\n    expr: up\n",
+				"test.yml":  tc.content,
+				// an alert of the same name on Lethe and on Prometheus
+				"down-logs.yml": "datasourceSelector: {type: lethe}\ngroups:\n- name: logs\n  rules:\n  - alert: Down\n    expr: pod{namespace=\"default\"}\n",
+				"down.yml":      "datasourceSelector: {type: prometheus}\ngroups:\n- name: down\n  rules:\n  - alert: Down\n    expr: vector(1)\n",
+				// the 3m group is evaluated at 0 and 3m, between the evaluations of the 2m group
+				"intervals.yml": "datasourceSelector: {type: prometheus}\ngroups:\n- name: every2m\n  interval: 2m\n  rules:\n  - alert: Every2m\n    expr: vector(time()) >= 120\n- name: every3m\n  interval: 3m\n  rules:\n  - alert: Every3m\n    expr: vector(time()) >= 180\n",
+			}
+			for name, content := range files {
+				require.NoError(t, os.WriteFile(filepath.Join(dir, name), []byte(content), 0660))
+			}
+			count, err := runFile(filepath.Join(dir, "test.yml"))
+			require.Equal(t, tc.wantCount, count)
+			if tc.wantError == "" {
+				require.NoError(t, err)
+				return
+			}
+			require.EqualError(t, err, strings.ReplaceAll(tc.wantError, "DIR", dir))
+		})
+	}
+}
@@ -0,0 +1,221 @@
+package ruletest
+
+import (
+	"fmt"
+	"net/http"
+	"slices"
+	"sync"
+	"time"
+
+	commonmodel "github.com/prometheus/common/model"
+	"github.com/prometheus/prometheus/model/labels"
+
+	"github.com/kuoss/venti/pkg/config"
+	"github.com/kuoss/venti/pkg/model"
+	"github.com/kuoss/venti/pkg/service/alerting"
+	"github.com/kuoss/venti/pkg/service/datasource"
+	"github.com/kuoss/venti/pkg/service/remote"
+	"github.com/kuoss/venti/pkg/webapi"
+)
+
+// The names of the fake datasources, which are the datasource label of the alerts.
+const (
+	prometheusName = "prometheus"
+	letheName      = "lethe"
+)
+
+// clock is the evaluation time of a test, which the fake datasources answer at.
+type clock struct {
+	mu sync.RWMutex
+	t  time.Time
+}
+
+func (c *clock) set(t time.Time) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.t = t
+}
+
+func (c *clock) get() time.Time {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.t
+}
+
+// runTestGroup evaluates the rules from time 0 to the last eval_time, by the intervals of their groups,
+// and compares the firing alerts at each eval_time. The first evaluation error stops the test.
+func (tf *testFile) runTestGroup(tg testGroup, ruleFiles []model.RuleFile) []error {
+	if tg.Interval == 0 {
+		tg.Interval = tf.EvaluationInterval
+	}
+	clk := &clock{t: time.Unix(0, 0).UTC()}
+
+	loader, err := newSeriesLoader(tg, time.Duration(tf.EvaluationInterval))
+	if err != nil {
+		return []error{fmt.Errorf("test %q: input_series err: %w", tg.Name, err)}
+	}
+	defer loader.Close()
+	prometheus, err := newFakePrometheus(loader, clk)
+	if err != nil {
+		return []error{fmt.Errorf("test %q: newFakePrometheus err: %w", tg.Name, err)}
+	}
+	defer prometheus.Close()
+	streams, err := parseLogStreams(tg.InputLogs)
+	if err != nil {
+		return []error{fmt.Errorf("test %q: input_logs err: %w", tg.Name, err)}
+	}
+	lethe, err := newFakeLethe(streams, clk, time.Duration(tf.EvaluationInterval))
+	if err != nil {
+		return []error{fmt.Errorf("test %q: newFakeLethe err: %w", tg.Name, err)}
+	}
+	defer lethe.Close()
+
+	datasourceService, err := datasource.New(&model.DatasourceConfig{Datasources: []model.Datasource{
+		{Type: model.DatasourceTypePrometheus, Name: prometheusName, URL: prometheus.URL},
+		{Type: model.DatasourceTypeLethe, Name: letheName, URL: lethe.URL},
+	}}, nil)
+	if err != nil {
+		return []error{fmt.Errorf("test %q: datasource.New err: %w", tg.Name, err)}
+	}
+	cfg := &config.Config{
+		GlobalConfig: model.GlobalConfig{ExternalURL: tf.ExternalURL},
+		AlertingConfig: model.AlertingConfig{
+			EvaluationInterval: time.Duration(tf.EvaluationInterval),
+			GlobalLabels:       tf.ExternalLabels,
+		},
+	}
+	service := alerting.New(cfg, ruleFiles, datasourceService, remote.New(&http.Client{}, 10*time.Second))
+
+	// the rules are evaluated at each multiple of the greatest common divisor of the intervals,
+	// and each group at the multiples of its own interval, e.g. groups of 2m and 3m at 0, 2m, 3m, 4m, 6m...
+	// an alert gets the datasource label of the only datasource of its group
+	groups := service.GetAlertingRuleGroups()
+	alertDatasources := map[alertKey]string{}
+	step := time.Duration(0)
+	for _, group := range groups {
+		datasources := datasourceService.GetDatasourcesWithSelector(group.DatasourceSelector)
+		if len(datasources) == 0 {
+			return []error{fmt.Errorf("test %q: group %q: no datasource matches the datasourceSelector", tg.Name, group.Name)}
+		}
+		for _, ar := range group.AlertingRules {
+			key := alertKey{group.File, group.Name, ar.Rule.Alert}
+			if len(datasources) == 1 {
+				alertDatasources[key] = datasources[0].Name
+			} else {
+				alertDatasources[key] = ""
+			}
+		}
+		step = gcd(step, group.Interval)
+	}
+
+	alertTests := slices.Clone(tg.AlertRuleTests)
+	slices.SortStableFunc(alertTests, func(a, b alertTestCase) int { return int(a.EvalTime - b.EvalTime) })
+	if len(alertTests) == 0 || step == 0 {
+		return nil
+	}
+	maxEvalTime := time.Duration(alertTests[len(alertTests)-1].EvalTime)
+
+	var errs []error
+	for ts := time.Duration(0); ts <= maxEvalTime; ts += step {
+		evalTime := time.Unix(0, 0).UTC().Add(ts)
+		if err := loader.appendTill(evalTime); err != nil {
+			return append(errs, fmt.Errorf("test %q: load samples at %s err: %w", tg.Name, commonmodel.Duration(ts), err))
+		}
+		clk.set(evalTime)
+		for _, group := range groups {
+			if ts%group.Interval != 0 {
+				continue
+			}
+			// DoAlertGroup fails only on sending the alerts, and there are no alertmanagers to send to
+			_ = service.DoAlertGroup(group, evalTime)
+		}
+		if err := evalError(service.GetRuleDiscovery(alerting.RulesFilter{})); err != nil {
+			return append(errs, fmt.Errorf("test %q: at %s: %w", tg.Name, commonmodel.Duration(ts), err))
+		}
+
+		// the state at an eval_time is the one of the last evaluation before it
+		for len(alertTests) > 0 && time.Duration(alertTests[0].EvalTime) < ts+step {
+			if err := checkAlerts(service, tg.Name, alertTests[0], alertDatasources); err != nil {
+				errs = append(errs, err)
+			}
+			alertTests = alertTests[1:]
+		}
+	}
+	return errs
+}
+
+func gcd(a, b time.Duration) time.Duration {
+	for b != 0 {
+		a, b = b, a%b
+	}
+	return a
+}
+
+// evalError returns the first error of the last evaluation of the rules.
+func evalError(discovery *webapi.RuleDiscovery) error {
+	for _, group := range discovery.RuleGroups {
+		for _, rule := range group.Rules {
+			switch r := rule.(type) {
+			case *webapi.AlertingRule:
+				if r.LastError != "" {
+					return fmt.Errorf("rule %q: %s", r.Name, r.LastError)
+				}
+			case *webapi.RecordingRule:
+				if r.LastError != "" {
+					return fmt.Errorf("rule %q: %s", r.Name, r.LastError)
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// alertKey identifies an alerting rule by its group, as several groups can have an alert of the same name.
+type alertKey struct {
+	file  string
+	group string
+	alert string
+}
+
+// alertDatasource returns the datasource label that all the alerts of the name get,
+// or "" if their groups have several datasources or different ones, so that the expected alerts set it.
+func alertDatasource(alertDatasources map[alertKey]string, alertname string) string {
+	name := ""
+	for key, datasource := range alertDatasources {
+		if key.alert != alertname {
+			continue
+		}
+		if datasource == "" || (name != "" && datasource != name) {
+			return ""
+		}
+		name = datasource
+	}
+	return name
+}
+
+func checkAlerts(service *alerting.AlertingService, test string, tc alertTestCase, alertDatasources map[alertKey]string) error {
+	datasource := alertDatasource(alertDatasources, tc.Alertname)
+	var exp []string
+	for _, alert := range tc.ExpAlerts {
+		lbls := map[string]string{"alertname": tc.Alertname}
+		if datasource != "" {
+			lbls["datasource"] = datasource
+		}
+		for k, v := range alert.ExpLabels {
+			lbls[k] = v
+		}
+		exp = append(exp, formatAlert(lbls, alert.ExpAnnotations))
+	}
+	var got []string
+	discovery := service.GetAlertDiscovery(alerting.AlertsFilter{
+		State:    alerting.StateFiring.String(),
+		Matchers: [][]*labels.Matcher{{labels.MustNewMatcher(labels.MatchEqual, "alertname", tc.Alertname)}},
+	})
+	for _, alert := range discovery.Alerts {
+		got = append(got, formatAlert(alert.Labels, alert.Annotations))
+	}
+	if compareAlerts(exp, got) {
+		return nil
+	}
+	return &alertsError{test: test, alertname: tc.Alertname, evalTime: tc.EvalTime, exp: exp, got: got}
+}
@@ -0,0 +1,123 @@
+package ruletest
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/prometheus/prometheus/model/labels"
+	"github.com/prometheus/prometheus/promql"
+	"github.com/prometheus/prometheus/promql/parser"
+	"github.com/prometheus/prometheus/storage"
+	"github.com/prometheus/prometheus/tsdb"
+)
+
+// seriesLoader loads the input series into a TSDB in a temporary directory, up to the evaluation time,
+// so that a query sees the samples of the past only, as it would on a live Prometheus.
+// It uses the PromQL engine and the TSDB directly, rather than promqltest, whose test scripts venti does not need.
+type seriesLoader struct {
+	dir     string
+	db      *tsdb.DB
+	engine  *promql.Engine
+	pending []pendingSeries
+}
+
+// pendingSeries is the samples of a series that are not loaded yet, in the order of their timestamps.
+type pendingSeries struct {
+	lbls    labels.Labels
+	samples []promql.Sample
+}
+
+// The subqueries without a step are at the evaluation interval, as on Prometheus.
+func newSeriesLoader(tg testGroup, evaluationInterval time.Duration) (*seriesLoader, error) {
+	pending, err := parseInputSeries(tg.InputSeries, time.Duration(tg.Interval))
+	if err != nil {
+		return nil, err
+	}
+	dir, err := os.MkdirTemp("", "venti-ruletest")
+	if err != nil {
+		return nil, fmt.Errorf("MkdirTemp err: %w", err)
+	}
+	// the samples are appended series by series, so the appendable window is a day
+	opts := tsdb.DefaultOptions()
+	opts.MinBlockDuration = int64(24 * time.Hour / time.Millisecond)
+	opts.MaxBlockDuration = opts.MinBlockDuration
+	opts.RetentionDuration = 0
+	opts.EnableNativeHistograms = true
+	db, err := tsdb.Open(dir, nil, nil, opts, nil)
+	if err != nil {
+		_ = os.RemoveAll(dir)
+		return nil, fmt.Errorf("tsdb.Open err: %w", err)
+	}
+	engine := promql.NewEngine(promql.EngineOpts{
+		MaxSamples:               50000000,
+		Timeout:                  100 * time.Second,
+		EnableAtModifier:         true,
+		EnableNegativeOffset:     true,
+		NoStepSubqueryIntervalFn: func(int64) int64 { return evaluationInterval.Milliseconds() },
+	})
+	return &seriesLoader{dir: dir, db: db, engine: engine, pending: pending}, nil
+}
+
+// parseInputSeries expands the values of the series, which are interval apart from time 0.
+func parseInputSeries(inputSeries []series, interval time.Duration) ([]pendingSeries, error) {
+	pending := make([]pendingSeries, 0, len(inputSeries))
+	for _, s := range inputSeries {
+		lbls, values, err := parser.ParseSeriesDesc(s.Series + " " + s.Values)
+		if err != nil {
+			return nil, fmt.Errorf("series %q: %w", s.Series, err)
+		}
+		ps := pendingSeries{lbls: lbls}
+		for i, v := range values {
+			if v.Omitted {
+				continue
+			}
+			ps.samples = append(ps.samples, promql.Sample{T: (time.Duration(i) * interval).Milliseconds(), F: v.Value, H: v.Histogram})
+		}
+		pending = append(pending, ps)
+	}
+	return pending, nil
+}
+
+// appendTill loads the samples up to the time.
+func (l *seriesLoader) appendTill(t time.Time) error {
+	ts := t.UnixMilli()
+	app := l.db.Appender(context.Background())
+	for i := range l.pending {
+		ps := &l.pending[i]
+		n := 0
+		for ; n < len(ps.samples) && ps.samples[n].T <= ts; n++ {
+			if err := appendSample(app, ps.lbls, ps.samples[n]); err != nil {
+				_ = app.Rollback()
+				return err
+			}
+		}
+		ps.samples = ps.samples[n:]
+	}
+	return app.Commit()
+}
+
+func appendSample(app storage.Appender, lbls labels.Labels, s promql.Sample) error {
+	var err error
+	if s.H != nil {
+		_, err = app.AppendHistogram(0, lbls, s.T, nil, s.H)
+	} else {
+		_, err = app.Append(0, lbls, s.T, s.F)
+	}
+	if err != nil {
+		return fmt.Errorf("append %s err: %w", lbls, err)
+	}
+	return nil
+}
+
+// query evaluates an instant query at the time.
+func (l *seriesLoader) query(ctx context.Context, qs string, t time.Time) (promql.Query, error) {
+	return l.engine.NewInstantQuery(ctx, l.db, nil, qs, t)
+}
+
+func (l *seriesLoader) Close() {
+	_ = l.engine.Close()
+	_ = l.db.Close()
+	_ = os.RemoveAll(l.dir)
+}
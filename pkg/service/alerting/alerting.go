@@ -1,18 +1,19 @@
 package alerting
 
 import (
-	"bytes"
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"net/http"
 	"net/url"
 	"strings"
-	"text/template"
+	"sync"
 	"time"
 
 	"github.com/kuoss/common/logger"
 	commonmodel "github.com/prometheus/common/model"
+	"github.com/prometheus/prometheus/model/relabel"
 	"github.com/valyala/fastjson"
 
 	"github.com/kuoss/venti/pkg/config"
@@ -24,88 +25,170 @@ import (
 
 type IAlertingService interface {
 	DoAlert() error
+	DoAlertGroup(group *AlertingRuleGroup, evalTime time.Time) error
+	GetAlertingRuleGroups() []*AlertingRuleGroup
+	ReloadDatasources() error
 }
 
 type AlertingService struct {
-	alertingRuleGroups  []AlertingRuleGroup
+	alertingRuleGroups  []*AlertingRuleGroup
 	globalLabels        map[string]string
+	externalURL         string
 	datasourceService   datasourceservice.IDatasourceService
 	datasourceReload    bool
 	remoteService       *remote.RemoteService
-	alertmanagerConfigs model.AlertmanagerConfigs
-	alertmanagerURL     string
-	client              http.Client
+	alertmanagers       []*alertmanager
+	alertRelabelConfigs []*relabel.Config
+	droppedAlerts       droppedAlerts
+	remoteWriters       []*remoteWriter
+	stateStore          *StateStore
+	limiter             *queryLimiter
 }
 
-var (
-	fakeErr1 bool = false
-	fakeErr2 bool = false
+const (
+	// resendDelay is the minimum validity of a firing alert, like --rules.alert.resend-delay of Prometheus.
+	resendDelay = time.Minute
+	// resolvedSendCount is how many cycles a resolved alert is sent to Alertmanager.
+	resolvedSendCount = 3
 )
 
+var fakeErr1 bool = false
+
 func New(cfg *config.Config, alertRuleFiles []model.RuleFile, datasourceService datasourceservice.IDatasourceService, remoteService *remote.RemoteService) *AlertingService {
-	var alertmanagerConfigs model.AlertmanagerConfigs
-	var alertmanagerURL string
-	if len(cfg.AlertingConfig.AlertmanagerConfigs) > 0 {
-		alertmanagerConfigs = cfg.AlertingConfig.AlertmanagerConfigs
-		if len(cfg.AlertingConfig.AlertmanagerConfigs[0].StaticConfig) > 0 && len(cfg.AlertingConfig.AlertmanagerConfigs[0].StaticConfig[0].Targets) > 0 {
-			alertmanagerURL = cfg.AlertingConfig.AlertmanagerConfigs[0].StaticConfig[0].Targets[0]
+	client := &http.Client{Timeout: 5 * time.Second}
+	var alertmanagers []*alertmanager
+	seen := map[string]bool{}
+	for _, alertmanagerConfig := range cfg.AlertingConfig.AlertmanagerConfigs {
+		for _, staticConfig := range alertmanagerConfig.StaticConfig {
+			for _, target := range staticConfig.Targets {
+				if seen[target] {
+					continue
+				}
+				seen[target] = true
+				alertmanagers = append(alertmanagers, newAlertmanager(target, client))
+			}
 		}
 	}
-	var alertingRuleGroups = []AlertingRuleGroup{}
+	var remoteWriters []*remoteWriter
+	for _, remoteWriteConfig := range cfg.AlertingConfig.RemoteWriteConfigs {
+		remoteWriters = append(remoteWriters, newRemoteWriter(remoteWriteConfig.URL, client))
+	}
+	var alertingRuleGroups = []*AlertingRuleGroup{}
 	for _, alertRuleFile := range alertRuleFiles {
-		var alertingRules = []AlertingRule{}
 		for _, group := range alertRuleFile.RuleGroups {
+			var alertingRules = []*AlertingRule{}
+			var recordingRules []*RecordingRule
+			// recorded series are shared by the rules of a group
+			recorded := newRecordedSeries()
 			for _, rule := range group.Rules {
-				alertingRules = append(alertingRules, AlertingRule{
-					Rule:   rule,
-					Active: map[uint64]*Alert{},
+				if rule.Record != "" {
+					recordingRules = append(recordingRules, &RecordingRule{Rule: rule, recorded: recorded})
+					continue
+				}
+				alertingRules = append(alertingRules, &AlertingRule{
+					Rule:         rule,
+					Active:       map[uint64]*Alert{},
+					generatorURL: generatorURL(cfg.GlobalConfig.ExternalURL, alertRuleFile.DatasourceSelector, rule.Expr),
+					recorded:     recorded,
 				})
 			}
+			// a group without its own interval falls back to the global one
+			interval := group.Interval
+			if interval == 0 {
+				interval = cfg.AlertingConfig.EvaluationInterval
+			}
+			alertingRuleGroups = append(alertingRuleGroups, &AlertingRuleGroup{
+				Name:               group.Name,
+				File:               alertRuleFile.File,
+				Interval:           interval,
+				Limit:              group.Limit,
+				DatasourceSelector: alertRuleFile.DatasourceSelector,
+				GroupLabels:        alertRuleFile.CommonLabels,
+				AlertingRules:      alertingRules,
+				RecordingRules:     recordingRules,
+			})
 		}
-		alertingRuleGroups = append(alertingRuleGroups, AlertingRuleGroup{
-			DatasourceSelector: alertRuleFile.DatasourceSelector,
-			GroupLabels:        alertRuleFile.CommonLabels,
-			AlertingRules:      alertingRules,
-		})
 	}
 	return &AlertingService{
 		alertingRuleGroups:  alertingRuleGroups,
 		globalLabels:        cfg.AlertingConfig.GlobalLabels,
+		externalURL:         cfg.GlobalConfig.ExternalURL,
 		datasourceService:   datasourceService,
 		datasourceReload:    cfg.DatasourceConfig.Discovery.Enabled,
 		remoteService:       remoteService,
-		alertmanagerConfigs: alertmanagerConfigs,
-		alertmanagerURL:     alertmanagerURL,
-		client:              http.Client{Timeout: 5 * time.Second},
+		alertmanagers:       alertmanagers,
+		alertRelabelConfigs: cfg.AlertingConfig.AlertRelabelConfigs,
+		remoteWriters:       remoteWriters,
+		limiter:             newQueryLimiter(cfg.AlertingConfig.Concurrency, cfg.AlertingConfig.DatasourceConcurrency),
 	}
 }
 
-func (s *AlertingService) GetAlertingRuleGroups() []AlertingRuleGroup {
+// Close stops the queues of the alertmanagers and the remote writers, once they have sent the pending alerts and samples.
+// The service must not evaluate rules any more, e.g. its alerter is stopped.
+func (s *AlertingService) Close() {
+	var wg sync.WaitGroup
+	for _, am := range s.alertmanagers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			am.stop()
+		}()
+	}
+	for _, w := range s.remoteWriters {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			w.stop()
+		}()
+	}
+	wg.Wait()
+}
+
+// generatorURL links an alert back to the metrics or logs view of Venti with the rule expression.
+func generatorURL(externalURL string, selector model.DatasourceSelector, expr string) string {
+	if externalURL == "" {
+		return ""
+	}
+	path := "/metrics"
+	if selector.Type == model.DatasourceTypeLethe {
+		path = "/logs"
+	}
+	return strings.TrimSuffix(externalURL, "/") + path + "?query=" + url.QueryEscape(expr)
+}
+
+func (s *AlertingService) GetAlertingRuleGroups() []*AlertingRuleGroup {
 	return s.alertingRuleGroups
 }
 
 func (s *AlertingService) GetAlertmanagerDiscovery() webapi.AlertmanagerDiscovery {
-	var alertmanagers []*webapi.AlertmanagerTarget
-	for _, alertmanagerConfig := range s.alertmanagerConfigs {
-		for _, staticConfig := range alertmanagerConfig.StaticConfig {
-			for _, target := range staticConfig.Targets {
-				alertmanagers = append(alertmanagers, &webapi.AlertmanagerTarget{URL: target})
-			}
-		}
+	alertmanagers := []*webapi.AlertmanagerTarget{}
+	for _, am := range s.alertmanagers {
+		alertmanagers = append(alertmanagers, am.target())
 	}
 	return webapi.AlertmanagerDiscovery{
 		ActiveAlertmanagers:  alertmanagers,
 		DroppedAlertmanagers: []*webapi.AlertmanagerTarget{},
+		DroppedAlerts:        s.droppedAlerts.list(time.Now()),
 	}
 }
 
+// ReloadDatasources refreshes discovered datasources. It is a no-op when discovery is disabled.
+func (s *AlertingService) ReloadDatasources() error {
+	if !s.datasourceReload {
+		return nil
+	}
+	err := s.datasourceService.Reload()
+	logger.Debugf("datasourceService.Reload") // 2023-09-19
+	if err != nil {
+		return fmt.Errorf("reload err: %w", err)
+	}
+	return nil
+}
+
+// DoAlert evaluates all groups at once and sends their fires.
 func (s *AlertingService) DoAlert() error {
-	if s.datasourceReload {
-		err := s.datasourceService.Reload()
-		logger.Debugf("datasourceService.Reload") // 2023-09-19
-		if err != nil {
-			return fmt.Errorf("reload err: %w", err)
-		}
+	if err := s.ReloadDatasources(); err != nil {
+		return err
 	}
 	fires := []Fire{}
 	s.evalAlertingRuleGroups(&fires)
@@ -116,14 +199,40 @@ func (s *AlertingService) DoAlert() error {
 	return nil
 }
 
+// DoAlertGroup evaluates a single group at evalTime and sends its fires.
+func (s *AlertingService) DoAlertGroup(group *AlertingRuleGroup, evalTime time.Time) error {
+	fires := []Fire{}
+	s.evalAlertingRuleGroup(group, evalTime, &fires)
+	err := s.sendFires(fires)
+	if err != nil {
+		return fmt.Errorf("sendFires err: %w", err)
+	}
+	return nil
+}
+
+// evalAlertingRuleGroups evaluates all groups concurrently.
 func (s *AlertingService) evalAlertingRuleGroups(fires *[]Fire) {
 	evalTime := time.Now()
-	for _, group := range s.alertingRuleGroups {
-		s.evalAlertingRuleGroup(&group, evalTime, fires)
+	groupFires := make([][]Fire, len(s.alertingRuleGroups))
+	var wg sync.WaitGroup
+	for i, group := range s.alertingRuleGroups {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			s.evalAlertingRuleGroup(group, evalTime, &groupFires[i])
+		}()
+	}
+	wg.Wait()
+	for _, f := range groupFires {
+		*fires = append(*fires, f...)
 	}
 }
 
 func (s *AlertingService) evalAlertingRuleGroup(group *AlertingRuleGroup, evalTime time.Time, fires *[]Fire) {
+	start := time.Now()
+	defer func() {
+		group.status.set(evalTime, time.Since(start), nil)
+	}()
 	datasources := s.datasourceService.GetDatasourcesWithSelector(group.DatasourceSelector)
 	logger.Debugf("datasources(%d): %v", len(datasources), datasources) // 2023-09-19
 	labels := map[string]string{}
@@ -133,139 +242,201 @@ func (s *AlertingService) evalAlertingRuleGroup(group *AlertingRuleGroup, evalTi
 	for k, v := range group.GroupLabels {
 		labels[k] = v
 	}
-	for _, ar := range group.AlertingRules {
-		s.evalAlertingRule(&ar, datasources, labels, evalTime, fires)
-	}
+	// recording rules go first, so that alerting rules see their results
+	s.evalRecordingRules(group, datasources, evalTime)
+
+	// https://github.com/prometheus/prometheus/blob/v3.5.0/rules/group.go#L606
+	validUntil := evalTime.Add(4 * max(resendDelay, group.Interval))
+	// alerting rules do not depend on each other, so they are evaluated concurrently
+	ruleFires := make([][]Fire, len(group.AlertingRules))
+	var wg sync.WaitGroup
+	for i, ar := range group.AlertingRules {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			s.evalAlertingRule(ar, datasources, labels, evalTime, validUntil, &ruleFires[i])
+		}()
+	}
+	wg.Wait()
+	for _, f := range ruleFires {
+		*fires = append(*fires, f...)
+	}
+	s.saveState(group)
 }
 
-func (s *AlertingService) evalAlertingRule(ar *AlertingRule, datasources []model.Datasource, commonLabels map[string]string, evalTime time.Time, fires *[]Fire) {
+func (s *AlertingService) evalAlertingRule(ar *AlertingRule, datasources []model.Datasource, commonLabels map[string]string, evalTime time.Time, validUntil time.Time, fires *[]Fire) {
 	labels := map[string]string{}
 	for k, v := range commonLabels {
 		labels[k] = v
 	}
-	for k, v := range ar.Rule.Labels {
-		labels[k] = v
-	}
 	labels["alertname"] = ar.Rule.Alert
 
-	for _, datasource := range datasources {
-		err := s.evalAlertingRuleDatasource(ar, datasource, labels, evalTime)
-		if err != nil {
-			logger.Warnf("evalAlertingRuleDatasource err: %s", err)
+	start := time.Now()
+	results := s.queryDatasources(ar.Rule, ar.recorded, datasources)
+
+	// the templates are expanded before the lock, as their query function may call the datasources
+	datasourceErrors := map[string]string{}
+	var sampleAlerts []sampleAlert
+	for i, datasource := range datasources {
+		if results[i].err != nil {
+			logger.Warnf("queryDatasources(%s, %s) err: %s", ar.Rule.Alert, datasource.Name, results[i].err)
+			datasourceErrors[datasource.Name] = results[i].err.Error()
+			continue
+		}
+		for _, sample := range results[i].samples {
+			sampleAlerts = append(sampleAlerts, s.newSampleAlert(ar, datasource, sample, labels, evalTime))
 		}
 	}
+
+	// the alerts are merged under the lock, as the API reads Active concurrently
+	ar.mu.Lock()
+	defer ar.mu.Unlock()
+	for _, sa := range sampleAlerts {
+		mergeSampleAlert(ar, sa, evalTime)
+	}
+	ar.Status.set(evalTime, time.Since(start), datasourceErrors)
 	for key, alert := range ar.Active {
-		// remove old alerts
-		if alert.UpdatedAt != evalTime {
+		// resolve old alerts, unless they have to keep firing
+		if alert.UpdatedAt != evalTime && !keepFiring(ar, alert, evalTime) && !resolve(alert, evalTime) {
 			delete(ar.Active, key)
 			continue
 		}
 		// add to fires
-		if alert.State == StateFiring {
-			*fires = append(*fires, Fire{
-				Annotations: alert.Annotations,
-				Labels:      alert.Labels,
-			})
+		switch alert.State {
+		case StateFiring:
+			*fires = append(*fires, alert.fire(ar.generatorURL, validUntil))
+		case StateInactive:
+			alert.resolvedSent++
+			*fires = append(*fires, alert.fire(ar.generatorURL, validUntil))
 		}
 	}
 }
 
-func (s *AlertingService) evalAlertingRuleDatasource(ar *AlertingRule, datasource model.Datasource, commonLabels map[string]string, evalTime time.Time) error {
-	labels := map[string]string{}
-	for k, v := range commonLabels {
-		labels[k] = v
+// resolve marks an alert whose condition has cleared as resolved,
+// and reports whether it should still be kept to send its resolution.
+func resolve(alert *Alert, evalTime time.Time) bool {
+	switch alert.State {
+	case StateFiring:
+		alert.State = StateInactive
+		alert.ResolvedAt = evalTime
+		return true
+	case StateInactive:
+		return !alert.ResolvedAt.IsZero() && alert.resolvedSent < resolvedSendCount
+	}
+	// pending alerts are never sent, so they are just removed
+	return false
+}
+
+// keepFiring reports whether a firing alert whose condition has cleared should stay firing.
+// https://github.com/prometheus/prometheus/blob/v3.5.0/rules/alerting.go#L467
+func keepFiring(ar *AlertingRule, alert *Alert, evalTime time.Time) bool {
+	if alert.State != StateFiring || ar.Rule.KeepFiringFor <= 0 {
+		return false
 	}
-	for k, v := range ar.Rule.Labels {
-		labels[k] = v
+	if alert.KeepFiringSince.IsZero() {
+		alert.KeepFiringSince = evalTime
 	}
-	labels["datasource"] = datasource.Name
+	return evalTime.Sub(alert.KeepFiringSince) < time.Duration(ar.Rule.KeepFiringFor)
+}
 
-	samples, err := s.queryRule(ar.Rule, datasource)
-	if err != nil {
-		return fmt.Errorf("queryRule err: %w", err)
-	}
-	for _, sample := range samples {
-		s.evalAlertingRuleSample(ar, sample, labels, evalTime)
+type queryResult struct {
+	samples []commonmodel.Sample
+	err     error
+}
+
+// queryDatasources queries a rule on each datasource concurrently, within the limits of the limiter.
+// Results are in the order of datasources.
+func (s *AlertingService) queryDatasources(rule model.Rule, recorded *recordedSeries, datasources []model.Datasource) []queryResult {
+	results := make([]queryResult, len(datasources))
+	var wg sync.WaitGroup
+	for i, datasource := range datasources {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			rule := rule
+			expr, err := recorded.inline(rule.Expr, datasource)
+			if err != nil {
+				results[i].err = fmt.Errorf("inline err: %w", err)
+				return
+			}
+			rule.Expr = expr
+			samples, err := s.queryRule(rule, datasource)
+			if err != nil {
+				results[i].err = fmt.Errorf("queryRule err: %w", err)
+				return
+			}
+			results[i].samples = samples
+		}()
 	}
-	return nil
+	wg.Wait()
+	return results
+}
+
+// sampleAlert is an alert of a sample, with its labels and annotations expanded, before it is merged into the active alerts.
+type sampleAlert struct {
+	signature uint64
+	alert     *Alert
 }
 
-func (s *AlertingService) evalAlertingRuleSample(ar *AlertingRule, sample commonmodel.Sample, commonLabels map[string]string, evalTime time.Time) {
-	// labels & signature
+// newSampleAlert expands the labels and annotations of a rule on a sample. It does not need ar.mu.
+func (s *AlertingService) newSampleAlert(ar *AlertingRule, datasource model.Datasource, sample commonmodel.Sample, commonLabels map[string]string, evalTime time.Time) sampleAlert {
+	// labels & annotations are templates on the sample
+	data := s.sampleTemplateData(sample, datasource)
 	labels := map[string]string{}
 	for k, v := range commonLabels {
 		labels[k] = v
 	}
+	for k, v := range s.expandTemplates(ar, "label", ar.Rule.Labels, data, datasource, evalTime) {
+		labels[k] = v
+	}
+	labels["datasource"] = datasource.Name
 	for k, v := range sample.Metric {
 		labels[string(k)] = string(v)
 	}
-	signature := commonmodel.LabelsToSignature(labels)
-
-	// annotations & summary
-	annotations := map[string]string{}
-	for k, v := range ar.Rule.Annotations {
-		annotations[k] = v
+	annotations := s.expandTemplates(ar, "annotation", ar.Rule.Annotations, data, datasource, evalTime)
+	if annotations == nil {
+		annotations = map[string]string{}
 	}
-	err := renderSummaryAnnotaion(annotations, labels, sample.Value.String())
-	if err != nil {
-		logger.Warnf("renderSummaryAnnotaion(%s) err: %s", ar.Rule.Alert, err)
+	return sampleAlert{
+		signature: commonmodel.LabelsToSignature(labels),
+		alert: &Alert{
+			Labels:      labels,
+			Annotations: annotations,
+			Value:       float64(sample.Value),
+		},
 	}
+}
 
-	// others
+// mergeSampleAlert sets the state of an alert of a sample from the active alert of the same labels, and makes it active.
+// The caller must hold ar.mu.
+func mergeSampleAlert(ar *AlertingRule, sa sampleAlert, evalTime time.Time) {
 	createdAt := evalTime
 	state := StatePending
 
-	temp, exists := ar.Active[signature]
-	if exists {
+	temp, exists := ar.Active[sa.signature]
+	if exists && temp.State != StateInactive {
 		createdAt = temp.CreatedAt
 	}
 	elapsed := evalTime.Sub(createdAt.Add(time.Duration(ar.Rule.For)))
 	if elapsed >= 0 {
 		state = StateFiring
 	}
-	alert := &Alert{
-		State:       state,
-		CreatedAt:   createdAt,
-		UpdatedAt:   evalTime,
-		Labels:      labels,
-		Annotations: annotations,
-	}
-	ar.Active[signature] = alert
+	alert := sa.alert
+	alert.State = state
+	alert.CreatedAt = createdAt
+	alert.UpdatedAt = evalTime
+	ar.Active[sa.signature] = alert
 
 	// show log if severity exists and not silence
 	severity, ok := alert.Annotations["severity"]
 	if ok && severity != "silence" {
-		logger.Infof("%s(%s): %s: %s", alert.State.String(), elapsed.Round(time.Second), labels["alertname"], annotations["summary"])
+		logger.Infof("%s(%s): %s: %s", alert.State.String(), elapsed.Round(time.Second), alert.Labels["alertname"], alert.Annotations["summary"])
 	}
 }
 
-func renderSummaryAnnotaion(annotations map[string]string, labels map[string]string, value string) error {
-	summary, exists := annotations["summary"]
-	if !exists {
-		annotations["summary"] = "placeholder summary"
-		return fmt.Errorf("no summary annotation")
-	}
-
-	// pre-render
-	summary = strings.ReplaceAll(summary, "$value", value)
-	summary = strings.ReplaceAll(summary, "$labels.", ".")
-	summary = strings.ReplaceAll(summary, "$labels", ".")
-
-	// render
-	tmpl, err := template.New("").Parse(summary)
-	if err != nil {
-		return fmt.Errorf("parse err: %w", err)
-	}
-	var buf bytes.Buffer
-	err = tmpl.Execute(&buf, labels)
-	if err != nil || fakeErr1 {
-		return fmt.Errorf("tmpl.Execute err: %w", err)
-	}
-	annotations["summary"] = buf.String()
-	return nil
-}
-
 func (s *AlertingService) queryRule(rule model.Rule, datasource model.Datasource) ([]commonmodel.Sample, error) {
+	release := s.limiter.acquire(datasource)
+	defer release()
 	ctx, cancel := context.WithTimeout(context.TODO(), 10*time.Second)
 	defer cancel()
 
@@ -344,31 +515,49 @@ func getDataFromVector(bodyBytes []byte) ([]commonmodel.Sample, error) {
 	return body.Data.Result, nil
 }
 
+// sendFires queues fires to every Alertmanager. Each one sends them on its own,
+// so a failing Alertmanager does not fail the evaluation cycle.
 func (s *AlertingService) sendFires(fires []Fire) error {
-	logger.Infof("sending %d fires...", len(fires))
-	pbytes, err := json.Marshal(fires)
+	if len(s.alertmanagers) == 0 {
+		return fmt.Errorf("no alertmanager")
+	}
+	fires = s.relabelFires(fires, time.Now())
+	logger.Infof("sending %d fires to %d alertmanagers...", len(fires), len(s.alertmanagers))
+	payload, err := json.Marshal(fires)
 	if err != nil || fakeErr1 {
 		return fmt.Errorf("marshal err: %w", err)
 	}
-	buff := bytes.NewBuffer(pbytes)
-	resp, err := s.client.Post(s.alertmanagerURL+"/api/v2/alerts", "application/json", buff)
-	if err != nil {
-		return fmt.Errorf("post err: %w", err)
-	}
-	defer resp.Body.Close()
-	if resp.StatusCode != http.StatusOK || fakeErr2 {
-		return fmt.Errorf("statusCode is not ok(200)")
+	for _, am := range s.alertmanagers {
+		am.enqueue(payload)
 	}
 	return nil
 }
 
+// SendTestAlert posts a test alert to every Alertmanager concurrently, bypassing the queues,
+// and reports the failed ones.
 func (s *AlertingService) SendTestAlert() error {
+	if len(s.alertmanagers) == 0 {
+		return fmt.Errorf("no alertmanager")
+	}
 	fires := []Fire{
 		{Labels: map[string]string{"alertname": "pizza", "severity": "info", "pizza": "🍕", "time": time.Now().String()}},
 	}
-	err := s.sendFires(fires)
-	if err != nil {
-		return fmt.Errorf("sendFires err: %w", err)
+	fires = s.relabelFires(fires, time.Now())
+	payload, err := json.Marshal(fires)
+	if err != nil || fakeErr1 {
+		return fmt.Errorf("marshal err: %w", err)
 	}
-	return nil
+	errs := make([]error, len(s.alertmanagers))
+	var wg sync.WaitGroup
+	for i, am := range s.alertmanagers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := am.post(payload); err != nil {
+				errs[i] = fmt.Errorf("alertmanager(%s): %w", am.url, err)
+			}
+		}()
+	}
+	wg.Wait()
+	return errors.Join(errs...)
 }
@@ -3,6 +3,7 @@ package alerting
 import (
 	"fmt"
 	"net/http"
+	"net/http/httptest"
 	"os"
 	"testing"
 	"time"
@@ -13,6 +14,7 @@ import (
 	datasourceservice "github.com/kuoss/venti/pkg/service/datasource"
 	"github.com/kuoss/venti/pkg/service/discovery"
 	remoteservice "github.com/kuoss/venti/pkg/service/remote"
+	"github.com/kuoss/venti/pkg/webapi"
 	commonmodel "github.com/prometheus/common/model"
 	"github.com/stretchr/testify/require"
 )
@@ -116,9 +118,45 @@ func TestNew(t *testing.T) {
 	require.NotZero(t, alertingService1)
 }
 
+func TestClose(t *testing.T) {
+	cfg := &config.Config{AlertingConfig: model.AlertingConfig{
+		AlertmanagerConfigs: model.AlertmanagerConfigs{{StaticConfig: []*model.TargetGroup{
+			{Targets: []string{servers.GetServersByType(ms.TypeAlertmanager)[0].URL}},
+		}}},
+	}}
+	service := New(cfg, nil, nil, nil)
+	require.NoError(t, service.sendFires([]Fire{{Labels: map[string]string{"alertname": "Test"}}}))
+	service.Close()
+	require.Equal(t, int64(1), service.alertmanagers[0].sent.Load())
+}
+
+func TestNew_recordingRules(t *testing.T) {
+	cfg := &config.Config{AlertingConfig: model.AlertingConfig{
+		RemoteWriteConfigs: []*model.RemoteWriteConfig{{URL: "http://prometheus:9090/api/v1/write"}},
+	}}
+	ruleFiles := []model.RuleFile{{RuleGroups: []model.RuleGroup{{
+		Name: "recording",
+		Rules: []model.Rule{
+			{Record: "job:up:sum", Expr: "sum by (job) (up)"},
+			{Alert: "JobDown", Expr: "job:up:sum == 0"},
+		},
+	}}}}
+	service := New(cfg, ruleFiles, nil, nil)
+	require.Len(t, service.remoteWriters, 1)
+	require.Equal(t, "http://prometheus:9090/api/v1/write", service.remoteWriters[0].url)
+	group := service.alertingRuleGroups[0]
+	require.Len(t, group.RecordingRules, 1)
+	require.Equal(t, "job:up:sum", group.RecordingRules[0].Rule.Record)
+	require.Len(t, group.AlertingRules, 1)
+	require.Equal(t, "JobDown", group.AlertingRules[0].Rule.Alert)
+	require.Same(t, group.RecordingRules[0].recorded, group.AlertingRules[0].recorded)
+}
+
 func TestGetAlertingRuleGroups(t *testing.T) {
 	got := alertingService1.GetAlertingRuleGroups()
-	require.NotEmpty(t, got)
+	require.Len(t, got, 2)
+	require.Equal(t, "sample", got[0].Name)
+	require.Equal(t, "sample2", got[1].Name)
 }
 
 func TestGetAlertmanagerDiscovery(t *testing.T) {
@@ -130,36 +168,36 @@ func TestDoAlert(t *testing.T) {
 	alertingRuleGroups := alertingService1.alertingRuleGroups
 	datasourceService := alertingService1.datasourceService
 	datasourceReload := alertingService1.datasourceReload
-	alertmanagerURL := alertingService1.alertmanagerURL
+	alertmanagers := alertingService1.alertmanagers
 	defer func() {
 		alertingService1.alertingRuleGroups = alertingRuleGroups
 		alertingService1.datasourceService = datasourceService
 		alertingService1.datasourceReload = datasourceReload
-		alertingService1.alertmanagerURL = alertmanagerURL
+		alertingService1.alertmanagers = alertmanagers
 	}()
 
 	testCases := []struct {
-		alertingRuleGroups []AlertingRuleGroup
+		alertingRuleGroups []*AlertingRuleGroup
 		datasourceService  datasourceservice.IDatasourceService
 		datasourceReload   bool
-		alertmanagerURL    string
+		alertmanagers      []*alertmanager
 		wantError          string
 	}{
 		{
-			alertingRuleGroups, datasourceService, datasourceReload, alertmanagerURL,
+			alertingRuleGroups, datasourceService, datasourceReload, alertmanagers,
 			"",
 		},
 		{
-			[]AlertingRuleGroup{}, datasourceService, datasourceReload, alertmanagerURL,
+			[]*AlertingRuleGroup{}, datasourceService, datasourceReload, alertmanagers,
 			"",
 		},
 		{
-			[]AlertingRuleGroup{}, &mockDatasourceService{}, true, alertmanagerURL,
+			[]*AlertingRuleGroup{}, &mockDatasourceService{}, true, alertmanagers,
 			"reload err: mock reload err",
 		},
 		{
-			[]AlertingRuleGroup{}, datasourceService, datasourceReload, "",
-			`sendFires err: post err: Post "/api/v2/alerts": unsupported protocol scheme ""`,
+			[]*AlertingRuleGroup{}, datasourceService, datasourceReload, nil,
+			"sendFires err: no alertmanager",
 		},
 	}
 	for _, tc := range testCases {
@@ -167,7 +205,7 @@ func TestDoAlert(t *testing.T) {
 			alertingService1.alertingRuleGroups = tc.alertingRuleGroups
 			alertingService1.datasourceService = tc.datasourceService
 			alertingService1.datasourceReload = tc.datasourceReload
-			alertingService1.alertmanagerURL = tc.alertmanagerURL
+			alertingService1.alertmanagers = tc.alertmanagers
 			err := alertingService1.DoAlert()
 			if tc.wantError == "" {
 				require.NoError(t, err)
@@ -178,9 +216,64 @@ func TestDoAlert(t *testing.T) {
 	}
 }
 
+func TestDoAlertGroup(t *testing.T) {
+	alertmanagers := alertingService1.alertmanagers
+	defer func() {
+		alertingService1.alertmanagers = alertmanagers
+	}()
+
+	testCases := []struct {
+		group         *AlertingRuleGroup
+		alertmanagers []*alertmanager
+		wantError     string
+	}{
+		{
+			alertingService1.alertingRuleGroups[0], alertmanagers,
+			"",
+		},
+		{
+			&AlertingRuleGroup{}, nil,
+			"sendFires err: no alertmanager",
+		},
+	}
+	for _, tc := range testCases {
+		t.Run("", func(t *testing.T) {
+			alertingService1.alertmanagers = tc.alertmanagers
+			err := alertingService1.DoAlertGroup(tc.group, time.Now())
+			if tc.wantError == "" {
+				require.NoError(t, err)
+			} else {
+				require.EqualError(t, err, tc.wantError)
+			}
+		})
+	}
+}
+
+func TestReloadDatasources(t *testing.T) {
+	datasourceService := alertingService1.datasourceService
+	datasourceReload := alertingService1.datasourceReload
+	defer func() {
+		alertingService1.datasourceService = datasourceService
+		alertingService1.datasourceReload = datasourceReload
+	}()
+
+	alertingService1.datasourceService = &mockDatasourceService{}
+	alertingService1.datasourceReload = false
+	require.NoError(t, alertingService1.ReloadDatasources())
+
+	alertingService1.datasourceReload = true
+	require.EqualError(t, alertingService1.ReloadDatasources(), "reload err: mock reload err")
+}
+
 func TestEvalAlertingRuleGroups(t *testing.T) {
 	fires := []Fire{}
 	alertingService1.evalAlertingRuleGroups(&fires)
+	for i := range fires {
+		require.False(t, fires[i].StartsAt.IsZero())
+		require.True(t, fires[i].EndsAt.After(fires[i].StartsAt))
+		fires[i].StartsAt = time.Time{}
+		fires[i].EndsAt = time.Time{}
+	}
 	want := []Fire{
 		{Labels: map[string]string{"__name__": "up", "alertname": "Up", "datasource": "prometheus1", "global1": "label1", "hello": "world", "instance": "localhost:9090", "job": "prometheus", "rulefile": "sample-v3", "severity": "silence"}, Annotations: map[string]string{"summary": "Up value=1"}},
 		{Labels: map[string]string{"__name__": "up", "alertname": "Up", "datasource": "prometheus1", "global1": "label1", "hello": "world", "instance2": "localhost:9092", "job": "prometheus2", "rulefile": "sample-v3", "severity": "silence"}, Annotations: map[string]string{"summary": "Up value=1"}},
@@ -204,6 +297,7 @@ func TestEvalAlertingRuleGroup(t *testing.T) {
 
 func TestEvalAlertingRule(t *testing.T) {
 	evalTime := time.Now()
+	validUntil := evalTime.Add(4 * time.Minute)
 	testCases := []struct {
 		active       map[uint64]*Alert
 		commonLabels map[string]string
@@ -229,8 +323,8 @@ func TestEvalAlertingRule(t *testing.T) {
 			},
 			map[string]string{},
 			[]Fire{
-				{Labels: map[string]string(nil), Annotations: map[string]string(nil)},
-				{Labels: map[string]string(nil), Annotations: map[string]string(nil)},
+				{Labels: map[string]string(nil), Annotations: map[string]string(nil), EndsAt: validUntil},
+				{Labels: map[string]string(nil), Annotations: map[string]string(nil), EndsAt: validUntil},
 			},
 		},
 		{
@@ -240,8 +334,8 @@ func TestEvalAlertingRule(t *testing.T) {
 			},
 			map[string]string{},
 			[]Fire{
-				{Labels: map[string]string{"hello": "world"}, Annotations: map[string]string(nil)},
-				{Labels: map[string]string{"hello": "world"}, Annotations: map[string]string(nil)},
+				{Labels: map[string]string{"hello": "world"}, Annotations: map[string]string(nil), EndsAt: validUntil},
+				{Labels: map[string]string{"hello": "world"}, Annotations: map[string]string(nil), EndsAt: validUntil},
 			},
 		},
 	}
@@ -249,149 +343,186 @@ func TestEvalAlertingRule(t *testing.T) {
 		t.Run("", func(t *testing.T) {
 			ar := &AlertingRule{Active: tc.active}
 			fires := []Fire{}
-			alertingService1.evalAlertingRule(ar, servers.GetDatasources(), tc.commonLabels, evalTime, &fires)
+			alertingService1.evalAlertingRule(ar, servers.GetDatasources(), tc.commonLabels, evalTime, validUntil, &fires)
 			require.Equal(t, tc.want, fires)
 		})
 	}
 }
 
-func TestEvalAlertingRuleSample(t *testing.T) {
-	active := map[uint64]*Alert{}
-	ar := AlertingRule{
-		Active: active,
-		Rule: model.Rule{
-			Annotations: map[string]string{"severity": "info"},
-		},
-	}
-	sample := commonmodel.Sample{}
-	labels := map[string]string{}
+func TestKeepFiring(t *testing.T) {
 	evalTime := time.Now()
-
-	want := AlertingRule{
-		Rule: model.Rule{
-			Labels:      map[string]string(nil),
-			Annotations: map[string]string{"severity": "info"},
-		},
-		Active: active,
-	}
-	alertingService1.evalAlertingRuleSample(&ar, sample, labels, evalTime)
-	require.Equal(t, want, ar)
-}
-
-func TestRenderSummaryAnnotation(t *testing.T) {
-	defer func() {
-		fakeErr1 = false
-	}()
 	testCases := []struct {
-		annotations map[string]string
-		labels      map[string]string
-		value       string
-		fakeErr1    bool
-		want        map[string]string
-		wantError   string
+		keepFiringFor   commonmodel.Duration
+		alert           *Alert
+		want            bool
+		wantFiringSince time.Time
 	}{
-		// error
 		{
-			map[string]string{},
-			map[string]string{"datasource": "datasource1", "hello": "world", "foo": "bar"},
-			"100", false,
-			map[string]string{"summary": "placeholder summary"},
-			`no summary annotation`,
+			0,
+			&Alert{State: StateFiring},
+			false, time.Time{},
 		},
 		{
-			map[string]string{"summary": "{{$xxx}}"},
-			map[string]string{"datasource": "datasource1", "hello": "world", "foo": "bar"},
-			"100", false,
-			map[string]string{"summary": "{{$xxx}}"},
-			`parse err: template: :1: undefined variable "$xxx"`,
+			commonmodel.Duration(time.Minute),
+			&Alert{State: StatePending},
+			false, time.Time{},
 		},
 		{
-			map[string]string{"summary": "{{.}}"},
-			map[string]string{"datasource": "datasource1", "hello": "world", "foo": "bar"},
-			"100", true,
-			map[string]string{"summary": "{{.}}"},
-			`tmpl.Execute err: %!w(<nil>)`,
+			commonmodel.Duration(time.Minute),
+			&Alert{State: StateFiring},
+			true, evalTime,
 		},
-		// ok
 		{
-			map[string]string{"summary": ""},
-			map[string]string{"datasource": "datasource1", "hello": "world", "foo": "bar"},
-			"100", false,
-			map[string]string{"summary": ""},
-			``,
+			commonmodel.Duration(time.Minute),
+			&Alert{State: StateFiring, KeepFiringSince: evalTime.Add(-30 * time.Second)},
+			true, evalTime.Add(-30 * time.Second),
 		},
 		{
-			map[string]string{"summary": "hello"},
-			map[string]string{"datasource": "datasource1", "hello": "world", "foo": "bar"},
-			"100", false,
-			map[string]string{"summary": "hello"},
-			``,
+			commonmodel.Duration(time.Minute),
+			&Alert{State: StateFiring, KeepFiringSince: evalTime.Add(-time.Minute)},
+			false, evalTime.Add(-time.Minute),
 		},
-		{
-			map[string]string{"summary": "{{$value}}"},
-			map[string]string{"datasource": "datasource1", "hello": "world", "foo": "bar"},
-			"100", false,
-			map[string]string{"summary": "100"},
-			``,
-		},
-		{
-			map[string]string{"summary": "{{$labels}}"},
-			map[string]string{"datasource": "datasource1", "hello": "world", "foo": "bar"},
-			"100", false,
-			map[string]string{"summary": "map[datasource:datasource1 foo:bar hello:world]"},
-			``,
-		},
-		{
-			map[string]string{"summary": "{{$labels.hello}}"},
-			map[string]string{"datasource": "datasource1", "hello": "world", "foo": "bar"},
-			"100", false,
-			map[string]string{"summary": "world"},
-			``,
-		},
-		{
-			map[string]string{"summary": "{{$labels.xxx}}"},
-			map[string]string{"datasource": "datasource1", "hello": "world", "foo": "bar"},
-			"100", false,
-			map[string]string{"summary": "<no value>"},
-			``,
-		},
-		{
-			map[string]string{"summary": "{{$}}"},
-			map[string]string{"datasource": "datasource1", "hello": "world", "foo": "bar"},
-			"100", false,
-			map[string]string{"summary": "map[datasource:datasource1 foo:bar hello:world]"},
-			``,
-		},
-		{
-			map[string]string{"summary": "{{$.foo}}"},
-			map[string]string{"datasource": "datasource1", "hello": "world", "foo": "bar"},
-			"100", false,
-			map[string]string{"summary": "bar"},
-			``,
-		},
-		{
-			map[string]string{"summary": "{{.}}"},
-			map[string]string{"datasource": "datasource1", "hello": "world", "foo": "bar"},
-			"100", false,
-			map[string]string{"summary": "map[datasource:datasource1 foo:bar hello:world]"},
-			``,
+	}
+	for _, tc := range testCases {
+		t.Run("", func(t *testing.T) {
+			ar := &AlertingRule{Rule: model.Rule{KeepFiringFor: tc.keepFiringFor}}
+			got := keepFiring(ar, tc.alert, evalTime)
+			require.Equal(t, tc.want, got)
+			require.Equal(t, tc.wantFiringSince, tc.alert.KeepFiringSince)
+		})
+	}
+}
+
+func TestEvalAlertingRuleKeepFiringFor(t *testing.T) {
+	ar := &AlertingRule{
+		Rule: model.Rule{Alert: "Flappy", KeepFiringFor: commonmodel.Duration(time.Minute)},
+		Active: map[uint64]*Alert{
+			1: {State: StateFiring, Labels: map[string]string{"hello": "world"}},
+			2: {State: StatePending, Labels: map[string]string{"foo": "bar"}},
 		},
 	}
+	evalTime := time.Now()
+	validUntil := evalTime.Add(time.Hour)
+
+	// the condition cleared: the firing alert keeps firing, the pending one is removed
+	fires := []Fire{}
+	alertingService1.evalAlertingRule(ar, []model.Datasource{}, map[string]string{}, evalTime, validUntil, &fires)
+	require.Equal(t, []Fire{{Labels: map[string]string{"hello": "world"}, EndsAt: validUntil}}, fires)
+	require.Len(t, ar.Active, 1)
+	require.Equal(t, evalTime, ar.Active[1].KeepFiringSince)
+
+	// still within keep_firing_for
+	fires = []Fire{}
+	alertingService1.evalAlertingRule(ar, []model.Datasource{}, map[string]string{}, evalTime.Add(30*time.Second), validUntil, &fires)
+	require.Equal(t, []Fire{{Labels: map[string]string{"hello": "world"}, EndsAt: validUntil}}, fires)
+
+	// keep_firing_for has elapsed: the alert is resolved
+	resolvedAt := evalTime.Add(time.Minute)
+	fires = []Fire{}
+	alertingService1.evalAlertingRule(ar, []model.Datasource{}, map[string]string{}, resolvedAt, validUntil, &fires)
+	require.Equal(t, []Fire{{Labels: map[string]string{"hello": "world"}, EndsAt: resolvedAt}}, fires)
+	require.Equal(t, StateInactive, ar.Active[1].State)
+}
+
+func TestEvalAlertingRuleResolved(t *testing.T) {
+	createdAt := time.Now()
+	ar := &AlertingRule{
+		Rule: model.Rule{Alert: "Resolved"},
+		Active: map[uint64]*Alert{
+			1: {State: StateFiring, Labels: map[string]string{"hello": "world"}, CreatedAt: createdAt},
+		},
+		generatorURL: "http://venti:3030/metrics?query=up",
+	}
+	evalTime := createdAt.Add(time.Minute)
+	want := []Fire{{Labels: map[string]string{"hello": "world"}, StartsAt: createdAt, EndsAt: evalTime, GeneratorURL: "http://venti:3030/metrics?query=up"}}
+
+	// the resolution is sent for a few cycles
+	for i := range resolvedSendCount {
+		fires := []Fire{}
+		alertingService1.evalAlertingRule(ar, []model.Datasource{}, map[string]string{}, evalTime.Add(time.Duration(i)*time.Second), evalTime.Add(time.Hour), &fires)
+		require.Equal(t, want, fires)
+	}
+
+	// and then the alert is removed
+	fires := []Fire{}
+	alertingService1.evalAlertingRule(ar, []model.Datasource{}, map[string]string{}, evalTime.Add(time.Hour), evalTime.Add(time.Hour), &fires)
+	require.Empty(t, fires)
+	require.Empty(t, ar.Active)
+}
+
+func TestResolve(t *testing.T) {
+	evalTime := time.Now()
+	testCases := []struct {
+		alert          *Alert
+		want           bool
+		wantState      AlertState
+		wantResolvedAt time.Time
+	}{
+		{&Alert{State: StatePending}, false, StatePending, time.Time{}},
+		{&Alert{State: StateFiring}, true, StateInactive, evalTime},
+		{&Alert{State: StateInactive}, false, StateInactive, time.Time{}},
+		{&Alert{State: StateInactive, ResolvedAt: evalTime}, true, StateInactive, evalTime},
+		{&Alert{State: StateInactive, ResolvedAt: evalTime, resolvedSent: resolvedSendCount}, false, StateInactive, evalTime},
+	}
 	for _, tc := range testCases {
 		t.Run("", func(t *testing.T) {
-			annotations := tc.annotations
-			fakeErr1 = tc.fakeErr1
-			err := renderSummaryAnnotaion(annotations, tc.labels, tc.value)
-			if tc.wantError == "" {
-				require.NoError(t, err)
-			} else {
-				require.EqualError(t, err, tc.wantError)
-			}
-			require.Equal(t, tc.want, annotations)
+			got := resolve(tc.alert, evalTime)
+			require.Equal(t, tc.want, got)
+			require.Equal(t, tc.wantState, tc.alert.State)
+			require.Equal(t, tc.wantResolvedAt, tc.alert.ResolvedAt)
+		})
+	}
+}
+
+func TestGeneratorURL(t *testing.T) {
+	testCases := []struct {
+		externalURL string
+		selector    model.DatasourceSelector
+		expr        string
+		want        string
+	}{
+		{"", model.DatasourceSelector{}, "up", ""},
+		{"http://venti:3030", model.DatasourceSelector{}, "up", "http://venti:3030/metrics?query=up"},
+		{"http://venti:3030/", model.DatasourceSelector{Type: model.DatasourceTypePrometheus}, "up == 0", "http://venti:3030/metrics?query=up+%3D%3D+0"},
+		{"https://example.com/venti", model.DatasourceSelector{Type: model.DatasourceTypeLethe}, `pod{namespace="a"}`, "https://example.com/venti/logs?query=pod%7Bnamespace%3D%22a%22%7D"},
+	}
+	for _, tc := range testCases {
+		t.Run("", func(t *testing.T) {
+			got := generatorURL(tc.externalURL, tc.selector, tc.expr)
+			require.Equal(t, tc.want, got)
 		})
 	}
 }
 
+// newVectorDatasource returns a Prometheus datasource whose every query returns the vector.
+func newVectorDatasource(t *testing.T, result string) model.Datasource {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(`{"status":"success","data":{"resultType":"vector","result":` + result + `}}`))
+	}))
+	t.Cleanup(server.Close)
+	return model.Datasource{Type: model.DatasourceTypePrometheus, Name: "prometheus1", URL: server.URL}
+}
+
+func TestEvalAlertingRule_sample(t *testing.T) {
+	ar := &AlertingRule{
+		Active: map[uint64]*Alert{},
+		Rule: model.Rule{
+			Alert:       "Up",
+			Expr:        "up",
+			Annotations: map[string]string{"severity": "info"},
+		},
+	}
+	datasource := newVectorDatasource(t, `[{"metric":{"job":"node"},"value":[0,"1"]}]`)
+	evalTime := time.Now()
+	alertingService1.evalAlertingRule(ar, []model.Datasource{datasource}, map[string]string{}, evalTime, evalTime, &[]Fire{})
+	require.Len(t, ar.Active, 1)
+	for _, alert := range ar.Active {
+		require.Equal(t, map[string]string{"alertname": "Up", "datasource": "prometheus1", "job": "node"}, alert.Labels)
+		require.Equal(t, map[string]string{"severity": "info"}, alert.Annotations)
+		require.Equal(t, float64(1), alert.Value)
+	}
+}
+
 func TestQueryRule(t *testing.T) {
 	testCases := []struct {
 		rule      model.Rule
@@ -506,45 +637,65 @@ func TestGetDataFromVector(t *testing.T) {
 
 }
 
+func TestGetAlertmanagerDiscovery_Targets(t *testing.T) {
+	cfg := &config.Config{
+		AlertingConfig: model.AlertingConfig{
+			AlertmanagerConfigs: model.AlertmanagerConfigs{
+				{StaticConfig: []*model.TargetGroup{{Targets: []string{"http://am1:9093", "http://am2:9093"}}}},
+				{StaticConfig: []*model.TargetGroup{{Targets: []string{"http://am2:9093", "http://am3:9093"}}}},
+			},
+		},
+	}
+	alertingService := New(cfg, nil, nil, nil)
+	got := alertingService.GetAlertmanagerDiscovery()
+	require.Equal(t, webapi.AlertmanagerDiscovery{
+		ActiveAlertmanagers: []*webapi.AlertmanagerTarget{
+			{URL: "http://am1:9093"},
+			{URL: "http://am2:9093"},
+			{URL: "http://am3:9093"},
+		},
+		DroppedAlertmanagers: []*webapi.AlertmanagerTarget{},
+		DroppedAlerts:        []*webapi.DroppedAlert{},
+	}, got)
+}
+
 func TestSendFires(t *testing.T) {
-	alertmanagerURL := alertingService1.alertmanagerURL
+	alertmanagers := alertingService1.alertmanagers
 	defer func() {
-		alertingService1.alertmanagerURL = alertmanagerURL
+		alertingService1.alertmanagers = alertmanagers
 		fakeErr1 = false
-		fakeErr2 = false
 	}()
 	fires1 := []Fire{
 		{Labels: map[string]string{"test": "test", "severity": "info", "pizza": "🍕", "time": time.Now().String()}},
 	}
 	testCases := []struct {
-		fires           []Fire
-		alertmanagerURL string
-		fakeErr1        bool
-		fakeErr2        bool
-		wantError       string
+		fires         []Fire
+		alertmanagers []*alertmanager
+		fakeErr1      bool
+		wantError     string
 	}{
 		{
-			fires1, alertmanagerURL, false, false,
+			fires1, alertmanagers, false,
 			``,
 		},
 		{
-			fires1, "", false, false,
-			`post err: Post "/api/v2/alerts": unsupported protocol scheme ""`,
+			fires1, nil, false,
+			`no alertmanager`,
 		},
 		{
-			fires1, alertmanagerURL, true, false,
+			fires1, alertmanagers, true,
 			`marshal err: %!w(<nil>)`,
 		},
 		{
-			fires1, alertmanagerURL, false, true,
-			`statusCode is not ok(200)`,
+			// a failing alertmanager does not fail the others
+			fires1, append([]*alertmanager{newAlertmanager("", &http.Client{})}, alertmanagers...), false,
+			``,
 		},
 	}
 	for _, tc := range testCases {
 		t.Run("", func(t *testing.T) {
-			alertingService1.alertmanagerURL = tc.alertmanagerURL
+			alertingService1.alertmanagers = tc.alertmanagers
 			fakeErr1 = tc.fakeErr1
-			fakeErr2 = tc.fakeErr2
 			err := alertingService1.sendFires(tc.fires)
 			if tc.wantError == "" {
 				require.NoError(t, err)
@@ -556,19 +707,27 @@ func TestSendFires(t *testing.T) {
 }
 
 func TestSendTestAlert(t *testing.T) {
-	alertmanagerURL := alertingService1.alertmanagerURL
+	alertmanagers := alertingService1.alertmanagers
 	defer func() {
-		alertingService1.alertmanagerURL = alertmanagerURL
+		alertingService1.alertmanagers = alertmanagers
+		fakeErr1 = false
 	}()
 	testCases := []struct {
-		alertmanagerURL string
-		wantError       string
+		alertmanagers []*alertmanager
+		fakeErr1      bool
+		wantError     string
 	}{
-		{alertmanagerURL, ``},
-		{"", `sendFires err: post err: Post "/api/v2/alerts": unsupported protocol scheme ""`},
+		{alertmanagers, false, ``},
+		{nil, false, `no alertmanager`},
+		{alertmanagers, true, `marshal err: %!w(<nil>)`},
+		{
+			append([]*alertmanager{newAlertmanager("", &http.Client{})}, alertmanagers...), false,
+			`alertmanager(): post err: Post "/api/v2/alerts": unsupported protocol scheme ""`,
+		},
 	}
 	for _, tc := range testCases {
-		alertingService1.alertmanagerURL = tc.alertmanagerURL
+		alertingService1.alertmanagers = tc.alertmanagers
+		fakeErr1 = tc.fakeErr1
 		err := alertingService1.SendTestAlert()
 		if tc.wantError == "" {
 			require.NoError(t, err)
@@ -0,0 +1,147 @@
+package alerting
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/kuoss/common/logger"
+	"github.com/kuoss/venti/pkg/webapi"
+)
+
+const (
+	// alertmanagerQueueCapacity is the number of pending payloads kept for each Alertmanager.
+	alertmanagerQueueCapacity = 100
+	// alertmanagerRetries is how many times a payload is retried before it is given up.
+	alertmanagerRetries = 3
+)
+
+// alertmanager sends fires to a single Alertmanager target.
+// Each target has its own queue, retries and counters,
+// so that a slow or failing target does not hold back the others.
+type alertmanager struct {
+	url          string
+	client       *http.Client
+	retryBackoff time.Duration
+	queue        chan []byte
+	done         chan struct{} // closed when the queue is drained after stop
+
+	stopMu  sync.Mutex // guards the queue against sends after it is closed
+	stopped bool
+
+	sent    atomic.Int64
+	failed  atomic.Int64
+	dropped atomic.Int64
+
+	mu        sync.RWMutex
+	lastError string
+}
+
+func newAlertmanager(url string, client *http.Client) *alertmanager {
+	am := &alertmanager{
+		url:          url,
+		client:       client,
+		retryBackoff: time.Second,
+		queue:        make(chan []byte, alertmanagerQueueCapacity),
+		done:         make(chan struct{}),
+	}
+	go am.run()
+	return am
+}
+
+func (am *alertmanager) run() {
+	defer close(am.done)
+	for payload := range am.queue {
+		if err := am.send(payload); err != nil {
+			logger.Warnf("alertmanager(%s) send err: %s", am.url, err)
+		}
+	}
+}
+
+// enqueue adds a payload to the queue. When the queue is full, the oldest payload is dropped.
+// After stop, the payload is dropped.
+func (am *alertmanager) enqueue(payload []byte) {
+	am.stopMu.Lock()
+	defer am.stopMu.Unlock()
+	if am.stopped {
+		am.dropped.Add(1)
+		logger.Warnf("alertmanager(%s) is stopped: dropped a payload", am.url)
+		return
+	}
+	for {
+		select {
+		case am.queue <- payload:
+			return
+		default:
+		}
+		select {
+		case <-am.queue:
+			am.dropped.Add(1)
+			logger.Warnf("alertmanager(%s) queue is full: dropped the oldest payload", am.url)
+		default:
+		}
+	}
+}
+
+// stop closes the queue, and waits until the pending payloads are sent.
+func (am *alertmanager) stop() {
+	am.stopMu.Lock()
+	if !am.stopped {
+		am.stopped = true
+		close(am.queue)
+	}
+	am.stopMu.Unlock()
+	<-am.done
+}
+
+// send posts a payload, retrying with a linear backoff.
+func (am *alertmanager) send(payload []byte) error {
+	var err error
+	for attempt := 1; attempt <= alertmanagerRetries; attempt++ {
+		if err = am.post(payload); err == nil {
+			am.sent.Add(1)
+			am.setLastError("")
+			return nil
+		}
+		if attempt < alertmanagerRetries {
+			time.Sleep(time.Duration(attempt) * am.retryBackoff)
+		}
+	}
+	am.failed.Add(1)
+	am.setLastError(err.Error())
+	return err
+}
+
+func (am *alertmanager) post(payload []byte) error {
+	resp, err := am.client.Post(am.url+"/api/v2/alerts", "application/json", bytes.NewReader(payload))
+	if err != nil {
+		return fmt.Errorf("post err: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("statusCode is not ok(200)")
+	}
+	return nil
+}
+
+func (am *alertmanager) setLastError(lastError string) {
+	am.mu.Lock()
+	defer am.mu.Unlock()
+	am.lastError = lastError
+}
+
+func (am *alertmanager) target() *webapi.AlertmanagerTarget {
+	am.mu.RLock()
+	defer am.mu.RUnlock()
+	return &webapi.AlertmanagerTarget{
+		URL:         am.url,
+		QueueLength: len(am.queue),
+		Sent:        am.sent.Load(),
+		Failed:      am.failed.Load(),
+		Dropped:     am.dropped.Load(),
+		LastError:   am.lastError,
+	}
+}
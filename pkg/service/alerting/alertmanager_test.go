@@ -0,0 +1,88 @@
+package alerting
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	ms "github.com/kuoss/venti/pkg/mock/servers"
+	"github.com/kuoss/venti/pkg/webapi"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAlertmanagerEnqueue(t *testing.T) {
+	// not running, so that the queue fills up
+	am := &alertmanager{url: "http://am:9093", queue: make(chan []byte, 2)}
+	am.enqueue([]byte("1"))
+	am.enqueue([]byte("2"))
+	am.enqueue([]byte("3"))
+	require.Equal(t, int64(1), am.dropped.Load())
+	require.Equal(t, []byte("2"), <-am.queue)
+	require.Equal(t, []byte("3"), <-am.queue)
+}
+
+func TestAlertmanagerSend(t *testing.T) {
+	url := servers.GetServersByType(ms.TypeAlertmanager)[0].URL
+	// a flag would race with the alertmanagers running in the background
+	badServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer badServer.Close()
+	testCases := []struct {
+		url        string
+		wantTarget *webapi.AlertmanagerTarget
+		wantError  string
+	}{
+		{
+			url,
+			&webapi.AlertmanagerTarget{URL: url, Sent: 1},
+			``,
+		},
+		{
+			badServer.URL,
+			&webapi.AlertmanagerTarget{URL: badServer.URL, Failed: 1, LastError: "statusCode is not ok(200)"},
+			`statusCode is not ok(200)`,
+		},
+		{
+			"",
+			&webapi.AlertmanagerTarget{Failed: 1, LastError: `post err: Post "/api/v2/alerts": unsupported protocol scheme ""`},
+			`post err: Post "/api/v2/alerts": unsupported protocol scheme ""`,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run("", func(t *testing.T) {
+			am := &alertmanager{url: tc.url, client: &http.Client{}, retryBackoff: time.Millisecond, queue: make(chan []byte, 1)}
+			err := am.send([]byte("[]"))
+			if tc.wantError == "" {
+				require.NoError(t, err)
+			} else {
+				require.EqualError(t, err, tc.wantError)
+			}
+			require.Equal(t, tc.wantTarget, am.target())
+		})
+	}
+}
+
+func TestAlertmanagerRun(t *testing.T) {
+	url := servers.GetServersByType(ms.TypeAlertmanager)[0].URL
+	am := newAlertmanager(url, &http.Client{})
+	am.enqueue([]byte("[]"))
+	require.Eventually(t, func() bool {
+		return am.sent.Load() == 1
+	}, time.Second, 10*time.Millisecond)
+}
+
+func TestAlertmanagerStop(t *testing.T) {
+	url := servers.GetServersByType(ms.TypeAlertmanager)[0].URL
+	am := newAlertmanager(url, &http.Client{})
+	am.enqueue([]byte("[]"))
+	am.enqueue([]byte("[]"))
+	am.stop()
+	// the pending payloads are sent before stop returns
+	require.Equal(t, int64(2), am.sent.Load())
+
+	am.enqueue([]byte("[]"))
+	require.Equal(t, int64(1), am.dropped.Load())
+	am.stop() // twice
+}
@@ -0,0 +1,67 @@
+package alerting
+
+import (
+	"sync"
+
+	"github.com/kuoss/venti/pkg/model"
+)
+
+const (
+	defaultConcurrency           = 16
+	defaultDatasourceConcurrency = 4
+)
+
+// queryLimiter bounds the queries of rule evaluation running at the same time,
+// in total and for each datasource, so that many rules and datasources do not overload either side.
+type queryLimiter struct {
+	global            chan struct{}
+	datasourceDefault int
+
+	mu          sync.Mutex
+	datasources map[string]chan struct{} // by datasource name
+}
+
+func newQueryLimiter(concurrency, datasourceConcurrency int) *queryLimiter {
+	if concurrency <= 0 {
+		concurrency = defaultConcurrency
+	}
+	if datasourceConcurrency <= 0 {
+		datasourceConcurrency = defaultDatasourceConcurrency
+	}
+	return &queryLimiter{
+		global:            make(chan struct{}, concurrency),
+		datasourceDefault: datasourceConcurrency,
+		datasources:       map[string]chan struct{}{},
+	}
+}
+
+// acquire blocks until a query on the datasource is allowed, and returns the function to release it.
+func (l *queryLimiter) acquire(datasource model.Datasource) (release func()) {
+	if l == nil {
+		return func() {}
+	}
+	sem := l.datasourceSemaphore(datasource)
+	// take the datasource slot first, so that a busy datasource does not hold global slots
+	sem <- struct{}{}
+	l.global <- struct{}{}
+	return func() {
+		<-l.global
+		<-sem
+	}
+}
+
+func (l *queryLimiter) datasourceSemaphore(datasource model.Datasource) chan struct{} {
+	limit := datasource.Concurrency
+	if limit <= 0 {
+		limit = l.datasourceDefault
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	sem, ok := l.datasources[datasource.Name]
+	// a reloaded datasource may come with another limit; the holders release the old one
+	if !ok || cap(sem) != limit {
+		sem = make(chan struct{}, limit)
+		l.datasources[datasource.Name] = sem
+	}
+	return sem
+}
@@ -0,0 +1,71 @@
+package alerting
+
+import (
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/kuoss/venti/pkg/model"
+)
+
+func TestNewQueryLimiter(t *testing.T) {
+	l := newQueryLimiter(0, 0)
+	require.Equal(t, defaultConcurrency, cap(l.global))
+	require.Equal(t, defaultDatasourceConcurrency, l.datasourceDefault)
+
+	l = newQueryLimiter(2, 1)
+	require.Equal(t, 2, cap(l.global))
+	require.Equal(t, 1, l.datasourceDefault)
+}
+
+func TestQueryLimiterAcquire(t *testing.T) {
+	testCases := []struct {
+		concurrency           int
+		datasourceConcurrency int
+		datasources           []model.Datasource
+		want                  int64
+	}{
+		{3, 1, []model.Datasource{{Name: "prometheus1"}}, 1},
+		{3, 2, []model.Datasource{{Name: "prometheus1"}}, 2},
+		{3, 2, []model.Datasource{{Name: "prometheus1", Concurrency: 3}}, 3},
+		{3, 2, []model.Datasource{{Name: "prometheus1"}, {Name: "prometheus2"}}, 3},
+		{8, 1, []model.Datasource{{Name: "prometheus1"}, {Name: "prometheus2"}}, 2},
+	}
+	for _, tc := range testCases {
+		t.Run("", func(t *testing.T) {
+			l := newQueryLimiter(tc.concurrency, tc.datasourceConcurrency)
+			var running, maxRunning atomic.Int64
+			var wg sync.WaitGroup
+			for range 10 {
+				for _, datasource := range tc.datasources {
+					wg.Add(1)
+					go func() {
+						defer wg.Done()
+						release := l.acquire(datasource)
+						defer release()
+						n := running.Add(1)
+						for {
+							m := maxRunning.Load()
+							if n <= m || maxRunning.CompareAndSwap(m, n) {
+								break
+							}
+						}
+						time.Sleep(5 * time.Millisecond)
+						running.Add(-1)
+					}()
+				}
+			}
+			wg.Wait()
+			require.Equal(t, tc.want, maxRunning.Load())
+		})
+	}
+}
+
+func TestQueryLimiterAcquire_nil(t *testing.T) {
+	var l *queryLimiter
+	release := l.acquire(model.Datasource{Name: "prometheus1"})
+	release()
+}
@@ -0,0 +1,201 @@
+package alerting
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/kuoss/common/logger"
+	commonmodel "github.com/prometheus/common/model"
+	"github.com/prometheus/prometheus/model/labels"
+	"github.com/prometheus/prometheus/promql/parser"
+
+	"github.com/kuoss/venti/pkg/model"
+)
+
+// maxInlinedExprLength limits the expressions with inlined recorded series, as they are sent in the URL of a query.
+const maxInlinedExprLength = 8 << 10
+
+// recordedSeries keeps the latest results of the recording rules of a group,
+// so that later rules in the group can reference them.
+// The results are kept per datasource, so that the series of a datasource are not mixed into the queries of another.
+type recordedSeries struct {
+	mu     sync.RWMutex
+	series map[string]map[string][]commonmodel.Sample // by record name, then by datasource name
+}
+
+func newRecordedSeries() *recordedSeries {
+	return &recordedSeries{series: map[string]map[string][]commonmodel.Sample{}}
+}
+
+// set replaces the results of a record, split by their datasource label.
+func (r *recordedSeries) set(name string, samples []commonmodel.Sample) {
+	byDatasource := map[string][]commonmodel.Sample{}
+	for _, sample := range samples {
+		datasource := string(sample.Metric["datasource"])
+		byDatasource[datasource] = append(byDatasource[datasource], sample)
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.series[name] = byDatasource
+}
+
+// inline replaces the selectors of recorded series in a PromQL expression with their values on the datasource,
+// so that a Prometheus datasource can evaluate an expression referencing series recorded by Venti.
+// Range selectors cannot be inlined and are left as they are.
+// Only the latest values are recorded, so a selector with an offset or @ modifier fails,
+// and so does an expression that grows over maxInlinedExprLength.
+func (r *recordedSeries) inline(expr string, datasource model.Datasource) (string, error) {
+	if r == nil || datasource.Type != model.DatasourceTypePrometheus {
+		return expr, nil
+	}
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	if len(r.series) == 0 {
+		return expr, nil
+	}
+	parsed, err := parser.ParseExpr(expr)
+	if err != nil {
+		return "", fmt.Errorf("parse err: %w", err)
+	}
+	type replacement struct {
+		start, end int
+		text       string
+	}
+	var replacements []replacement
+	var inlineErr error
+	parser.Inspect(parsed, func(node parser.Node, path []parser.Node) error {
+		vs, ok := node.(*parser.VectorSelector)
+		if !ok {
+			return nil
+		}
+		if len(path) > 0 {
+			if _, ok := path[len(path)-1].(*parser.MatrixSelector); ok {
+				return nil
+			}
+		}
+		byDatasource, ok := r.series[vs.Name]
+		if !ok {
+			return nil
+		}
+		if vs.OriginalOffset != 0 || vs.Timestamp != nil || vs.StartOrEnd != 0 {
+			inlineErr = fmt.Errorf("recorded series %q cannot be inlined with an offset or @ modifier", vs.Name)
+			return inlineErr
+		}
+		samples := byDatasource[datasource.Name]
+		replacements = append(replacements, replacement{
+			start: int(vs.PosRange.Start),
+			end:   int(vs.PosRange.End),
+			text:  "(" + vectorLiteral(matchSamples(samples, vs.LabelMatchers)) + ")",
+		})
+		return nil
+	})
+	if inlineErr != nil {
+		return "", inlineErr
+	}
+	// replace from the end, so that the positions of the others stay valid
+	sort.Slice(replacements, func(i, j int) bool {
+		return replacements[i].start > replacements[j].start
+	})
+	for _, rep := range replacements {
+		expr = expr[:rep.start] + rep.text + expr[rep.end:]
+	}
+	if len(expr) > maxInlinedExprLength {
+		return "", fmt.Errorf("expression with inlined recorded series is %d bytes, over the limit of %d bytes", len(expr), maxInlinedExprLength)
+	}
+	return expr, nil
+}
+
+func matchSamples(samples []commonmodel.Sample, matchers []*labels.Matcher) []commonmodel.Sample {
+	matched := []commonmodel.Sample{}
+	for _, sample := range samples {
+		ok := true
+		for _, m := range matchers {
+			if !m.Matches(string(sample.Metric[commonmodel.LabelName(m.Name)])) {
+				ok = false
+				break
+			}
+		}
+		if ok {
+			matched = append(matched, sample)
+		}
+	}
+	return matched
+}
+
+// vectorLiteral returns a PromQL expression evaluating to the given samples, without their metric names.
+func vectorLiteral(samples []commonmodel.Sample) string {
+	if len(samples) == 0 {
+		return "vector(1) < 0"
+	}
+	parts := make([]string, 0, len(samples))
+	for _, sample := range samples {
+		names := make([]string, 0, len(sample.Metric))
+		for name := range sample.Metric {
+			if name != commonmodel.MetricNameLabel {
+				names = append(names, string(name))
+			}
+		}
+		sort.Strings(names)
+		literal := "vector(" + strconv.FormatFloat(float64(sample.Value), 'g', -1, 64) + ")"
+		for _, name := range names {
+			value := strings.ReplaceAll(string(sample.Metric[commonmodel.LabelName(name)]), "$", "$$")
+			literal = fmt.Sprintf(`label_replace(%s, %q, %q, "", "")`, literal, name, value)
+		}
+		parts = append(parts, literal)
+	}
+	return strings.Join(parts, " or ")
+}
+
+// evalRecordingRules evaluates the recording rules of a group in order, and queues their results for remote write.
+func (s *AlertingService) evalRecordingRules(group *AlertingRuleGroup, datasources []model.Datasource, evalTime time.Time) {
+	if len(group.RecordingRules) == 0 {
+		return
+	}
+	samples := []commonmodel.Sample{}
+	for _, rr := range group.RecordingRules {
+		samples = append(samples, s.evalRecordingRule(rr, datasources, evalTime)...)
+	}
+	if len(samples) == 0 {
+		return
+	}
+	for _, w := range s.remoteWriters {
+		w.enqueue(samples)
+	}
+}
+
+// evalRecordingRule queries a recording rule on each datasource, and keeps the results in the group.
+// https://github.com/prometheus/prometheus/blob/v3.5.0/rules/recording.go#L76
+func (s *AlertingService) evalRecordingRule(rr *RecordingRule, datasources []model.Datasource, evalTime time.Time) []commonmodel.Sample {
+	start := time.Now()
+	timestamp := commonmodel.TimeFromUnixNano(evalTime.UnixNano())
+	recorded := []commonmodel.Sample{}
+	datasourceErrors := map[string]string{}
+	rule := rr.Rule
+	results := s.queryDatasources(rule, rr.recorded, datasources)
+	for i, datasource := range datasources {
+		if results[i].err != nil {
+			logger.Warnf("queryDatasources(%s, %s) err: %s", rule.Record, datasource.Name, results[i].err)
+			datasourceErrors[datasource.Name] = results[i].err.Error()
+			continue
+		}
+		for _, sample := range results[i].samples {
+			metric := commonmodel.Metric{}
+			for k, v := range sample.Metric {
+				metric[k] = v
+			}
+			for k, v := range rule.Labels {
+				metric[commonmodel.LabelName(k)] = commonmodel.LabelValue(v)
+			}
+			metric[commonmodel.MetricNameLabel] = commonmodel.LabelValue(rule.Record)
+			metric["datasource"] = commonmodel.LabelValue(datasource.Name)
+			recorded = append(recorded, commonmodel.Sample{Metric: metric, Value: sample.Value, Timestamp: timestamp})
+		}
+	}
+	rr.recorded.set(rr.Rule.Record, recorded)
+	rr.Status.set(evalTime, time.Since(start), datasourceErrors)
+	return recorded
+}
@@ -0,0 +1,154 @@
+package alerting
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	commonmodel "github.com/prometheus/common/model"
+	"github.com/stretchr/testify/require"
+
+	"github.com/kuoss/venti/pkg/model"
+)
+
+func TestInline(t *testing.T) {
+	prometheus := model.Datasource{Type: model.DatasourceTypePrometheus}
+	recorded := newRecordedSeries()
+	recorded.set("job:up:sum", []commonmodel.Sample{
+		{Metric: commonmodel.Metric{"__name__": "job:up:sum", "job": "prometheus"}, Value: 2},
+		{Metric: commonmodel.Metric{"__name__": "job:up:sum", "job": "node"}, Value: 0.5},
+	})
+	testCases := []struct {
+		recorded   *recordedSeries
+		datasource model.Datasource
+		expr       string
+		want       string
+		wantError  string
+	}{
+		{
+			nil, prometheus,
+			"job:up:sum > 1",
+			"job:up:sum > 1", "",
+		},
+		{
+			newRecordedSeries(), prometheus,
+			"job:up:sum > 1",
+			"job:up:sum > 1", "",
+		},
+		{
+			recorded, model.Datasource{Type: model.DatasourceTypeLethe},
+			"job:up:sum > 1",
+			"job:up:sum > 1", "",
+		},
+		{
+			recorded, prometheus,
+			"up > 0",
+			"up > 0", "",
+		},
+		{
+			recorded, prometheus,
+			`job:up:sum{job="prometheus"} > 1`,
+			`(label_replace(vector(2), "job", "prometheus", "", "")) > 1`, "",
+		},
+		{
+			recorded, prometheus,
+			`job:up:sum{job="none"}`,
+			"(vector(1) < 0)", "",
+		},
+		{
+			recorded, prometheus,
+			`job:up:sum < 1 and up`,
+			`(label_replace(vector(2), "job", "prometheus", "", "") or label_replace(vector(0.5), "job", "node", "", "")) < 1 and up`, "",
+		},
+		{
+			recorded, prometheus,
+			"rate(job:up:sum[5m])",
+			"rate(job:up:sum[5m])", "",
+		},
+		{
+			recorded, model.Datasource{Type: model.DatasourceTypePrometheus, Name: "prometheus2"},
+			`job:up:sum > 1`,
+			"(vector(1) < 0) > 1", "",
+		},
+		{
+			recorded, prometheus,
+			"job:up:sum offset 5m > 1",
+			"", `recorded series "job:up:sum" cannot be inlined with an offset or @ modifier`,
+		},
+		{
+			recorded, prometheus,
+			"job:up:sum @ end()",
+			"", `recorded series "job:up:sum" cannot be inlined with an offset or @ modifier`,
+		},
+		{
+			recorded, prometheus,
+			"job:up:sum" + strings.Repeat(" + job:up:sum", 100),
+			"", "expression with inlined recorded series is 11208 bytes, over the limit of 8192 bytes",
+		},
+		{
+			recorded, prometheus,
+			"job:up:sum >",
+			"", "parse err: 1:13: parse error: unexpected end of input",
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.expr, func(t *testing.T) {
+			got, err := tc.recorded.inline(tc.expr, tc.datasource)
+			if tc.wantError == "" {
+				require.NoError(t, err)
+			} else {
+				require.EqualError(t, err, tc.wantError)
+			}
+			require.Equal(t, tc.want, got)
+		})
+	}
+}
+
+func TestVectorLiteral(t *testing.T) {
+	testCases := []struct {
+		samples []commonmodel.Sample
+		want    string
+	}{
+		{
+			[]commonmodel.Sample{},
+			"vector(1) < 0",
+		},
+		{
+			[]commonmodel.Sample{{Metric: commonmodel.Metric{"__name__": "a"}, Value: 1234}},
+			"vector(1234)",
+		},
+		{
+			[]commonmodel.Sample{{Metric: commonmodel.Metric{"b": "2", "a": `$1"`}, Value: 1e-9}},
+			`label_replace(label_replace(vector(1e-09), "a", "$$1\"", "", ""), "b", "2", "", "")`,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.want, func(t *testing.T) {
+			require.Equal(t, tc.want, vectorLiteral(tc.samples))
+		})
+	}
+}
+
+func TestEvalRecordingRule(t *testing.T) {
+	evalTime := time.Now()
+	rr := &RecordingRule{
+		Rule:     model.Rule{Record: "job:up", Expr: "up", Labels: map[string]string{"hello": "world"}},
+		recorded: newRecordedSeries(),
+	}
+	datasources := servers.GetDatasources()[2:4] // prometheus1, prometheus2
+	got := alertingService1.evalRecordingRule(rr, datasources, evalTime)
+	timestamp := commonmodel.TimeFromUnixNano(evalTime.UnixNano())
+	want := []commonmodel.Sample{
+		{Metric: commonmodel.Metric{"__name__": "job:up", "datasource": "prometheus1", "hello": "world", "instance": "localhost:9090", "job": "prometheus"}, Value: 1, Timestamp: timestamp},
+		{Metric: commonmodel.Metric{"__name__": "job:up", "datasource": "prometheus1", "hello": "world", "instance2": "localhost:9092", "job": "prometheus2"}, Value: 1, Timestamp: timestamp},
+		{Metric: commonmodel.Metric{"__name__": "job:up", "datasource": "prometheus2", "hello": "world", "instance": "localhost:9090", "job": "prometheus"}, Value: 1, Timestamp: timestamp},
+		{Metric: commonmodel.Metric{"__name__": "job:up", "datasource": "prometheus2", "hello": "world", "instance2": "localhost:9092", "job": "prometheus2"}, Value: 1, Timestamp: timestamp},
+	}
+	require.Equal(t, want, got)
+	require.Equal(t, map[string][]commonmodel.Sample{"prometheus1": want[:2], "prometheus2": want[2:]}, rr.recorded.series["job:up"])
+
+	// a later rule of the group sees the recorded series of the same datasource only
+	expr, err := rr.recorded.inline(`job:up{job="prometheus"}`, datasources[0])
+	require.NoError(t, err)
+	require.Equal(t, `(label_replace(label_replace(label_replace(label_replace(vector(1), "datasource", "prometheus1", "", ""), "hello", "world", "", ""), "instance", "localhost:9090", "", ""), "job", "prometheus", "", ""))`, expr)
+}
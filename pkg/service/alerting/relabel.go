@@ -0,0 +1,68 @@
+package alerting
+
+import (
+	"sort"
+	"sync"
+	"time"
+
+	commonmodel "github.com/prometheus/common/model"
+	"github.com/prometheus/prometheus/model/labels"
+	"github.com/prometheus/prometheus/model/relabel"
+
+	"github.com/kuoss/venti/pkg/webapi"
+)
+
+// droppedAlertRetention is how long an alert dropped by relabeling is listed.
+const droppedAlertRetention = 15 * time.Minute
+
+// droppedAlerts keeps the alerts recently dropped by alert_relabel_configs, so operators can see them.
+type droppedAlerts struct {
+	mu     sync.Mutex
+	alerts map[uint64]*webapi.DroppedAlert
+}
+
+func (d *droppedAlerts) add(lbls map[string]string, now time.Time) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.alerts == nil {
+		d.alerts = map[uint64]*webapi.DroppedAlert{}
+	}
+	d.alerts[commonmodel.LabelsToSignature(lbls)] = &webapi.DroppedAlert{Labels: lbls, DroppedAt: now}
+}
+
+// list returns dropped alerts within the retention, oldest first, and forgets the others.
+func (d *droppedAlerts) list(now time.Time) []*webapi.DroppedAlert {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	alerts := []*webapi.DroppedAlert{}
+	for key, alert := range d.alerts {
+		if now.Sub(alert.DroppedAt) > droppedAlertRetention {
+			delete(d.alerts, key)
+			continue
+		}
+		alerts = append(alerts, alert)
+	}
+	sort.Slice(alerts, func(i, j int) bool {
+		return alerts[i].DroppedAt.Before(alerts[j].DroppedAt)
+	})
+	return alerts
+}
+
+// relabelFires applies alert_relabel_configs to fires, and returns the ones to send.
+// https://github.com/prometheus/prometheus/blob/v3.5.0/notifier/manager.go#L388
+func (s *AlertingService) relabelFires(fires []Fire, now time.Time) []Fire {
+	if len(s.alertRelabelConfigs) == 0 {
+		return fires
+	}
+	kept := make([]Fire, 0, len(fires))
+	for _, fire := range fires {
+		lbls, keep := relabel.Process(labels.FromMap(fire.Labels), s.alertRelabelConfigs...)
+		if !keep || lbls.Len() == 0 {
+			s.droppedAlerts.add(fire.Labels, now)
+			continue
+		}
+		fire.Labels = lbls.Map()
+		kept = append(kept, fire)
+	}
+	return kept
+}
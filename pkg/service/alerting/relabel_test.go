@@ -0,0 +1,80 @@
+package alerting
+
+import (
+	"testing"
+	"time"
+
+	"github.com/kuoss/venti/pkg/util"
+	"github.com/kuoss/venti/pkg/webapi"
+	"github.com/prometheus/prometheus/model/relabel"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDroppedAlerts(t *testing.T) {
+	now := time.Now()
+	d := droppedAlerts{}
+	require.Equal(t, []*webapi.DroppedAlert{}, d.list(now))
+
+	d.add(map[string]string{"alertname": "Old"}, now.Add(-time.Hour))
+	d.add(map[string]string{"alertname": "A"}, now.Add(-time.Minute))
+	d.add(map[string]string{"alertname": "B"}, now)
+	d.add(map[string]string{"alertname": "A"}, now.Add(-2*time.Minute))
+	want := []*webapi.DroppedAlert{
+		{Labels: map[string]string{"alertname": "A"}, DroppedAt: now.Add(-2 * time.Minute)},
+		{Labels: map[string]string{"alertname": "B"}, DroppedAt: now},
+	}
+	require.Equal(t, want, d.list(now))
+	require.Len(t, d.alerts, 2)
+}
+
+func TestRelabelFires(t *testing.T) {
+	var relabelConfigs []*relabel.Config
+	err := util.UnmarshalStrict([]byte(`
+- action: labeldrop
+  regex: noisy
+- source_labels: [cluster]
+  regex: dev-(.*)
+  target_label: cluster
+  replacement: development-$1
+- source_labels: [severity]
+  regex: silence
+  action: drop
+`), &relabelConfigs)
+	require.NoError(t, err)
+
+	now := time.Now()
+	fires := []Fire{
+		{Labels: map[string]string{"alertname": "A", "noisy": "1", "cluster": "dev-a"}},
+		{Labels: map[string]string{"alertname": "B", "cluster": "prod"}},
+		{Labels: map[string]string{"alertname": "C", "severity": "silence"}},
+	}
+	testCases := []struct {
+		relabelConfigs []*relabel.Config
+		want           []Fire
+		wantDropped    []*webapi.DroppedAlert
+	}{
+		{
+			nil,
+			fires,
+			[]*webapi.DroppedAlert{},
+		},
+		{
+			relabelConfigs,
+			[]Fire{
+				{Labels: map[string]string{"alertname": "A", "cluster": "development-a"}},
+				{Labels: map[string]string{"alertname": "B", "cluster": "prod"}},
+			},
+			[]*webapi.DroppedAlert{
+				{Labels: map[string]string{"alertname": "C", "severity": "silence"}, DroppedAt: now},
+			},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run("", func(t *testing.T) {
+			s := &AlertingService{alertRelabelConfigs: tc.relabelConfigs}
+			got := s.relabelFires(fires, now)
+			require.Equal(t, tc.want, got)
+			require.Equal(t, tc.wantDropped, s.droppedAlerts.list(now))
+		})
+	}
+}
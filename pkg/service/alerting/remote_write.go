@@ -0,0 +1,129 @@
+package alerting
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"net/http"
+	"sync"
+
+	"github.com/golang/snappy"
+	"github.com/kuoss/common/logger"
+	commonmodel "github.com/prometheus/common/model"
+	"github.com/prometheus/prometheus/model/labels"
+	"github.com/prometheus/prometheus/prompb"
+)
+
+// remoteWriteQueueCapacity is the number of pending writes kept for each remote-write endpoint.
+const remoteWriteQueueCapacity = 100
+
+// remoteWriter pushes the results of recording rules to a Prometheus remote-write endpoint.
+// The writes are queued, like the alerts to Alertmanager, so that a slow endpoint does not delay the evaluation.
+// https://prometheus.io/docs/specs/prw/remote_write_spec/
+type remoteWriter struct {
+	url    string
+	client *http.Client
+	queue  chan []commonmodel.Sample
+	done   chan struct{} // closed when the queue is drained after stop
+
+	stopMu  sync.Mutex // guards the queue against sends after it is closed
+	stopped bool
+}
+
+func newRemoteWriter(url string, client *http.Client) *remoteWriter {
+	w := &remoteWriter{
+		url:    url,
+		client: client,
+		queue:  make(chan []commonmodel.Sample, remoteWriteQueueCapacity),
+		done:   make(chan struct{}),
+	}
+	go w.run()
+	return w
+}
+
+func (w *remoteWriter) run() {
+	defer close(w.done)
+	for samples := range w.queue {
+		if err := w.write(samples); err != nil {
+			logger.Warnf("remoteWriter(%s) write err: %s", w.url, err)
+		}
+	}
+}
+
+// enqueue adds samples to the queue. When the queue is full, the oldest samples are dropped.
+// After stop, the samples are dropped.
+func (w *remoteWriter) enqueue(samples []commonmodel.Sample) {
+	w.stopMu.Lock()
+	defer w.stopMu.Unlock()
+	if w.stopped {
+		logger.Warnf("remoteWriter(%s) is stopped: dropped %d samples", w.url, len(samples))
+		return
+	}
+	for {
+		select {
+		case w.queue <- samples:
+			return
+		default:
+		}
+		select {
+		case dropped := <-w.queue:
+			logger.Warnf("remoteWriter(%s) queue is full: dropped the oldest %d samples", w.url, len(dropped))
+		default:
+		}
+	}
+}
+
+// stop closes the queue, and waits until the pending samples are written.
+func (w *remoteWriter) stop() {
+	w.stopMu.Lock()
+	if !w.stopped {
+		w.stopped = true
+		close(w.queue)
+	}
+	w.stopMu.Unlock()
+	<-w.done
+}
+
+func (w *remoteWriter) write(samples []commonmodel.Sample) error {
+	req := &prompb.WriteRequest{Timeseries: make([]prompb.TimeSeries, 0, len(samples))}
+	for _, sample := range samples {
+		lbls := labels.FromMap(metricToMap(sample.Metric))
+		ts := prompb.TimeSeries{
+			Labels:  make([]prompb.Label, 0, lbls.Len()),
+			Samples: []prompb.Sample{{Value: float64(sample.Value), Timestamp: int64(sample.Timestamp)}},
+		}
+		lbls.Range(func(l labels.Label) {
+			ts.Labels = append(ts.Labels, prompb.Label{Name: l.Name, Value: l.Value})
+		})
+		req.Timeseries = append(req.Timeseries, ts)
+	}
+	data, err := req.Marshal()
+	if err != nil {
+		return fmt.Errorf("marshal err: %w", err)
+	}
+	httpReq, err := http.NewRequest(http.MethodPost, w.url, bytes.NewReader(snappy.Encode(nil, data)))
+	if err != nil {
+		return fmt.Errorf("NewRequest err: %w", err)
+	}
+	httpReq.Header.Set("Content-Encoding", "snappy")
+	httpReq.Header.Set("Content-Type", "application/x-protobuf")
+	httpReq.Header.Set("X-Prometheus-Remote-Write-Version", "0.1.0")
+	resp, err := w.client.Do(httpReq)
+	if err != nil {
+		return fmt.Errorf("post err: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 256))
+		return fmt.Errorf("statusCode=%d body=%s", resp.StatusCode, body)
+	}
+	return nil
+}
+
+func metricToMap(metric commonmodel.Metric) map[string]string {
+	m := make(map[string]string, len(metric))
+	for k, v := range metric {
+		m[string(k)] = string(v)
+	}
+	return m
+}
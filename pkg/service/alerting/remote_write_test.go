@@ -0,0 +1,123 @@
+package alerting
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/golang/snappy"
+	commonmodel "github.com/prometheus/common/model"
+	"github.com/prometheus/prometheus/prompb"
+	"github.com/stretchr/testify/require"
+
+	"github.com/kuoss/venti/pkg/model"
+)
+
+func TestRemoteWrite(t *testing.T) {
+	var got prompb.WriteRequest
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		require.Equal(t, "snappy", r.Header.Get("Content-Encoding"))
+		require.Equal(t, "application/x-protobuf", r.Header.Get("Content-Type"))
+		require.Equal(t, "0.1.0", r.Header.Get("X-Prometheus-Remote-Write-Version"))
+		body, err := io.ReadAll(r.Body)
+		require.NoError(t, err)
+		data, err := snappy.Decode(nil, body)
+		require.NoError(t, err)
+		require.NoError(t, got.Unmarshal(data))
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer server.Close()
+
+	w := &remoteWriter{url: server.URL, client: &http.Client{}}
+	err := w.write([]commonmodel.Sample{
+		{Metric: commonmodel.Metric{"__name__": "job:up", "job": "prometheus"}, Value: 1, Timestamp: 1000},
+	})
+	require.NoError(t, err)
+	want := prompb.WriteRequest{Timeseries: []prompb.TimeSeries{{
+		Labels:  []prompb.Label{{Name: "__name__", Value: "job:up"}, {Name: "job", Value: "prometheus"}},
+		Samples: []prompb.Sample{{Value: 1, Timestamp: 1000}},
+	}}}
+	require.Equal(t, want, got)
+}
+
+func TestRemoteWrite_error(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "out of order sample", http.StatusBadRequest)
+	}))
+	defer server.Close()
+
+	testCases := []struct {
+		url       string
+		wantError string
+	}{
+		{server.URL, "statusCode=400 body=out of order sample\n"},
+		{"http://127.0.0.1:0", `post err: Post "http://127.0.0.1:0": dial tcp 127.0.0.1:0: connect: connection refused`},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.url, func(t *testing.T) {
+			w := &remoteWriter{url: tc.url, client: &http.Client{}}
+			require.EqualError(t, w.write([]commonmodel.Sample{}), tc.wantError)
+		})
+	}
+}
+
+func TestRemoteWriterQueue(t *testing.T) {
+	var requests atomic.Int64
+	release := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-release
+		requests.Add(1)
+	}))
+	defer server.Close()
+
+	w := newRemoteWriter(server.URL, &http.Client{})
+	w.enqueue([]commonmodel.Sample{})
+	require.Eventually(t, func() bool {
+		return len(w.queue) == 0
+	}, time.Second, time.Millisecond)
+	// a slow endpoint does not block enqueue
+	for range remoteWriteQueueCapacity + 1 {
+		w.enqueue([]commonmodel.Sample{})
+	}
+	close(release)
+	w.stop()
+	// the first write was in flight, the queue was full, and the oldest one was dropped
+	require.Equal(t, int64(remoteWriteQueueCapacity+1), requests.Load())
+
+	// after stop, the samples are dropped
+	w.enqueue([]commonmodel.Sample{})
+	w.stop()
+	require.Equal(t, int64(remoteWriteQueueCapacity+1), requests.Load())
+}
+
+func TestEvalRecordingRules(t *testing.T) {
+	var requests atomic.Int64
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+	}))
+	defer server.Close()
+
+	remoteWriters := alertingService1.remoteWriters
+	defer func() {
+		alertingService1.remoteWriters = remoteWriters
+	}()
+	w := newRemoteWriter(server.URL, &http.Client{})
+	alertingService1.remoteWriters = []*remoteWriter{w}
+
+	recorded := newRecordedSeries()
+	group := &AlertingRuleGroup{RecordingRules: []*RecordingRule{
+		{Rule: model.Rule{Record: "job:up", Expr: "up"}, recorded: recorded},
+		{Rule: model.Rule{Record: "job:up:none", Expr: "vector(1)"}, recorded: recorded},
+	}}
+	alertingService1.evalRecordingRules(group, servers.GetDatasources()[2:3], time.Now())
+	require.Len(t, recorded.series["job:up"]["prometheus1"], 2)
+	require.Empty(t, recorded.series["job:up:none"])
+
+	// nothing is written without recorded samples
+	alertingService1.evalRecordingRules(&AlertingRuleGroup{}, servers.GetDatasources(), time.Now())
+	w.stop()
+	require.Equal(t, int64(1), requests.Load())
+}
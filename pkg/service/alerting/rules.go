@@ -0,0 +1,261 @@
+package alerting
+
+import (
+	"slices"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/prometheus/prometheus/model/labels"
+
+	"github.com/kuoss/venti/pkg/webapi"
+)
+
+// RulesFilter selects rules, like the parameters of /api/v1/rules of Prometheus.
+type RulesFilter struct {
+	Type        string                 // "alert", "record", or empty for both
+	RuleNames   []string               // empty for all
+	Datasources func(name string) bool // nil for all, for the users restricted to some datasources
+}
+
+func (f RulesFilter) match(typ string, name string) bool {
+	if f.Type != "" && f.Type != typ {
+		return false
+	}
+	return len(f.RuleNames) == 0 || slices.Contains(f.RuleNames, name)
+}
+
+// AlertsFilter selects active alerts. All of the fields must match.
+type AlertsFilter struct {
+	State       string                 // "pending", "firing", or empty for both
+	Datasource  string                 // empty for all
+	Datasources func(name string) bool // nil for all, for the users restricted to some datasources
+	Matchers    [][]*labels.Matcher    // any of the sets, like match[] of Prometheus; empty for all
+}
+
+func (f AlertsFilter) match(alert *Alert) bool {
+	if f.State != "" && f.State != alert.State.String() {
+		return false
+	}
+	if f.Datasource != "" && f.Datasource != alert.Labels["datasource"] {
+		return false
+	}
+	if f.Datasources != nil && !f.Datasources(alert.Labels["datasource"]) {
+		return false
+	}
+	if len(f.Matchers) == 0 {
+		return true
+	}
+	for _, matchers := range f.Matchers {
+		if matchLabels(alert.Labels, matchers) {
+			return true
+		}
+	}
+	return false
+}
+
+func matchLabels(lbls map[string]string, matchers []*labels.Matcher) bool {
+	for _, m := range matchers {
+		if !m.Matches(lbls[m.Name]) {
+			return false
+		}
+	}
+	return true
+}
+
+// GetAlertDiscovery returns the pending and firing alerts of all rules
+// in the format of /api/v1/alerts of Prometheus, sorted by labels.
+// https://github.com/prometheus/prometheus/blob/v3.5.0/web/api/v1/api.go#L1328
+func (s *AlertingService) GetAlertDiscovery(filter AlertsFilter) *webapi.AlertDiscovery {
+	alerts := []*webapi.Alert{}
+	for _, group := range s.alertingRuleGroups {
+		for _, ar := range group.AlertingRules {
+			ar.mu.RLock()
+			for _, alert := range ar.Active {
+				if alert.State == StateInactive || !filter.match(alert) {
+					continue
+				}
+				alerts = append(alerts, alert.apiAlert())
+			}
+			ar.mu.RUnlock()
+		}
+	}
+	sortAPIAlerts(alerts)
+	return &webapi.AlertDiscovery{Alerts: alerts}
+}
+
+// GetRuleDiscovery returns the rule groups in the format of /api/v1/rules of Prometheus.
+// Groups without any matching rule, or without any datasource of the filter, are left out.
+// https://github.com/prometheus/prometheus/blob/v3.5.0/web/api/v1/api.go#L1482
+func (s *AlertingService) GetRuleDiscovery(filter RulesFilter) *webapi.RuleDiscovery {
+	groups := []*webapi.RuleGroup{}
+	for _, group := range s.alertingRuleGroups {
+		if !s.groupAllowed(group, filter.Datasources) {
+			continue
+		}
+		lastEvaluation, evaluationTime, _ := group.status.get()
+		apiGroup := &webapi.RuleGroup{
+			Name:           group.Name,
+			File:           group.File,
+			Rules:          []any{},
+			Interval:       group.Interval.Seconds(),
+			Limit:          group.Limit,
+			EvaluationTime: evaluationTime.Seconds(),
+			LastEvaluation: lastEvaluation,
+		}
+		// recording rules are evaluated first
+		for _, rr := range group.RecordingRules {
+			if filter.match("record", rr.Rule.Record) {
+				apiGroup.Rules = append(apiGroup.Rules, rr.apiRule(filter.Datasources))
+			}
+		}
+		for _, ar := range group.AlertingRules {
+			if filter.match("alert", ar.Rule.Alert) {
+				apiGroup.Rules = append(apiGroup.Rules, ar.apiRule(filter.Datasources))
+			}
+		}
+		if len(apiGroup.Rules) > 0 {
+			groups = append(groups, apiGroup)
+		}
+	}
+	return &webapi.RuleDiscovery{RuleGroups: groups}
+}
+
+// groupAllowed returns whether the group selects any datasource of allowed, or allowed is nil.
+func (s *AlertingService) groupAllowed(group *AlertingRuleGroup, allowed func(name string) bool) bool {
+	if allowed == nil {
+		return true
+	}
+	for _, datasource := range s.datasourceService.GetDatasourcesWithSelector(group.DatasourceSelector) {
+		if allowed(datasource.Name) {
+			return true
+		}
+	}
+	return false
+}
+
+// GetAlertingRuleGroupsWithDatasources returns the groups that select any datasource of allowed,
+// with the alerts and the errors of those datasources only. nil allows all.
+func (s *AlertingService) GetAlertingRuleGroupsWithDatasources(allowed func(name string) bool) []*AlertingRuleGroup {
+	if allowed == nil {
+		return s.alertingRuleGroups
+	}
+	groups := []*AlertingRuleGroup{}
+	for _, group := range s.alertingRuleGroups {
+		if !s.groupAllowed(group, allowed) {
+			continue
+		}
+		g := &AlertingRuleGroup{
+			Name:               group.Name,
+			File:               group.File,
+			Interval:           group.Interval,
+			Limit:              group.Limit,
+			DatasourceSelector: group.DatasourceSelector,
+			GroupLabels:        group.GroupLabels,
+		}
+		for _, rr := range group.RecordingRules {
+			r := &RecordingRule{Rule: rr.Rule}
+			r.Status.setFrom(&rr.Status, allowed)
+			g.RecordingRules = append(g.RecordingRules, r)
+		}
+		for _, ar := range group.AlertingRules {
+			r := &AlertingRule{Rule: ar.Rule, Active: map[uint64]*Alert{}}
+			ar.mu.RLock()
+			for h, alert := range ar.Active {
+				if allowed(alert.Labels["datasource"]) {
+					a := *alert
+					r.Active[h] = &a
+				}
+			}
+			ar.mu.RUnlock()
+			r.Status.setFrom(&ar.Status, allowed)
+			g.AlertingRules = append(g.AlertingRules, r)
+		}
+		groups = append(groups, g)
+	}
+	return groups
+}
+
+func (r *RecordingRule) apiRule(allowed func(name string) bool) *webapi.RecordingRule {
+	status := r.Status.withDatasources(allowed)
+	lastEvaluation, evaluationTime, lastError := status.get()
+	return &webapi.RecordingRule{
+		Name:           r.Rule.Record,
+		Query:          r.Rule.Expr,
+		Labels:         r.Rule.Labels,
+		Health:         string(status.health()),
+		LastError:      lastError,
+		EvaluationTime: evaluationTime.Seconds(),
+		LastEvaluation: lastEvaluation,
+		Type:           "recording",
+	}
+}
+
+func (r *AlertingRule) apiRule(allowed func(name string) bool) *webapi.AlertingRule {
+	status := r.Status.withDatasources(allowed)
+	lastEvaluation, evaluationTime, lastError := status.get()
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	alerts, state := r.apiAlerts(allowed)
+	return &webapi.AlertingRule{
+		State:          state.String(),
+		Name:           r.Rule.Alert,
+		Query:          r.Rule.Expr,
+		Duration:       time.Duration(r.Rule.For).Seconds(),
+		KeepFiringFor:  time.Duration(r.Rule.KeepFiringFor).Seconds(),
+		Labels:         nonNil(r.Rule.Labels),
+		Annotations:    nonNil(r.Rule.Annotations),
+		Alerts:         alerts,
+		Health:         string(status.health()),
+		LastError:      lastError,
+		EvaluationTime: evaluationTime.Seconds(),
+		LastEvaluation: lastEvaluation,
+		Type:           "alerting",
+	}
+}
+
+// apiAlerts returns the pending and firing alerts of the rule of the allowed datasources, sorted by labels,
+// and the state of the rule from those alerts. The caller must hold r.mu.
+func (r *AlertingRule) apiAlerts(allowed func(name string) bool) ([]*webapi.Alert, AlertState) {
+	alerts := []*webapi.Alert{}
+	state := StateInactive
+	for _, alert := range r.Active {
+		if alert.State == StateInactive || (allowed != nil && !allowed(alert.Labels["datasource"])) {
+			continue
+		}
+		alerts = append(alerts, alert.apiAlert())
+		state = max(state, alert.State)
+	}
+	sortAPIAlerts(alerts)
+	return alerts, state
+}
+
+func sortAPIAlerts(alerts []*webapi.Alert) {
+	sort.Slice(alerts, func(i, j int) bool {
+		return labels.FromMap(alerts[i].Labels).String() < labels.FromMap(alerts[j].Labels).String()
+	})
+}
+
+func (a *Alert) apiAlert() *webapi.Alert {
+	activeAt := a.CreatedAt
+	alert := &webapi.Alert{
+		Labels:      nonNil(a.Labels),
+		Annotations: nonNil(a.Annotations),
+		State:       a.State.String(),
+		ActiveAt:    &activeAt,
+		Value:       strconv.FormatFloat(a.Value, 'e', -1, 64),
+	}
+	if !a.KeepFiringSince.IsZero() {
+		keepFiringSince := a.KeepFiringSince
+		alert.KeepFiringSince = &keepFiringSince
+	}
+	return alert
+}
+
+// nonNil returns an empty map instead of nil, as Prometheus shows empty labels as {}.
+func nonNil(m map[string]string) map[string]string {
+	if m == nil {
+		return map[string]string{}
+	}
+	return m
+}
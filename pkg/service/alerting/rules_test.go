@@ -0,0 +1,313 @@
+package alerting
+
+import (
+	"encoding/json"
+	"fmt"
+	"testing"
+	"time"
+
+	commonmodel "github.com/prometheus/common/model"
+	"github.com/prometheus/prometheus/model/labels"
+	"github.com/stretchr/testify/require"
+
+	"github.com/kuoss/venti/pkg/config"
+	"github.com/kuoss/venti/pkg/model"
+	"github.com/kuoss/venti/pkg/webapi"
+)
+
+func TestRulesFilterMatch(t *testing.T) {
+	testCases := []struct {
+		filter RulesFilter
+		typ    string
+		name   string
+		want   bool
+	}{
+		{RulesFilter{}, "alert", "Up", true},
+		{RulesFilter{}, "record", "job:up", true},
+		{RulesFilter{Type: "alert"}, "alert", "Up", true},
+		{RulesFilter{Type: "alert"}, "record", "job:up", false},
+		{RulesFilter{Type: "record"}, "record", "job:up", true},
+		{RulesFilter{RuleNames: []string{"Up", "Down"}}, "alert", "Up", true},
+		{RulesFilter{RuleNames: []string{"Down"}}, "alert", "Up", false},
+		{RulesFilter{Type: "record", RuleNames: []string{"Up"}}, "alert", "Up", false},
+	}
+	for i, tc := range testCases {
+		t.Run(fmt.Sprintf("#%d", i), func(t *testing.T) {
+			require.Equal(t, tc.want, tc.filter.match(tc.typ, tc.name))
+		})
+	}
+}
+
+func TestEvalStatus(t *testing.T) {
+	evalTime := time.Now()
+	var status EvalStatus
+	require.Equal(t, HealthUnknown, status.health())
+
+	status.set(evalTime, time.Second, nil)
+	require.Equal(t, HealthGood, status.health())
+
+	status.set(evalTime, time.Second, map[string]string{"prometheus2": "fake err2", "prometheus1": "fake err1"})
+	require.Equal(t, HealthBad, status.health())
+	lastEvaluation, evaluationTime, lastError := status.get()
+	require.Equal(t, evalTime, lastEvaluation)
+	require.Equal(t, time.Second, evaluationTime)
+	require.Equal(t, "datasource(prometheus1): fake err1; datasource(prometheus2): fake err2", lastError)
+}
+
+func TestEvalStatusMarshalJSON(t *testing.T) {
+	evalTime := time.Date(2009, 11, 10, 23, 0, 0, 0, time.UTC)
+	testCases := []struct {
+		datasourceErrors map[string]string
+		evalTime         time.Time
+		want             string
+	}{
+		{
+			nil, time.Time{},
+			`{"health":"unknown","lastEvaluation":"0001-01-01T00:00:00Z","evaluationTime":1.5}`,
+		},
+		{
+			map[string]string{}, evalTime,
+			`{"health":"ok","lastEvaluation":"2009-11-10T23:00:00Z","evaluationTime":1.5}`,
+		},
+		{
+			map[string]string{"prometheus1": "fake err"}, evalTime,
+			`{"health":"err","lastError":"datasource(prometheus1): fake err","datasourceErrors":{"prometheus1":"fake err"},"lastEvaluation":"2009-11-10T23:00:00Z","evaluationTime":1.5}`,
+		},
+	}
+	for i, tc := range testCases {
+		t.Run(fmt.Sprintf("#%d", i), func(t *testing.T) {
+			var status EvalStatus
+			status.set(tc.evalTime, 1500*time.Millisecond, tc.datasourceErrors)
+			got, err := json.Marshal(&status)
+			require.NoError(t, err)
+			require.Equal(t, tc.want, string(got))
+		})
+	}
+}
+
+func TestGetRuleDiscovery(t *testing.T) {
+	ruleFiles := []model.RuleFile{{
+		File:               "etc/alertrules/rules.yml",
+		DatasourceSelector: model.DatasourceSelector{Type: model.DatasourceTypePrometheus, System: model.DatasourceSystemMain},
+		RuleGroups: []model.RuleGroup{{
+			Name:     "rules",
+			Interval: 30 * time.Second,
+			Rules: []model.Rule{
+				{Alert: "Up", Expr: "up", For: commonmodel.Duration(time.Hour), Annotations: map[string]string{"summary": "up"}},
+				{Record: "job:up", Expr: "up"},
+			},
+		}},
+	}}
+	service := New(&config.Config{}, ruleFiles, alertingService1.datasourceService, alertingService1.remoteService)
+	evalTime := time.Now()
+	service.evalAlertingRuleGroup(service.alertingRuleGroups[0], evalTime, &[]Fire{})
+
+	got := service.GetRuleDiscovery(RulesFilter{})
+	require.Len(t, got.RuleGroups, 1)
+	group := got.RuleGroups[0]
+	require.Equal(t, "rules", group.Name)
+	require.Equal(t, "etc/alertrules/rules.yml", group.File)
+	require.Equal(t, 30.0, group.Interval)
+	require.Equal(t, evalTime, group.LastEvaluation)
+	require.Len(t, group.Rules, 2)
+
+	recordingRule := group.Rules[0].(*webapi.RecordingRule)
+	require.Equal(t, "job:up", recordingRule.Name)
+	require.Equal(t, "ok", recordingRule.Health)
+	require.Equal(t, "recording", recordingRule.Type)
+	require.Equal(t, evalTime, recordingRule.LastEvaluation)
+
+	alertingRule := group.Rules[1].(*webapi.AlertingRule)
+	require.Equal(t, "Up", alertingRule.Name)
+	require.Equal(t, "pending", alertingRule.State)
+	require.Equal(t, 3600.0, alertingRule.Duration)
+	require.Equal(t, "ok", alertingRule.Health)
+	require.Equal(t, "alerting", alertingRule.Type)
+	require.Len(t, alertingRule.Alerts, 2)
+	require.Equal(t, "pending", alertingRule.Alerts[0].State)
+	require.Equal(t, "1e+00", alertingRule.Alerts[0].Value)
+	require.Equal(t, evalTime, *alertingRule.Alerts[0].ActiveAt)
+	require.Equal(t, "prometheus2", alertingRule.Alerts[0].Labels["job"])
+	require.Equal(t, "prometheus", alertingRule.Alerts[1].Labels["job"])
+
+	got = service.GetRuleDiscovery(RulesFilter{Type: "record"})
+	require.Len(t, got.RuleGroups[0].Rules, 1)
+	got = service.GetRuleDiscovery(RulesFilter{RuleNames: []string{"Down"}})
+	require.Empty(t, got.RuleGroups)
+
+	// the group selects the main prometheus only
+	got = service.GetRuleDiscovery(RulesFilter{Datasources: func(name string) bool { return name == "prometheus1" }})
+	require.Len(t, got.RuleGroups, 1)
+	require.Len(t, got.RuleGroups[0].Rules[1].(*webapi.AlertingRule).Alerts, 2)
+	got = service.GetRuleDiscovery(RulesFilter{Datasources: func(name string) bool { return name == "prometheus2" }})
+	require.Empty(t, got.RuleGroups)
+}
+
+func TestGetAlertingRuleGroupsWithDatasources(t *testing.T) {
+	ruleFiles := []model.RuleFile{
+		{
+			DatasourceSelector: model.DatasourceSelector{Type: model.DatasourceTypePrometheus},
+			RuleGroups:         []model.RuleGroup{{Name: "prometheus", Rules: []model.Rule{{Record: "job:up", Expr: "up"}, {Alert: "Up", Expr: "up"}}}},
+		},
+		{
+			DatasourceSelector: model.DatasourceSelector{Type: model.DatasourceTypeLethe},
+			RuleGroups:         []model.RuleGroup{{Name: "lethe", Rules: []model.Rule{{Alert: "Logs", Expr: `pod{namespace="namespace01"}`}}}},
+		},
+	}
+	service := New(&config.Config{}, ruleFiles, alertingService1.datasourceService, alertingService1.remoteService)
+	service.evalAlertingRuleGroups(&[]Fire{})
+	service.alertingRuleGroups[0].AlertingRules[0].Status.set(time.Now(), 0, map[string]string{"prometheus1": "err1", "prometheus2": "err2"})
+
+	require.Same(t, service.alertingRuleGroups[0], service.GetAlertingRuleGroupsWithDatasources(nil)[0])
+
+	got := service.GetAlertingRuleGroupsWithDatasources(func(name string) bool { return name == "prometheus2" })
+	require.Len(t, got, 1)
+	require.Equal(t, "prometheus", got[0].Name)
+	require.Len(t, got[0].RecordingRules, 1)
+	require.Len(t, got[0].AlertingRules, 1)
+	ar := got[0].AlertingRules[0]
+	require.Len(t, service.alertingRuleGroups[0].AlertingRules[0].Active, 6) // datasources x samples
+	require.Len(t, ar.Active, 2)
+	for _, alert := range ar.Active {
+		require.Equal(t, "prometheus2", alert.Labels["datasource"])
+	}
+	require.Equal(t, map[string]string{"prometheus2": "err2"}, ar.Status.datasourceErrors)
+
+	got = service.GetAlertingRuleGroupsWithDatasources(func(name string) bool { return name == "lethe2" })
+	require.Len(t, got, 1)
+	require.Equal(t, "lethe", got[0].Name)
+	_, err := json.Marshal(got)
+	require.NoError(t, err)
+}
+
+// TestGetRuleDiscovery_concurrent is meaningful with -race.
+func TestGetRuleDiscovery_concurrent(t *testing.T) {
+	ruleFiles := []model.RuleFile{{
+		DatasourceSelector: model.DatasourceSelector{Type: model.DatasourceTypePrometheus},
+		RuleGroups: []model.RuleGroup{
+			{Name: "group1", Rules: []model.Rule{{Alert: "Up1", Expr: "up"}, {Alert: "Up2", Expr: "up"}}},
+			{Name: "group2", Rules: []model.Rule{{Record: "job:up", Expr: "up"}, {Alert: "Up3", Expr: "up"}}},
+		},
+	}}
+	service := New(&config.Config{}, ruleFiles, alertingService1.datasourceService, alertingService1.remoteService)
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for range 3 {
+			service.evalAlertingRuleGroups(&[]Fire{})
+		}
+	}()
+	for {
+		select {
+		case <-done:
+			got := service.GetAlertDiscovery(AlertsFilter{})
+			require.Len(t, got.Alerts, 3*3*2) // rules x datasources x samples
+			return
+		default:
+			service.GetRuleDiscovery(RulesFilter{})
+			service.GetAlertDiscovery(AlertsFilter{})
+			_, err := json.Marshal(service.GetAlertingRuleGroups())
+			require.NoError(t, err)
+		}
+	}
+}
+
+func TestGetRuleDiscovery_lastError(t *testing.T) {
+	ar := &AlertingRule{Rule: model.Rule{Alert: "Up", Expr: "up"}, Active: map[uint64]*Alert{}}
+	datasources := []model.Datasource{{Type: model.DatasourceTypePrometheus, Name: "down", URL: "http://127.0.0.1:0"}}
+	alertingService1.evalAlertingRule(ar, datasources, map[string]string{}, time.Now(), time.Now(), &[]Fire{})
+	got := ar.apiRule(nil)
+	require.Equal(t, "err", got.Health)
+	require.Contains(t, got.LastError, "datasource(down): queryRule err: GET err: ")
+	require.Len(t, ar.Status.datasourceErrors, 1)
+	require.Contains(t, ar.Status.datasourceErrors["down"], "queryRule err: GET err: ")
+
+	// a recovered datasource clears its error
+	alertingService1.evalAlertingRule(ar, servers.GetDatasources()[2:3], map[string]string{}, time.Now(), time.Now(), &[]Fire{})
+	require.Equal(t, "ok", ar.apiRule(nil).Health)
+	require.Empty(t, ar.Status.datasourceErrors)
+}
+
+func TestAlertingRuleAPIRule_allowed(t *testing.T) {
+	ar := &AlertingRule{Rule: model.Rule{Alert: "Up", Expr: "up"}, Active: map[uint64]*Alert{
+		1: {State: StateFiring, Labels: map[string]string{"alertname": "Up", "datasource": "prometheus1"}},
+		2: {State: StatePending, Labels: map[string]string{"alertname": "Up", "datasource": "prometheus2"}},
+		3: {State: StateInactive, Labels: map[string]string{"alertname": "Up", "datasource": "prometheus3"}},
+	}}
+	testCases := []struct {
+		datasource string
+		wantState  string
+		wantAlerts int
+	}{
+		{"prometheus1", "firing", 1},
+		{"prometheus2", "pending", 1},
+		{"prometheus3", "inactive", 0},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.datasource, func(t *testing.T) {
+			got := ar.apiRule(func(name string) bool { return name == tc.datasource })
+			require.Equal(t, tc.wantState, got.State)
+			require.Len(t, got.Alerts, tc.wantAlerts)
+		})
+	}
+	require.Equal(t, "firing", ar.apiRule(nil).State)
+}
+
+func TestAlertsFilterMatch(t *testing.T) {
+	alert := &Alert{State: StateFiring, Labels: map[string]string{"alertname": "Up", "datasource": "prometheus1", "severity": "critical"}}
+	testCases := []struct {
+		filter AlertsFilter
+		want   bool
+	}{
+		{AlertsFilter{}, true},
+		{AlertsFilter{State: "firing"}, true},
+		{AlertsFilter{State: "pending"}, false},
+		{AlertsFilter{Datasource: "prometheus1"}, true},
+		{AlertsFilter{Datasource: "prometheus2"}, false},
+		{AlertsFilter{Datasources: func(name string) bool { return name == "prometheus1" }}, true},
+		{AlertsFilter{Datasources: func(name string) bool { return name == "prometheus2" }}, false},
+		{AlertsFilter{Matchers: [][]*labels.Matcher{
+			{labels.MustNewMatcher(labels.MatchEqual, "severity", "critical")},
+		}}, true},
+		{AlertsFilter{Matchers: [][]*labels.Matcher{
+			{labels.MustNewMatcher(labels.MatchEqual, "severity", "critical"), labels.MustNewMatcher(labels.MatchEqual, "alertname", "Down")},
+		}}, false},
+		{AlertsFilter{Matchers: [][]*labels.Matcher{
+			{labels.MustNewMatcher(labels.MatchEqual, "alertname", "Down")},
+			{labels.MustNewMatcher(labels.MatchRegexp, "alertname", "U.*")},
+		}}, true},
+		{AlertsFilter{State: "firing", Datasource: "prometheus1", Matchers: [][]*labels.Matcher{
+			{labels.MustNewMatcher(labels.MatchNotEqual, "severity", "critical")},
+		}}, false},
+	}
+	for i, tc := range testCases {
+		t.Run(fmt.Sprintf("#%d", i), func(t *testing.T) {
+			require.Equal(t, tc.want, tc.filter.match(alert))
+		})
+	}
+}
+
+func TestGetAlertDiscovery(t *testing.T) {
+	activeAt := time.Date(2009, 11, 10, 23, 0, 0, 0, time.UTC)
+	service := &AlertingService{alertingRuleGroups: []*AlertingRuleGroup{
+		{AlertingRules: []*AlertingRule{
+			{Active: map[uint64]*Alert{
+				1: {State: StateFiring, Labels: map[string]string{"alertname": "B", "datasource": "prometheus1"}, CreatedAt: activeAt, Value: 1},
+				2: {State: StateInactive, Labels: map[string]string{"alertname": "B", "datasource": "prometheus2"}, CreatedAt: activeAt},
+			}},
+		}},
+		{AlertingRules: []*AlertingRule{
+			{Active: map[uint64]*Alert{
+				3: {State: StatePending, Labels: map[string]string{"alertname": "A", "datasource": "prometheus2"}, CreatedAt: activeAt, Value: 0.5},
+			}},
+		}},
+	}}
+	want := &webapi.AlertDiscovery{Alerts: []*webapi.Alert{
+		{Labels: map[string]string{"alertname": "A", "datasource": "prometheus2"}, Annotations: map[string]string{}, State: "pending", ActiveAt: &activeAt, Value: "5e-01"},
+		{Labels: map[string]string{"alertname": "B", "datasource": "prometheus1"}, Annotations: map[string]string{}, State: "firing", ActiveAt: &activeAt, Value: "1e+00"},
+	}}
+	require.Equal(t, want, service.GetAlertDiscovery(AlertsFilter{}))
+
+	want = &webapi.AlertDiscovery{Alerts: []*webapi.Alert{}}
+	require.Equal(t, want, service.GetAlertDiscovery(AlertsFilter{State: "firing", Datasource: "prometheus2"}))
+}
@@ -0,0 +1,205 @@
+package alerting
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/kuoss/common/logger"
+	commonmodel "github.com/prometheus/common/model"
+	"gorm.io/gorm"
+
+	"github.com/kuoss/venti/pkg/model"
+)
+
+// StateStore persists the active alerts of each group, so that the `for` timers survive restarts.
+type StateStore struct {
+	mu    sync.Mutex        // groups are saved concurrently
+	saved map[string]string // the state of each group at its last save, by file and group name
+	db    *gorm.DB
+}
+
+func NewStateStore(db *gorm.DB) (*StateStore, error) {
+	err := db.AutoMigrate(model.ActiveAlert{})
+	if err != nil {
+		return nil, fmt.Errorf("auto migration failed: %w", err)
+	}
+	return &StateStore{saved: map[string]string{}, db: db}, nil
+}
+
+// savedAlert is the part of an alert that its restore depends on.
+// The value, the annotations and the update time change at each evaluation, and are set again by the first one after a restore.
+type savedAlert struct {
+	RuleKey         string
+	Fingerprint     uint64
+	State           AlertState
+	CreatedAt       time.Time
+	KeepFiringSince time.Time
+	ResolvedAt      time.Time
+}
+
+// save replaces the persisted alerts of a group with its current ones, unless they did not change since the last save.
+func (st *StateStore) save(group *AlertingRuleGroup) error {
+	rows := []model.ActiveAlert{}
+	saved := []savedAlert{}
+	for _, ar := range group.AlertingRules {
+		key := hashRuleKey(ruleKey(group, ar))
+		ar.mu.RLock()
+		for fingerprint, alert := range ar.Active {
+			data, err := json.Marshal(alert)
+			if err != nil || fakeErr1 {
+				ar.mu.RUnlock()
+				return fmt.Errorf("marshal err: %w", err)
+			}
+			rows = append(rows, model.ActiveAlert{File: group.File, GroupName: group.Name, RuleName: ar.Rule.Alert, RuleKey: key, Data: string(data)})
+			saved = append(saved, savedAlert{key, fingerprint, alert.State, alert.CreatedAt, alert.KeepFiringSince, alert.ResolvedAt})
+		}
+		ar.mu.RUnlock()
+	}
+	sort.Slice(saved, func(i, j int) bool {
+		if saved[i].RuleKey != saved[j].RuleKey {
+			return saved[i].RuleKey < saved[j].RuleKey
+		}
+		return saved[i].Fingerprint < saved[j].Fingerprint
+	})
+	state, err := json.Marshal(saved)
+	if err != nil {
+		return fmt.Errorf("marshal state err: %w", err)
+	}
+	groupKey := group.File + "\x00" + group.Name
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	if st.saved[groupKey] == string(state) {
+		return nil
+	}
+	err = st.db.Transaction(func(tx *gorm.DB) error {
+		err := tx.Where("file = ? AND group_name = ?", group.File, group.Name).Delete(&model.ActiveAlert{}).Error
+		if err != nil {
+			return fmt.Errorf("delete err: %w", err)
+		}
+		if len(rows) == 0 {
+			return nil
+		}
+		err = tx.Create(&rows).Error
+		if err != nil {
+			return fmt.Errorf("create err: %w", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	st.saved[groupKey] = string(state)
+	return nil
+}
+
+// load returns the persisted alerts, by the hash of their rule key.
+func (st *StateStore) load() (map[string][]*Alert, error) {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	var rows []model.ActiveAlert
+	err := st.db.Find(&rows).Error
+	if err != nil {
+		return nil, fmt.Errorf("find err: %w", err)
+	}
+	alerts := map[string][]*Alert{}
+	for _, row := range rows {
+		var alert Alert
+		err := json.Unmarshal([]byte(row.Data), &alert)
+		if err != nil {
+			logger.Warnf("unmarshal alert(%s/%s) err: %s", row.GroupName, row.RuleName, err)
+			continue
+		}
+		alerts[row.RuleKey] = append(alerts[row.RuleKey], &alert)
+	}
+	return alerts, nil
+}
+
+// RestoreState restores the persisted alerts into the rules, and keeps saving them to the store.
+// Like on reload, only the rules that did not change get their alerts back.
+// Restored alerts are reconciled by the first evaluation of their group:
+// the ones still active keep their creation time, and the others are resolved.
+func (s *AlertingService) RestoreState(store *StateStore) error {
+	alerts, err := store.load()
+	if err != nil {
+		return fmt.Errorf("load err: %w", err)
+	}
+	restored := 0
+	for _, group := range s.alertingRuleGroups {
+		for _, ar := range group.AlertingRules {
+			ar.mu.Lock()
+			for _, alert := range alerts[hashRuleKey(ruleKey(group, ar))] {
+				ar.Active[commonmodel.LabelsToSignature(alert.Labels)] = alert
+				restored++
+			}
+			ar.mu.Unlock()
+		}
+	}
+	logger.Infof("restored %d alerts", restored)
+	s.stateStore = store
+	return nil
+}
+
+func (s *AlertingService) saveState(group *AlertingRuleGroup) {
+	if s.stateStore == nil {
+		return
+	}
+	err := s.stateStore.save(group)
+	if err != nil {
+		logger.Warnf("save state(%s) err: %s", group.Name, err)
+	}
+}
+
+// InheritState takes over the active alerts and the state store of the service replaced by a reload.
+// Only the rules that did not change keep their alerts, so that their `for` timers go on;
+// the others start over, like a new rule.
+func (s *AlertingService) InheritState(old *AlertingService) {
+	oldRules := map[string]*AlertingRule{}
+	for _, group := range old.alertingRuleGroups {
+		for _, ar := range group.AlertingRules {
+			oldRules[ruleKey(group, ar)] = ar
+		}
+	}
+	inherited := 0
+	for _, group := range s.alertingRuleGroups {
+		for _, ar := range group.AlertingRules {
+			oldAr, ok := oldRules[ruleKey(group, ar)]
+			if !ok {
+				continue
+			}
+			oldAr.mu.RLock()
+			ar.mu.Lock()
+			for fingerprint, alert := range oldAr.Active {
+				copied := *alert
+				ar.Active[fingerprint] = &copied
+				inherited++
+			}
+			ar.mu.Unlock()
+			oldAr.mu.RUnlock()
+		}
+	}
+	logger.Infof("inherited %d alerts", inherited)
+	s.stateStore = old.stateStore
+}
+
+// ruleKey identifies a rule with everything that makes its alerts, besides the global config.
+func ruleKey(group *AlertingRuleGroup, ar *AlertingRule) string {
+	key, _ := json.Marshal(struct {
+		File               string
+		Group              string
+		DatasourceSelector model.DatasourceSelector
+		GroupLabels        map[string]string
+		Rule               model.Rule
+	}{group.File, group.Name, group.DatasourceSelector, group.GroupLabels, ar.Rule})
+	return string(key)
+}
+
+// hashRuleKey shortens a rule key for the store.
+func hashRuleKey(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,198 @@
+package alerting
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	commonmodel "github.com/prometheus/common/model"
+	"github.com/stretchr/testify/require"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+
+	"github.com/kuoss/venti/pkg/config"
+	"github.com/kuoss/venti/pkg/model"
+)
+
+func newTestStateStore(t *testing.T) *StateStore {
+	db, err := gorm.Open(sqlite.Open(filepath.Join(t.TempDir(), "venti.sqlite3")), &gorm.Config{})
+	require.NoError(t, err)
+	store, err := NewStateStore(db)
+	require.NoError(t, err)
+	return store
+}
+
+func TestStateStore(t *testing.T) {
+	store := newTestStateStore(t)
+	createdAt := time.Date(2009, 11, 10, 23, 0, 0, 0, time.UTC)
+	alert := &Alert{State: StatePending, Labels: map[string]string{"alertname": "Up"}, CreatedAt: createdAt, UpdatedAt: createdAt}
+	group := &AlertingRuleGroup{Name: "group1", File: "a.yml", AlertingRules: []*AlertingRule{
+		{Rule: model.Rule{Alert: "Up"}, Active: map[uint64]*Alert{1: alert}},
+		{Rule: model.Rule{Alert: "Down"}, Active: map[uint64]*Alert{}},
+	}}
+	upKey := hashRuleKey(ruleKey(group, group.AlertingRules[0]))
+	require.NoError(t, store.save(group))
+	require.NoError(t, store.save(&AlertingRuleGroup{Name: "group2"}))
+
+	got, err := store.load()
+	require.NoError(t, err)
+	require.Equal(t, map[string][]*Alert{upKey: {alert}}, got)
+
+	// a group of the same name in another file does not replace them
+	require.NoError(t, store.save(&AlertingRuleGroup{Name: "group1", File: "b.yml"}))
+	got, err = store.load()
+	require.NoError(t, err)
+	require.Equal(t, map[string][]*Alert{upKey: {alert}}, got)
+
+	// saving a group replaces its alerts
+	group.AlertingRules[0].Active = map[uint64]*Alert{}
+	require.NoError(t, store.save(group))
+	got, err = store.load()
+	require.NoError(t, err)
+	require.Empty(t, got)
+}
+
+func TestStateStore_changedRule(t *testing.T) {
+	store := newTestStateStore(t)
+	alert := &Alert{State: StateFiring, Labels: map[string]string{"alertname": "Disk"}}
+	group := &AlertingRuleGroup{Name: "group1", File: "a.yml", AlertingRules: []*AlertingRule{
+		{Rule: model.Rule{Alert: "Disk", Expr: "disk > 80"}, Active: map[uint64]*Alert{1: alert}},
+	}}
+	require.NoError(t, store.save(group))
+	got, err := store.load()
+	require.NoError(t, err)
+	require.Equal(t, map[string][]*Alert{hashRuleKey(ruleKey(group, group.AlertingRules[0])): {alert}}, got)
+
+	// the same alert name with another expression is another rule
+	changed := &AlertingRule{Rule: model.Rule{Alert: "Disk", Expr: "disk > 90"}}
+	require.Empty(t, got[hashRuleKey(ruleKey(group, changed))])
+}
+
+func TestStateStore_unchanged(t *testing.T) {
+	store := newTestStateStore(t)
+	alert := &Alert{State: StatePending, Labels: map[string]string{"alertname": "Up"}, Value: 1}
+	group := &AlertingRuleGroup{Name: "group1", AlertingRules: []*AlertingRule{
+		{Rule: model.Rule{Alert: "Up"}, Active: map[uint64]*Alert{1: alert}},
+	}}
+	require.NoError(t, store.save(group))
+
+	// a new value alone is not written
+	alert.Value = 2
+	alert.UpdatedAt = time.Now()
+	require.NoError(t, store.save(group))
+	got, err := store.load()
+	require.NoError(t, err)
+	require.Equal(t, float64(1), got[hashRuleKey(ruleKey(group, group.AlertingRules[0]))][0].Value)
+
+	// a new state is
+	alert.State = StateFiring
+	require.NoError(t, store.save(group))
+	got, err = store.load()
+	require.NoError(t, err)
+	require.Equal(t, float64(2), got[hashRuleKey(ruleKey(group, group.AlertingRules[0]))][0].Value)
+}
+
+func TestStateStore_error(t *testing.T) {
+	store := newTestStateStore(t)
+	group := &AlertingRuleGroup{Name: "group1", AlertingRules: []*AlertingRule{
+		{Rule: model.Rule{Alert: "Up"}, Active: map[uint64]*Alert{1: {}}},
+	}}
+	fakeErr1 = true
+	defer func() {
+		fakeErr1 = false
+	}()
+	require.EqualError(t, store.save(group), "marshal err: %!w(<nil>)")
+}
+
+func TestRestoreState(t *testing.T) {
+	store := newTestStateStore(t)
+	ruleFiles := []model.RuleFile{{
+		DatasourceSelector: model.DatasourceSelector{Type: model.DatasourceTypePrometheus, System: model.DatasourceSystemMain},
+		RuleGroups: []model.RuleGroup{{
+			Name:  "restore",
+			Rules: []model.Rule{{Alert: "Up", Expr: "up", For: commonmodel.Duration(time.Hour), Annotations: map[string]string{"summary": "up"}}},
+		}},
+	}}
+	cfg := &config.Config{}
+	service1 := New(cfg, ruleFiles, alertingService1.datasourceService, alertingService1.remoteService)
+	require.NoError(t, service1.RestoreState(store))
+
+	// an alert pending for 50 minutes before a restart
+	evalTime := time.Now()
+	service1.evalAlertingRuleGroup(service1.alertingRuleGroups[0], evalTime.Add(-50*time.Minute), &[]Fire{})
+	active := service1.alertingRuleGroups[0].AlertingRules[0].Active
+	require.Len(t, active, 2)
+
+	// plus a stale one, which is not active anymore at the first evaluation
+	for _, alert := range active {
+		stale := *alert
+		stale.Labels = map[string]string{"alertname": "Up", "stale": "true"}
+		stale.State = StateFiring
+		active[commonmodel.LabelsToSignature(stale.Labels)] = &stale
+		break
+	}
+	require.NoError(t, store.save(service1.alertingRuleGroups[0]))
+
+	service2 := New(cfg, ruleFiles, alertingService1.datasourceService, alertingService1.remoteService)
+	require.NoError(t, service2.RestoreState(store))
+	ar := service2.alertingRuleGroups[0].AlertingRules[0]
+	require.Len(t, ar.Active, 3)
+
+	fires := []Fire{}
+	service2.evalAlertingRuleGroup(service2.alertingRuleGroups[0], evalTime, &fires)
+	states := map[AlertState]int{}
+	for _, alert := range ar.Active {
+		states[alert.State]++
+		if alert.State == StatePending {
+			require.Equal(t, evalTime.Add(-50*time.Minute).Unix(), alert.CreatedAt.Unix())
+		}
+	}
+	require.Equal(t, map[AlertState]int{StatePending: 2, StateInactive: 1}, states)
+	require.Len(t, fires, 1) // the resolution of the stale alert
+
+	// the `for` timer goes on from before the restart
+	service2.evalAlertingRuleGroup(service2.alertingRuleGroups[0], evalTime.Add(10*time.Minute), &[]Fire{})
+	for _, alert := range ar.Active {
+		if alert.Labels["stale"] == "" {
+			require.Equal(t, StateFiring, alert.State)
+		}
+	}
+}
+
+func TestInheritState(t *testing.T) {
+	store := newTestStateStore(t)
+	up := model.Rule{Alert: "Up", Expr: "up", For: commonmodel.Duration(time.Hour)}
+	ruleFiles := []model.RuleFile{{
+		File:               "rules.yml",
+		DatasourceSelector: model.DatasourceSelector{Type: model.DatasourceTypePrometheus, System: model.DatasourceSystemMain},
+		RuleGroups:         []model.RuleGroup{{Name: "inherit", Rules: []model.Rule{up, {Alert: "Changed", Expr: "up"}}}},
+	}}
+	cfg := &config.Config{}
+	oldService := New(cfg, ruleFiles, alertingService1.datasourceService, alertingService1.remoteService)
+	require.NoError(t, oldService.RestoreState(store))
+	evalTime := time.Now()
+	oldService.evalAlertingRuleGroup(oldService.alertingRuleGroups[0], evalTime.Add(-50*time.Minute), &[]Fire{})
+	for _, ar := range oldService.alertingRuleGroups[0].AlertingRules {
+		require.Len(t, ar.Active, 2)
+	}
+
+	// the second rule gets a label on the reload
+	newRuleFiles := []model.RuleFile{ruleFiles[0]}
+	newRuleFiles[0].RuleGroups = []model.RuleGroup{{Name: "inherit", Rules: []model.Rule{up, {Alert: "Changed", Expr: "up", Labels: map[string]string{"severity": "info"}}}}}
+	newService := New(cfg, newRuleFiles, alertingService1.datasourceService, alertingService1.remoteService)
+	newService.InheritState(oldService)
+	require.Equal(t, store, newService.stateStore)
+
+	upRule := newService.alertingRuleGroups[0].AlertingRules[0]
+	require.Len(t, upRule.Active, 2)
+	for fingerprint, alert := range upRule.Active {
+		oldAlert := oldService.alertingRuleGroups[0].AlertingRules[0].Active[fingerprint]
+		require.Equal(t, *oldAlert, *alert)
+		require.NotSame(t, oldAlert, alert)
+	}
+	require.Empty(t, newService.alertingRuleGroups[0].AlertingRules[1].Active)
+
+	// the `for` timer goes on from before the reload
+	newService.evalAlertingRuleGroup(newService.alertingRuleGroups[0], evalTime.Add(10*time.Minute), &[]Fire{})
+	require.Equal(t, StateFiring, upRule.State())
+}
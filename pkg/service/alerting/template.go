@@ -0,0 +1,75 @@
+package alerting
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"time"
+
+	"github.com/kuoss/common/logger"
+	commonmodel "github.com/prometheus/common/model"
+	"github.com/prometheus/prometheus/model/labels"
+	"github.com/prometheus/prometheus/promql"
+	"github.com/prometheus/prometheus/template"
+
+	"github.com/kuoss/venti/pkg/model"
+	alerttemplate "github.com/kuoss/venti/pkg/service/alerting/template"
+)
+
+// sampleTemplateData returns the data for the templates of an alert from a sample.
+// $labels are the labels of the sample with its datasource.
+func (s *AlertingService) sampleTemplateData(sample commonmodel.Sample, datasource model.Datasource) any {
+	lbls := metricToMap(sample.Metric)
+	lbls["datasource"] = datasource.Name
+	return template.AlertTemplateData(lbls, s.globalLabels, s.externalURL, promql.Sample{F: float64(sample.Value)})
+}
+
+// expandTemplate renders a template of an annotation or a label with the functions of Prometheus.
+// The query function queries the datasource of the alert.
+func (s *AlertingService) expandTemplate(name string, text string, data any, datasource model.Datasource, evalTime time.Time) (string, error) {
+	externalURL, err := url.Parse(s.externalURL)
+	if err != nil {
+		return "", fmt.Errorf("parse externalURL err: %w", err)
+	}
+	queryFunc := func(_ context.Context, query string, _ time.Time) (promql.Vector, error) {
+		samples, err := s.queryRule(model.Rule{Expr: query}, datasource)
+		if err != nil {
+			return nil, fmt.Errorf("queryRule err: %w", err)
+		}
+		vector := make(promql.Vector, len(samples))
+		for i, sample := range samples {
+			vector[i] = promql.Sample{
+				Metric: labels.FromMap(metricToMap(sample.Metric)),
+				T:      int64(sample.Timestamp),
+				F:      float64(sample.Value),
+			}
+		}
+		return vector, nil
+	}
+	expander := template.NewTemplateExpander(context.TODO(), alerttemplate.Defs+text, name, data,
+		commonmodel.TimeFromUnixNano(evalTime.UnixNano()), queryFunc, externalURL, nil)
+	result, err := expander.Expand()
+	if err != nil {
+		return "", fmt.Errorf("expand err: %w", err)
+	}
+	return result, nil
+}
+
+// expandTemplates renders each value of a map of templates.
+// Like Prometheus, a failed template is replaced with its error, so that the alert still goes out.
+func (s *AlertingService) expandTemplates(ar *AlertingRule, kind string, templates map[string]string, data any, datasource model.Datasource, evalTime time.Time) map[string]string {
+	if templates == nil {
+		return nil
+	}
+	expanded := make(map[string]string, len(templates))
+	for k, v := range templates {
+		name := fmt.Sprintf("__alert_%s_%s_%s", ar.Rule.Alert, kind, k)
+		result, err := s.expandTemplate(name, v, data, datasource, evalTime)
+		if err != nil {
+			logger.Warnf("expandTemplate(%s) err: %s", name, err)
+			result = fmt.Sprintf("<error expanding template: %s>", err)
+		}
+		expanded[k] = result
+	}
+	return expanded
+}
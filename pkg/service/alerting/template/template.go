@@ -0,0 +1,25 @@
+// Package template holds what the alerting service and the validation of rule files share about
+// the templates of annotations and labels, so that the validation does not depend on the alerting service.
+package template
+
+import (
+	"context"
+	"strings"
+
+	"github.com/prometheus/prometheus/template"
+)
+
+// Defs are the variables of annotation and label templates, as in Prometheus.
+// https://github.com/prometheus/prometheus/blob/v3.5.0/rules/alerting.go#L399
+var Defs = strings.Join([]string{
+	"{{$labels := .Labels}}",
+	"{{$externalLabels := .ExternalLabels}}",
+	"{{$externalURL := .ExternalURL}}",
+	"{{$value := .Value}}",
+}, "")
+
+// Parse checks the syntax of an annotation or a label template, without rendering it.
+func Parse(name string, text string) error {
+	expander := template.NewTemplateExpander(context.TODO(), Defs+text, name, nil, 0, nil, nil, nil)
+	return expander.ParseTest()
+}
@@ -0,0 +1,30 @@
+package template
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestParse(t *testing.T) {
+	testCases := []struct {
+		text      string
+		wantError string
+	}{
+		{"", ""},
+		{"{{ $labels.job }} is {{ humanize $value }}", ""},
+		{"{{ $externalLabels.cluster }} {{ $externalURL }}", ""},
+		{"{{ $xxx }}", `template: __alert_Up_annotation_summary:1: undefined variable "$xxx"`},
+		{"{{ if }}", "template: __alert_Up_annotation_summary:1: missing value for if"},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.text, func(t *testing.T) {
+			err := Parse("__alert_Up_annotation_summary", tc.text)
+			if tc.wantError == "" {
+				require.NoError(t, err)
+			} else {
+				require.ErrorContains(t, err, tc.wantError)
+			}
+		})
+	}
+}
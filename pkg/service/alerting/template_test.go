@@ -0,0 +1,159 @@
+package alerting
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	commonmodel "github.com/prometheus/common/model"
+	"github.com/stretchr/testify/require"
+
+	"github.com/kuoss/venti/pkg/model"
+)
+
+func TestExpandTemplate(t *testing.T) {
+	service := &AlertingService{
+		globalLabels:  map[string]string{"cluster": "dev"},
+		externalURL:   "http://venti:8080",
+		remoteService: alertingService1.remoteService,
+	}
+	datasource := servers.GetDatasources()[2] // prometheus1
+	sample := commonmodel.Sample{Metric: commonmodel.Metric{"job": "node", "instance": "node1:9100"}, Value: 1234.5678}
+	data := service.sampleTemplateData(sample, datasource)
+	testCases := []struct {
+		text      string
+		want      string
+		wantError string
+	}{
+		// variables
+		{`hello`, `hello`, ``},
+		{`{{ $value }}`, `1234.5678`, ``},
+		{`{{ $labels.job }}/{{ $labels.instance }}`, `node/node1:9100`, ``},
+		{`{{ $labels.datasource }}`, `prometheus1`, ``},
+		{`{{ $labels.xxx }}`, ``, ``},
+		{`{{ $externalLabels.cluster }}`, `dev`, ``},
+		{`{{ $externalURL }}`, `http://venti:8080`, ``},
+		// functions
+		{`{{ humanize $value }}`, `1.235k`, ``},
+		{`{{ humanizeDuration 3661 }}`, `1h 1m 1s`, ``},
+		{`{{ humanizePercentage 0.1234 }}`, `12.34%`, ``},
+		{`{{ printf "%.1f" $value }}`, `1234.6`, ``},
+		{`{{ reReplaceAll ":.*" "" $labels.instance }}`, `node1`, ``},
+		{`{{ $labels.job | toUpper }}`, `NODE`, ``},
+		{`{{ query "up" | len }}`, `2`, ``},
+		{`{{ with query "up" }}{{ . | first | label "job" }}={{ . | first | value }}{{ end }}`, `prometheus=1`, ``},
+		{`{{ range query "up" | sortByLabel "job" }}{{ .Labels.job }} {{ end }}`, `prometheus prometheus2 `, ``},
+		// errors
+		{`{{ $xxx }}`, ``, `expand err: error parsing template __test: template: __test:1: undefined variable "$xxx"`},
+		{`{{ humanize "xxx" }}`, ``, `expand err: error executing template __test: template: __test:1:115: executing "__test" at <humanize "xxx">: error calling humanize: strconv.ParseFloat: parsing "xxx": invalid syntax`},
+		{`{{ query "not_answered" | first }}`, ``, `expand err: error executing template __test: template: __test:1:138: executing "__test" at <first>: error calling first: first() called on vector with no elements`},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.text, func(t *testing.T) {
+			got, err := service.expandTemplate("__test", tc.text, data, datasource, time.Now())
+			if tc.wantError == "" {
+				require.NoError(t, err)
+			} else {
+				require.EqualError(t, err, tc.wantError)
+			}
+			require.Equal(t, tc.want, got)
+		})
+	}
+}
+
+func TestExpandTemplate_externalURL(t *testing.T) {
+	service := &AlertingService{externalURL: ":"}
+	_, err := service.expandTemplate("__test", "hello", nil, model.Datasource{}, time.Now())
+	require.EqualError(t, err, `parse externalURL err: parse ":": missing protocol scheme`)
+}
+
+func TestExpandTemplates(t *testing.T) {
+	ar := &AlertingRule{Rule: model.Rule{Alert: "Up"}}
+	data := alertingService1.sampleTemplateData(commonmodel.Sample{Metric: commonmodel.Metric{"job": "node"}, Value: 1}, model.Datasource{Name: "prometheus1"})
+	testCases := []struct {
+		templates map[string]string
+		want      map[string]string
+	}{
+		{nil, nil},
+		{map[string]string{}, map[string]string{}},
+		{
+			map[string]string{"summary": "{{ $labels.job }} on {{ $labels.datasource }}", "severity": "warning"},
+			map[string]string{"summary": "node on prometheus1", "severity": "warning"},
+		},
+		{
+			map[string]string{"summary": "{{ $xxx }}"},
+			map[string]string{"summary": `<error expanding template: expand err: error parsing template __alert_Up_annotation_summary: template: __alert_Up_annotation_summary:1: undefined variable "$xxx">`},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run("", func(t *testing.T) {
+			got := alertingService1.expandTemplates(ar, "annotation", tc.templates, data, model.Datasource{Name: "prometheus1"}, time.Now())
+			require.Equal(t, tc.want, got)
+		})
+	}
+}
+
+func TestEvalAlertingRule_templates(t *testing.T) {
+	ar := &AlertingRule{
+		Rule: model.Rule{
+			Alert:       "Up",
+			Expr:        "up",
+			Labels:      map[string]string{"severity": `{{ if gt $value 100.0 }}critical{{ else }}warning{{ end }}`, "team": "infra"},
+			Annotations: map[string]string{"summary": "{{ $labels.job }} is {{ humanize $value }}", "description": "value={{ $value }}"},
+		},
+		Active: map[uint64]*Alert{},
+	}
+	datasource := newVectorDatasource(t, `[{"metric":{"job":"node"},"value":[0,"1000"]}]`)
+	evalTime := time.Now()
+	alertingService1.evalAlertingRule(ar, []model.Datasource{datasource}, map[string]string{}, evalTime, evalTime, &[]Fire{})
+	require.Len(t, ar.Active, 1)
+	for _, alert := range ar.Active {
+		require.Equal(t, map[string]string{"alertname": "Up", "datasource": "prometheus1", "job": "node", "severity": "critical", "team": "infra"}, alert.Labels)
+		require.Equal(t, map[string]string{"summary": "node is 1k", "description": "value=1000"}, alert.Annotations)
+	}
+}
+
+func TestEvalAlertingRule_slowTemplateQuery(t *testing.T) {
+	querying := make(chan struct{})
+	release := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("query") == "slow" {
+			close(querying)
+			<-release
+		}
+		_, _ = w.Write([]byte(`{"status":"success","data":{"resultType":"vector","result":[{"metric":{"job":"node"},"value":[0,"1"]}]}}`))
+	}))
+	defer server.Close()
+	var releaseOnce sync.Once
+	releaseQuery := func() { releaseOnce.Do(func() { close(release) }) }
+	defer releaseQuery() // before server.Close, also on a failure
+
+	ar := &AlertingRule{
+		Rule: model.Rule{
+			Alert:       "Slow",
+			Expr:        "up",
+			Annotations: map[string]string{"summary": `{{ with query "slow" }}{{ . | first | value }}{{ end }}`},
+		},
+		Active: map[uint64]*Alert{},
+	}
+	datasource := model.Datasource{Type: model.DatasourceTypePrometheus, Name: "slow", URL: server.URL}
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		evalTime := time.Now()
+		alertingService1.evalAlertingRule(ar, []model.Datasource{datasource}, map[string]string{}, evalTime, evalTime, &[]Fire{})
+	}()
+
+	// the readers of the alerts are not blocked by the template query
+	<-querying
+	require.True(t, ar.mu.TryRLock())
+	ar.mu.RUnlock()
+	releaseQuery()
+	<-done
+	require.Len(t, ar.Active, 1)
+	for _, alert := range ar.Active {
+		require.Equal(t, "1", alert.Annotations["summary"])
+	}
+}
@@ -1,7 +1,11 @@
 package alerting
 
 import (
+	"encoding/json"
 	"fmt"
+	"sort"
+	"strings"
+	"sync"
 	"time"
 
 	"github.com/kuoss/venti/pkg/model"
@@ -41,23 +45,189 @@ type Alert struct {
 
 	Labels      map[string]string `json:"labels"`
 	Annotations map[string]string `json:"annotations"`
+	Value       float64           `json:"value"`
 
 	CreatedAt time.Time `json:"createdAt,omitempty"`
 	UpdatedAt time.Time `json:"updatedAt,omitempty"`
+	// KeepFiringSince is set when a firing alert stops matching while keep_firing_for is configured.
+	KeepFiringSince time.Time `json:"keepFiringSince,omitzero"`
+	// ResolvedAt is set when a firing alert stops matching. The alert is then kept as inactive
+	// for a few cycles so that its resolution is sent to Alertmanager.
+	ResolvedAt time.Time `json:"resolvedAt,omitzero"`
+
+	resolvedSent int
+}
+
+// fire returns the notification of the alert for Alertmanager.
+// A resolved alert ends at its resolution, others are valid until validUntil.
+func (a *Alert) fire(generatorURL string, validUntil time.Time) Fire {
+	endsAt := validUntil
+	if a.State == StateInactive {
+		endsAt = a.ResolvedAt
+	}
+	return Fire{
+		Labels:       a.Labels,
+		Annotations:  a.Annotations,
+		StartsAt:     a.CreatedAt,
+		EndsAt:       endsAt,
+		GeneratorURL: generatorURL,
+	}
+}
+
+// RuleHealth describes the health of a rule, by the result of its last evaluation.
+type RuleHealth string
+
+const (
+	HealthUnknown RuleHealth = "unknown"
+	HealthGood    RuleHealth = "ok"
+	HealthBad     RuleHealth = "err"
+)
+
+// EvalStatus is the result of the last evaluation of a rule or a group.
+// It is written by the evaluation and read by the API concurrently.
+type EvalStatus struct {
+	mu               sync.RWMutex
+	lastEvaluation   time.Time
+	evaluationTime   time.Duration
+	datasourceErrors map[string]string // by datasource name
+}
+
+func (s *EvalStatus) set(lastEvaluation time.Time, evaluationTime time.Duration, datasourceErrors map[string]string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.lastEvaluation = lastEvaluation
+	s.evaluationTime = evaluationTime
+	s.datasourceErrors = datasourceErrors
+}
+
+func (s *EvalStatus) health() RuleHealth {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.healthLocked()
+}
+
+func (s *EvalStatus) healthLocked() RuleHealth {
+	switch {
+	case s.lastEvaluation.IsZero():
+		return HealthUnknown
+	case len(s.datasourceErrors) > 0:
+		return HealthBad
+	}
+	return HealthGood
+}
+
+func (s *EvalStatus) get() (lastEvaluation time.Time, evaluationTime time.Duration, lastError string) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.lastEvaluation, s.evaluationTime, s.lastErrorLocked()
+}
+
+// withDatasources returns the status with the errors of the allowed datasources only. nil allows all.
+func (s *EvalStatus) withDatasources(allowed func(name string) bool) *EvalStatus {
+	if allowed == nil {
+		return s
+	}
+	filtered := &EvalStatus{}
+	filtered.setFrom(s, allowed)
+	return filtered
+}
+
+// setFrom sets the status to the one of o, with the errors of the allowed datasources only.
+func (s *EvalStatus) setFrom(o *EvalStatus, allowed func(name string) bool) {
+	o.mu.RLock()
+	var datasourceErrors map[string]string
+	for name, err := range o.datasourceErrors {
+		if allowed(name) {
+			if datasourceErrors == nil {
+				datasourceErrors = map[string]string{}
+			}
+			datasourceErrors[name] = err
+		}
+	}
+	lastEvaluation, evaluationTime := o.lastEvaluation, o.evaluationTime
+	o.mu.RUnlock()
+	s.set(lastEvaluation, evaluationTime, datasourceErrors)
+}
+
+// lastErrorLocked joins the errors of datasources in a single line, like the lastError of Prometheus rules.
+func (s *EvalStatus) lastErrorLocked() string {
+	names := make([]string, 0, len(s.datasourceErrors))
+	for name := range s.datasourceErrors {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	msgs := make([]string, len(names))
+	for i, name := range names {
+		msgs[i] = fmt.Sprintf("datasource(%s): %s", name, s.datasourceErrors[name])
+	}
+	return strings.Join(msgs, "; ")
+}
+
+func (s *EvalStatus) MarshalJSON() ([]byte, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return json.Marshal(struct {
+		Health           RuleHealth        `json:"health"`
+		LastError        string            `json:"lastError,omitempty"`
+		DatasourceErrors map[string]string `json:"datasourceErrors,omitempty"`
+		LastEvaluation   time.Time         `json:"lastEvaluation"`
+		EvaluationTime   float64           `json:"evaluationTime"` // seconds
+	}{
+		Health:           s.healthLocked(),
+		LastError:        s.lastErrorLocked(),
+		DatasourceErrors: s.datasourceErrors,
+		LastEvaluation:   s.lastEvaluation,
+		EvaluationTime:   s.evaluationTime.Seconds(),
+	})
 }
 
 type AlertingRuleGroup struct {
+	Name               string                   `json:"name"`
+	File               string                   `json:"file,omitempty"`
+	Interval           time.Duration            `json:"interval,omitempty"`
+	Limit              int                      `json:"limit,omitempty"`
 	DatasourceSelector model.DatasourceSelector `json:"datasourceSelector,omitempty"`
 	GroupLabels        map[string]string        `json:"groupLabels,omitempty"`
-	AlertingRules      []AlertingRule           `json:"alertingRules,omitempty"`
+	AlertingRules      []*AlertingRule          `json:"alertingRules,omitempty"`
+	RecordingRules     []*RecordingRule         `json:"recordingRules,omitempty"`
+
+	status EvalStatus
 }
 
+// AlertingRule is a rule whose results fire alerts.
+// Active is updated by the evaluation and read by the API concurrently, guarded by mu.
 type AlertingRule struct {
 	Rule   model.Rule        `json:"rule,omitempty"`
 	Active map[uint64]*Alert `json:"active,omitempty"`
+
+	Status EvalStatus `json:"status"`
+
+	mu           sync.RWMutex
+	generatorURL string
+	recorded     *recordedSeries
+}
+
+func (r *AlertingRule) MarshalJSON() ([]byte, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return json.Marshal(struct {
+		Rule   model.Rule        `json:"rule,omitempty"`
+		Active map[uint64]*Alert `json:"active,omitempty"`
+		Status *EvalStatus       `json:"status"`
+	}{
+		Rule:   r.Rule,
+		Active: r.Active,
+		Status: &r.Status,
+	})
+}
+
+func (r *AlertingRule) State() AlertState {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.stateLocked()
 }
 
-func (r AlertingRule) State() AlertState {
+func (r *AlertingRule) stateLocked() AlertState {
 	maxState := StateInactive
 	for _, alert := range r.Active {
 		if alert.State > maxState {
@@ -67,7 +237,20 @@ func (r AlertingRule) State() AlertState {
 	return maxState
 }
 
+// RecordingRule is a rule whose results are recorded as new series.
+// They can be referenced by the later rules of the same group, and pushed via remote write.
+type RecordingRule struct {
+	Rule   model.Rule `json:"rule,omitempty"`
+	Status EvalStatus `json:"status"`
+
+	recorded *recordedSeries
+}
+
+// https://github.com/prometheus/alertmanager/blob/v0.28.1/api/v2/models/postable_alert.go
 type Fire struct {
-	Labels      map[string]string `json:"labels"`
-	Annotations map[string]string `json:"annotations"`
+	Labels       map[string]string `json:"labels"`
+	Annotations  map[string]string `json:"annotations"`
+	StartsAt     time.Time         `json:"startsAt,omitzero"`
+	EndsAt       time.Time         `json:"endsAt,omitzero"`
+	GeneratorURL string            `json:"generatorURL,omitempty"`
 }
@@ -1,7 +1,9 @@
 package alerting
 
 import (
+	"encoding/json"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/require"
 )
@@ -62,3 +64,43 @@ func TestAlertingRuleState(t *testing.T) {
 		})
 	}
 }
+
+func TestAlertFire(t *testing.T) {
+	createdAt := time.Now()
+	resolvedAt := createdAt.Add(time.Minute)
+	validUntil := createdAt.Add(time.Hour)
+	labels := map[string]string{"alertname": "Up"}
+	testCases := []struct {
+		alert *Alert
+		want  Fire
+	}{
+		{
+			&Alert{State: StateFiring, Labels: labels, CreatedAt: createdAt},
+			Fire{Labels: labels, StartsAt: createdAt, EndsAt: validUntil, GeneratorURL: "/metrics?query=up"},
+		},
+		{
+			&Alert{State: StateInactive, Labels: labels, CreatedAt: createdAt, ResolvedAt: resolvedAt},
+			Fire{Labels: labels, StartsAt: createdAt, EndsAt: resolvedAt, GeneratorURL: "/metrics?query=up"},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run("", func(t *testing.T) {
+			got := tc.alert.fire("/metrics?query=up", validUntil)
+			require.Equal(t, tc.want, got)
+		})
+	}
+}
+
+func TestAlertMarshalJSON(t *testing.T) {
+	createdAt := time.Date(2009, 11, 10, 23, 0, 0, 0, time.UTC)
+	alert := &Alert{State: StateFiring, Labels: map[string]string{"alertname": "Up"}, CreatedAt: createdAt}
+	got, err := json.Marshal(alert)
+	require.NoError(t, err)
+	require.NotContains(t, string(got), "keepFiringSince")
+	require.NotContains(t, string(got), "resolvedAt")
+
+	alert.KeepFiringSince = createdAt.Add(time.Minute)
+	got, err = json.Marshal(alert)
+	require.NoError(t, err)
+	require.Contains(t, string(got), `"keepFiringSince":"2009-11-10T23:01:00Z"`)
+}
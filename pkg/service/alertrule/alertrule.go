@@ -6,6 +6,7 @@ import (
 	"path/filepath"
 
 	"github.com/kuoss/common/logger"
+	"github.com/kuoss/venti/pkg/config"
 	"github.com/kuoss/venti/pkg/model"
 	"github.com/kuoss/venti/pkg/util"
 )
@@ -44,9 +45,39 @@ func loadAlertRuleFileFromFilename(filename string) (*model.RuleFile, error) {
 	if err := util.UnmarshalStrict(yamlBytes, &alertRuleFile); err != nil {
 		return nil, fmt.Errorf("unmarshalStrict err: %w", err)
 	}
+	if alertRuleFile == nil {
+		return nil, fmt.Errorf("empty rule file")
+	}
+	alertRuleFile.File = filename
+	if err := validateRuleFile(alertRuleFile, yamlBytes); err != nil {
+		return nil, fmt.Errorf("validateRuleFile err: %w", err)
+	}
 	return alertRuleFile, nil
 }
 
+// Check loads each rule file with the loader of New, and reports all of them.
+func Check(pattern string) ([]config.FileCheck, error) {
+	files, err := filepath.Glob(pattern)
+	if err != nil {
+		return nil, fmt.Errorf("glob err: %w", err)
+	}
+	checks := make([]config.FileCheck, len(files))
+	for i, filename := range files {
+		checks[i].File = filename
+		ruleFile, err := loadAlertRuleFileFromFilename(filename)
+		if err != nil {
+			checks[i].Err = err
+			continue
+		}
+		count := 0
+		for _, group := range ruleFile.RuleGroups {
+			count += len(group.Rules)
+		}
+		checks[i].Summary = fmt.Sprintf("%d rules found", count)
+	}
+	return checks, nil
+}
+
 func (s *AlertRuleService) GetAlertRuleFiles() []model.RuleFile {
 	return s.AlertRuleFiles
 }
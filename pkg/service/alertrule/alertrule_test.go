@@ -5,6 +5,7 @@ import (
 	"os"
 	"testing"
 
+	"github.com/kuoss/venti/pkg/config"
 	"github.com/kuoss/venti/pkg/model"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
@@ -12,6 +13,7 @@ import (
 
 var (
 	ruleFiles = []model.RuleFile{{
+		File:               "etc/alertrules/sample.yml",
 		Kind:               "AlertRuleFile",
 		CommonLabels:       map[string]string{"rulefile": "sample-v3", "severity": "silence"},
 		DatasourceSelector: model.DatasourceSelector{System: "", Type: "prometheus"},
@@ -23,7 +25,7 @@ var (
 				{Alert: "PodNotHealthy", Expr: "sum by (namespace, pod) (kube_pod_status_phase{phase=~\"Pending|Unknown|Failed\"}) > 0", For: 3000000000, KeepFiringFor: 0, Labels: map[string]string(nil), Annotations: map[string]string{"summary": "{{ $labels.namespace }}/{{ $labels.pod }}"}},
 			}}}}}
 	ruleFiles1 = []model.RuleFile{
-		{Kind: "AlertRuleFile", CommonLabels: map[string]string{"rulefile": "sample-v3", "severity": "silence"}, DatasourceSelector: model.DatasourceSelector{System: "", Type: "prometheus"}, RuleGroups: []model.RuleGroup{
+		{File: "etc/alertrules/sample.yml", Kind: "AlertRuleFile", CommonLabels: map[string]string{"rulefile": "sample-v3", "severity": "silence"}, DatasourceSelector: model.DatasourceSelector{System: "", Type: "prometheus"}, RuleGroups: []model.RuleGroup{
 			{Name: "sample", Interval: 0, Limit: 0, Rules: []model.Rule{
 				{Alert: "S00-AlwaysOn", Expr: "vector(1234)", For: 0, KeepFiringFor: 0, Labels: map[string]string{"hello": "world"}, Annotations: map[string]string{"summary": "AlwaysOn value={{ $value }}"}},
 				{Alert: "S01-Monday", Expr: "day_of_week() == 1 and hour() < 2", For: 0, KeepFiringFor: 0, Labels: map[string]string(nil), Annotations: map[string]string{"summary": "Monday"}},
@@ -105,7 +107,7 @@ groups:
     annotations:
       summary: "hello world"
 `,
-			&AlertRuleService{AlertRuleFiles: []model.RuleFile{{RuleGroups: []model.RuleGroup{
+			&AlertRuleService{AlertRuleFiles: []model.RuleFile{{File: "test.ok.yaml", RuleGroups: []model.RuleGroup{
 				{Name: "info", Rules: []model.Rule{{
 					Alert:       "hello",
 					Expr:        "greet > 90",
@@ -191,6 +193,7 @@ func TestLoadAwesomeAlerts(t *testing.T) {
 		{
 			"testdata/awesome-prometheus-alerts/kubestate-exporter.yml",
 			&model.RuleFile{
+				File: "testdata/awesome-prometheus-alerts/kubestate-exporter.yml",
 				RuleGroups: []model.RuleGroup{{Name: "KubestateExporter", Rules: []model.Rule{
 					{Alert: "KubernetesNodeNotReady", Expr: "kube_node_status_condition{condition=\"Ready\",status=\"true\"} == 0", For: 600000000000, KeepFiringFor: 0, Labels: map[string]string{"severity": "critical"}, Annotations: map[string]string{"description": "Node {{ $labels.node }} has been unready for a long time\n  VALUE = {{ $value }}\n  LABELS = {{ $labels }}", "summary": "Kubernetes Node ready (node {{ $labels.node }})"}},
 					{Alert: "KubernetesNodeMemoryPressure", Expr: "kube_node_status_condition{condition=\"MemoryPressure\",status=\"true\"} == 1", For: 120000000000, KeepFiringFor: 0, Labels: map[string]string{"severity": "critical"}, Annotations: map[string]string{"description": "Node {{ $labels.node }} has MemoryPressure condition\n  VALUE = {{ $value }}\n  LABELS = {{ $labels }}", "summary": "Kubernetes memory pressure (node {{ $labels.node }})"}},
@@ -233,6 +236,7 @@ func TestLoadAwesomeAlerts(t *testing.T) {
 		{
 			"testdata/awesome-prometheus-alerts/node-exporter.yml",
 			&model.RuleFile{
+				File: "testdata/awesome-prometheus-alerts/node-exporter.yml",
 				RuleGroups: []model.RuleGroup{{Name: "NodeExporter", Interval: 0, Limit: 0, Rules: []model.Rule{
 					{Alert: "HostOutOfMemory", Expr: "(node_memory_MemAvailable_bytes / node_memory_MemTotal_bytes < .10)", For: 120000000000, KeepFiringFor: 0, Labels: map[string]string{"severity": "warning"}, Annotations: map[string]string{"description": "Node memory is filling up (< 10% left)\n  VALUE = {{ $value }}\n  LABELS = {{ $labels }}", "summary": "Host out of memory (instance {{ $labels.instance }})"}},
 					{Alert: "HostMemoryUnderMemoryPressure", Expr: "(rate(node_vmstat_pgmajfault[5m]) > 1000)", For: 0, KeepFiringFor: 0, Labels: map[string]string{"severity": "warning"}, Annotations: map[string]string{"description": "The node is under heavy memory pressure. High rate of loading memory pages from disk.\n  VALUE = {{ $value }}\n  LABELS = {{ $labels }}", "summary": "Host memory under memory pressure (instance {{ $labels.instance }})"}},
@@ -276,6 +280,7 @@ func TestLoadAwesomeAlerts(t *testing.T) {
 		{
 			"testdata/awesome-prometheus-alerts/embedded-exporter.yml",
 			&model.RuleFile{
+				File: "testdata/awesome-prometheus-alerts/embedded-exporter.yml",
 				RuleGroups: []model.RuleGroup{{Name: "EmbeddedExporter", Rules: []model.Rule{
 					{Alert: "PrometheusJobMissing", Expr: "absent(up{job=\"prometheus\"})", For: 0, KeepFiringFor: 0, Labels: map[string]string{"severity": "warning"}, Annotations: map[string]string{"description": "A Prometheus job has disappeared\n  VALUE = {{ $value }}\n  LABELS = {{ $labels }}", "summary": "Prometheus job missing (instance {{ $labels.instance }})"}},
 					{Alert: "PrometheusTargetMissing", Expr: "up == 0", For: 0, KeepFiringFor: 0, Labels: map[string]string{"severity": "critical"}, Annotations: map[string]string{"description": "A Prometheus target has disappeared. An exporter might be crashed.\n  VALUE = {{ $value }}\n  LABELS = {{ $labels }}", "summary": "Prometheus target missing (instance {{ $labels.instance }})"}},
@@ -317,3 +322,45 @@ func TestLoadAwesomeAlerts(t *testing.T) {
 		})
 	}
 }
+
+func TestCheck(t *testing.T) {
+	dir := t.TempDir()
+	require.NoError(t, os.WriteFile(dir+"/empty.yml", []byte("---\n"), 0644))
+	testCases := []struct {
+		pattern   string
+		want      []config.FileCheck
+		wantError string
+	}{
+		{
+			"etc/alertrules/*.yml",
+			[]config.FileCheck{{File: "etc/alertrules/sample.yml", Summary: "4 rules found"}},
+			"",
+		},
+		{
+			dir + "/*.yml",
+			[]config.FileCheck{{File: dir + "/empty.yml", Err: fmt.Errorf("empty rule file")}},
+			"",
+		},
+		{
+			"asdf",
+			[]config.FileCheck{},
+			"",
+		},
+		{
+			"[]",
+			nil,
+			"glob err: syntax error in pattern",
+		},
+	}
+	for i, tc := range testCases {
+		t.Run(fmt.Sprintf("#%d", i), func(t *testing.T) {
+			got, err := Check(tc.pattern)
+			if tc.wantError == "" {
+				require.NoError(t, err)
+			} else {
+				require.EqualError(t, err, tc.wantError)
+			}
+			require.Equal(t, tc.want, got)
+		})
+	}
+}
@@ -0,0 +1,224 @@
+package alertrule
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+
+	commonmodel "github.com/prometheus/common/model"
+	"github.com/prometheus/prometheus/promql/parser"
+	"gopkg.in/yaml.v3"
+
+	"github.com/kuoss/venti/pkg/model"
+	"github.com/kuoss/venti/pkg/service/alerting/template"
+)
+
+// RuleError is an invalid part of a rule file, located by its line and column.
+type RuleError struct {
+	File   string
+	Line   int
+	Column int
+	Group  string // empty for the errors of the file itself
+	Rule   string // the alert or record name; empty for the errors of a group
+	Err    error
+}
+
+func (e *RuleError) Error() string {
+	msg := fmt.Sprintf("%s:%d:%d: ", e.File, e.Line, e.Column)
+	if e.Group != "" {
+		msg += fmt.Sprintf("group %q, ", e.Group)
+	}
+	if e.Rule != "" {
+		msg += fmt.Sprintf("rule %q, ", e.Rule)
+	}
+	return msg + e.Err.Error()
+}
+
+func (e *RuleError) Unwrap() error {
+	return e.Err
+}
+
+// The nodes of a rule file keep the positions of its values, like ruleGroupNode of Prometheus.
+// https://github.com/prometheus/prometheus/blob/v3.5.0/model/rulefmt/rulefmt.go#L160
+type ruleFileNode struct {
+	CommonLabels       yaml.Node       `yaml:"commonLabels"`
+	DatasourceSelector yaml.Node       `yaml:"datasourceSelector"`
+	RuleGroups         []ruleGroupNode `yaml:"groups"`
+}
+
+type ruleGroupNode struct {
+	Name  yaml.Node  `yaml:"name"`
+	Rules []ruleNode `yaml:"rules"`
+
+	node *yaml.Node // the group itself, for the errors on missing keys
+}
+
+func (n *ruleGroupNode) UnmarshalYAML(value *yaml.Node) error {
+	type plain ruleGroupNode
+	n.node = value
+	return value.Decode((*plain)(n))
+}
+
+type ruleNode struct {
+	Record      yaml.Node `yaml:"record"`
+	Alert       yaml.Node `yaml:"alert"`
+	Expr        yaml.Node `yaml:"expr"`
+	Labels      yaml.Node `yaml:"labels"`
+	Annotations yaml.Node `yaml:"annotations"`
+
+	node *yaml.Node // the rule itself, for the errors on missing keys
+}
+
+func (n *ruleNode) UnmarshalYAML(value *yaml.Node) error {
+	type plain ruleNode
+	n.node = value
+	return value.Decode((*plain)(n))
+}
+
+// validateRuleFile checks the rules of a file, beyond its YAML shape.
+// All of the errors are returned at once, so that a file can be fixed in one go.
+func validateRuleFile(ruleFile *model.RuleFile, yamlBytes []byte) error {
+	var node ruleFileNode
+	if err := yaml.Unmarshal(yamlBytes, &node); err != nil {
+		return fmt.Errorf("unmarshal err: %w", err)
+	}
+	v := &validator{file: ruleFile.File}
+	v.validateSelector(ruleFile.DatasourceSelector, &node.DatasourceSelector)
+	v.validateLabelNames(ruleFile.CommonLabels, &node.CommonLabels, "", "")
+
+	groupNames := map[string]bool{}
+	for i, group := range ruleFile.RuleGroups {
+		groupNode := &node.RuleGroups[i]
+		switch {
+		case group.Name == "":
+			v.add(groupNode.node, "", "", errors.New("group name is required"))
+		case groupNames[group.Name]:
+			v.add(&groupNode.Name, group.Name, "", errors.New("duplicate group name"))
+		}
+		groupNames[group.Name] = true
+
+		alertNames := map[string]bool{}
+		for j, rule := range group.Rules {
+			ruleNode := &groupNode.Rules[j]
+			if rule.Alert != "" {
+				if alertNames[rule.Alert] {
+					v.add(&ruleNode.Alert, group.Name, rule.Alert, errors.New("duplicate alert name in the group"))
+				}
+				alertNames[rule.Alert] = true
+			}
+			v.validateRule(ruleFile.DatasourceSelector, group.Name, rule, ruleNode)
+		}
+	}
+	return errors.Join(v.errs...)
+}
+
+type validator struct {
+	file string
+	errs []error
+}
+
+func (v *validator) add(node *yaml.Node, group string, rule string, err error) {
+	v.errs = append(v.errs, &RuleError{File: v.file, Line: node.Line, Column: node.Column, Group: group, Rule: rule, Err: err})
+}
+
+func (v *validator) validateSelector(selector model.DatasourceSelector, node *yaml.Node) {
+	switch selector.System {
+	case model.DatasourceSystemNone, model.DatasourceSystemMain, model.DatasourceSystemSub:
+	default:
+		v.add(valueNode(node, "system"), "", "", fmt.Errorf("invalid datasourceSelector system %q, must be one of main, sub", selector.System))
+	}
+	switch selector.Type {
+	case model.DatasourceTypeNone, model.DatasourceTypePrometheus, model.DatasourceTypeLethe:
+	default:
+		v.add(valueNode(node, "type"), "", "", fmt.Errorf("invalid datasourceSelector type %q, must be one of prometheus, lethe", selector.Type))
+	}
+}
+
+func (v *validator) validateRule(selector model.DatasourceSelector, group string, rule model.Rule, node *ruleNode) {
+	name := rule.Alert
+	if name == "" {
+		name = rule.Record
+	}
+	switch {
+	case rule.Alert != "" && rule.Record != "":
+		v.add(&node.Record, group, name, errors.New("only one of 'alert' and 'record' can be set"))
+	case rule.Alert == "" && rule.Record == "":
+		v.add(node.node, group, name, errors.New("one of 'alert' and 'record' must be set"))
+	case rule.Record != "" && !commonmodel.IsValidLegacyMetricName(rule.Record):
+		v.add(&node.Record, group, name, fmt.Errorf("invalid record name %q", rule.Record))
+	}
+	if rule.Record != "" && len(rule.Annotations) > 0 {
+		v.add(&node.Annotations, group, name, errors.New("a recording rule cannot have annotations"))
+	}
+
+	// LetheQL has no parser here, so only the rules for Prometheus are parsed
+	switch {
+	case rule.Expr == "":
+		v.add(orNode(&node.Expr, node.node), group, name, errors.New("expr is required"))
+	case selector.Type == model.DatasourceTypePrometheus:
+		if _, err := parser.ParseExpr(rule.Expr); err != nil {
+			v.add(&node.Expr, group, name, fmt.Errorf("invalid expr: %w", err))
+		}
+	}
+
+	v.validateLabelNames(rule.Labels, &node.Labels, group, name)
+	v.validateTemplates("label", rule.Labels, &node.Labels, group, name)
+	v.validateTemplates("annotation", rule.Annotations, &node.Annotations, group, name)
+}
+
+func (v *validator) validateLabelNames(lbls map[string]string, node *yaml.Node, group string, rule string) {
+	for _, k := range sortedKeys(lbls) {
+		switch {
+		case !commonmodel.LabelName(k).IsValidLegacy():
+			v.add(keyNode(node, k), group, rule, fmt.Errorf("invalid label name %q", k))
+		case k == commonmodel.MetricNameLabel:
+			v.add(keyNode(node, k), group, rule, fmt.Errorf("label name %q is reserved", k))
+		}
+	}
+}
+
+func (v *validator) validateTemplates(kind string, templates map[string]string, node *yaml.Node, group string, rule string) {
+	for _, k := range sortedKeys(templates) {
+		name := fmt.Sprintf("__alert_%s_%s_%s", rule, kind, k)
+		if err := template.Parse(name, templates[k]); err != nil {
+			v.add(valueNode(node, k), group, rule, fmt.Errorf("invalid %s template %q: %w", kind, k, err))
+		}
+	}
+}
+
+// orNode returns the node if it is present in the file, or the fallback.
+func orNode(node *yaml.Node, fallback *yaml.Node) *yaml.Node {
+	if node.Line == 0 {
+		return fallback
+	}
+	return node
+}
+
+// keyNode returns the node of a key in a mapping node, or the mapping node itself if it is not found.
+func keyNode(node *yaml.Node, key string) *yaml.Node {
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		if node.Content[i].Value == key {
+			return node.Content[i]
+		}
+	}
+	return node
+}
+
+// valueNode returns the node of a value in a mapping node, or the mapping node itself if it is not found.
+func valueNode(node *yaml.Node, key string) *yaml.Node {
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		if node.Content[i].Value == key {
+			return node.Content[i+1]
+		}
+	}
+	return node
+}
+
+func sortedKeys(m map[string]string) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
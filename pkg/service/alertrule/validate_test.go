@@ -0,0 +1,162 @@
+package alertrule
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestValidateRuleFile(t *testing.T) {
+	testCases := []struct {
+		name      string
+		content   string
+		wantError string
+	}{
+		{
+			"ok",
+			`
+datasourceSelector:
+  type: prometheus
+groups:
+- name: group1
+  rules:
+  - record: job:up:sum
+    expr: sum by (job) (up)
+  - alert: Down
+    expr: job:up:sum == 0
+    labels:
+      severity: '{{ if gt $value 1.0 }}critical{{ end }}'
+    annotations:
+      summary: '{{ $labels.job }} is down ({{ humanize $value }})'
+`,
+			``,
+		},
+		{
+			"lethe expr is not parsed",
+			`
+datasourceSelector:
+  type: lethe
+groups:
+- name: group1
+  rules:
+  - alert: Error
+    expr: pod{namespace="default"} |= "error"
+`,
+			``,
+		},
+		{
+			"datasourceSelector",
+			`
+datasourceSelector:
+  system: primary
+  type: loki
+groups: []
+`,
+			`rules.yml:3:11: invalid datasourceSelector system "primary", must be one of main, sub` + "\n" +
+				`rules.yml:4:9: invalid datasourceSelector type "loki", must be one of prometheus, lethe`,
+		},
+		{
+			"commonLabels",
+			`
+commonLabels:
+  team-name: infra
+groups: []
+`,
+			`rules.yml:3:3: invalid label name "team-name"`,
+		},
+		{
+			"groups",
+			`
+groups:
+- name: group1
+  rules: []
+- name: group1
+  rules: []
+- rules: []
+`,
+			`rules.yml:5:9: group "group1", duplicate group name` + "\n" +
+				`rules.yml:7:3: group name is required`,
+		},
+		{
+			"expr",
+			`
+datasourceSelector:
+  type: prometheus
+groups:
+- name: group1
+  rules:
+  - alert: Up
+    expr: sum(up
+  - alert: Empty
+    expr: ""
+`,
+			`rules.yml:8:11: group "group1", rule "Up", invalid expr: 1:7: parse error: unclosed left parenthesis` + "\n" +
+				`rules.yml:10:11: group "group1", rule "Empty", expr is required`,
+		},
+		{
+			"rules",
+			`
+groups:
+- name: group1
+  rules:
+  - alert: Up
+    expr: up
+  - alert: Up
+    expr: up
+  - record: job:up
+    alert: JobUp
+    expr: up
+  - expr: up
+  - record: job-up
+    expr: up
+    annotations:
+      summary: up
+`,
+			`rules.yml:7:12: group "group1", rule "Up", duplicate alert name in the group` + "\n" +
+				`rules.yml:9:13: group "group1", rule "JobUp", only one of 'alert' and 'record' can be set` + "\n" +
+				`rules.yml:12:5: group "group1", one of 'alert' and 'record' must be set` + "\n" +
+				`rules.yml:13:13: group "group1", rule "job-up", invalid record name "job-up"` + "\n" +
+				`rules.yml:16:7: group "group1", rule "job-up", a recording rule cannot have annotations`,
+		},
+		{
+			"labels and annotations",
+			`
+groups:
+- name: group1
+  rules:
+  - alert: Up
+    expr: up
+    labels:
+      __name__: up
+      0severity: info
+      team: '{{ $team }}'
+    annotations:
+      summary: '{{ $labels.job'
+`,
+			`rules.yml:9:7: group "group1", rule "Up", invalid label name "0severity"` + "\n" +
+				`rules.yml:8:7: group "group1", rule "Up", label name "__name__" is reserved` + "\n" +
+				`rules.yml:10:13: group "group1", rule "Up", invalid label template "team": template: __alert_Up_label_team:1: undefined variable "$team"` + "\n" +
+				`rules.yml:12:16: group "group1", rule "Up", invalid annotation template "summary": template: __alert_Up_annotation_summary:1: unclosed action`,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			dir := t.TempDir()
+			filename := filepath.Join(dir, "rules.yml")
+			require.NoError(t, os.WriteFile(filename, []byte(tc.content), 0660))
+			_, err := loadAlertRuleFileFromFilename(filename)
+			if tc.wantError == "" {
+				require.NoError(t, err)
+				return
+			}
+			require.Error(t, err)
+			var ruleErr *RuleError
+			require.True(t, errors.As(err, &ruleErr))
+			require.Equal(t, "validateRuleFile err: "+tc.wantError, strings.ReplaceAll(err.Error(), dir+"/", ""))
+		})
+	}
+}
@@ -0,0 +1,86 @@
+package auth
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/kuoss/common/logger"
+)
+
+// The events of the audit log.
+const (
+	EventLoginFailed          = "login_failed"
+	EventLoginThrottled       = "login_throttled"
+	EventLockedOut            = "locked_out"
+	EventPasswordChangeFailed = "password_change_failed"
+)
+
+// AuditEvent is a line of the audit log.
+type AuditEvent struct {
+	Time     time.Time `json:"time"`
+	Event    string    `json:"event"`
+	Username string    `json:"username"`
+	IP       string    `json:"ip"`
+	Reason   string    `json:"reason,omitempty"`
+}
+
+// auditLog writes the events as JSON lines to a file, or to the log without a file.
+// The file is switched on reload under the lock of the writers, so that no event is written to a closed file.
+type auditLog struct {
+	mu   sync.Mutex
+	file string
+	w    io.WriteCloser
+}
+
+func newAuditLog(file string) (*auditLog, error) {
+	a := &auditLog{}
+	if err := a.setFile(file); err != nil {
+		return nil, err
+	}
+	return a, nil
+}
+
+// setFile switches to the file, and closes the previous one. Nothing changes when the file fails to open.
+func (a *auditLog) setFile(file string) error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if file == a.file {
+		return nil
+	}
+	var w io.WriteCloser
+	if file != "" {
+		f, err := os.OpenFile(file, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o600)
+		if err != nil {
+			return fmt.Errorf("open audit log err: %w", err)
+		}
+		w = f
+	}
+	if a.w != nil {
+		if err := a.w.Close(); err != nil {
+			logger.Warnf("close audit log err: %s", err.Error())
+		}
+	}
+	a.file, a.w = file, w
+	return nil
+}
+
+func (a *auditLog) write(event AuditEvent) {
+	line, err := json.Marshal(event)
+	if err != nil {
+		logger.Errorf("marshal audit event err: %s", err.Error())
+		return
+	}
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if a.w == nil {
+		logger.Warnf("audit: %s", line)
+		return
+	}
+	if _, err := a.w.Write(append(line, '\n')); err != nil {
+		logger.Errorf("write audit log err: %s", err.Error())
+	}
+}
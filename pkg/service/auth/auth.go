@@ -0,0 +1,98 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/kuoss/venti/pkg/model"
+	"github.com/kuoss/venti/pkg/service/user"
+)
+
+var (
+	// ErrUserNotFound is returned by an authenticator that does not know the user, so that the next one is tried.
+	ErrUserNotFound = errors.New("username not found")
+	// ErrInvalidPassword is returned for a known user with a wrong password.
+	ErrInvalidPassword = errors.New("username or password is incorrect")
+	// ErrUserDisabled is returned for a disabled user with the right password.
+	ErrUserDisabled = errors.New("user disabled")
+)
+
+// Authenticator checks the password of a user, and returns the user in the users table.
+type Authenticator interface {
+	Name() string
+	Authenticate(ctx context.Context, username, password string) (model.User, error)
+}
+
+// AuthService logs in the users by password, with the local users and optionally a directory.
+type AuthService struct {
+	authenticators []Authenticator
+	userService    *user.UserService
+	guard          *Guard
+}
+
+// New returns the service with the local users of users.yml first, and then the users of the directory if any.
+// The guard throttles the logins, and is kept across reloads.
+func New(userService *user.UserService, ldapConfig *model.LDAPConfig, guard *Guard) *AuthService {
+	authenticators := []Authenticator{NewLocal(userService)}
+	if ldapConfig != nil {
+		authenticators = append(authenticators, NewLDAP(*ldapConfig, userService))
+	}
+	return &AuthService{authenticators, userService, guard}
+}
+
+// Login authenticates the user from the IP, unless the username or the IP is throttled.
+// The failed credentials are counted by the guard, and returned as ErrInvalidPassword alike,
+// so that the client cannot tell an unknown or disabled user from a wrong password.
+func (s *AuthService) Login(ctx context.Context, username, password, ip string) (model.User, error) {
+	if err := s.guard.Reserve(username, ip); err != nil {
+		return model.User{}, err
+	}
+	user, err := s.Authenticate(ctx, username, password)
+	if err != nil {
+		if errors.Is(err, ErrUserNotFound) || errors.Is(err, ErrInvalidPassword) || errors.Is(err, ErrUserDisabled) {
+			s.guard.Fail(username, ip, err)
+			return model.User{}, ErrInvalidPassword
+		}
+		s.guard.Release(username, ip)
+		return model.User{}, err
+	}
+	s.guard.Succeed(username, ip)
+	return user, nil
+}
+
+// ChangePassword changes the password of the user from the IP, unless the username or the IP is throttled,
+// as the current password is checked like at the login.
+func (s *AuthService) ChangePassword(u model.User, currentPassword, newPassword, token, ip string) error {
+	if err := s.guard.Reserve(u.Username, ip); err != nil {
+		return err
+	}
+	err := s.userService.ChangePassword(u, currentPassword, newPassword, token)
+	switch {
+	case errors.Is(err, user.ErrWrongPassword):
+		s.guard.FailPasswordChange(u.Username, ip)
+	case err != nil:
+		s.guard.Release(u.Username, ip)
+	default:
+		s.guard.Succeed(u.Username, ip)
+	}
+	return err
+}
+
+// Authenticate asks the authenticators in order, until one knows the user.
+func (s *AuthService) Authenticate(ctx context.Context, username, password string) (model.User, error) {
+	for _, a := range s.authenticators {
+		user, err := a.Authenticate(ctx, username, password)
+		if errors.Is(err, ErrUserNotFound) {
+			continue
+		}
+		if err != nil && !errors.Is(err, ErrInvalidPassword) {
+			return model.User{}, fmt.Errorf("%s: %w", a.Name(), err)
+		}
+		if err == nil && user.Disabled {
+			return model.User{}, ErrUserDisabled
+		}
+		return user, err
+	}
+	return model.User{}, ErrUserNotFound
+}
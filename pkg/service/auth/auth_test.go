@@ -0,0 +1,420 @@
+package auth
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/bcrypt"
+
+	"github.com/kuoss/venti/pkg/mocker/ldap"
+	"github.com/kuoss/venti/pkg/model"
+	"github.com/kuoss/venti/pkg/service/user"
+)
+
+func newUserService(t *testing.T) *user.UserService {
+	hash, err := bcrypt.GenerateFromPassword([]byte("admin"), bcrypt.MinCost)
+	require.NoError(t, err)
+	s, err := user.New(t.TempDir()+"/venti.sqlite3", model.UserConfig{
+		EtcUsers: []model.EtcUser{{Username: "admin", Hash: string(hash), IsAdmin: true}},
+	}, model.SessionConfig{})
+	require.NoError(t, err)
+	return s
+}
+
+func newDirectory(t *testing.T) (*ldap.Server, model.LDAPConfig) {
+	server, err := ldap.New(
+		ldap.Entry{DN: "cn=venti,ou=services,dc=example,dc=com", Password: "venti"},
+		ldap.Entry{DN: "uid=alice,ou=people,dc=example,dc=com", Password: "alice", Attributes: map[string][]string{"uid": {"alice"}}},
+		ldap.Entry{DN: "uid=bob,ou=people,dc=example,dc=com", Password: "bob", Attributes: map[string][]string{"uid": {"bob"}}},
+		ldap.Entry{DN: "uid=admin,ou=people,dc=example,dc=com", Password: "ldap-admin", Attributes: map[string][]string{"uid": {"admin"}}},
+		ldap.Entry{DN: "cn=ops,ou=groups,dc=example,dc=com", Attributes: map[string][]string{"cn": {"ops"}, "member": {"uid=alice,ou=people,dc=example,dc=com"}}},
+		ldap.Entry{DN: "cn=dev,ou=groups,dc=example,dc=com", Attributes: map[string][]string{"cn": {"dev"}, "member": {"uid=alice,ou=people,dc=example,dc=com", "uid=bob,ou=people,dc=example,dc=com", "uid=admin,ou=people,dc=example,dc=com"}}},
+	)
+	require.NoError(t, err)
+	t.Cleanup(server.Close)
+	return server, model.LDAPConfig{
+		URL:            server.URL,
+		Timeout:        time.Second,
+		BindDN:         "cn=venti,ou=services,dc=example,dc=com",
+		BindPassword:   "venti",
+		BaseDN:         "ou=people,dc=example,dc=com",
+		UserFilter:     "(uid={username})",
+		GroupBaseDN:    "ou=groups,dc=example,dc=com",
+		GroupFilter:    "(member={dn})",
+		GroupAttribute: "cn",
+		GroupRoles:     map[string]model.Role{"ops": model.RoleAdmin, "dev": model.RoleEditor},
+	}
+}
+
+func newGuard(t *testing.T) *Guard {
+	guard, err := NewGuard(model.LoginConfig{})
+	require.NoError(t, err)
+	return guard
+}
+
+func TestLocal(t *testing.T) {
+	userService := newUserService(t)
+	_, err := userService.ProvisionUser("carol", "oidc", nil, model.RoleViewer)
+	require.NoError(t, err)
+	local := NewLocal(userService)
+
+	u, err := local.Authenticate(context.Background(), "admin", "admin")
+	require.NoError(t, err)
+	require.Equal(t, "admin", u.Username)
+
+	_, err = local.Authenticate(context.Background(), "admin", "wrong")
+	require.ErrorIs(t, err, ErrInvalidPassword)
+	_, err = local.Authenticate(context.Background(), "nobody", "admin")
+	require.ErrorIs(t, err, ErrUserNotFound)
+	_, err = local.Authenticate(context.Background(), "carol", "")
+	require.ErrorIs(t, err, ErrUserNotFound)
+}
+
+func TestLDAP(t *testing.T) {
+	_, cfg := newDirectory(t)
+	userService := newUserService(t)
+	l := NewLDAP(cfg, userService)
+	ctx := context.Background()
+
+	u, err := l.Authenticate(ctx, "alice", "alice")
+	require.NoError(t, err)
+	require.Equal(t, "ldap", u.Provider)
+	require.Equal(t, []string{"dev", "ops"}, u.Groups)
+	require.Equal(t, model.RoleAdmin, u.GetRole())
+
+	u, err = l.Authenticate(ctx, "bob", "bob")
+	require.NoError(t, err)
+	require.Equal(t, model.RoleEditor, u.GetRole())
+
+	_, err = l.Authenticate(ctx, "bob", "wrong")
+	require.ErrorIs(t, err, ErrInvalidPassword)
+	_, err = l.Authenticate(ctx, "bob", "")
+	require.ErrorIs(t, err, ErrInvalidPassword)
+	_, err = l.Authenticate(ctx, "nobody", "bob")
+	require.ErrorIs(t, err, ErrUserNotFound)
+	_, err = l.Authenticate(ctx, "*", "bob")
+	require.ErrorIs(t, err, ErrUserNotFound)
+
+	// a local user is not taken over
+	_, err = l.Authenticate(ctx, "admin", "ldap-admin")
+	require.EqualError(t, err, `ProvisionUser err: user "admin" exists but not by ldap`)
+
+	// without a role
+	cfg.GroupRoles = map[string]model.Role{"ops": model.RoleAdmin}
+	_, err = NewLDAP(cfg, userService).Authenticate(ctx, "bob", "bob")
+	require.EqualError(t, err, `no role for user "bob" with groups [dev]`)
+	cfg.DefaultRole = model.RoleViewer
+	u, err = NewLDAP(cfg, userService).Authenticate(ctx, "bob", "bob")
+	require.NoError(t, err)
+	require.Equal(t, model.RoleViewer, u.GetRole())
+
+	// a wrong service account
+	cfg.BindPassword = "wrong"
+	_, err = NewLDAP(cfg, userService).Authenticate(ctx, "bob", "bob")
+	require.ErrorContains(t, err, "bind as bindDN err: ")
+}
+
+func TestAuthService(t *testing.T) {
+	server, cfg := newDirectory(t)
+	userService := newUserService(t)
+	ctx := context.Background()
+
+	testCases := []struct {
+		name      string
+		ldap      *model.LDAPConfig
+		username  string
+		password  string
+		wantError error
+	}{
+		{"local", nil, "admin", "admin", nil},
+		{"local wrong password", nil, "admin", "wrong", ErrInvalidPassword},
+		{"local not found", nil, "alice", "alice", ErrUserNotFound},
+		{"ldap", &cfg, "alice", "alice", nil},
+		{"local first", &cfg, "admin", "admin", nil},
+		{"local first wrong password", &cfg, "admin", "ldap-admin", ErrInvalidPassword},
+		{"ldap wrong password", &cfg, "alice", "wrong", ErrInvalidPassword},
+		{"not found", &cfg, "nobody", "nobody", ErrUserNotFound},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			u, err := New(userService, tc.ldap, newGuard(t)).Authenticate(ctx, tc.username, tc.password)
+			if tc.wantError != nil {
+				require.ErrorIs(t, err, tc.wantError)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, tc.username, u.Username)
+		})
+	}
+
+	// disabled
+	alice, err := userService.FindByUsername("alice")
+	require.NoError(t, err)
+	disabled := true
+	_, err = userService.UpdateUser(alice.ID, user.UserUpdate{Disabled: &disabled})
+	require.NoError(t, err)
+	_, err = New(userService, &cfg, newGuard(t)).Authenticate(ctx, "alice", "alice")
+	require.ErrorIs(t, err, ErrUserDisabled)
+	_, err = New(userService, &cfg, newGuard(t)).Authenticate(ctx, "alice", "wrong")
+	require.ErrorIs(t, err, ErrInvalidPassword)
+
+	server.Close()
+	_, err = New(userService, &cfg, newGuard(t)).Authenticate(ctx, "alice", "alice")
+	require.ErrorContains(t, err, "ldap: dial err: ")
+}
+
+func TestLogin(t *testing.T) {
+	userService := newUserService(t)
+	auditLogFile := t.TempDir() + "/audit.log"
+	guard, err := NewGuard(model.LoginConfig{MaxFailures: 2, AuditLogFile: auditLogFile})
+	require.NoError(t, err)
+	now := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	guard.now = func() time.Time { return now }
+	s := New(userService, nil, guard)
+	ctx := context.Background()
+
+	// an unknown user fails like a wrong password
+	_, err = s.Login(ctx, "nobody", "admin", "10.0.0.1")
+	require.Equal(t, ErrInvalidPassword, err)
+	_, err = s.Login(ctx, "admin", "wrong", "10.0.0.1")
+	require.EqualError(t, err, "too many failed logins, retry after 1s")
+	_, err = s.Login(ctx, "admin", "wrong", "10.0.0.9")
+	require.Equal(t, ErrInvalidPassword, err)
+
+	// the delay of the username, from another IP
+	_, err = s.Login(ctx, "admin", "admin", "10.0.0.2")
+	require.EqualError(t, err, "too many failed logins, retry after 1s")
+	now = now.Add(time.Second)
+	u, err := s.Login(ctx, "admin", "admin", "10.0.0.2")
+	require.NoError(t, err)
+	require.Equal(t, "admin", u.Username)
+
+	// the lockout
+	_, err = s.Login(ctx, "admin", "wrong", "10.0.0.2")
+	require.Equal(t, ErrInvalidPassword, err)
+	now = now.Add(time.Second)
+	_, err = s.Login(ctx, "admin", "wrong", "10.0.0.2")
+	require.Equal(t, ErrInvalidPassword, err)
+	now = now.Add(time.Minute)
+	_, err = s.Login(ctx, "admin", "admin", "10.0.0.3")
+	var throttled *ThrottledError
+	require.ErrorAs(t, err, &throttled)
+	require.Equal(t, 14*time.Minute, throttled.RetryAfter)
+	now = now.Add(14 * time.Minute)
+	_, err = s.Login(ctx, "admin", "admin", "10.0.0.3")
+	require.NoError(t, err)
+
+	data, err := os.ReadFile(auditLogFile)
+	require.NoError(t, err)
+	require.Equal(t, `{"time":"2026-01-01T00:00:00Z","event":"login_failed","username":"nobody","ip":"10.0.0.1","reason":"user not found"}
+{"time":"2026-01-01T00:00:00Z","event":"login_throttled","username":"admin","ip":"10.0.0.1","reason":"delay"}
+{"time":"2026-01-01T00:00:00Z","event":"login_failed","username":"admin","ip":"10.0.0.9","reason":"invalid password"}
+{"time":"2026-01-01T00:00:00Z","event":"login_throttled","username":"admin","ip":"10.0.0.2","reason":"delay"}
+{"time":"2026-01-01T00:00:01Z","event":"login_failed","username":"admin","ip":"10.0.0.2","reason":"invalid password"}
+{"time":"2026-01-01T00:00:02Z","event":"login_failed","username":"admin","ip":"10.0.0.2","reason":"invalid password"}
+{"time":"2026-01-01T00:00:02Z","event":"locked_out","username":"admin","ip":"10.0.0.2","reason":"username"}
+{"time":"2026-01-01T00:01:02Z","event":"login_throttled","username":"admin","ip":"10.0.0.3","reason":"locked out"}
+`, string(data))
+}
+
+func TestChangePassword(t *testing.T) {
+	userService := newUserService(t)
+	alice, err := userService.CreateUser("alice", "password1", model.RoleViewer, nil)
+	require.NoError(t, err)
+	auditLogFile := t.TempDir() + "/audit.log"
+	guard, err := NewGuard(model.LoginConfig{MaxFailures: 2, AuditLogFile: auditLogFile})
+	require.NoError(t, err)
+	now := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	guard.now = func() time.Time { return now }
+	s := New(userService, nil, guard)
+
+	// a wrong current password counts as a failed login
+	require.ErrorIs(t, s.ChangePassword(alice, "wrong", "password2", "", "10.0.0.1"), user.ErrWrongPassword)
+	require.EqualError(t, s.ChangePassword(alice, "password1", "password2", "", "10.0.0.2"), "too many failed logins, retry after 1s")
+	_, err = s.Login(context.Background(), "alice", "password1", "10.0.0.2")
+	require.EqualError(t, err, "too many failed logins, retry after 1s")
+	now = now.Add(time.Second)
+	// other errors do not count
+	require.EqualError(t, s.ChangePassword(alice, "password1", "short", "", "10.0.0.2"), "password is shorter than 8 characters")
+	require.ErrorIs(t, s.ChangePassword(alice, "wrong", "password2", "", "10.0.0.2"), user.ErrWrongPassword)
+	var throttled *ThrottledError
+	require.ErrorAs(t, s.ChangePassword(alice, "password1", "password2", "", "10.0.0.3"), &throttled)
+	require.Equal(t, 15*time.Minute, throttled.RetryAfter)
+
+	now = now.Add(15 * time.Minute)
+	require.NoError(t, s.ChangePassword(alice, "password1", "password2", "", "10.0.0.3"))
+
+	data, err := os.ReadFile(auditLogFile)
+	require.NoError(t, err)
+	require.Equal(t, `{"time":"2026-01-01T00:00:00Z","event":"password_change_failed","username":"alice","ip":"10.0.0.1","reason":"invalid password"}
+{"time":"2026-01-01T00:00:00Z","event":"login_throttled","username":"alice","ip":"10.0.0.2","reason":"delay"}
+{"time":"2026-01-01T00:00:00Z","event":"login_throttled","username":"alice","ip":"10.0.0.2","reason":"delay"}
+{"time":"2026-01-01T00:00:01Z","event":"password_change_failed","username":"alice","ip":"10.0.0.2","reason":"invalid password"}
+{"time":"2026-01-01T00:00:01Z","event":"locked_out","username":"alice","ip":"10.0.0.2","reason":"username"}
+{"time":"2026-01-01T00:00:01Z","event":"login_throttled","username":"alice","ip":"10.0.0.3","reason":"locked out"}
+`, string(data))
+}
+
+func TestThrottle(t *testing.T) {
+	th := newThrottle(5, 15*time.Minute, time.Second, 5*time.Second)
+	now := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	testCases := []struct {
+		failures  int
+		wantDelay time.Duration
+	}{
+		{0, 0},
+		{1, time.Second},
+		{2, 2 * time.Second},
+		{3, 4 * time.Second},
+		{4, 5 * time.Second},
+		{40, 5 * time.Second},
+	}
+	for _, tc := range testCases {
+		require.Equal(t, tc.wantDelay, th.delay(tc.failures), tc.failures)
+	}
+
+	// reserve and fail
+	for i := 0; i < 4; i++ {
+		wait, locked := th.reserve("alice", now)
+		require.Zero(t, wait)
+		require.False(t, locked)
+		require.False(t, th.fail("alice", now))
+		now = now.Add(th.delay(i + 1))
+	}
+	wait, locked := th.reserve("alice", now.Add(-time.Second))
+	require.Equal(t, time.Second, wait)
+	require.False(t, locked)
+	wait, _ = th.reserve("alice", now)
+	require.Zero(t, wait)
+	// the attempt in progress is the last failure left
+	wait, locked = th.reserve("alice", now)
+	require.Equal(t, 5*time.Second, wait)
+	require.False(t, locked)
+	require.True(t, th.fail("alice", now))
+	wait, locked = th.reserve("alice", now.Add(time.Minute))
+	require.Equal(t, 14*time.Minute, wait)
+	require.True(t, locked)
+
+	// the attempts in progress count against maxFailures
+	for i := 0; i < 5; i++ {
+		wait, _ = th.reserve("dave", now)
+		require.Zero(t, wait)
+	}
+	wait, locked = th.reserve("dave", now)
+	require.Equal(t, time.Second, wait)
+	require.False(t, locked)
+	th.release("dave")
+	wait, _ = th.reserve("dave", now)
+	require.Zero(t, wait)
+	for i := 0; i < 4; i++ {
+		th.release("dave")
+	}
+	th.succeed("dave")
+	require.NotContains(t, th.entries, "dave")
+
+	// the failures are forgotten after lockoutDuration
+	th.reserve("bob", now)
+	require.False(t, th.fail("bob", now))
+	wait, _ = th.reserve("bob", now.Add(16*time.Minute))
+	require.Zero(t, wait)
+	require.False(t, th.fail("bob", now.Add(16*time.Minute)))
+	require.Equal(t, 1, th.entries["bob"].failures)
+
+	// and pruned
+	th.reserve("carol", now.Add(time.Hour))
+	require.Len(t, th.entries, 1)
+
+	th.succeed("carol")
+	require.Empty(t, th.entries)
+}
+
+func TestGuardReserve_parallel(t *testing.T) {
+	guard, err := NewGuard(model.LoginConfig{MaxFailures: 3, IPMaxFailures: 1000})
+	require.NoError(t, err)
+
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	reserved := []string{}
+	for i := range 50 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			ip := fmt.Sprintf("10.0.0.%d", i)
+			if guard.Reserve("alice", ip) == nil {
+				mu.Lock()
+				reserved = append(reserved, ip)
+				mu.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+	// a burst gets no more attempts than maxFailures
+	require.Len(t, reserved, 3)
+	for _, ip := range reserved {
+		guard.Fail("alice", ip, ErrInvalidPassword)
+	}
+	var throttled *ThrottledError
+	require.ErrorAs(t, guard.Reserve("alice", "10.0.0.99"), &throttled)
+	require.Equal(t, 15*time.Minute, throttled.RetryAfter.Round(time.Minute))
+}
+
+func TestGuardReload(t *testing.T) {
+	guard, err := NewGuard(model.LoginConfig{})
+	require.NoError(t, err)
+	require.NoError(t, guard.Reserve("alice", "10.0.0.1"))
+	guard.Fail("alice", "10.0.0.1", ErrInvalidPassword)
+
+	auditLogFile := t.TempDir() + "/audit.log"
+	require.NoError(t, guard.Reload(model.LoginConfig{BaseDelay: time.Minute, AuditLogFile: auditLogFile}))
+	err = guard.Reserve("alice", "10.0.0.9")
+	var throttled *ThrottledError
+	require.ErrorAs(t, err, &throttled)
+	require.Greater(t, throttled.RetryAfter, 59*time.Second)
+
+	data, err := os.ReadFile(auditLogFile)
+	require.NoError(t, err)
+	require.Contains(t, string(data), `"event":"login_throttled","username":"alice","ip":"10.0.0.9"`)
+
+	require.ErrorContains(t, guard.Reload(model.LoginConfig{AuditLogFile: t.TempDir() + "/no/audit.log"}), "open audit log err: ")
+}
+
+func TestGuardReload_writing(t *testing.T) {
+	dir := t.TempDir()
+	cfg := model.LoginConfig{AuditLogFile: dir + "/audit0.log", MaxFailures: 1000, IPMaxFailures: 1000, BaseDelay: time.Nanosecond, MaxDelay: time.Nanosecond}
+	guard, err := NewGuard(cfg)
+	require.NoError(t, err)
+
+	var wg sync.WaitGroup
+	for i := range 4 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for range 100 {
+				for guard.Reserve(fmt.Sprintf("user%d", i), "10.0.0.1") != nil {
+				}
+				guard.Fail(fmt.Sprintf("user%d", i), "10.0.0.1", ErrInvalidPassword)
+			}
+		}()
+	}
+	for i := range 10 {
+		cfg.AuditLogFile = fmt.Sprintf("%s/audit%d.log", dir, i%2)
+		require.NoError(t, guard.Reload(cfg))
+	}
+	wg.Wait()
+
+	// every failure is in one of the files, none is lost on a closed file
+	lines := 0
+	for _, file := range []string{"audit0.log", "audit1.log"} {
+		data, err := os.ReadFile(dir + "/" + file)
+		require.NoError(t, err)
+		lines += strings.Count(string(data), `"event":"login_failed"`)
+	}
+	require.Equal(t, 400, lines)
+}
@@ -0,0 +1,127 @@
+package auth
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/kuoss/venti/pkg/model"
+)
+
+// ThrottledError is returned for a login tried too early after failures, or during a lockout.
+type ThrottledError struct {
+	RetryAfter time.Duration
+}
+
+func (e *ThrottledError) Error() string {
+	return fmt.Sprintf("too many failed logins, retry after %s", e.RetryAfter.Round(time.Second))
+}
+
+// Guard protects the password login against brute force, with the failures counted per username and per IP.
+// It is kept across reloads, so that a reload does not forget the failures.
+type Guard struct {
+	users *throttle
+	ips   *throttle
+	now   func() time.Time
+	audit *auditLog
+}
+
+func NewGuard(cfg model.LoginConfig) (*Guard, error) {
+	cfg = cfg.WithDefaults()
+	audit, err := newAuditLog(cfg.AuditLogFile)
+	if err != nil {
+		return nil, err
+	}
+	return &Guard{
+		users: newThrottle(cfg.MaxFailures, cfg.LockoutDuration, cfg.BaseDelay, cfg.MaxDelay),
+		ips:   newThrottle(cfg.IPMaxFailures, cfg.LockoutDuration, cfg.BaseDelay, cfg.MaxDelay),
+		now:   time.Now,
+		audit: audit,
+	}, nil
+}
+
+// Reload applies the limits and the audit log of a reloaded configuration, and keeps the failures.
+// It fails only on opening the audit log, before it changes anything.
+func (g *Guard) Reload(cfg model.LoginConfig) error {
+	cfg = cfg.WithDefaults()
+	if err := g.audit.setFile(cfg.AuditLogFile); err != nil {
+		return err
+	}
+	g.users.setLimits(cfg.MaxFailures, cfg.LockoutDuration, cfg.BaseDelay, cfg.MaxDelay)
+	g.ips.setLimits(cfg.IPMaxFailures, cfg.LockoutDuration, cfg.BaseDelay, cfg.MaxDelay)
+	return nil
+}
+
+// Reserve checks the username and the IP, and counts the attempt in the same step, so that parallel attempts
+// cannot pass the limits. It returns a ThrottledError when the username or the IP must wait.
+// A reserved attempt must be done by Fail, Succeed or Release.
+func (g *Guard) Reserve(username, ip string) error {
+	now := g.now()
+	wait, locked := g.users.reserve(username, now)
+	if wait == 0 {
+		wait, locked = g.ips.reserve(ip, now)
+		if wait > 0 {
+			g.users.release(username)
+		}
+	}
+	if wait == 0 {
+		return nil
+	}
+	reason := "delay"
+	if locked {
+		reason = "locked out"
+	}
+	g.write(EventLoginThrottled, username, ip, reason)
+	return &ThrottledError{RetryAfter: wait}
+}
+
+// Fail records the reserved attempt as a failed login of the username from the IP.
+func (g *Guard) Fail(username, ip string, err error) {
+	g.fail(EventLoginFailed, username, ip, failureReason(err))
+}
+
+// FailPasswordChange records the reserved attempt as a password change with a wrong current password,
+// which counts as a failed login, so that the password cannot be guessed there instead.
+func (g *Guard) FailPasswordChange(username, ip string) {
+	g.fail(EventPasswordChangeFailed, username, ip, "invalid password")
+}
+
+func (g *Guard) fail(event, username, ip, reason string) {
+	now := g.now()
+	g.write(event, username, ip, reason)
+	if g.users.fail(username, now) {
+		g.write(EventLockedOut, username, ip, "username")
+	}
+	if g.ips.fail(ip, now) {
+		g.write(EventLockedOut, username, ip, "ip")
+	}
+}
+
+// Succeed forgets the failures of the username. The IP keeps its failures, so that a valid account does not reset them.
+func (g *Guard) Succeed(username, ip string) {
+	g.users.succeed(username)
+	g.ips.release(ip)
+}
+
+// Release gives the reserved attempt back without counting it, e.g. when the directory is unreachable.
+func (g *Guard) Release(username, ip string) {
+	g.users.release(username)
+	g.ips.release(ip)
+}
+
+func (g *Guard) write(event, username, ip, reason string) {
+	g.audit.write(AuditEvent{Time: g.now(), Event: event, Username: username, IP: ip, Reason: reason})
+}
+
+// failureReason tells the failures apart in the audit log only, as the client gets the same error for all of them.
+func failureReason(err error) string {
+	switch {
+	case errors.Is(err, ErrUserNotFound):
+		return "user not found"
+	case errors.Is(err, ErrInvalidPassword):
+		return "invalid password"
+	case errors.Is(err, ErrUserDisabled):
+		return "user disabled"
+	}
+	return err.Error()
+}
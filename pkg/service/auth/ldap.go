@@ -0,0 +1,134 @@
+package auth
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"net"
+	"sort"
+	"strings"
+
+	"github.com/go-ldap/ldap/v3"
+
+	"github.com/kuoss/venti/pkg/model"
+	"github.com/kuoss/venti/pkg/service/user"
+)
+
+const ldapProvider = "ldap"
+
+// LDAP logs in the users of a directory. It finds the user by a search, checks the password by a bind as the user,
+// and gives the user the role of its groups, found by another search.
+type LDAP struct {
+	config      model.LDAPConfig
+	userService *user.UserService
+}
+
+func NewLDAP(cfg model.LDAPConfig, userService *user.UserService) *LDAP {
+	return &LDAP{cfg, userService}
+}
+
+func (l *LDAP) Name() string {
+	return ldapProvider
+}
+
+func (l *LDAP) Authenticate(_ context.Context, username, password string) (model.User, error) {
+	// an empty password would be an unauthenticated bind, which succeeds on most servers
+	if username == "" || password == "" {
+		return model.User{}, ErrInvalidPassword
+	}
+	conn, err := l.dial()
+	if err != nil {
+		return model.User{}, fmt.Errorf("dial err: %w", err)
+	}
+	defer conn.Close()
+
+	if err := l.bindSearcher(conn); err != nil {
+		return model.User{}, err
+	}
+	userDN, err := l.searchUser(conn, username)
+	if err != nil {
+		return model.User{}, err
+	}
+	if err := conn.Bind(userDN, password); err != nil {
+		if ldap.IsErrorWithCode(err, ldap.LDAPResultInvalidCredentials) {
+			return model.User{}, ErrInvalidPassword
+		}
+		return model.User{}, fmt.Errorf("bind err: %w", err)
+	}
+	if err := l.bindSearcher(conn); err != nil {
+		return model.User{}, err
+	}
+	groups, err := l.searchGroups(conn, username, userDN)
+	if err != nil {
+		return model.User{}, err
+	}
+
+	role := model.RoleOfGroups(l.config.GroupRoles, groups, l.config.DefaultRole)
+	if role == model.RoleNone {
+		return model.User{}, fmt.Errorf("no role for user %q with groups %v", username, groups)
+	}
+	u, err := l.userService.ProvisionUser(username, ldapProvider, groups, role)
+	if err != nil {
+		return model.User{}, fmt.Errorf("ProvisionUser err: %w", err)
+	}
+	return u, nil
+}
+
+func (l *LDAP) dial() (*ldap.Conn, error) {
+	tlsConfig := &tls.Config{InsecureSkipVerify: l.config.InsecureSkipVerify} // #nosec G402 -- opt-in for testing
+	dialer := &net.Dialer{Timeout: l.config.Timeout}
+	conn, err := ldap.DialURL(l.config.URL, ldap.DialWithDialer(dialer), ldap.DialWithTLSConfig(tlsConfig))
+	if err != nil {
+		return nil, err
+	}
+	conn.SetTimeout(l.config.Timeout)
+	if l.config.StartTLS {
+		if err := conn.StartTLS(tlsConfig); err != nil {
+			conn.Close()
+			return nil, fmt.Errorf("StartTLS err: %w", err)
+		}
+	}
+	return conn, nil
+}
+
+// bindSearcher binds as the service account to search, or stays anonymous without it.
+func (l *LDAP) bindSearcher(conn *ldap.Conn) error {
+	if l.config.BindDN == "" {
+		return conn.UnauthenticatedBind("")
+	}
+	if err := conn.Bind(l.config.BindDN, l.config.BindPassword); err != nil {
+		return fmt.Errorf("bind as bindDN err: %w", err)
+	}
+	return nil
+}
+
+func (l *LDAP) searchUser(conn *ldap.Conn, username string) (string, error) {
+	filter := strings.ReplaceAll(l.config.UserFilter, "{username}", ldap.EscapeFilter(username))
+	result, err := conn.Search(ldap.NewSearchRequest(l.config.BaseDN, ldap.ScopeWholeSubtree, ldap.NeverDerefAliases, 2, 0, false, filter, []string{"dn"}, nil))
+	if err != nil && !ldap.IsErrorWithCode(err, ldap.LDAPResultSizeLimitExceeded) {
+		return "", fmt.Errorf("search user err: %w", err)
+	}
+	switch {
+	case result == nil || len(result.Entries) == 0:
+		return "", ErrUserNotFound
+	case len(result.Entries) > 1:
+		return "", fmt.Errorf("user %q is not unique", username)
+	}
+	return result.Entries[0].DN, nil
+}
+
+func (l *LDAP) searchGroups(conn *ldap.Conn, username, userDN string) ([]string, error) {
+	filter := strings.NewReplacer("{username}", ldap.EscapeFilter(username), "{dn}", ldap.EscapeFilter(userDN)).Replace(l.config.GroupFilter)
+	result, err := conn.Search(ldap.NewSearchRequest(l.config.GroupBaseDN, ldap.ScopeWholeSubtree, ldap.NeverDerefAliases, 0, 0, false, filter, []string{l.config.GroupAttribute}, nil))
+	if err != nil {
+		return nil, fmt.Errorf("search groups err: %w", err)
+	}
+	groups := []string{}
+	for _, entry := range result.Entries {
+		if name := entry.GetAttributeValue(l.config.GroupAttribute); name != "" {
+			groups = append(groups, name)
+		}
+	}
+	sort.Strings(groups)
+	return groups, nil
+}
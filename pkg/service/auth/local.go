@@ -0,0 +1,52 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+
+	"golang.org/x/crypto/bcrypt"
+	"gorm.io/gorm"
+
+	"github.com/kuoss/venti/pkg/model"
+	"github.com/kuoss/venti/pkg/service/user"
+)
+
+// Local checks the bcrypt hash of the users of users.yml.
+// The users of a provider, e.g. oidc or ldap, are left to it.
+// An unknown user is checked against a dummy hash, so that it takes as long as a wrong password.
+type Local struct {
+	userService *user.UserService
+}
+
+func NewLocal(userService *user.UserService) *Local {
+	return &Local{userService}
+}
+
+func (l *Local) Name() string {
+	return "local"
+}
+
+func (l *Local) Authenticate(_ context.Context, username, password string) (model.User, error) {
+	u, err := l.userService.FindByUsername(username)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			_ = bcrypt.CompareHashAndPassword(dummyHash(), []byte(password))
+			return model.User{}, ErrUserNotFound
+		}
+		return model.User{}, fmt.Errorf("FindByUsername err: %w", err)
+	}
+	if u.Provider != "" {
+		return model.User{}, ErrUserNotFound
+	}
+	if bcrypt.CompareHashAndPassword([]byte(u.Hash), []byte(password)) != nil {
+		return model.User{}, ErrInvalidPassword
+	}
+	return u, nil
+}
+
+var dummyHash = sync.OnceValue(func() []byte {
+	hash, _ := bcrypt.GenerateFromPassword([]byte("venti"), bcrypt.DefaultCost)
+	return hash
+})
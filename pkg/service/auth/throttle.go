@@ -0,0 +1,151 @@
+package auth
+
+import (
+	"sync"
+	"time"
+)
+
+// throttle counts the failed logins of a key, e.g. a username or an IP.
+// After a failure the key waits for a delay that doubles at each failure, up to maxDelay,
+// and it is locked out for lockoutDuration after maxFailures failures.
+// The failures are forgotten lockoutDuration after the last one.
+type throttle struct {
+	maxFailures     int
+	lockoutDuration time.Duration
+	baseDelay       time.Duration
+	maxDelay        time.Duration
+
+	mu        sync.Mutex
+	entries   map[string]*throttleEntry
+	lastPrune time.Time
+}
+
+type throttleEntry struct {
+	failures    int
+	pending     int // the attempts reserved and not done yet
+	lastFailure time.Time
+	lockedUntil time.Time
+}
+
+func newThrottle(maxFailures int, lockoutDuration, baseDelay, maxDelay time.Duration) *throttle {
+	t := &throttle{entries: map[string]*throttleEntry{}}
+	t.setLimits(maxFailures, lockoutDuration, baseDelay, maxDelay)
+	return t
+}
+
+// setLimits changes the limits on reload, and keeps the failures.
+func (t *throttle) setLimits(maxFailures int, lockoutDuration, baseDelay, maxDelay time.Duration) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.maxFailures = maxFailures
+	t.lockoutDuration = lockoutDuration
+	t.baseDelay = baseDelay
+	t.maxDelay = maxDelay
+}
+
+// reserve checks and counts an attempt of the key in one step, so that parallel attempts cannot pass the limits.
+// It returns how long the key must wait before its next try, and whether it is locked out, without counting the attempt;
+// or zero when the attempt is reserved, to be done by fail, succeed or release.
+// The attempts in progress count as failures against maxFailures, so that a burst is not more attempts than the failures left.
+func (t *throttle) reserve(key string, now time.Time) (time.Duration, bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.prune(now)
+	e, ok := t.entries[key]
+	if !ok {
+		e = &throttleEntry{}
+		t.entries[key] = e
+	}
+	if now.Before(e.lockedUntil) {
+		return e.lockedUntil.Sub(now), true
+	}
+	if e.failures > 0 && e.pending == 0 && now.Sub(e.lastFailure) > t.lockoutDuration {
+		e.failures = 0
+	}
+	if e.failures > 0 {
+		if wait := e.lastFailure.Add(t.delay(e.failures)).Sub(now); wait > 0 {
+			return wait, false
+		}
+	}
+	if e.failures+e.pending >= t.maxFailures {
+		return t.delay(e.failures + 1), false
+	}
+	e.pending++
+	return 0, false
+}
+
+// fail records the reserved attempt of the key as a failure, and returns true when it locks the key out.
+func (t *throttle) fail(key string, now time.Time) bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	e := t.done(key)
+	e.failures++
+	e.lastFailure = now
+	if e.failures >= t.maxFailures {
+		e.failures = 0
+		e.lockedUntil = now.Add(t.lockoutDuration)
+		return true
+	}
+	return false
+}
+
+// succeed forgets the failures of the key, after its reserved attempt logged in.
+func (t *throttle) succeed(key string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	e := t.done(key)
+	e.failures = 0
+	t.dropIdle(key, e)
+}
+
+// release gives the reserved attempt of the key back without counting it, e.g. when the directory is unreachable.
+func (t *throttle) release(key string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.dropIdle(key, t.done(key))
+}
+
+// done ends a reserved attempt of the key. The entry of a reserved attempt is not pruned.
+func (t *throttle) done(key string) *throttleEntry {
+	e, ok := t.entries[key]
+	if !ok {
+		// test unreachable: done without reserve
+		e = &throttleEntry{}
+		t.entries[key] = e
+	}
+	if e.pending > 0 {
+		e.pending--
+	}
+	return e
+}
+
+// dropIdle drops the entry without failures, attempts or lockout.
+func (t *throttle) dropIdle(key string, e *throttleEntry) {
+	if e.failures == 0 && e.pending == 0 && e.lockedUntil.IsZero() {
+		delete(t.entries, key)
+	}
+}
+
+func (t *throttle) delay(failures int) time.Duration {
+	if failures <= 0 {
+		return 0
+	}
+	delay := t.baseDelay
+	for i := 1; i < failures && delay < t.maxDelay; i++ {
+		delay *= 2
+	}
+	return min(delay, t.maxDelay)
+}
+
+// prune drops the forgotten entries, at most once per lockoutDuration, so that the map does not grow with the tried keys.
+func (t *throttle) prune(now time.Time) {
+	if now.Sub(t.lastPrune) < t.lockoutDuration {
+		return
+	}
+	t.lastPrune = now
+	for key, e := range t.entries {
+		if e.pending == 0 && now.After(e.lockedUntil) && now.Sub(e.lastFailure) > t.lockoutDuration {
+			delete(t.entries, key)
+		}
+	}
+}
@@ -6,6 +6,7 @@ import (
 	"path/filepath"
 
 	"github.com/kuoss/common/logger"
+	"github.com/kuoss/venti/pkg/config"
 	"github.com/kuoss/venti/pkg/model"
 	"github.com/kuoss/venti/pkg/util"
 )
@@ -62,9 +63,32 @@ func loadDashboardFromFile(filename string) (*model.Dashboard, error) {
 	if err := util.UnmarshalStrict(yamlBytes, &dashboard); err != nil {
 		return nil, fmt.Errorf("error on UnmarshalStrict: %w", err)
 	}
+	if dashboard == nil {
+		return nil, fmt.Errorf("empty dashboard")
+	}
 	return dashboard, nil
 }
 
+// Check loads each dashboard file with the loader of New,
+// and reports the ones that New would skip with a warning.
+func Check(dirpath string) ([]config.FileCheck, error) {
+	files, err := getDashboardFilesFromPath(dirpath)
+	if err != nil {
+		return nil, fmt.Errorf("getDashboardFilesFromPath err: %w", err)
+	}
+	checks := make([]config.FileCheck, len(files))
+	for i, filename := range files {
+		checks[i].File = filename
+		dashboard, err := loadDashboardFromFile(filename)
+		if err != nil {
+			checks[i].Err = err
+			continue
+		}
+		checks[i].Summary = fmt.Sprintf("%d rows found", len(dashboard.Rows))
+	}
+	return checks, nil
+}
+
 func (s *DashboardService) Dashboards() []model.Dashboard {
 	return s.dashboards
 }
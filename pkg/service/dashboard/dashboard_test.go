@@ -5,6 +5,7 @@ import (
 	"os"
 	"testing"
 
+	"github.com/kuoss/venti/pkg/config"
 	"github.com/kuoss/venti/pkg/model"
 	"github.com/stretchr/testify/assert"
 )
@@ -131,3 +132,53 @@ func TestDashboards(t *testing.T) {
 	got := service1.Dashboards()
 	assert.Equal(t, want, got)
 }
+
+func TestCheck(t *testing.T) {
+	dir := t.TempDir()
+	assert.NoError(t, os.WriteFile(dir+"/empty.yml", []byte("---\n"), 0644))
+	assert.NoError(t, os.WriteFile(dir+"/invalid.yml", []byte("xxx: 1\n"), 0644))
+	testCases := []struct {
+		dirpath   string
+		want      []config.FileCheck
+		wantError string
+	}{
+		{
+			"",
+			[]config.FileCheck{{File: "etc/dashboards/sample.yml", Summary: "6 rows found"}},
+			"",
+		},
+		{
+			dir,
+			[]config.FileCheck{
+				{File: dir + "/empty.yml", Err: fmt.Errorf("empty dashboard")},
+				{File: dir + "/invalid.yml", Err: fmt.Errorf("error on UnmarshalStrict: yaml: unmarshal errors:\n  line 1: field xxx not found in type model.Dashboard")},
+			},
+			"",
+		},
+		{
+			"asdf",
+			nil,
+			"getDashboardFilesFromPath err: no dashboard file: dirpath: asdf",
+		},
+	}
+	for i, tc := range testCases {
+		t.Run(fmt.Sprintf("#%d", i), func(t *testing.T) {
+			got, err := Check(tc.dirpath)
+			if tc.wantError == "" {
+				assert.NoError(t, err)
+			} else {
+				assert.EqualError(t, err, tc.wantError)
+			}
+			assert.Len(t, got, len(tc.want))
+			for j := range got {
+				assert.Equal(t, tc.want[j].File, got[j].File)
+				assert.Equal(t, tc.want[j].Summary, got[j].Summary)
+				if tc.want[j].Err == nil {
+					assert.NoError(t, got[j].Err)
+				} else {
+					assert.EqualError(t, got[j].Err, tc.want[j].Err.Error())
+				}
+			}
+		})
+	}
+}
@@ -2,6 +2,7 @@ package datasource
 
 import (
 	"fmt"
+	"sync"
 
 	"github.com/kuoss/venti/pkg/model"
 	"github.com/kuoss/venti/pkg/service/discovery"
@@ -18,11 +19,12 @@ type DatasourceService struct {
 	config      model.DatasourceConfig
 	datasources []model.Datasource
 	discoverer  discovery.Discoverer
+	mu          sync.RWMutex
 }
 
 // NewDatasourceService return *DatasourceService after service discovery (with k8s service)
 func New(cfg *model.DatasourceConfig, discoverer discovery.Discoverer) (*DatasourceService, error) {
-	service := &DatasourceService{false, *cfg, nil, discoverer}
+	service := &DatasourceService{loaded: false, config: *cfg, discoverer: discoverer}
 	err := service.load()
 	if err != nil {
 		return nil, fmt.Errorf("load err: %w", err)
@@ -44,7 +46,9 @@ func (s *DatasourceService) load() error {
 		datasources = append(datasources, discoveredDatasources...)
 	}
 	setMainDatasources(datasources)
+	s.mu.Lock()
 	s.datasources = datasources
+	s.mu.Unlock()
 	return nil
 }
 
@@ -103,6 +107,8 @@ func setMainDatasources(datasources []model.Datasource) {
 
 // return deep copied datasources
 func (s *DatasourceService) getDatasources() []model.Datasource {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
 	datasources := []model.Datasource{}
 	datasources = append(datasources, s.datasources...)
 	return datasources
@@ -67,6 +67,7 @@ func (s *k8sService) getDatasourcesFromServices(services []v1.Service, discovery
 			URL:          fmt.Sprintf("http://%s.%s:%d", service.Name, service.Namespace, portNumber),
 			IsDiscovered: true,
 			IsMain:       isMain,
+			Namespace:    service.Namespace,
 		})
 	}
 	return datasources
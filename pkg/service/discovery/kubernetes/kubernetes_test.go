@@ -75,6 +75,7 @@ func TestDoDiscoveryWithoutAnnotationKey(t *testing.T) {
 			URL:          "http://lethe.kube-system:8080",
 			IsMain:       false,
 			IsDiscovered: true,
+			Namespace:    "kube-system",
 		},
 		{
 			Type:         "prometheus",
@@ -82,6 +83,7 @@ func TestDoDiscoveryWithoutAnnotationKey(t *testing.T) {
 			URL:          "http://prometheus.kube-system:30900",
 			IsMain:       false,
 			IsDiscovered: true,
+			Namespace:    "kube-system",
 		},
 		{
 			Type:         "lethe",
@@ -89,6 +91,7 @@ func TestDoDiscoveryWithoutAnnotationKey(t *testing.T) {
 			URL:          "http://lethe.kuoss:8080",
 			IsMain:       false,
 			IsDiscovered: true,
+			Namespace:    "kuoss",
 		},
 		{
 			Type:         "prometheus",
@@ -96,6 +99,7 @@ func TestDoDiscoveryWithoutAnnotationKey(t *testing.T) {
 			URL:          "http://prometheus.namespace1:30900",
 			IsMain:       false,
 			IsDiscovered: true,
+			Namespace:    "namespace1",
 		},
 		{
 			Type:         "prometheus",
@@ -103,6 +107,7 @@ func TestDoDiscoveryWithoutAnnotationKey(t *testing.T) {
 			URL:          "http://prometheus.namespace2:30900",
 			IsMain:       false,
 			IsDiscovered: true,
+			Namespace:    "namespace2",
 		}}
 
 	k8sService := &k8sService{fake.NewSimpleClientset(servicesWithoutAnnotation...)}
@@ -125,6 +130,7 @@ func TestDoDiscoveryAnnotationKey(t *testing.T) {
 			URL:          "http://lethe.kube-system:8080",
 			IsMain:       false,
 			IsDiscovered: true,
+			Namespace:    "kube-system",
 		},
 		{
 			Type:         "prometheus",
@@ -132,6 +138,7 @@ func TestDoDiscoveryAnnotationKey(t *testing.T) {
 			URL:          "http://prometheus.kube-system:30900",
 			IsMain:       false,
 			IsDiscovered: true,
+			Namespace:    "kube-system",
 		},
 		{
 			Type:         "lethe",
@@ -139,6 +146,7 @@ func TestDoDiscoveryAnnotationKey(t *testing.T) {
 			URL:          "http://lethe.kuoss:8080",
 			IsMain:       false,
 			IsDiscovered: true,
+			Namespace:    "kuoss",
 		},
 		{
 			Type:         "prometheus",
@@ -146,6 +154,7 @@ func TestDoDiscoveryAnnotationKey(t *testing.T) {
 			URL:          "http://prometheus.namespace1:30900",
 			IsMain:       false,
 			IsDiscovered: true,
+			Namespace:    "namespace1",
 		},
 		{
 			Type:         "prometheus",
@@ -153,6 +162,7 @@ func TestDoDiscoveryAnnotationKey(t *testing.T) {
 			URL:          "http://prometheus.namespace2:30900",
 			IsMain:       false,
 			IsDiscovered: true,
+			Namespace:    "namespace2",
 		}}
 
 	k8sService := &k8sService{fake.NewSimpleClientset(servicesWithAnnotation...)}
@@ -0,0 +1,167 @@
+package oidc
+
+import (
+	"context"
+	"crypto/rand"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	gooidc "github.com/coreos/go-oidc/v3/oidc"
+	"golang.org/x/oauth2"
+
+	"github.com/kuoss/venti/pkg/model"
+)
+
+// loginTimeout is how long a login can take at the provider.
+var loginTimeout = 10 * time.Minute
+
+// OIDCService logs in the users with an OpenID Connect provider, by the authorization code flow with PKCE.
+type OIDCService struct {
+	config   model.OIDCConfig
+	mu       sync.Mutex
+	provider *gooidc.Provider // discovered at the first login, so that Venti starts while the provider is down
+	logins   map[string]login // by state
+}
+
+type login struct {
+	verifier string
+	nonce    string
+	expires  time.Time
+}
+
+// Identity is a user logged in by the provider.
+type Identity struct {
+	Username string
+	Groups   []string
+	Role     model.Role
+}
+
+// New returns the service of the provider, or nil without it.
+func New(cfg *model.OIDCConfig) *OIDCService {
+	if cfg == nil {
+		return nil
+	}
+	return &OIDCService{config: *cfg, logins: map[string]login{}}
+}
+
+// PasswordLoginDisabled returns whether the users must log in with the provider.
+func (s *OIDCService) PasswordLoginDisabled() bool {
+	return s != nil && s.config.DisablePasswordLogin
+}
+
+func (s *OIDCService) getProvider(ctx context.Context) (*gooidc.Provider, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.provider == nil {
+		provider, err := gooidc.NewProvider(ctx, s.config.Issuer)
+		if err != nil {
+			return nil, fmt.Errorf("NewProvider err: %w", err)
+		}
+		s.provider = provider
+	}
+	return s.provider, nil
+}
+
+func (s *OIDCService) oauth2Config(provider *gooidc.Provider) *oauth2.Config {
+	return &oauth2.Config{
+		ClientID:     s.config.ClientID,
+		ClientSecret: s.config.ClientSecret,
+		Endpoint:     provider.Endpoint(),
+		RedirectURL:  s.config.RedirectURL,
+		Scopes:       s.config.Scopes,
+	}
+}
+
+// AuthCodeURL starts a login, and returns its state and the URL of the provider to send the user to.
+func (s *OIDCService) AuthCodeURL(ctx context.Context) (state string, url string, err error) {
+	provider, err := s.getProvider(ctx)
+	if err != nil {
+		return "", "", fmt.Errorf("getProvider err: %w", err)
+	}
+	state = rand.Text()
+	l := login{verifier: oauth2.GenerateVerifier(), nonce: rand.Text(), expires: time.Now().Add(loginTimeout)}
+
+	s.mu.Lock()
+	for k, v := range s.logins {
+		if v.expires.Before(time.Now()) {
+			delete(s.logins, k)
+		}
+	}
+	s.logins[state] = l
+	s.mu.Unlock()
+
+	url = s.oauth2Config(provider).AuthCodeURL(state, gooidc.Nonce(l.nonce), oauth2.S256ChallengeOption(l.verifier))
+	return state, url, nil
+}
+
+// Exchange finishes the login of the state with the code from the provider,
+// and returns the identity in the ID token with the role of its groups.
+func (s *OIDCService) Exchange(ctx context.Context, state string, code string) (Identity, error) {
+	s.mu.Lock()
+	l, ok := s.logins[state]
+	delete(s.logins, state)
+	s.mu.Unlock()
+	if !ok || l.expires.Before(time.Now()) {
+		return Identity{}, fmt.Errorf("unknown or expired state")
+	}
+
+	provider, err := s.getProvider(ctx)
+	if err != nil {
+		return Identity{}, fmt.Errorf("getProvider err: %w", err)
+	}
+	token, err := s.oauth2Config(provider).Exchange(ctx, code, oauth2.VerifierOption(l.verifier))
+	if err != nil {
+		return Identity{}, fmt.Errorf("exchange err: %w", err)
+	}
+	rawIDToken, ok := token.Extra("id_token").(string)
+	if !ok {
+		return Identity{}, fmt.Errorf("no id_token")
+	}
+	idToken, err := provider.Verifier(&gooidc.Config{ClientID: s.config.ClientID}).Verify(ctx, rawIDToken)
+	if err != nil {
+		return Identity{}, fmt.Errorf("verify err: %w", err)
+	}
+	if idToken.Nonce != l.nonce {
+		return Identity{}, fmt.Errorf("invalid nonce")
+	}
+	var claims map[string]any
+	if err := idToken.Claims(&claims); err != nil {
+		return Identity{}, fmt.Errorf("claims err: %w", err)
+	}
+	return s.identity(claims)
+}
+
+func (s *OIDCService) identity(claims map[string]any) (Identity, error) {
+	var identity Identity
+	if s.config.UsernameClaim != "" {
+		identity.Username, _ = claims[s.config.UsernameClaim].(string)
+	} else {
+		identity.Username, _ = claims["preferred_username"].(string)
+		if identity.Username == "" {
+			identity.Username, _ = claims["email"].(string)
+		}
+	}
+	if identity.Username == "" {
+		return Identity{}, fmt.Errorf("no username in the claims")
+	}
+
+	switch groups := claims[s.config.GroupsClaim].(type) {
+	case string:
+		identity.Groups = []string{groups}
+	case []any:
+		for _, group := range groups {
+			if g, ok := group.(string); ok {
+				identity.Groups = append(identity.Groups, g)
+			}
+		}
+	}
+	sort.Strings(identity.Groups)
+
+	identity.Role = model.RoleOfGroups(s.config.GroupRoles, identity.Groups, s.config.DefaultRole)
+	if identity.Role == model.RoleNone {
+		return Identity{}, fmt.Errorf("no role for user %q with groups %v", identity.Username, identity.Groups)
+	}
+	return identity, nil
+}
@@ -0,0 +1,116 @@
+package oidc
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	mockerOIDC "github.com/kuoss/venti/pkg/mocker/oidc"
+	"github.com/kuoss/venti/pkg/model"
+)
+
+func TestNew(t *testing.T) {
+	require.Nil(t, New(nil))
+	require.False(t, New(nil).PasswordLoginDisabled())
+	require.True(t, New(&model.OIDCConfig{DisablePasswordLogin: true}).PasswordLoginDisabled())
+}
+
+func TestIdentity(t *testing.T) {
+	s := New(&model.OIDCConfig{
+		GroupsClaim: "groups",
+		GroupRoles:  map[string]model.Role{"dev": model.RoleEditor, "ops": model.RoleAdmin, "guest": model.RoleViewer},
+	})
+	testCases := []struct {
+		name      string
+		claims    map[string]any
+		want      Identity
+		wantError string
+	}{
+		{"preferred_username", map[string]any{"preferred_username": "alice", "email": "alice@example.com", "groups": []any{"dev"}}, Identity{"alice", []string{"dev"}, model.RoleEditor}, ""},
+		{"email", map[string]any{"email": "alice@example.com", "groups": "dev"}, Identity{"alice@example.com", []string{"dev"}, model.RoleEditor}, ""},
+		{"highest role", map[string]any{"preferred_username": "bob", "groups": []any{"ops", "dev", "guest"}}, Identity{"bob", []string{"dev", "guest", "ops"}, model.RoleAdmin}, ""},
+		{"no username", map[string]any{"groups": []any{"dev"}}, Identity{}, "no username in the claims"},
+		{"no role", map[string]any{"preferred_username": "carol", "groups": []any{"other"}}, Identity{}, `no role for user "carol" with groups [other]`},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := s.identity(tc.claims)
+			if tc.wantError != "" {
+				require.EqualError(t, err, tc.wantError)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, tc.want, got)
+		})
+	}
+
+	s.config.UsernameClaim = "email"
+	s.config.DefaultRole = model.RoleViewer
+	got, err := s.identity(map[string]any{"preferred_username": "carol", "email": "carol@example.com"})
+	require.NoError(t, err)
+	require.Equal(t, Identity{Username: "carol@example.com", Role: model.RoleViewer}, got)
+}
+
+// authorize follows the authorization code flow through the fake issuer, and returns the state and the code of the callback.
+func authorize(t *testing.T, authURL string) (string, string) {
+	client := &http.Client{CheckRedirect: func(*http.Request, []*http.Request) error { return http.ErrUseLastResponse }}
+	resp, err := client.Get(authURL)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	require.Equal(t, http.StatusFound, resp.StatusCode)
+	location, err := url.Parse(resp.Header.Get("Location"))
+	require.NoError(t, err)
+	require.Equal(t, "/auth/oidc/callback", location.Path)
+	return location.Query().Get("state"), location.Query().Get("code")
+}
+
+func TestExchange(t *testing.T) {
+	issuer, err := mockerOIDC.New("venti")
+	require.NoError(t, err)
+	defer issuer.Close()
+
+	s := New(&model.OIDCConfig{
+		Issuer:      issuer.URL,
+		ClientID:    "venti",
+		RedirectURL: "http://venti.example.com/auth/oidc/callback",
+		Scopes:      []string{"openid"},
+		GroupsClaim: "groups",
+		GroupRoles:  map[string]model.Role{"ops": model.RoleAdmin},
+	})
+	ctx := context.Background()
+
+	issuer.SetClaims(map[string]any{"preferred_username": "alice", "groups": []string{"ops"}})
+	state, authURL, err := s.AuthCodeURL(ctx)
+	require.NoError(t, err)
+	require.Contains(t, authURL, "code_challenge_method=S256")
+	gotState, code := authorize(t, authURL)
+	require.Equal(t, state, gotState)
+
+	identity, err := s.Exchange(ctx, state, code)
+	require.NoError(t, err)
+	require.Equal(t, Identity{Username: "alice", Groups: []string{"ops"}, Role: model.RoleAdmin}, identity)
+
+	// the state is used once
+	_, err = s.Exchange(ctx, state, code)
+	require.EqualError(t, err, "unknown or expired state")
+
+	// the code is bound to the verifier of its state
+	state1, authURL1, err := s.AuthCodeURL(ctx)
+	require.NoError(t, err)
+	state2, _, err := s.AuthCodeURL(ctx)
+	require.NoError(t, err)
+	_, code1 := authorize(t, authURL1)
+	_, err = s.Exchange(ctx, state2, code1)
+	require.ErrorContains(t, err, "exchange err: ")
+	_, err = s.Exchange(ctx, state1, code1)
+	require.ErrorContains(t, err, "exchange err: ") // the code is gone with the failed exchange
+}
+
+func TestExchange_wrongIssuer(t *testing.T) {
+	s := New(&model.OIDCConfig{Issuer: "http://127.0.0.1:1", ClientID: "venti"})
+	_, _, err := s.AuthCodeURL(context.Background())
+	require.ErrorContains(t, err, "getProvider err: NewProvider err: ")
+}
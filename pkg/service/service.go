@@ -8,10 +8,12 @@ import (
 	"github.com/kuoss/venti/pkg/config"
 	"github.com/kuoss/venti/pkg/service/alerting"
 	"github.com/kuoss/venti/pkg/service/alertrule"
+	"github.com/kuoss/venti/pkg/service/auth"
 	"github.com/kuoss/venti/pkg/service/dashboard"
 	"github.com/kuoss/venti/pkg/service/datasource"
 	"github.com/kuoss/venti/pkg/service/discovery"
 	"github.com/kuoss/venti/pkg/service/discovery/kubernetes"
+	"github.com/kuoss/venti/pkg/service/oidc"
 	"github.com/kuoss/venti/pkg/service/remote"
 	"github.com/kuoss/venti/pkg/service/status"
 	"github.com/kuoss/venti/pkg/service/user"
@@ -25,18 +27,86 @@ type Services struct {
 	*status.StatusService
 	*user.UserService
 	*alerting.AlertingService
+	*oidc.OIDCService
+	*auth.AuthService
+
+	// not embedded, so that its Check, Fail and Reload are not promoted to the services
+	LoginGuard *auth.Guard
 }
 
 func NewServices(cfg *config.Config) (*Services, error) {
+	services, err := newConfigServices(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	// status
+	services.StatusService, err = status.New(cfg)
+	if err != nil {
+		return nil, fmt.Errorf("new statusService err: %w", err)
+	}
+
+	// user
+	services.UserService, err = user.New(cfg.Flags.WithDefaults().DBFile, cfg.UserConfig, cfg.GlobalConfig.Session)
+	if err != nil {
+		return nil, fmt.Errorf("NewUserService err: %w", err)
+	}
+
+	// auth
+	services.LoginGuard, err = auth.NewGuard(cfg.GlobalConfig.Login)
+	if err != nil {
+		return nil, fmt.Errorf("new guard err: %w", err)
+	}
+	services.AuthService = auth.New(services.UserService, cfg.GlobalConfig.LDAP, services.LoginGuard)
+
+	// alerting
+	stateStore, err := alerting.NewStateStore(services.UserService.DB())
+	if err != nil {
+		return nil, fmt.Errorf("new stateStore err: %w", err)
+	}
+	err = services.AlertingService.RestoreState(stateStore)
+	if err != nil {
+		return nil, fmt.Errorf("restoreState err: %w", err)
+	}
+	return services, nil
+}
+
+// Reload returns the services of a reloaded configuration, with the rule files, dashboards and datasources loaded again.
+// The status, the users and the failed logins are kept with the users, the grants, the directory and the login limits applied, and the alerts of unchanged rules are inherited from the current services.
+// The current services are left as they are when the reload fails, so that they can go on: the shared services are changed only once nothing else can fail.
+// The alerter of the current services must be stopped beforehand, so that it does not change the inherited alerts.
+func (s *Services) Reload(cfg *config.Config) (*Services, error) {
+	services, err := newConfigServices(cfg)
+	if err != nil {
+		return nil, err
+	}
+	// the guard fails only before it changes anything, on opening the audit log
+	if err := s.LoginGuard.Reload(cfg.GlobalConfig.Login); err != nil {
+		services.AlertingService.Close()
+		return nil, fmt.Errorf("reload guard err: %w", err)
+	}
+	services.StatusService = s.StatusService
+	services.UserService = s.UserService
+	services.UserService.Reload(cfg.UserConfig, cfg.GlobalConfig.Session)
+	services.LoginGuard = s.LoginGuard
+	services.AuthService = auth.New(services.UserService, cfg.GlobalConfig.LDAP, services.LoginGuard)
+	services.AlertingService.InheritState(s.AlertingService)
+	return services, nil
+}
+
+// newConfigServices returns the services of the configuration files, with the alerting service of their rules.
+func newConfigServices(cfg *config.Config) (*Services, error) {
+	flags := cfg.Flags.WithDefaults()
+
 	// alertrule
-	alertRuleService, err := alertrule.New("")
+	alertRuleService, err := alertrule.New(flags.AlertRuleFiles)
 	if err != nil {
 		return nil, fmt.Errorf("new alertRuleService err: %w", err)
 	}
 
 	// dashboard
 	logger.Debugf("new dashboard Service...")
-	dashboardService, err := dashboard.New("etc/dashboards")
+	dashboardService, err := dashboard.New(flags.DashboardsDir)
 	if err != nil {
 		return nil, fmt.Errorf("new dashboardService err: %w", err)
 	}
@@ -57,28 +127,18 @@ func NewServices(cfg *config.Config) (*Services, error) {
 	// remote
 	remoteService := remote.New(&http.Client{}, cfg.DatasourceConfig.QueryTimeout)
 
-	// status
-	statusService, err := status.New(cfg)
-	if err != nil {
-		return nil, fmt.Errorf("new statusService err: %w", err)
-	}
-
-	// user
-	userService, err := user.New("./data/venti.sqlite3", cfg.UserConfig)
-	if err != nil {
-		return nil, fmt.Errorf("NewUserService err: %w", err)
-	}
-
 	// alerting
 	alertingService := alerting.New(cfg, alertRuleService.GetAlertRuleFiles(), datasourceService, remoteService)
 
+	// oidc: the logins in progress are lost on reload, and start over
+	oidcService := oidc.New(cfg.GlobalConfig.OIDC)
+
 	return &Services{
-		alertRuleService,
-		dashboardService,
-		datasourceService,
-		remoteService,
-		statusService,
-		userService,
-		alertingService,
+		AlertRuleService:  alertRuleService,
+		DashboardService:  dashboardService,
+		DatasourceService: datasourceService,
+		RemoteService:     remoteService,
+		AlertingService:   alertingService,
+		OIDCService:       oidcService,
 	}, nil
 }
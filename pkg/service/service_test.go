@@ -60,3 +60,38 @@ func TestNewServicesError(t *testing.T) {
 	assert.NotEmpty(t, got.StatusService)
 	assert.NotEmpty(t, got.UserService)
 }
+
+func TestReload(t *testing.T) {
+	services, err := NewServices(&config.Config{})
+	assert.NoError(t, err)
+
+	cfg := &config.Config{DatasourceConfig: model.DatasourceConfig{
+		Datasources: []model.Datasource{{Name: "prometheus", Type: model.DatasourceTypePrometheus, URL: "http://prometheus:9090"}},
+	}}
+	got, err := services.Reload(cfg)
+	assert.NoError(t, err)
+	assert.NotSame(t, services.AlertRuleService, got.AlertRuleService)
+	assert.NotSame(t, services.AlertingService, got.AlertingService)
+	assert.NotSame(t, services.DashboardService, got.DashboardService)
+	assert.NotSame(t, services.DatasourceService, got.DatasourceService)
+	assert.NotSame(t, services.RemoteService, got.RemoteService)
+	assert.Same(t, services.StatusService, got.StatusService)
+	assert.Same(t, services.UserService, got.UserService)
+	assert.Len(t, got.DatasourceService.GetDatasources(), 1)
+}
+
+func TestReload_error(t *testing.T) {
+	services, err := NewServices(&config.Config{})
+	assert.NoError(t, err)
+
+	cfg := &config.Config{
+		GlobalConfig: model.GlobalConfig{Login: model.LoginConfig{AuditLogFile: t.TempDir() + "/no/audit.log"}},
+		UserConfig:   model.UserConfig{EtcUsers: []model.EtcUser{{Username: "reloaded", Hash: "x"}}},
+	}
+	got, err := services.Reload(cfg)
+	assert.ErrorContains(t, err, "reload guard err: open audit log err: ")
+	assert.Nil(t, got)
+	// the users of the failed reload are not applied
+	_, err = services.UserService.FindByUsername("reloaded")
+	assert.Error(t, err)
+}
@@ -5,6 +5,7 @@ import (
 	"os"
 	"runtime"
 	"runtime/debug"
+	"sync"
 	"time"
 
 	"github.com/kuoss/venti/pkg/config"
@@ -14,6 +15,7 @@ import (
 type StatusService struct {
 	buildInfo   webapi.BuildInfo
 	runtimeInfo webapi.RuntimeInfo
+	mu          sync.Mutex // the runtime info is updated by reloads
 }
 
 func New(cfg *config.Config) (*StatusService, error) {
@@ -51,7 +53,20 @@ func (s *StatusService) BuildInfo() webapi.BuildInfo {
 }
 
 func (s *StatusService) RuntimeInfo() webapi.RuntimeInfo {
+	s.mu.Lock()
+	defer s.mu.Unlock()
 	s.runtimeInfo.GOMEMLIMIT = debug.SetMemoryLimit(-1)
 	s.runtimeInfo.GoroutineCount = runtime.NumGoroutine()
 	return s.runtimeInfo
 }
+
+// ConfigReloaded records the outcome of a reload of the configurations.
+// LastConfigTime is the time of the last successful one, like in Prometheus.
+func (s *StatusService) ConfigReloaded(success bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.runtimeInfo.ReloadConfigSuccess = success
+	if success {
+		s.runtimeInfo.LastConfigTime = time.Now()
+	}
+}
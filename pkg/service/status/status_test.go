@@ -89,3 +89,19 @@ func TestRuntimeInfo(t *testing.T) {
 	runtimeInfo101.GOMEMLIMIT = got.GOMEMLIMIT
 	require.Equal(t, runtimeInfo101, got)
 }
+
+func TestConfigReloaded(t *testing.T) {
+	service, err := New(&config.Config{})
+	require.NoError(t, err)
+	lastConfigTime := service.RuntimeInfo().LastConfigTime
+
+	service.ConfigReloaded(false)
+	got := service.RuntimeInfo()
+	require.False(t, got.ReloadConfigSuccess)
+	require.Equal(t, lastConfigTime, got.LastConfigTime)
+
+	service.ConfigReloaded(true)
+	got = service.RuntimeInfo()
+	require.True(t, got.ReloadConfigSuccess)
+	require.True(t, got.LastConfigTime.After(lastConfigTime))
+}
@@ -0,0 +1,68 @@
+package user
+
+import "github.com/kuoss/venti/pkg/model"
+
+// Access is what an authenticated user can do, and which datasources the user can use.
+type Access struct {
+	User   model.User
+	grants []model.Grant // nil for all datasources, empty for none
+}
+
+// Access returns the access of the user with the grants of the configuration.
+func (s *UserService) Access(user model.User) Access {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return NewAccess(user, s.grants, s.defaultAccess)
+}
+
+// NewAccess returns the access of the user, with the grants that apply to the user, or the default access if none applies.
+// Admins use all datasources.
+func NewAccess(user model.User, grants []model.Grant, defaultAccess model.DefaultAccess) Access {
+	access := Access{User: user}
+	if user.HasRole(model.RoleAdmin) {
+		return access
+	}
+	for _, grant := range grants {
+		if grant.AppliesTo(user) {
+			access.grants = append(access.grants, grant)
+		}
+	}
+	if access.grants == nil && defaultAccess == model.DefaultAccessNone {
+		access.grants = []model.Grant{}
+	}
+	return access
+}
+
+// HasRole returns whether the user has the role or a higher one.
+func (a Access) HasRole(role model.Role) bool {
+	return a.User.HasRole(role)
+}
+
+// AllDatasources returns whether the user can use all datasources.
+func (a Access) AllDatasources() bool {
+	return a.grants == nil
+}
+
+// AllowsDatasource returns whether the user can use the datasource.
+func (a Access) AllowsDatasource(datasource model.Datasource) bool {
+	if a.AllDatasources() {
+		return true
+	}
+	for _, grant := range a.grants {
+		if grant.Allows(datasource) {
+			return true
+		}
+	}
+	return false
+}
+
+// FilterDatasources returns the datasources that the user can use.
+func (a Access) FilterDatasources(datasources []model.Datasource) []model.Datasource {
+	outputs := []model.Datasource{}
+	for _, datasource := range datasources {
+		if a.AllowsDatasource(datasource) {
+			outputs = append(outputs, datasource)
+		}
+	}
+	return outputs
+}
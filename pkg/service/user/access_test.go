@@ -0,0 +1,94 @@
+package user
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/kuoss/venti/pkg/model"
+)
+
+func TestAccess(t *testing.T) {
+	grants := []model.Grant{
+		{Groups: []string{"team-a"}, Namespaces: []string{"team-a"}},
+		{Users: []string{"bob"}, Datasources: []string{"prometheus"}},
+	}
+	datasources := []model.Datasource{
+		{Name: "prometheus"},
+		{Name: "lethe"},
+		{Name: "lethe.team-a", Namespace: "team-a", IsDiscovered: true},
+		{Name: "lethe.team-b", Namespace: "team-b", IsDiscovered: true},
+	}
+	testCases := []struct {
+		name          string
+		user          model.User
+		defaultAccess model.DefaultAccess
+		want          []string
+		wantAll       bool
+	}{
+		{"no grant", model.User{Username: "alice"}, "", []string{"prometheus", "lethe", "lethe.team-a", "lethe.team-b"}, true},
+		{"no grant, all", model.User{Username: "alice"}, model.DefaultAccessAll, []string{"prometheus", "lethe", "lethe.team-a", "lethe.team-b"}, true},
+		{"no grant, none", model.User{Username: "alice"}, model.DefaultAccessNone, []string{}, false},
+		{"group", model.User{Username: "carol", Groups: []string{"team-a"}}, model.DefaultAccessNone, []string{"lethe.team-a"}, false},
+		{"user and group", model.User{Username: "bob", Groups: []string{"team-a"}}, "", []string{"prometheus", "lethe.team-a"}, false},
+		{"admin", model.User{Username: "bob", Role: model.RoleAdmin}, model.DefaultAccessNone, []string{"prometheus", "lethe", "lethe.team-a", "lethe.team-b"}, true},
+		{"isAdmin", model.User{Username: "bob", IsAdmin: true}, "", []string{"prometheus", "lethe", "lethe.team-a", "lethe.team-b"}, true},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			access := NewAccess(tc.user, grants, tc.defaultAccess)
+			require.Equal(t, tc.wantAll, access.AllDatasources())
+			got := []string{}
+			for _, ds := range access.FilterDatasources(datasources) {
+				got = append(got, ds.Name)
+			}
+			require.Equal(t, tc.want, got)
+		})
+	}
+}
+
+func TestHasRole(t *testing.T) {
+	testCases := []struct {
+		user model.User
+		role model.Role
+		want bool
+	}{
+		{model.User{}, model.RoleViewer, true},
+		{model.User{}, model.RoleEditor, false},
+		{model.User{Role: model.RoleEditor}, model.RoleViewer, true},
+		{model.User{Role: model.RoleEditor}, model.RoleEditor, true},
+		{model.User{Role: model.RoleEditor}, model.RoleAdmin, false},
+		{model.User{Role: model.RoleAdmin}, model.RoleAdmin, true},
+		{model.User{IsAdmin: true}, model.RoleAdmin, true},
+	}
+	for _, tc := range testCases {
+		t.Run(string(tc.user.Role)+">="+string(tc.role), func(t *testing.T) {
+			require.Equal(t, tc.want, NewAccess(tc.user, nil, "").HasRole(tc.role))
+		})
+	}
+}
+
+func TestReload(t *testing.T) {
+	s, err := New(t.TempDir()+"/venti.sqlite3", model.UserConfig{
+		EtcUsers: []model.EtcUser{{Username: "alice", Hash: "hash1", Groups: []string{"team-a"}}},
+		Grants:   []model.Grant{{Groups: []string{"team-a"}, Datasources: []string{"lethe"}}},
+	}, model.SessionConfig{})
+	require.NoError(t, err)
+	alice, err := s.FindByUsername("alice")
+	require.NoError(t, err)
+	require.Equal(t, model.RoleViewer, alice.GetRole())
+	require.Equal(t, []string{"team-a"}, alice.Groups)
+	require.False(t, s.Access(alice).AllowsDatasource(model.Datasource{Name: "prometheus"}))
+
+	s.Reload(model.UserConfig{
+		EtcUsers: []model.EtcUser{{Username: "alice", Hash: "hash1", Role: model.RoleEditor}},
+		Grants:   []model.Grant{{Groups: []string{"team-a"}, Datasources: []string{"prometheus"}}},
+	}, model.SessionConfig{TTL: time.Hour})
+	alice, err = s.FindByUsername("alice")
+	require.NoError(t, err)
+	require.Equal(t, model.RoleEditor, alice.GetRole())
+	require.Empty(t, alice.Groups)
+	require.True(t, s.Access(alice).AllDatasources())
+	require.Equal(t, time.Hour, s.sessionConfig.TTL)
+}
@@ -0,0 +1,106 @@
+package user
+
+import (
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/kuoss/common/logger"
+	"gorm.io/gorm"
+
+	"github.com/kuoss/venti/pkg/model"
+)
+
+// APIKeyPrefix starts every API key, so that tokenRequired tells them from the login tokens.
+const APIKeyPrefix = "venti_"
+
+var (
+	ErrAPIKeyNotFound = errors.New("api key not found")
+	ErrAPIKeyExpired  = errors.New("api key expired")
+)
+
+// CreateAPIKey creates a key with the role, and returns it with the key itself, which is not kept.
+func (s *UserService) CreateAPIKey(name string, role model.Role, expiresAt *time.Time, createdBy string) (model.APIKey, string, error) {
+	if name == "" {
+		return model.APIKey{}, "", fmt.Errorf("name is empty")
+	}
+	if role == model.RoleNone || !role.Valid() {
+		return model.APIKey{}, "", fmt.Errorf("unknown role %q", role)
+	}
+	if expiresAt != nil && !expiresAt.After(time.Now()) {
+		return model.APIKey{}, "", fmt.Errorf("expiresAt is in the past")
+	}
+	var count int64
+	if err := s.db.Model(&model.APIKey{}).Where("name = ?", name).Count(&count).Error; err != nil {
+		return model.APIKey{}, "", fmt.Errorf("count err: %w", err)
+	}
+	if count > 0 {
+		return model.APIKey{}, "", fmt.Errorf("api key %q exists", name)
+	}
+
+	secret := APIKeyPrefix + rand.Text()
+	key := model.APIKey{
+		Name:      name,
+		Hash:      hashToken(secret),
+		Prefix:    secret[:len(APIKeyPrefix)+4],
+		Role:      role,
+		ExpiresAt: expiresAt,
+		CreatedBy: createdBy,
+	}
+	if err := s.db.Create(&key).Error; err != nil {
+		return model.APIKey{}, "", fmt.Errorf("create err: %w", err)
+	}
+	logger.Infof("API key '%s' created by '%s'.", name, createdBy)
+	return key, secret, nil
+}
+
+// ListAPIKeys returns the keys, without the keys themselves.
+func (s *UserService) ListAPIKeys() ([]model.APIKey, error) {
+	keys := []model.APIKey{}
+	if err := s.db.Order("id").Find(&keys).Error; err != nil {
+		return nil, fmt.Errorf("find err: %w", err)
+	}
+	return keys, nil
+}
+
+// RevokeAPIKey deletes the key, so that it can be used no more.
+func (s *UserService) RevokeAPIKey(id int) error {
+	tx := s.db.Delete(&model.APIKey{}, id)
+	if tx.Error != nil {
+		return fmt.Errorf("delete err: %w", tx.Error)
+	}
+	if tx.RowsAffected == 0 {
+		return ErrAPIKeyNotFound
+	}
+	logger.Infof("API key %d revoked.", id)
+	return nil
+}
+
+// FindAPIKey returns the unexpired key, and marks it as used, to the minute.
+func (s *UserService) FindAPIKey(secret string) (model.APIKey, error) {
+	if !strings.HasPrefix(secret, APIKeyPrefix) {
+		return model.APIKey{}, ErrAPIKeyNotFound
+	}
+	var key model.APIKey
+	err := s.db.First(&key, "hash = ?", hashToken(secret)).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return model.APIKey{}, ErrAPIKeyNotFound
+		}
+		return model.APIKey{}, fmt.Errorf("first err: %w", err)
+	}
+	now := time.Now()
+	if key.ExpiresAt != nil && !key.ExpiresAt.After(now) {
+		return model.APIKey{}, ErrAPIKeyExpired
+	}
+	// a minute is fine enough, without a write on every request
+	if key.LastUsedAt == nil || now.Sub(*key.LastUsedAt) > time.Minute {
+		key.LastUsedAt = &now
+		if err := s.db.Model(&key).Update("last_used_at", now).Error; err != nil {
+			logger.Warnf("update lastUsedAt of API key '%s' err: %s", key.Name, err)
+		}
+	}
+	return key, nil
+}
@@ -0,0 +1,93 @@
+package user
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/kuoss/venti/pkg/model"
+)
+
+func TestAPIKey(t *testing.T) {
+	s, err := New(t.TempDir()+"/venti.sqlite3", model.UserConfig{}, model.SessionConfig{})
+	require.NoError(t, err)
+
+	key, secret, err := s.CreateAPIKey("ci", model.RoleEditor, nil, "admin")
+	require.NoError(t, err)
+	require.True(t, strings.HasPrefix(secret, APIKeyPrefix))
+	require.True(t, strings.HasPrefix(secret, key.Prefix))
+	require.NotContains(t, key.Hash, secret)
+	require.Equal(t, model.User{Username: "apikey:ci", Role: model.RoleEditor, Provider: "apikey"}, key.User())
+
+	found, err := s.FindAPIKey(secret)
+	require.NoError(t, err)
+	require.Equal(t, key.ID, found.ID)
+	require.NotNil(t, found.LastUsedAt)
+	lastUsedAt := *found.LastUsedAt
+
+	// within a minute, the use is not written again
+	found, err = s.FindAPIKey(secret)
+	require.NoError(t, err)
+	require.True(t, lastUsedAt.Equal(*found.LastUsedAt))
+	require.NoError(t, s.db.Model(&key).Update("last_used_at", lastUsedAt.Add(-2*time.Minute)).Error)
+	found, err = s.FindAPIKey(secret)
+	require.NoError(t, err)
+	require.True(t, found.LastUsedAt.After(lastUsedAt))
+
+	_, err = s.FindAPIKey(secret + "x")
+	require.ErrorIs(t, err, ErrAPIKeyNotFound)
+	_, err = s.FindAPIKey("token")
+	require.ErrorIs(t, err, ErrAPIKeyNotFound)
+
+	// expiry
+	expiresAt := time.Now().Add(time.Hour)
+	key2, secret2, err := s.CreateAPIKey("bot", model.RoleViewer, &expiresAt, "admin")
+	require.NoError(t, err)
+	_, err = s.FindAPIKey(secret2)
+	require.NoError(t, err)
+	require.NoError(t, s.db.Model(&key2).Update("expires_at", time.Now().Add(-time.Minute)).Error)
+	_, err = s.FindAPIKey(secret2)
+	require.ErrorIs(t, err, ErrAPIKeyExpired)
+
+	keys, err := s.ListAPIKeys()
+	require.NoError(t, err)
+	require.Len(t, keys, 2)
+	require.Equal(t, "ci", keys[0].Name)
+	require.Equal(t, "bot", keys[1].Name)
+
+	// revoke
+	require.NoError(t, s.RevokeAPIKey(key.ID))
+	_, err = s.FindAPIKey(secret)
+	require.ErrorIs(t, err, ErrAPIKeyNotFound)
+	require.ErrorIs(t, s.RevokeAPIKey(key.ID), ErrAPIKeyNotFound)
+}
+
+func TestCreateAPIKey_invalid(t *testing.T) {
+	s, err := New(t.TempDir()+"/venti.sqlite3", model.UserConfig{}, model.SessionConfig{})
+	require.NoError(t, err)
+	_, _, err = s.CreateAPIKey("ci", model.RoleViewer, nil, "admin")
+	require.NoError(t, err)
+
+	past := time.Now().Add(-time.Hour)
+	testCases := []struct {
+		name      string
+		keyName   string
+		role      model.Role
+		expiresAt *time.Time
+		wantError string
+	}{
+		{"no name", "", model.RoleViewer, nil, "name is empty"},
+		{"no role", "bot", model.RoleNone, nil, `unknown role ""`},
+		{"unknown role", "bot", "root", nil, `unknown role "root"`},
+		{"expired", "bot", model.RoleViewer, &past, "expiresAt is in the past"},
+		{"exists", "ci", model.RoleViewer, nil, `api key "ci" exists`},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			_, _, err := s.CreateAPIKey(tc.keyName, tc.role, tc.expiresAt, "admin")
+			require.EqualError(t, err, tc.wantError)
+		})
+	}
+}
@@ -0,0 +1,211 @@
+package user
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/kuoss/common/logger"
+	"golang.org/x/crypto/bcrypt"
+	"gorm.io/gorm"
+
+	"github.com/kuoss/venti/pkg/model"
+)
+
+const minPasswordLength = 8
+
+var (
+	ErrUserNotFound  = errors.New("user not found")
+	ErrWrongPassword = errors.New("current password is incorrect")
+)
+
+// UserUpdate is a change of a user. The nil fields are left as they are.
+type UserUpdate struct {
+	Password  *string     `json:"password,omitempty"`
+	Role      *model.Role `json:"role,omitempty"`
+	Groups    *[]string   `json:"groups,omitempty"`
+	DBManaged *bool       `json:"dbManaged,omitempty"`
+	Disabled  *bool       `json:"disabled,omitempty"`
+}
+
+func (s *UserService) ListUsers() ([]model.User, error) {
+	users := []model.User{}
+	if err := s.db.Order("id").Find(&users).Error; err != nil {
+		return nil, fmt.Errorf("find err: %w", err)
+	}
+	return users, nil
+}
+
+func (s *UserService) FindByID(id int) (model.User, error) {
+	var user model.User
+	err := s.db.First(&user, id).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return model.User{}, ErrUserNotFound
+	}
+	return user, err
+}
+
+// CreateUser creates a user with a password, managed in the database.
+func (s *UserService) CreateUser(username, password string, role model.Role, groups []string) (model.User, error) {
+	if username == "" {
+		return model.User{}, fmt.Errorf("username is empty")
+	}
+	if !role.Valid() {
+		return model.User{}, fmt.Errorf("unknown role %q", role)
+	}
+	hash, err := hashPassword(password)
+	if err != nil {
+		return model.User{}, err
+	}
+	if _, err := s.FindByUsername(username); err == nil {
+		return model.User{}, fmt.Errorf("user %q exists", username)
+	}
+	user := model.User{Username: username, Hash: hash, Role: role, Groups: groups, DBManaged: true}
+	if err := s.db.Create(&user).Error; err != nil {
+		return model.User{}, fmt.Errorf("create err: %w", err)
+	}
+	logger.Infof("User '%s' created.", username)
+	return user, nil
+}
+
+// UpdateUser changes the user. Only the users managed in the database can change but for disabling,
+// as users.yml or the provider would change them back. Disabling logs out the user.
+// The last admin cannot be demoted or disabled.
+func (s *UserService) UpdateUser(id int, update UserUpdate) (model.User, error) {
+	s.adminMu.Lock()
+	defer s.adminMu.Unlock()
+	user, err := s.FindByID(id)
+	if err != nil {
+		return model.User{}, err
+	}
+	if update.DBManaged != nil {
+		if user.Provider != "" {
+			return model.User{}, fmt.Errorf("user %q is managed by %s", user.Username, user.Provider)
+		}
+		user.DBManaged = *update.DBManaged
+	}
+	if update.Password != nil || update.Role != nil || update.Groups != nil {
+		if err := checkManaged(user); err != nil {
+			return model.User{}, err
+		}
+	}
+	if update.Password != nil {
+		user.Hash, err = hashPassword(*update.Password)
+		if err != nil {
+			return model.User{}, err
+		}
+	}
+	if update.Role != nil {
+		if !update.Role.Valid() {
+			return model.User{}, fmt.Errorf("unknown role %q", *update.Role)
+		}
+		user.Role = *update.Role
+		user.IsAdmin = false
+	}
+	if update.Groups != nil {
+		user.Groups = *update.Groups
+	}
+	if update.Disabled != nil {
+		user.Disabled = *update.Disabled
+	}
+	if !isActiveAdmin(user) {
+		if err := s.checkLastAdmin(id); err != nil {
+			return model.User{}, err
+		}
+	}
+	if err := s.db.Save(&user).Error; err != nil {
+		return model.User{}, fmt.Errorf("save err: %w", err)
+	}
+	if user.Disabled {
+		if _, err := s.DeleteSessions(user.ID); err != nil {
+			return model.User{}, err
+		}
+	}
+	logger.Infof("User '%s' updated.", user.Username)
+	return user, nil
+}
+
+// DeleteUser deletes the user with its sessions. The users of users.yml are deleted there, and the last admin cannot be deleted.
+func (s *UserService) DeleteUser(id int) error {
+	s.adminMu.Lock()
+	defer s.adminMu.Unlock()
+	user, err := s.FindByID(id)
+	if err != nil {
+		return err
+	}
+	if user.Provider == "" && !user.DBManaged {
+		return fmt.Errorf("user %q is managed by users.yml", user.Username)
+	}
+	if err := s.checkLastAdmin(id); err != nil {
+		return err
+	}
+	if _, err := s.DeleteSessions(user.ID); err != nil {
+		return err
+	}
+	if err := s.db.Delete(&user).Error; err != nil {
+		return fmt.Errorf("delete err: %w", err)
+	}
+	logger.Infof("User '%s' deleted.", user.Username)
+	return nil
+}
+
+// ChangePassword changes the password of the user, and logs out the other sessions than the one of the token.
+func (s *UserService) ChangePassword(user model.User, currentPassword, newPassword, token string) error {
+	if err := checkManaged(user); err != nil {
+		return err
+	}
+	if bcrypt.CompareHashAndPassword([]byte(user.Hash), []byte(currentPassword)) != nil {
+		return ErrWrongPassword
+	}
+	hash, err := hashPassword(newPassword)
+	if err != nil {
+		return err
+	}
+	if err := s.db.Model(&user).Update("hash", hash).Error; err != nil {
+		return fmt.Errorf("update err: %w", err)
+	}
+	err = s.db.Where("user_id = ? AND hash <> ?", user.ID, hashToken(token)).Delete(&model.Session{}).Error
+	if err != nil {
+		return fmt.Errorf("delete sessions err: %w", err)
+	}
+	logger.Infof("User '%s' changed the password.", user.Username)
+	return nil
+}
+
+// checkLastAdmin returns an error when the user of the id is the only active admin, which is to lose the role.
+func (s *UserService) checkLastAdmin(id int) error {
+	var admins []model.User
+	err := s.db.Where("(role = ? OR is_admin = ?) AND disabled = ?", model.RoleAdmin, true, false).Find(&admins).Error
+	if err != nil {
+		return fmt.Errorf("find admins err: %w", err)
+	}
+	if len(admins) == 1 && admins[0].ID == id {
+		return fmt.Errorf("user %q is the last admin", admins[0].Username)
+	}
+	return nil
+}
+
+func isActiveAdmin(user model.User) bool {
+	return user.HasRole(model.RoleAdmin) && !user.Disabled
+}
+
+// checkManaged returns an error for the users whose password, role and groups are managed elsewhere.
+func checkManaged(user model.User) error {
+	if user.Provider != "" {
+		return fmt.Errorf("user %q is managed by %s", user.Username, user.Provider)
+	}
+	if !user.DBManaged {
+		return fmt.Errorf("user %q is managed by users.yml", user.Username)
+	}
+	return nil
+}
+
+func hashPassword(password string) (string, error) {
+	if len(password) < minPasswordLength {
+		return "", fmt.Errorf("password is shorter than %d characters", minPasswordLength)
+	}
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		return "", fmt.Errorf("GenerateFromPassword err: %w", err)
+	}
+	return string(hash), nil
+}
@@ -0,0 +1,186 @@
+package user
+
+import (
+	"strconv"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/bcrypt"
+
+	"github.com/kuoss/venti/pkg/model"
+)
+
+func newManageTestService(t *testing.T) *UserService {
+	hash, err := bcrypt.GenerateFromPassword([]byte("file-password"), bcrypt.MinCost)
+	require.NoError(t, err)
+	s, err := New(t.TempDir()+"/venti.sqlite3", model.UserConfig{
+		EtcUsers: []model.EtcUser{{Username: "file", Hash: string(hash)}},
+	}, model.SessionConfig{})
+	require.NoError(t, err)
+	return s
+}
+
+func ptr[T any](v T) *T {
+	return &v
+}
+
+func TestCreateUser(t *testing.T) {
+	s := newManageTestService(t)
+
+	user, err := s.CreateUser("alice", "password1", model.RoleEditor, []string{"team-a"})
+	require.NoError(t, err)
+	require.True(t, user.DBManaged)
+	require.NoError(t, bcrypt.CompareHashAndPassword([]byte(user.Hash), []byte("password1")))
+
+	testCases := []struct {
+		name      string
+		username  string
+		password  string
+		role      model.Role
+		wantError string
+	}{
+		{"no username", "", "password1", model.RoleViewer, "username is empty"},
+		{"unknown role", "bob", "password1", "root", `unknown role "root"`},
+		{"short password", "bob", "short", model.RoleViewer, "password is shorter than 8 characters"},
+		{"exists", "alice", "password1", model.RoleViewer, `user "alice" exists`},
+		{"exists in users.yml", "file", "password1", model.RoleViewer, `user "file" exists`},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := s.CreateUser(tc.username, tc.password, tc.role, nil)
+			require.EqualError(t, err, tc.wantError)
+		})
+	}
+
+	users, err := s.ListUsers()
+	require.NoError(t, err)
+	require.Len(t, users, 2)
+	require.Equal(t, "file", users[0].Username)
+	require.False(t, users[0].DBManaged)
+	require.Equal(t, "alice", users[1].Username)
+}
+
+func TestUpdateUser(t *testing.T) {
+	s := newManageTestService(t)
+	alice, err := s.CreateUser("alice", "password1", model.RoleViewer, nil)
+	require.NoError(t, err)
+	file, err := s.FindByUsername("file")
+	require.NoError(t, err)
+	oidc, err := s.ProvisionUser("carol", "oidc", nil, model.RoleViewer)
+	require.NoError(t, err)
+
+	alice, err = s.UpdateUser(alice.ID, UserUpdate{Role: ptr(model.RoleAdmin), Groups: ptr([]string{"ops"}), Password: ptr("password2")})
+	require.NoError(t, err)
+	require.Equal(t, model.RoleAdmin, alice.Role)
+	require.Equal(t, []string{"ops"}, alice.Groups)
+	require.NoError(t, bcrypt.CompareHashAndPassword([]byte(alice.Hash), []byte("password2")))
+
+	testCases := []struct {
+		name      string
+		id        int
+		update    UserUpdate
+		wantError string
+	}{
+		{"not found", 0, UserUpdate{Role: ptr(model.RoleAdmin)}, "user not found"},
+		{"unknown role", alice.ID, UserUpdate{Role: ptr(model.Role("root"))}, `unknown role "root"`},
+		{"short password", alice.ID, UserUpdate{Password: ptr("short")}, "password is shorter than 8 characters"},
+		{"users.yml", file.ID, UserUpdate{Role: ptr(model.RoleAdmin)}, `user "file" is managed by users.yml`},
+		{"provider", oidc.ID, UserUpdate{Password: ptr("password1")}, `user "carol" is managed by oidc`},
+		{"provider dbManaged", oidc.ID, UserUpdate{DBManaged: ptr(true)}, `user "carol" is managed by oidc`},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := s.UpdateUser(tc.id, tc.update)
+			require.EqualError(t, err, tc.wantError)
+		})
+	}
+
+	// disabling is for all users, and logs out
+	token, err := s.CreateSession(oidc)
+	require.NoError(t, err)
+	oidc, err = s.UpdateUser(oidc.ID, UserUpdate{Disabled: ptr(true)})
+	require.NoError(t, err)
+	require.True(t, oidc.Disabled)
+	_, err = s.FindBySession(strconv.Itoa(oidc.ID), token)
+	require.ErrorIs(t, err, ErrSessionNotFound)
+	oidc, err = s.ProvisionUser("carol", "oidc", nil, model.RoleViewer)
+	require.NoError(t, err)
+	require.True(t, oidc.Disabled)
+
+	// taking over a user of users.yml
+	file, err = s.UpdateUser(file.ID, UserUpdate{DBManaged: ptr(true), Password: ptr("password3")})
+	require.NoError(t, err)
+	require.True(t, file.DBManaged)
+	s.Reload(model.UserConfig{EtcUsers: []model.EtcUser{{Username: "file", Hash: "hash1"}}}, model.SessionConfig{})
+	file, err = s.FindByUsername("file")
+	require.NoError(t, err)
+	require.NoError(t, bcrypt.CompareHashAndPassword([]byte(file.Hash), []byte("password3")))
+}
+
+func TestDeleteUser(t *testing.T) {
+	s := newManageTestService(t)
+	alice, err := s.CreateUser("alice", "password1", model.RoleViewer, nil)
+	require.NoError(t, err)
+	token, err := s.CreateSession(alice)
+	require.NoError(t, err)
+	file, err := s.FindByUsername("file")
+	require.NoError(t, err)
+
+	require.NoError(t, s.DeleteUser(alice.ID))
+	_, err = s.FindByID(alice.ID)
+	require.ErrorIs(t, err, ErrUserNotFound)
+	_, err = s.FindBySession(strconv.Itoa(alice.ID), token)
+	require.ErrorIs(t, err, ErrSessionNotFound)
+
+	require.ErrorIs(t, s.DeleteUser(alice.ID), ErrUserNotFound)
+	require.EqualError(t, s.DeleteUser(file.ID), `user "file" is managed by users.yml`)
+}
+
+func TestLastAdmin(t *testing.T) {
+	s := newManageTestService(t)
+	root, err := s.CreateUser("root", "password1", model.RoleAdmin, nil)
+	require.NoError(t, err)
+
+	_, err = s.UpdateUser(root.ID, UserUpdate{Role: ptr(model.RoleEditor)})
+	require.EqualError(t, err, `user "root" is the last admin`)
+	_, err = s.UpdateUser(root.ID, UserUpdate{Disabled: ptr(true)})
+	require.EqualError(t, err, `user "root" is the last admin`)
+	require.EqualError(t, s.DeleteUser(root.ID), `user "root" is the last admin`)
+	_, err = s.UpdateUser(root.ID, UserUpdate{Groups: &[]string{"ops"}})
+	require.NoError(t, err)
+
+	// with another admin
+	root2, err := s.CreateUser("root2", "password1", model.RoleAdmin, nil)
+	require.NoError(t, err)
+	_, err = s.UpdateUser(root.ID, UserUpdate{Role: ptr(model.RoleEditor)})
+	require.NoError(t, err)
+	require.EqualError(t, s.DeleteUser(root2.ID), `user "root2" is the last admin`)
+	require.NoError(t, s.DeleteUser(root.ID))
+}
+
+func TestChangePassword(t *testing.T) {
+	s := newManageTestService(t)
+	alice, err := s.CreateUser("alice", "password1", model.RoleViewer, nil)
+	require.NoError(t, err)
+	aliceID := strconv.Itoa(alice.ID)
+	token1, err := s.CreateSession(alice)
+	require.NoError(t, err)
+	token2, err := s.CreateSession(alice)
+	require.NoError(t, err)
+
+	require.ErrorIs(t, s.ChangePassword(alice, "wrong", "password2", token1), ErrWrongPassword)
+	require.EqualError(t, s.ChangePassword(alice, "password1", "short", token1), "password is shorter than 8 characters")
+
+	require.NoError(t, s.ChangePassword(alice, "password1", "password2", token1))
+	alice, err = s.FindByID(alice.ID)
+	require.NoError(t, err)
+	require.NoError(t, bcrypt.CompareHashAndPassword([]byte(alice.Hash), []byte("password2")))
+	_, err = s.FindBySession(aliceID, token1)
+	require.NoError(t, err)
+	_, err = s.FindBySession(aliceID, token2)
+	require.ErrorIs(t, err, ErrSessionNotFound)
+
+	file, err := s.FindByUsername("file")
+	require.NoError(t, err)
+	require.EqualError(t, s.ChangePassword(file, "file-password", "password2", ""), `user "file" is managed by users.yml`)
+}
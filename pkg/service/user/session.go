@@ -0,0 +1,118 @@
+package user
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/kuoss/common/logger"
+	"gorm.io/gorm"
+
+	"github.com/kuoss/venti/pkg/model"
+)
+
+var (
+	ErrSessionNotFound = errors.New("session not found")
+	ErrSessionExpired  = errors.New("session expired")
+	ErrUserDisabled    = errors.New("user disabled")
+)
+
+// CreateSession logs in the user with a new session, besides the other sessions of the user,
+// and returns the token of the session. Only the hash of the token is kept.
+func (s *UserService) CreateSession(user model.User) (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("rand err: %w", err)
+	}
+	token := hex.EncodeToString(b)
+
+	s.mu.RLock()
+	ttl := s.sessionConfig.TTL
+	s.mu.RUnlock()
+	now := time.Now()
+	session := model.Session{UserID: user.ID, Hash: hashToken(token), ExpiresAt: now.Add(ttl), LastUsedAt: now}
+	if err := s.db.Create(&session).Error; err != nil {
+		return "", fmt.Errorf("create err: %w", err)
+	}
+	// the expired sessions are of no use
+	if err := s.db.Where("expires_at <= ?", now).Delete(&model.Session{}).Error; err != nil {
+		logger.Warnf("delete expired sessions err: %s", err)
+	}
+	return token, nil
+}
+
+// FindBySession returns the user of the unexpired session of the token.
+// A sliding session is extended to the TTL from now, up to the max lifetime.
+func (s *UserService) FindBySession(userID, token string) (model.User, error) {
+	var session model.Session
+	err := s.db.First(&session, "user_id = ? AND hash = ?", userID, hashToken(token)).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return model.User{}, ErrSessionNotFound
+		}
+		return model.User{}, fmt.Errorf("first session err: %w", err)
+	}
+	now := time.Now()
+	if !session.ExpiresAt.After(now) {
+		return model.User{}, ErrSessionExpired
+	}
+	var user model.User
+	if err := s.db.First(&user, session.UserID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return model.User{}, ErrSessionNotFound
+		}
+		return model.User{}, fmt.Errorf("first user err: %w", err)
+	}
+	if user.Disabled {
+		return model.User{}, ErrUserDisabled
+	}
+
+	s.mu.RLock()
+	cfg := s.sessionConfig
+	s.mu.RUnlock()
+	if cfg.Sliding {
+		expiresAt := now.Add(cfg.TTL)
+		if cfg.MaxLifetime > 0 && expiresAt.After(session.CreatedAt.Add(cfg.MaxLifetime)) {
+			expiresAt = session.CreatedAt.Add(cfg.MaxLifetime)
+		}
+		// a minute is fine enough, without a write on every request
+		if expiresAt.Sub(session.ExpiresAt) > time.Minute {
+			err := s.db.Model(&session).Updates(model.Session{ExpiresAt: expiresAt, LastUsedAt: now}).Error
+			if err != nil {
+				logger.Warnf("extend session err: %s", err)
+			}
+		}
+	}
+	return user, nil
+}
+
+// DeleteSession logs out the session of the token.
+func (s *UserService) DeleteSession(userID, token string) error {
+	tx := s.db.Where("user_id = ? AND hash = ?", userID, hashToken(token)).Delete(&model.Session{})
+	if tx.Error != nil {
+		return fmt.Errorf("delete err: %w", tx.Error)
+	}
+	if tx.RowsAffected == 0 {
+		return ErrSessionNotFound
+	}
+	return nil
+}
+
+// DeleteSessions logs out all sessions of the user, and returns how many there were.
+func (s *UserService) DeleteSessions(userID int) (int64, error) {
+	tx := s.db.Where("user_id = ?", userID).Delete(&model.Session{})
+	if tx.Error != nil {
+		return 0, fmt.Errorf("delete err: %w", tx.Error)
+	}
+	logger.Infof("%d sessions of user %d deleted.", tx.RowsAffected, userID)
+	return tx.RowsAffected, nil
+}
+
+// hashToken hashes a token or an API key for the lookup. They are random enough for a hash without salt.
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
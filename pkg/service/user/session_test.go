@@ -0,0 +1,114 @@
+package user
+
+import (
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/kuoss/venti/pkg/model"
+)
+
+func newSessionTestService(t *testing.T, cfg model.SessionConfig) (*UserService, model.User) {
+	s, err := New(t.TempDir()+"/venti.sqlite3", model.UserConfig{
+		EtcUsers: []model.EtcUser{{Username: "alice", Hash: "hash1"}},
+	}, cfg)
+	require.NoError(t, err)
+	alice, err := s.FindByUsername("alice")
+	require.NoError(t, err)
+	return s, alice
+}
+
+func getSession(t *testing.T, s *UserService, token string) model.Session {
+	var session model.Session
+	require.NoError(t, s.db.First(&session, "hash = ?", hashToken(token)).Error)
+	return session
+}
+
+func TestSession(t *testing.T) {
+	s, alice := newSessionTestService(t, model.SessionConfig{})
+	aliceID := strconv.Itoa(alice.ID)
+
+	token1, err := s.CreateSession(alice)
+	require.NoError(t, err)
+	token2, err := s.CreateSession(alice)
+	require.NoError(t, err)
+	require.NotEqual(t, token1, token2)
+
+	// only the hash is stored
+	session := getSession(t, s, token1)
+	require.NotContains(t, session.Hash, token1)
+	require.WithinDuration(t, time.Now().Add(48*time.Hour), session.ExpiresAt, time.Minute)
+
+	// the sessions are independent
+	for _, token := range []string{token1, token2} {
+		user, err := s.FindBySession(aliceID, token)
+		require.NoError(t, err)
+		require.Equal(t, "alice", user.Username)
+	}
+	_, err = s.FindBySession("0", token1)
+	require.ErrorIs(t, err, ErrSessionNotFound)
+	_, err = s.FindBySession(aliceID, "INVALID")
+	require.ErrorIs(t, err, ErrSessionNotFound)
+
+	require.NoError(t, s.DeleteSession(aliceID, token1))
+	_, err = s.FindBySession(aliceID, token1)
+	require.ErrorIs(t, err, ErrSessionNotFound)
+	_, err = s.FindBySession(aliceID, token2)
+	require.NoError(t, err)
+	require.ErrorIs(t, s.DeleteSession(aliceID, token1), ErrSessionNotFound)
+
+	// expired
+	require.NoError(t, s.db.Model(&model.Session{}).Where("hash = ?", hashToken(token2)).Update("expires_at", time.Now().Add(-time.Minute)).Error)
+	_, err = s.FindBySession(aliceID, token2)
+	require.ErrorIs(t, err, ErrSessionExpired)
+	// and deleted at the next login
+	_, err = s.CreateSession(alice)
+	require.NoError(t, err)
+	_, err = s.FindBySession(aliceID, token2)
+	require.ErrorIs(t, err, ErrSessionNotFound)
+}
+
+func TestDeleteSessions(t *testing.T) {
+	s, alice := newSessionTestService(t, model.SessionConfig{})
+	token1, err := s.CreateSession(alice)
+	require.NoError(t, err)
+	_, err = s.CreateSession(alice)
+	require.NoError(t, err)
+
+	n, err := s.DeleteSessions(alice.ID)
+	require.NoError(t, err)
+	require.Equal(t, int64(2), n)
+	_, err = s.FindBySession(strconv.Itoa(alice.ID), token1)
+	require.ErrorIs(t, err, ErrSessionNotFound)
+}
+
+func TestSession_expiry(t *testing.T) {
+	testCases := []struct {
+		name   string
+		cfg    model.SessionConfig
+		age    time.Duration // since the login
+		left   time.Duration // until the expiry
+		wantIn time.Duration // from now after a use
+	}{
+		{"absolute", model.SessionConfig{TTL: time.Hour}, 30 * time.Minute, 30 * time.Minute, 30 * time.Minute},
+		{"sliding", model.SessionConfig{TTL: time.Hour, Sliding: true}, 30 * time.Minute, 30 * time.Minute, time.Hour},
+		{"sliding within a minute", model.SessionConfig{TTL: time.Hour, Sliding: true}, 30 * time.Second, 59*time.Minute + 30*time.Second, 59*time.Minute + 30*time.Second},
+		{"sliding up to max lifetime", model.SessionConfig{TTL: time.Hour, Sliding: true, MaxLifetime: 2 * time.Hour}, 90 * time.Minute, 10 * time.Minute, 30 * time.Minute},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			s, alice := newSessionTestService(t, tc.cfg)
+			token, err := s.CreateSession(alice)
+			require.NoError(t, err)
+			now := time.Now()
+			require.NoError(t, s.db.Model(&model.Session{}).Where("hash = ?", hashToken(token)).
+				Updates(model.Session{CreatedAt: now.Add(-tc.age), ExpiresAt: now.Add(tc.left)}).Error)
+
+			_, err = s.FindBySession(strconv.Itoa(alice.ID), token)
+			require.NoError(t, err)
+			require.WithinDuration(t, now.Add(tc.wantIn), getSession(t, s, token).ExpiresAt, time.Second)
+		})
+	}
+}
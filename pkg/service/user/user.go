@@ -1,8 +1,11 @@
 package user
 
 import (
+	"errors"
 	"fmt"
 	"log"
+	"slices"
+	"sync"
 
 	"github.com/kuoss/common/logger"
 	"github.com/kuoss/venti/pkg/model"
@@ -14,10 +17,15 @@ import (
 // const dbfilepath = "./data/venti.sqlite3"
 
 type UserService struct {
-	db *gorm.DB
+	db            *gorm.DB
+	mu            sync.RWMutex
+	adminMu       sync.Mutex // one change of the users at a time, so that two cannot remove the last admins together
+	grants        []model.Grant
+	defaultAccess model.DefaultAccess
+	sessionConfig model.SessionConfig
 }
 
-func New(filepath string, config model.UserConfig) (*UserService, error) {
+func New(filepath string, config model.UserConfig, sessionConfig model.SessionConfig) (*UserService, error) {
 	log.Println("Initializing database...")
 
 	db, err := gorm.Open(sqlite.Open(filepath), &gorm.Config{})
@@ -25,12 +33,41 @@ func New(filepath string, config model.UserConfig) (*UserService, error) {
 		return nil, fmt.Errorf("DB open err: %w", err)
 	}
 
-	err = db.AutoMigrate(model.User{})
+	err = db.AutoMigrate(model.User{}, model.Session{}, model.APIKey{})
 	if err != nil {
 		return nil, fmt.Errorf("auto migration failed: %w", err)
 	}
+	err = dropTokenColumns(db)
+	if err != nil {
+		return nil, fmt.Errorf("dropTokenColumns err: %w", err)
+	}
 	setEtcUsers(db, config)
-	return &UserService{db}, nil
+	return &UserService{db: db, grants: config.Grants, defaultAccess: config.DefaultAccess, sessionConfig: sessionConfig.WithDefaults()}, nil
+}
+
+// dropTokenColumns drops the plain tokens of the users before the sessions, which AutoMigrate leaves.
+// They are logged out, as their tokens are not sessions.
+func dropTokenColumns(db *gorm.DB) error {
+	for _, column := range []string{"token", "token_expires"} {
+		if !db.Migrator().HasColumn(&model.User{}, column) {
+			continue
+		}
+		if err := db.Migrator().DropColumn(&model.User{}, column); err != nil {
+			return fmt.Errorf("drop column %s err: %w", column, err)
+		}
+		logger.Infof("Column '%s' of users dropped.", column)
+	}
+	return nil
+}
+
+// Reload applies the users, the grants, the default access and the lifetime of the sessions of a reloaded configuration.
+func (s *UserService) Reload(config model.UserConfig, sessionConfig model.SessionConfig) {
+	setEtcUsers(s.db, config)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.grants = config.Grants
+	s.defaultAccess = config.DefaultAccess
+	s.sessionConfig = sessionConfig.WithDefaults()
 }
 
 func setEtcUsers(db *gorm.DB, config model.UserConfig) {
@@ -39,12 +76,17 @@ func setEtcUsers(db *gorm.DB, config model.UserConfig) {
 		var user model.User
 		result := db.First(&user, "username = ?", etcUser.Username)
 		if result.RowsAffected == 0 {
-			db.Create(&model.User{Username: etcUser.Username, Hash: etcUser.Hash, IsAdmin: etcUser.IsAdmin})
+			db.Create(&model.User{Username: etcUser.Username, Hash: etcUser.Hash, IsAdmin: etcUser.IsAdmin, Role: etcUser.Role, Groups: etcUser.Groups})
 			logger.Infof("User '%s' added.", etcUser.Username)
+		} else if user.DBManaged {
+			logger.Infof("User '%s' is managed in the database, not by users.yml.", etcUser.Username)
 		} else {
 			logger.Infof("User '%s' already exists.", etcUser.Username)
-			if user.Hash != etcUser.Hash {
+			if user.Hash != etcUser.Hash || user.IsAdmin != etcUser.IsAdmin || user.Role != etcUser.Role || !slices.Equal(user.Groups, etcUser.Groups) {
 				user.Hash = etcUser.Hash
+				user.IsAdmin = etcUser.IsAdmin
+				user.Role = etcUser.Role
+				user.Groups = etcUser.Groups
 				db.Save(&user)
 				logger.Infof("User '%s' updated.", etcUser.Username)
 			}
@@ -52,18 +94,40 @@ func setEtcUsers(db *gorm.DB, config model.UserConfig) {
 	}
 }
 
-func (s *UserService) FindByUsername(name string) (model.User, error) {
-	var user model.User
-	tx := s.db.First(&user, "username = ?", name)
-	return user, tx.Error
+// ProvisionUser creates or updates a user logged in by the provider, with the groups and the role from the provider.
+// A user of another provider, or with a password, is not taken over.
+func (s *UserService) ProvisionUser(username, provider string, groups []string, role model.Role) (model.User, error) {
+	user, err := s.FindByUsername(username)
+	if err != nil {
+		if !errors.Is(err, gorm.ErrRecordNotFound) {
+			return model.User{}, fmt.Errorf("FindByUsername err: %w", err)
+		}
+		user = model.User{Username: username, Provider: provider}
+		logger.Infof("User '%s' added by %s.", username, provider)
+	}
+	if user.Provider != provider {
+		return model.User{}, fmt.Errorf("user %q exists but not by %s", username, provider)
+	}
+	user.Role = role
+	user.Groups = groups
+	err = s.db.Save(&user).Error
+	if err != nil {
+		return model.User{}, fmt.Errorf("save err: %w", err)
+	}
+	return user, nil
 }
 
-func (s *UserService) FindByUserIdAndToken(id, token string) (model.User, error) {
+func (s *UserService) FindByUsername(name string) (model.User, error) {
 	var user model.User
-	tx := s.db.First(&user, "ID = ? AND token = ?", id, token)
+	tx := s.db.First(&user, "username = ?", name)
 	return user, tx.Error
 }
 
 func (s *UserService) Save(user model.User) error {
 	return s.db.Save(&user).Error
 }
+
+// DB returns the database, so that other services can keep their state in it.
+func (s *UserService) DB() *gorm.DB {
+	return s.db
+}
@@ -3,9 +3,12 @@ package user
 import (
 	"os"
 	"testing"
+	"time"
 
 	"github.com/kuoss/venti/pkg/model"
 	"github.com/stretchr/testify/require"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
 )
 
 func init() {
@@ -16,11 +19,38 @@ func init() {
 }
 
 func TestNew(t *testing.T) {
-	userService, err := New("./data/venti.sqlite3", model.UserConfig{})
+	userService, err := New("./data/venti.sqlite3", model.UserConfig{}, model.SessionConfig{})
 	require.NoError(t, err)
 	require.NotEmpty(t, userService)
 }
 
+func TestNew_dropTokenColumns(t *testing.T) {
+	file := t.TempDir() + "/venti.sqlite3"
+	db, err := gorm.Open(sqlite.Open(file), &gorm.Config{})
+	require.NoError(t, err)
+	// the users before the sessions
+	type user struct {
+		ID           int    `gorm:"primaryKey"`
+		Username     string `gorm:"index:,unique"`
+		Hash         string
+		Token        string
+		TokenExpires time.Time
+	}
+	require.NoError(t, db.Table("users").AutoMigrate(&user{}))
+	require.NoError(t, db.Table("users").Create(&user{Username: "admin", Hash: "hash1", Token: "token1"}).Error)
+	sqlDB, err := db.DB()
+	require.NoError(t, err)
+	require.NoError(t, sqlDB.Close())
+
+	s, err := New(file, model.UserConfig{}, model.SessionConfig{})
+	require.NoError(t, err)
+	require.False(t, s.db.Migrator().HasColumn(&model.User{}, "token"))
+	require.False(t, s.db.Migrator().HasColumn(&model.User{}, "token_expires"))
+	got, err := s.FindByUsername("admin")
+	require.NoError(t, err)
+	require.Equal(t, "hash1", got.Hash)
+}
+
 func TestSetEtcUsers(t *testing.T) {
 
 }
@@ -29,10 +59,30 @@ func TestFindByUsername(t *testing.T) {
 
 }
 
-func TestFindByUserIdAndToken(t *testing.T) {
+func TestSave(t *testing.T) {
 
 }
 
-func TestSave(t *testing.T) {
+func TestProvisionUser(t *testing.T) {
+	s, err := New(t.TempDir()+"/venti.sqlite3", model.UserConfig{
+		EtcUsers: []model.EtcUser{{Username: "admin", Hash: "hash1", IsAdmin: true}},
+	}, model.SessionConfig{})
+	require.NoError(t, err)
+
+	user, err := s.ProvisionUser("alice", "oidc", []string{"team-a"}, model.RoleViewer)
+	require.NoError(t, err)
+	require.NotZero(t, user.ID)
+	require.Equal(t, "oidc", user.Provider)
+
+	user2, err := s.ProvisionUser("alice", "oidc", []string{"ops"}, model.RoleEditor)
+	require.NoError(t, err)
+	require.Equal(t, user.ID, user2.ID)
+	require.Equal(t, []string{"ops"}, user2.Groups)
+	require.Equal(t, model.RoleEditor, user2.GetRole())
 
+	_, err = s.ProvisionUser("admin", "oidc", nil, model.RoleViewer)
+	require.EqualError(t, err, `user "admin" exists but not by oidc`)
+	admin, err := s.FindByUsername("admin")
+	require.NoError(t, err)
+	require.Equal(t, model.RoleAdmin, admin.GetRole())
 }
@@ -38,9 +38,87 @@ type RuntimeInfo struct {
 type AlertmanagerDiscovery struct {
 	ActiveAlertmanagers  []*AlertmanagerTarget `json:"activeAlertmanagers"`
 	DroppedAlertmanagers []*AlertmanagerTarget `json:"droppedAlertmanagers"`
+	DroppedAlerts        []*DroppedAlert       `json:"droppedAlerts"`
+}
+
+// DroppedAlert is an alert dropped by alert_relabel_configs. It is Venti's own.
+type DroppedAlert struct {
+	Labels    map[string]string `json:"labels"`
+	DroppedAt time.Time         `json:"droppedAt"`
 }
 
 // AlertmanagerTarget has info on one AM.
+// The fields other than URL are Venti's own, showing the state of the notification queue.
 type AlertmanagerTarget struct {
-	URL string `json:"url"`
+	URL         string `json:"url"`
+	QueueLength int    `json:"queueLength"`
+	Sent        int64  `json:"sent"`
+	Failed      int64  `json:"failed"`
+	Dropped     int64  `json:"dropped"`
+	LastError   string `json:"lastError,omitempty"`
+}
+
+// https://github.com/prometheus/prometheus/blob/v3.5.0/web/api/v1/api.go#L1387
+
+// RuleDiscovery has info for all rules.
+type RuleDiscovery struct {
+	RuleGroups []*RuleGroup `json:"groups"`
+}
+
+// RuleGroup has info for rules which are part of a group.
+type RuleGroup struct {
+	Name string `json:"name"`
+	File string `json:"file"`
+	// In order to preserve rule ordering, while exposing type (alerting or recording)
+	// specific properties, both alerting and recording rules are exposed in the
+	// same array.
+	Rules          []any     `json:"rules"`
+	Interval       float64   `json:"interval"`
+	Limit          int       `json:"limit"`
+	EvaluationTime float64   `json:"evaluationTime"`
+	LastEvaluation time.Time `json:"lastEvaluation"`
+}
+
+type Alert struct {
+	Labels          map[string]string `json:"labels"`
+	Annotations     map[string]string `json:"annotations"`
+	State           string            `json:"state"`
+	ActiveAt        *time.Time        `json:"activeAt,omitempty"`
+	KeepFiringSince *time.Time        `json:"keepFiringSince,omitempty"`
+	Value           string            `json:"value"`
+}
+
+type AlertingRule struct {
+	// State can be "pending", "firing", "inactive".
+	State          string            `json:"state"`
+	Name           string            `json:"name"`
+	Query          string            `json:"query"`
+	Duration       float64           `json:"duration"`
+	KeepFiringFor  float64           `json:"keepFiringFor"`
+	Labels         map[string]string `json:"labels"`
+	Annotations    map[string]string `json:"annotations"`
+	Alerts         []*Alert          `json:"alerts"`
+	Health         string            `json:"health"`
+	LastError      string            `json:"lastError,omitempty"`
+	EvaluationTime float64           `json:"evaluationTime"`
+	LastEvaluation time.Time         `json:"lastEvaluation"`
+	// Type of an alertingRule is always "alerting".
+	Type string `json:"type"`
+}
+
+type RecordingRule struct {
+	Name           string            `json:"name"`
+	Query          string            `json:"query"`
+	Labels         map[string]string `json:"labels,omitempty"`
+	Health         string            `json:"health"`
+	LastError      string            `json:"lastError,omitempty"`
+	EvaluationTime float64           `json:"evaluationTime"`
+	LastEvaluation time.Time         `json:"lastEvaluation"`
+	// Type of a recordingRule is always "recording".
+	Type string `json:"type"`
+}
+
+// AlertDiscovery has info for all active alerts.
+type AlertDiscovery struct {
+	Alerts []*Alert `json:"alerts"`
 }
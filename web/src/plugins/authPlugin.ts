@@ -8,8 +8,34 @@ declare module '@vue/runtime-core' {
   }
 }
 
+// withAuth sends the token of the login with the requests to the server itself, as the API requires it.
+// On 401, the token is gone or expired, so the user logs in again.
+function withAuth(fetch: typeof window.fetch): typeof window.fetch {
+  return async (input, init) => {
+    if (typeof input === 'string' && input.startsWith('/')) {
+      const headers = new Headers(init?.headers);
+      const token = localStorage.getItem('token');
+      const userID = localStorage.getItem('userID');
+      if (token && userID && !headers.has('Authorization')) {
+        headers.set('Authorization', token);
+        headers.set('UserID', userID);
+      }
+      init = { ...init, headers };
+    }
+    const response = await fetch(input, init);
+    if (response.status === 401 && typeof input === 'string' && input.startsWith('/api/')) {
+      localStorage.removeItem('token');
+      localStorage.removeItem('userID');
+      localStorage.removeItem('username');
+      window.location.reload();
+    }
+    return response;
+  };
+}
+
 export default {
   install: (app: App) => {
     app.config.globalProperties.$auth = useAuthStore();
+    window.fetch = withAuth(window.fetch.bind(window));
   },
-};
\ No newline at end of file
+};
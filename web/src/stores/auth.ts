@@ -25,19 +25,8 @@ export const useAuthStore = defineStore('auth', {
 
       try {
         const response = await axios.post('/auth/login', formData);
-        const data = response.data;
-        if (data) {
-          useErrorStore().clear();
-          const token = `Bearer ${data.token}`;
-          localStorage.setItem('token', token);
-          localStorage.setItem('userID', data.userID);
-          localStorage.setItem('username', data.username);
-          axios.defaults.headers.common['Authorization'] = token;
-          axios.defaults.headers.common['UserID'] = data.userID;
-          axios.defaults.headers.common['Username'] = data.username;
-          this.userID = data.userID;
-          this.username = data.username;
-          this.loggedIn = true;
+        if (response.data) {
+          this.setSession(response.data);
         }
       } catch (error) {
         const err = error as ResponseError;
@@ -45,6 +34,31 @@ export const useAuthStore = defineStore('auth', {
         useErrorStore().set(err.response.data);
       }
     },
+    // loginWithFragment finishes a single sign-on, whose callback redirects to /login#token=...&userID=...&username=...
+    loginWithFragment(hash: string) {
+      const params = new URLSearchParams(hash.replace(/^#/, ''));
+      const error = params.get('error');
+      if (error) {
+        useErrorStore().set({ status: 'error', errorType: 'unauthorized', error: error });
+        return;
+      }
+      if (params.get('token')) {
+        this.setSession({ token: params.get('token'), userID: params.get('userID'), username: params.get('username') });
+      }
+    },
+    setSession(data: any) {
+      useErrorStore().clear();
+      const token = `Bearer ${data.token}`;
+      localStorage.setItem('token', token);
+      localStorage.setItem('userID', data.userID);
+      localStorage.setItem('username', data.username);
+      axios.defaults.headers.common['Authorization'] = token;
+      axios.defaults.headers.common['UserID'] = data.userID;
+      axios.defaults.headers.common['Username'] = data.username;
+      this.userID = data.userID;
+      this.username = data.username;
+      this.loggedIn = true;
+    },
     async logout() {
       try {
         const response = await fetch('/auth/logout', { method: 'post' });